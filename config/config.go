@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"github.com/ilyakaznacheev/cleanenv"
 	"path"
+	"time"
 )
 
 type Config struct {
@@ -27,6 +28,17 @@ type Config struct {
 		Database    string `yaml:"database" env:"POSTGRES_DATABASE"`
 		MaxPoolSize int    `yaml:"max_pool_size" env:"POSTGRES_MAX_POOL_SIZE"`
 	} `yaml:"postgresql"`
+	Worker struct {
+		ExpiryInterval  time.Duration `yaml:"expiry_interval" env:"WORKER_EXPIRY_INTERVAL" env-default:"1m"`
+		ExpiryBatchSize int           `yaml:"expiry_batch_size" env:"WORKER_EXPIRY_BATCH_SIZE" env-default:"1000"`
+		PurgeInterval   time.Duration `yaml:"purge_interval" env:"WORKER_PURGE_INTERVAL" env-default:"1h"`
+		PurgeBatchSize  int           `yaml:"purge_batch_size" env:"WORKER_PURGE_BATCH_SIZE" env-default:"100"`
+	} `yaml:"worker"`
+	Segment struct {
+		RenameGracePeriod    time.Duration `yaml:"rename_grace_period" env:"SEGMENT_RENAME_GRACE_PERIOD" env-default:"720h"`
+		DeletedRetentionDays int           `yaml:"deleted_retention_days" env:"SEGMENT_DELETED_RETENTION_DAYS" env-default:"0"`
+		ArchiveFormat        string        `yaml:"archive_format" env:"SEGMENT_ARCHIVE_FORMAT" env-default:"csv"`
+	} `yaml:"segment"`
 	WebAPI struct {
 		GDriveJSONFilePath string `yaml:"google_drive_json_file_path" env:"GOOGLE_DRIVE_JSON_FILE_PATH"`
 	} `yaml:"webapi"`
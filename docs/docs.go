@@ -19,64 +19,41 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/api/v1/reports": {
-            "get": {
-                "description": "Возвращает csv-строку, представляющую собой csv-отчёт,\nсодержащий столбцы ` + "`" + `user_id` + "`" + `, ` + "`" + `segment_name` + "`" + `, ` + "`" + `start_date` + "`" + `,\n` + "`" + `end_date` + "`" + `, обозначающие идентификатор пользователя,\nнаименование сегмента, дату добавления пользователя в сегмент и\nдату выхода пользователя из сегмента соответственно. Строки отчёта\nотсортированы в порядке возрастания по дате добавления пользователя в сегмент.",
-                "tags": [
-                    "reports"
+        "/api/v1/experiments": {
+            "post": {
+                "description": "Создаёт A/B эксперимент с указанными вариантами. Для каждого варианта создаётся сегмент\nс именем ` + "`" + `\u003cимя эксперимента\u003e_\u003cимя варианта\u003e` + "`" + `; удалённый сегмент с таким именем восстанавливается,\nкак при создании сегмента, если он не входит в группу взаимоисключающих сегментов. Каждый существующий и каждый созданный позднее\nпользователь детерминированно распределяется ровно в один вариант: доля пользователей варианта\nравна его весу, делённому на сумму весов всех вариантов.",
+                "consumes": [
+                    "application/json"
                 ],
-                "summary": "Получить отчёт в формате csv",
-                "responses": {
-                    "200": {
-                        "description": "Структура, содержащая дату формирования отчёта и отчёт в виде csv-строки",
-                        "schema": {
-                            "$ref": "#/definitions/internal_controller_http_v1.MakeReportResponse"
-                        }
-                    },
-                    "400": {
-                        "description": "Ошибка валидации данных запроса",
-                        "schema": {
-                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrReportValidationError"
-                        }
-                    },
-                    "500": {
-                        "description": "Внутренняя ошибка сервера",
-                        "schema": {
-                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrInternalServerError"
-                        }
-                    }
-                }
-            }
-        },
-        "/api/v1/segments": {
-            "get": {
-                "description": "Возвращает список всех сегментов",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "segments"
+                    "experiments"
                 ],
-                "summary": "Получить список всех сегментов",
+                "summary": "Создать эксперимент",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "Параметр, определяющий, сегменты какого типа (живые и(или) удалённые) необходимо вернуть. Значение ` + "`" + `both` + "`" + ` предполагает, что будут возвращены сегменты обоих типов (то есть абсолютно все сегменты, когда-либо созданные в системе). Значение ` + "`" + `alive` + "`" + ` предполагает, что будут возвращены только живые (то есть не помеченные как удалённые) сегменты. Значение ` + "`" + `deleted` + "`" + ` предполагает, что будут возвращены только сегменты, помеченные как удалённые. Отсутствие параметра равносильно параметру со значением ` + "`" + `both` + "`" + `.",
-                        "name": "segment_type",
-                        "in": "query"
+                        "description": "Структура с информацией о создаваемом эксперименте",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/avito-rest-api_internal_service.ExperimentCreateInput"
+                        }
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "Список всех сегментов",
+                    "201": {
+                        "description": "Созданный эксперимент",
                         "schema": {
-                            "$ref": "#/definitions/internal_controller_http_v1.GetAllSegmentsResponse"
+                            "$ref": "#/definitions/internal_controller_http_v1.ExperimentResponse"
                         }
                     },
                     "400": {
-                        "description": "Ошибка валидации данных запроса",
+                        "description": "Сегмент для одного из вариантов уже существует",
                         "schema": {
-                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrSegmentValidationError"
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrSegmentAlreadyExists"
                         }
                     },
                     "500": {
@@ -86,41 +63,38 @@ const docTemplate = `{
                         }
                     }
                 }
-            },
-            "post": {
-                "description": "Создаёт сегмент на основе информации в теле запроса",
-                "consumes": [
-                    "application/json"
-                ],
+            }
+        },
+        "/api/v1/experiments/{name}": {
+            "get": {
+                "description": "Возвращает эксперимент с указанным именем вместе с его вариантами",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "segments"
+                    "experiments"
                 ],
-                "summary": "Создать сегмент",
+                "summary": "Получить эксперимент",
                 "parameters": [
                     {
-                        "description": "Структура с информацией о создаваемом сегменте",
-                        "name": "data",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/avito-rest-api_internal_service.SegmentCreateInput"
-                        }
+                        "type": "string",
+                        "description": "Наименование эксперимента",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "Наименование созданного сегмента",
+                    "200": {
+                        "description": "Эксперимент",
                         "schema": {
-                            "$ref": "#/definitions/internal_controller_http_v1.CreateResponse"
+                            "$ref": "#/definitions/internal_controller_http_v1.ExperimentResponse"
                         }
                     },
-                    "400": {
-                        "description": "Ошибка валидации данных запроса",
+                    "404": {
+                        "description": "Эксперимент с указанным именем не был найден",
                         "schema": {
-                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrSegmentValidationError"
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrExperimentNotFound"
                         }
                     },
                     "500": {
@@ -132,20 +106,20 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/segments/{name}": {
+        "/api/v1/experiments/{name}/report": {
             "get": {
-                "description": "Возвращает информацию о сегменте с указанным именем",
+                "description": "Возвращает количество пользователей, входящих на текущий момент в каждый из вариантов эксперимента",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "segments"
+                    "experiments"
                 ],
-                "summary": "Получить сегмент с указанным именем",
+                "summary": "Получить отчёт по эксперименту",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Наименование сегмента",
+                        "description": "Наименование эксперимента",
                         "name": "name",
                         "in": "path",
                         "required": true
@@ -153,21 +127,15 @@ const docTemplate = `{
                 ],
                 "responses": {
                     "200": {
-                        "description": "Сегмент с указанным именем",
-                        "schema": {
-                            "$ref": "#/definitions/internal_controller_http_v1.GetSegmentByNameResponse"
-                        }
-                    },
-                    "400": {
-                        "description": "Ошибка валидации данных запроса",
+                        "description": "Размеры вариантов эксперимента",
                         "schema": {
-                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrSegmentValidationError"
+                            "$ref": "#/definitions/internal_controller_http_v1.ExperimentReportResponse"
                         }
                     },
                     "404": {
-                        "description": "Сегмент с указанным именем не был найден",
+                        "description": "Эксперимент с указанным именем не был найден",
                         "schema": {
-                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrSegmentNotFound"
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrExperimentNotFound"
                         }
                     },
                     "500": {
@@ -177,45 +145,51 @@ const docTemplate = `{
                         }
                     }
                 }
-            },
-            "delete": {
-                "description": "Удаляет сегмент с указанным именем из системы.\nВ случае, если на момент совершения запроса в этот\nсегмент входят какие-либо пользователи, они автоматически\nвыйдут из данного сегмента.",
-                "consumes": [
-                    "application/json"
-                ],
+            }
+        },
+        "/api/v1/experiments/{name}/users/{id}": {
+            "get": {
+                "description": "Возвращает вариант эксперимента, в который распределён пользователь с указанным ID",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "segments"
+                    "experiments"
                 ],
-                "summary": "Удалить сегмент с указанным именем",
+                "summary": "Получить вариант пользователя",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Наименование сегмента",
+                        "description": "Наименование эксперимента",
                         "name": "name",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "ID пользователя",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Сообщение об успехе",
+                        "description": "Вариант пользователя",
                         "schema": {
-                            "$ref": "#/definitions/internal_controller_http_v1.DeleteSegmentByNameResponse"
+                            "$ref": "#/definitions/internal_controller_http_v1.UserVariantResponse"
                         }
                     },
                     "400": {
-                        "description": "Ошибка валидации данных запроса",
+                        "description": "Пользователь удалён",
                         "schema": {
-                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrSegmentValidationError"
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrUserDeleted"
                         }
                     },
                     "404": {
-                        "description": "Сегмент с указанным именем не был найден",
+                        "description": "Пользователь не распределён ни в один вариант эксперимента",
                         "schema": {
-                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrSegmentNotFound"
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrExperimentVariantNotFound"
                         }
                     },
                     "500": {
@@ -227,27 +201,44 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/users": {
+        "/api/v1/reports": {
             "get": {
-                "description": "Возвращает список абсолютно всех пользователей",
-                "produces": [
-                    "application/json"
-                ],
+                "description": "Возвращает отчёт,\nсодержащий столбцы ` + "`" + `user_id` + "`" + `, ` + "`" + `segment_name` + "`" + `, ` + "`" + `start_date` + "`" + `,\n` + "`" + `end_date` + "`" + `, обозначающие идентификатор пользователя,\nнаименование сегмента, дату добавления пользователя в сегмент и\nдату выхода пользователя из сегмента соответственно. Строки отчёта\nотсортированы в порядке возрастания по дате добавления пользователя в сегмент.\nПараметры ` + "`" + `from` + "`" + `, ` + "`" + `to` + "`" + ` и ` + "`" + `month` + "`" + ` позволяют ограничить отчёт записями,\nпериод действия которых пересекается с указанным периодом.",
                 "tags": [
-                    "users"
+                    "reports"
+                ],
+                "summary": "Получить отчёт в формате csv",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Начало периода (включительно) в формате ` + "`" + `15:04:05 02.01.2006` + "`" + ` или ` + "`" + `02.01.2006` + "`" + `",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Конец периода (включительно) в формате ` + "`" + `15:04:05 02.01.2006` + "`" + ` или ` + "`" + `02.01.2006` + "`" + `",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Месяц в формате ` + "`" + `2006-01` + "`" + `, сокращение для периода с первого по последний день месяца. Не может использоваться вместе с ` + "`" + `from` + "`" + ` и ` + "`" + `to` + "`" + `",
+                        "name": "month",
+                        "in": "query"
+                    }
                 ],
-                "summary": "Получить список всех пользователей",
                 "responses": {
                     "200": {
-                        "description": "Список всех пользователей",
+                        "description": "Структура, содержащая дату формирования отчёта и ссылку на файл с отчётом или отчёт в виде csv-строки",
                         "schema": {
-                            "$ref": "#/definitions/internal_controller_http_v1.GetAllUsersResponse"
+                            "$ref": "#/definitions/internal_controller_http_v1.MakeReportResponse"
                         }
                     },
                     "400": {
                         "description": "Ошибка валидации данных запроса",
                         "schema": {
-                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrUserValidationError"
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrReportValidationError"
                         }
                     },
                     "500": {
@@ -257,9 +248,11 @@ const docTemplate = `{
                         }
                     }
                 }
-            },
+            }
+        },
+        "/api/v1/segment-groups": {
             "post": {
-                "description": "Создаёт пользователя на основе информации в теле запроса",
+                "description": "Создаёт группу взаимоисключающих сегментов: пользователь может одновременно входить не более\nчем в один сегмент группы. Сегменты должны существовать и не входить в другие группы, а ни один\nпользователь не должен входить одновременно в несколько из них.",
                 "consumes": [
                     "application/json"
                 ],
@@ -267,31 +260,43 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "users"
+                    "segment-groups"
                 ],
-                "summary": "Создать пользователя",
+                "summary": "Создать группу сегментов",
                 "parameters": [
                     {
-                        "description": "Структура с информацией о создаваемом пользователе",
+                        "description": "Структура с информацией о создаваемой группе",
                         "name": "data",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/avito-rest-api_internal_service.UserCreateInput"
+                            "$ref": "#/definitions/avito-rest-api_internal_service.SegmentGroupCreateInput"
                         }
                     }
                 ],
                 "responses": {
                     "201": {
-                        "description": "Идентификатор созданного пользователя",
+                        "description": "Созданная группа сегментов",
                         "schema": {
-                            "$ref": "#/definitions/internal_controller_http_v1.UserCreateResponse"
+                            "$ref": "#/definitions/internal_controller_http_v1.SegmentGroupResponse"
                         }
                     },
                     "400": {
-                        "description": "Ошибка валидации данных запроса",
+                        "description": "Группа с указанным именем уже существует",
                         "schema": {
-                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrUserValidationError"
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrSegmentGroupAlreadyExists"
+                        }
+                    },
+                    "404": {
+                        "description": "Некоторые из указанных сегментов не существуют",
+                        "schema": {
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrSegmentNotFound"
+                        }
+                    },
+                    "409": {
+                        "description": "Некоторые пользователи входят в несколько сегментов группы",
+                        "schema": {
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrSegmentGroupConflict"
                         }
                     },
                     "500": {
@@ -303,41 +308,36 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/users/addUserToSegments": {
-            "post": {
-                "description": "Добавляет пользователя с указанным ID в указанные сегменты",
+        "/api/v1/segment-groups/{name}": {
+            "get": {
+                "description": "Возвращает группу сегментов с указанным именем вместе с наименованиями её сегментов",
+                "produces": [
+                    "application/json"
+                ],
                 "tags": [
-                    "users"
+                    "segment-groups"
                 ],
-                "summary": "Добавить пользователя в сегменты",
+                "summary": "Получить группу сегментов",
                 "parameters": [
                     {
-                        "description": "Структура, содержащая ID пользователя и наименование сегментов, в которые необходимо добавить пользователя. Поле ` + "`" + `end_date` + "`" + ` у сегмента является опциональным, и, если не  установлено, сигнализирует о том, что время выхода пользователя из сегмента не определено (пока сегмент  не будет удалён или пользователь не будет удалён из этого сегмента)",
-                        "name": "data",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/internal_controller_http_v1.AddUserToSegmentsInput"
-                        }
+                        "type": "string",
+                        "description": "Наименование группы",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Сообщение об успехе",
+                        "description": "Группа сегментов",
                         "schema": {
-                            "$ref": "#/definitions/internal_controller_http_v1.AddUserToSegmentsResponse"
-                        }
-                    },
-                    "400": {
-                        "description": "Ошибка валидации данных запроса",
-                        "schema": {
-                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrUserValidationError"
+                            "$ref": "#/definitions/internal_controller_http_v1.SegmentGroupResponse"
                         }
                     },
                     "404": {
-                        "description": "Пользователь с указанным ID не был найден или некоторые из указанных сегментов не существуют",
+                        "description": "Группа с указанным именем не была найдена",
                         "schema": {
-                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrUserNotFound"
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrSegmentGroupNotFound"
                         }
                     },
                     "500": {
@@ -349,41 +349,60 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/users/deleteUserFromSegments": {
-            "post": {
-                "description": "Удаляет пользователя с указанным ID из указанных сегментов",
+        "/api/v1/segment-groups/{name}/segments": {
+            "put": {
+                "description": "Заменяет список сегментов группы с указанным именем. Сегменты, отсутствующие в новом списке,\nисключаются из группы.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
                 "tags": [
-                    "users"
+                    "segment-groups"
                 ],
-                "summary": "Удалить пользователя из сегментов",
+                "summary": "Изменить сегменты группы",
                 "parameters": [
                     {
-                        "description": "Структура, содержащая ID пользователя и наименования сегментов, из которых пользователя необходимо удалить",
+                        "type": "string",
+                        "description": "Наименование группы",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Структура с новым списком сегментов группы",
                         "name": "data",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/internal_controller_http_v1.DeleteUserFromSegmentsInput"
+                            "$ref": "#/definitions/internal_controller_http_v1.UpdateSegmentGroupSegmentsInput"
                         }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Сообщение об успехе",
+                        "description": "Группа сегментов с обновлённым списком сегментов",
                         "schema": {
-                            "$ref": "#/definitions/internal_controller_http_v1.DeleteUserFromSegmentsResponse"
+                            "$ref": "#/definitions/internal_controller_http_v1.SegmentGroupResponse"
                         }
                     },
                     "400": {
-                        "description": "Ошибка валидации данных запроса, может возникать, если пользователь не входит в указанные сегменты",
+                        "description": "Ошибка валидации данных запроса",
                         "schema": {
-                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrUserValidationError"
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrSegmentGroupValidationError"
                         }
                     },
                     "404": {
-                        "description": "Пользователь с указанным ID не был найден или некоторые из указанных сегментов не существуют",
+                        "description": "Группа с указанным именем не была найдена",
                         "schema": {
-                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrUserNotFound"
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrSegmentGroupNotFound"
+                        }
+                    },
+                    "409": {
+                        "description": "Некоторые пользователи входят в несколько сегментов группы",
+                        "schema": {
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrSegmentGroupConflict"
                         }
                     },
                     "500": {
@@ -395,27 +414,47 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/users/withSegments": {
+        "/api/v1/segments": {
             "get": {
-                "description": "Возвращает список всех пользователей, включая список активных для каждого пользователя сегментов на момент совершения запроса",
+                "description": "Возвращает список всех сегментов",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "users"
+                    "segments"
+                ],
+                "summary": "Получить список всех сегментов",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Параметр, определяющий, сегменты какого типа (живые и(или) удалённые) необходимо вернуть. Значение ` + "`" + `both` + "`" + ` предполагает, что будут возвращены сегменты обоих типов (то есть абсолютно все сегменты, когда-либо созданные в системе). Значение ` + "`" + `alive` + "`" + ` предполагает, что будут возвращены только живые (то есть не помеченные как удалённые) сегменты. Значение ` + "`" + `deleted` + "`" + ` предполагает, что будут возвращены только сегменты, помеченные как удалённые. Отсутствие параметра равносильно параметру со значением ` + "`" + `both` + "`" + `.",
+                        "name": "segment_type",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Тег, который должен быть у сегмента",
+                        "name": "tag",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Команда, владеющая сегментом",
+                        "name": "owner",
+                        "in": "query"
+                    }
                 ],
-                "summary": "Получить список всех пользователей, включая их сегменты",
                 "responses": {
                     "200": {
-                        "description": "Список пользователей с их активными сегментами",
+                        "description": "Список всех сегментов",
                         "schema": {
-                            "$ref": "#/definitions/internal_controller_http_v1.GetAllUsersWithSegmentsResponse"
+                            "$ref": "#/definitions/internal_controller_http_v1.GetAllSegmentsResponse"
                         }
                     },
                     "400": {
                         "description": "Ошибка валидации данных запроса",
                         "schema": {
-                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrUserValidationError"
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrSegmentValidationError"
                         }
                     },
                     "500": {
@@ -425,44 +464,105 @@ const docTemplate = `{
                         }
                     }
                 }
-            }
-        },
-        "/api/v1/users/{id}": {
-            "get": {
-                "description": "Возвращает пользователя с указанным ID",
-                "produces": [
+            },
+            "post": {
+                "description": "Создаёт сегмент на основе информации в теле запроса. Если ` + "`" + `dry_run` + "`" + ` равен true, сегмент не создаётся,\nа в ответе с кодом 200 возвращаются пользователи, которые вошли бы в него по правилам и проценту раскатки,\nи соль раскатки: при создании с этой солью в поле ` + "`" + `salt` + "`" + ` состав сегмента совпадёт с предварительным",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "users"
+                    "segments"
                 ],
-                "summary": "Получить пользователя по ID",
+                "summary": "Создать сегмент",
                 "parameters": [
                     {
-                        "type": "integer",
-                        "description": "ID пользователя",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
+                        "type": "boolean",
+                        "description": "Пробный запуск без сохранения изменений",
+                        "name": "dry_run",
+                        "in": "query"
+                    },
+                    {
+                        "description": "Структура с информацией о создаваемом сегменте",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/avito-rest-api_internal_service.SegmentCreateInput"
+                        }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Пользователь с указанным ID",
+                        "description": "Результат пробного запуска",
                         "schema": {
-                            "$ref": "#/definitions/internal_controller_http_v1.GetUserByIDResponse"
+                            "$ref": "#/definitions/internal_controller_http_v1.PreviewSegmentResponse"
+                        }
+                    },
+                    "201": {
+                        "description": "Наименование созданного сегмента",
+                        "schema": {
+                            "$ref": "#/definitions/internal_controller_http_v1.CreateResponse"
                         }
                     },
                     "400": {
                         "description": "Ошибка валидации данных запроса",
                         "schema": {
-                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrUserValidationError"
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrSegmentValidationError"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrInternalServerError"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/segments/derived": {
+            "post": {
+                "description": "Создаёт сегмент из пользователей, входящих на текущий момент в исходные сегменты, применяя\nобъединение (` + "`" + `union` + "`" + `), пересечение (` + "`" + `intersection` + "`" + `) или разность первого сегмента и остальных\n(` + "`" + `difference` + "`" + `). По умолчанию пользователи однократно добавляются в новый обычный сегмент.\nЕсли ` + "`" + `live` + "`" + ` равен true, сегмент остаётся вычисляемым: его состав определяется при каждом запросе\nсегментов пользователя, а добавлять в него пользователей вручную нельзя",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "segments"
+                ],
+                "summary": "Создать сегмент операцией над сегментами",
+                "parameters": [
+                    {
+                        "description": "Структура с информацией о создаваемом сегменте",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/avito-rest-api_internal_service.SegmentDeriveInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Наименование созданного сегмента и количество пользователей в нём",
+                        "schema": {
+                            "$ref": "#/definitions/internal_controller_http_v1.CreateDerivedSegmentResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Ошибка валидации данных запроса, исходный сегмент удалён или имя занято",
+                        "schema": {
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrSegmentValidationError"
                         }
                     },
                     "404": {
-                        "description": "Пользователь с указанным ID не был найден",
+                        "description": "Исходный сегмент не был найден",
                         "schema": {
-                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrUserNotFound"
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrSegmentNotFound"
                         }
                     },
                     "500": {
@@ -474,42 +574,42 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/users/{id}/withSegments": {
+        "/api/v1/segments/{name}": {
             "get": {
-                "description": "Возвращает пользователя с указанным ID, включая в тело ответа список сегментов, в которые пользователь входит на момент совершения запроса",
+                "description": "Возвращает информацию о сегменте с указанным именем",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "users"
+                    "segments"
                 ],
-                "summary": "Получить пользователя с его сегментами по ID",
+                "summary": "Получить сегмент с указанным именем",
                 "parameters": [
                     {
-                        "type": "integer",
-                        "description": "ID пользователя",
-                        "name": "id",
+                        "type": "string",
+                        "description": "Наименование сегмента",
+                        "name": "name",
                         "in": "path",
                         "required": true
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Пользователь с его активными сегментами",
+                        "description": "Сегмент с указанным именем",
                         "schema": {
-                            "$ref": "#/definitions/internal_controller_http_v1.GetUserByIDWithSegmentsResponse"
+                            "$ref": "#/definitions/internal_controller_http_v1.GetSegmentByNameResponse"
                         }
                     },
                     "400": {
                         "description": "Ошибка валидации данных запроса",
                         "schema": {
-                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrUserValidationError"
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrSegmentValidationError"
                         }
                     },
                     "404": {
-                        "description": "Пользователь с указанным ID не был найден",
+                        "description": "Сегмент с указанным именем не был найден",
                         "schema": {
-                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrUserNotFound"
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrSegmentNotFound"
                         }
                     },
                     "500": {
@@ -519,411 +619,3074 @@ const docTemplate = `{
                         }
                     }
                 }
-            }
-        }
-    },
-    "definitions": {
-        "avito-rest-api_internal_entity.Segment": {
-            "type": "object",
-            "properties": {
-                "is_deleted": {
-                    "type": "boolean",
-                    "example": false
-                },
-                "name": {
-                    "type": "string",
-                    "example": "AVITO_MUSIC_SERVICE"
-                },
-                "segment_id": {
-                    "type": "integer",
-                    "example": 43
+            },
+            "delete": {
+                "description": "Удаляет сегмент с указанным именем из системы.\nВ случае, если на момент совершения запроса в этот\nсегмент входят какие-либо пользователи, они автоматически\nвыйдут из данного сегмента.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "segments"
+                ],
+                "summary": "Удалить сегмент с указанным именем",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Наименование сегмента",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Сообщение об успехе",
+                        "schema": {
+                            "$ref": "#/definitions/internal_controller_http_v1.DeleteSegmentByNameResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Ошибка валидации данных запроса",
+                        "schema": {
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrSegmentValidationError"
+                        }
+                    },
+                    "404": {
+                        "description": "Сегмент с указанным именем не был найден",
+                        "schema": {
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrSegmentNotFound"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrInternalServerError"
+                        }
+                    }
                 }
-            }
-        },
-        "avito-rest-api_internal_entity.User": {
-            "type": "object",
-            "properties": {
-                "age": {
-                    "type": "integer",
-                    "example": 26
-                },
-                "is_deleted": {
-                    "type": "boolean",
-                    "example": false
-                },
-                "lastname": {
-                    "type": "string",
-                    "example": "Иванов"
-                },
-                "name": {
-                    "type": "string",
-                    "example": "Михаил"
-                },
-                "sex": {
-                    "description": "Пол, 0 - мужской, 1 - женский",
-                    "type": "integer",
-                    "enum": [
-                        0,
-                        1
-                    ],
-                    "example": 0
-                },
-                "sex_text": {
-                    "type": "string",
-                    "enum": [
-                        "мужской",
-                        "женский"
-                    ],
-                    "example": "мужской"
-                },
-                "user_id": {
-                    "type": "integer",
-                    "example": 16
+            },
+            "patch": {
+                "description": "Изменяет описание, команду-владельца, теги, срок вхождения пользователей по умолчанию и дату\nавтоматического удаления сегмента с указанным именем. Не указанные в запросе поля не изменяются,\nпустые значения удаляют соответствующее поле",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "segments"
+                ],
+                "summary": "Изменить сегмент",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Наименование сегмента",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Структура с изменяемыми полями сегмента",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/avito-rest-api_internal_service.SegmentUpdateInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Изменённый сегмент",
+                        "schema": {
+                            "$ref": "#/definitions/internal_controller_http_v1.UpdateSegmentResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Ошибка валидации данных запроса или сегмент удалён",
+                        "schema": {
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrSegmentValidationError"
+                        }
+                    },
+                    "404": {
+                        "description": "Сегмент с указанным именем не был найден",
+                        "schema": {
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrSegmentNotFound"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrInternalServerError"
+                        }
+                    }
                 }
             }
         },
-        "avito-rest-api_internal_entity.UserSegmentInformation": {
+        "/api/v1/segments/{name}/name": {
+            "put": {
+                "description": "Переименовывает сегмент с указанным именем, сохраняя его идентификатор и историю вхождения пользователей.\nПрежнее имя записывается в историю имён сегмента, и в течение настраиваемого периода сегмент можно\nнайти по нему, если имя не занято новым сегментом. Новое имя не должно быть занято другим сегментом,\nв том числе удалённым",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "segments"
+                ],
+                "summary": "Переименовать сегмент",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Наименование сегмента",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Структура с новым именем сегмента",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/internal_controller_http_v1.RenameSegmentInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Переименованный сегмент и история его имён",
+                        "schema": {
+                            "$ref": "#/definitions/internal_controller_http_v1.RenameSegmentResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Ошибка валидации данных запроса, сегмент удалён или новое имя занято",
+                        "schema": {
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrSegmentValidationError"
+                        }
+                    },
+                    "404": {
+                        "description": "Сегмент с указанным именем не был найден",
+                        "schema": {
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrSegmentNotFound"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrInternalServerError"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/segments/{name}/percentage": {
+            "put": {
+                "description": "Изменяет процент пользователей, автоматически попадающих в сегмент с указанным именем.\nПопадание пользователя в сегмент определяется стабильным хешем от соли сегмента и ID пользователя,\nпоэтому при увеличении процента пользователи, уже попавшие в сегмент, остаются в нём, а при\nуменьшении - выходят только пользователи вне нового процента. Значение null отключает раскатку,\nне изменяя состав сегмента.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "segments"
+                ],
+                "summary": "Изменить процент раскатки сегмента",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Наименование сегмента",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Структура с новым процентом раскатки сегмента",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/internal_controller_http_v1.UpdateSegmentPercentageInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Сегмент с обновлённым процентом раскатки",
+                        "schema": {
+                            "$ref": "#/definitions/internal_controller_http_v1.UpdateSegmentPercentageResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Ошибка валидации данных запроса",
+                        "schema": {
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrSegmentValidationError"
+                        }
+                    },
+                    "404": {
+                        "description": "Сегмент с указанным именем не был найден",
+                        "schema": {
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrSegmentNotFound"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrInternalServerError"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/segments/{name}/purge": {
+            "post": {
+                "description": "Архивирует историю вхождения пользователей в удалённый сегмент с указанным именем\nи окончательно удаляет сегмент вместе с этой историей. Архив загружается в Google Drive,\nв ответе возвращается ссылка на него; если Google Drive не настроен, в ответе возвращается\nсодержимое архива. Сегмент должен быть предварительно удалён и не должен быть вариантом эксперимента.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "segments"
+                ],
+                "summary": "Окончательно удалить сегмент с архивацией",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Наименование сегмента",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "enum": [
+                            "csv",
+                            "ndjson"
+                        ],
+                        "type": "string",
+                        "description": "Формат архива, по умолчанию csv",
+                        "name": "format",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Результат архивации",
+                        "schema": {
+                            "$ref": "#/definitions/internal_controller_http_v1.PurgeSegmentResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Ошибка валидации данных запроса",
+                        "schema": {
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrSegmentValidationError"
+                        }
+                    },
+                    "404": {
+                        "description": "Сегмент с указанным именем не был найден",
+                        "schema": {
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrSegmentNotFound"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrInternalServerError"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/segments/{name}/rules": {
+            "put": {
+                "description": "Заменяет правила автоматического вхождения пользователей в сегмент с указанным именем.\nПользователи, не удовлетворяющие новым правилам, выходят из сегмента, а удовлетворяющие\nим пользователи, не входящие в сегмент, добавляются в него. Пустой список правил удаляет\nправила сегмента, не изменяя его состав.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "segments"
+                ],
+                "summary": "Изменить правила сегмента",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Наименование сегмента",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Структура с новыми правилами сегмента",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/internal_controller_http_v1.UpdateSegmentRulesInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Сегмент с обновлёнными правилами",
+                        "schema": {
+                            "$ref": "#/definitions/internal_controller_http_v1.UpdateSegmentRulesResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Ошибка валидации данных запроса",
+                        "schema": {
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrSegmentValidationError"
+                        }
+                    },
+                    "404": {
+                        "description": "Сегмент с указанным именем не был найден",
+                        "schema": {
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrSegmentNotFound"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrInternalServerError"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/segments/{name}/users": {
+            "get": {
+                "description": "Возвращает страницу записей о вхождении пользователей в сегмент с указанным именем вместе с информацией\nо пользователях. Статус записей определяется относительно момента времени ` + "`" + `at` + "`" + ` (по умолчанию - момент\nсовершения запроса). Для получения следующей страницы передайте ` + "`" + `next_cursor` + "`" + ` из ответа в параметр ` + "`" + `cursor` + "`" + `",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "segments"
+                ],
+                "summary": "Получить пользователей сегмента",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Наименование сегмента",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Момент времени в формате ` + "`" + `15:04:05 02.01.2006` + "`" + `",
+                        "name": "at",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "active",
+                            "expired",
+                            "all"
+                        ],
+                        "type": "string",
+                        "description": "Статус записей: active - действующие (по умолчанию), expired - завершённые, all - все",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            0,
+                            1
+                        ],
+                        "type": "integer",
+                        "description": "Пол пользователей, 0 - мужской, 1 - женский",
+                        "name": "sex",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Минимальный возраст пользователей, включительно",
+                        "name": "min_age",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Максимальный возраст пользователей, включительно",
+                        "name": "max_age",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Размер страницы, от 1 до 1000, по умолчанию 100",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Курсор страницы",
+                        "name": "cursor",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Страница пользователей сегмента",
+                        "schema": {
+                            "$ref": "#/definitions/internal_controller_http_v1.GetSegmentUsersResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Ошибка валидации данных запроса",
+                        "schema": {
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrSegmentValidationError"
+                        }
+                    },
+                    "404": {
+                        "description": "Сегмент с указанным именем не был найден",
+                        "schema": {
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrSegmentNotFound"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrInternalServerError"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Добавляет в сегмент с указанным именем список пользователей, переданный в виде JSON или CSV\n(` + "`" + `Content-Type: text/csv` + "`" + `, по одному ID пользователя в строке, заголовок ` + "`" + `user_id` + "`" + ` необязателен).\nДля CSV дата выхода из сегмента передаётся в параметре ` + "`" + `end_date` + "`" + `. Все пользователи должны существовать\nи не быть удалёнными, пользователи, уже входящие в сегмент, пропускаются и перечисляются в ответе.\nОперация выполняется в одной транзакции. Если ` + "`" + `dry_run` + "`" + ` равен true, изменения не сохраняются,\nа в ответе перечисляются пользователи, которые были бы добавлены",
+                "consumes": [
+                    "application/json",
+                    "text/csv"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "segments"
+                ],
+                "summary": "Добавить пользователей в сегмент",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Наименование сегмента",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Дата и время выхода пользователей из сегмента для CSV (15:04:05 02.01.2006)",
+                        "name": "end_date",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Пробный запуск без сохранения изменений",
+                        "name": "dry_run",
+                        "in": "query"
+                    },
+                    {
+                        "description": "Список добавляемых пользователей",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/avito-rest-api_internal_service.SegmentUsersAddInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Результат добавления",
+                        "schema": {
+                            "$ref": "#/definitions/internal_controller_http_v1.AddUsersToSegmentResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Ошибка валидации данных запроса или пользователь удалён",
+                        "schema": {
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrSegmentValidationError"
+                        }
+                    },
+                    "404": {
+                        "description": "Сегмент или пользователи не были найдены",
+                        "schema": {
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrUserNotFound"
+                        }
+                    },
+                    "409": {
+                        "description": "Пользователи уже входят во взаимоисключающий сегмент",
+                        "schema": {
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrSegmentGroupConflict"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrInternalServerError"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Удаляет из сегмента с указанным именем список пользователей, переданный в виде JSON или CSV\n(` + "`" + `Content-Type: text/csv` + "`" + `, по одному ID пользователя в строке, заголовок ` + "`" + `user_id` + "`" + ` необязателен).\nВсе пользователи должны существовать, пользователи, не входящие в сегмент, пропускаются\nи перечисляются в ответе. Операция выполняется в одной транзакции. Если ` + "`" + `dry_run` + "`" + ` равен true,\nизменения не сохраняются, а в ответе перечисляются пользователи, которые вышли бы из сегмента",
+                "consumes": [
+                    "application/json",
+                    "text/csv"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "segments"
+                ],
+                "summary": "Удалить пользователей из сегмента",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Наименование сегмента",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Пробный запуск без сохранения изменений",
+                        "name": "dry_run",
+                        "in": "query"
+                    },
+                    {
+                        "description": "Список удаляемых пользователей",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/avito-rest-api_internal_service.SegmentUsersDeleteInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Результат удаления",
+                        "schema": {
+                            "$ref": "#/definitions/internal_controller_http_v1.DeleteUsersFromSegmentResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Ошибка валидации данных запроса",
+                        "schema": {
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrSegmentValidationError"
+                        }
+                    },
+                    "404": {
+                        "description": "Сегмент или пользователи не были найдены",
+                        "schema": {
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrUserNotFound"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrInternalServerError"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/users": {
+            "get": {
+                "description": "Возвращает страницу списка пользователей, удовлетворяющих фильтрам, в заданном порядке.\nДля получения следующей страницы передайте ` + "`" + `next_cursor` + "`" + ` из ответа в параметр ` + "`" + `cursor` + "`" + `,\nсохранив остальные параметры запроса. Фильтры по дополнительным атрибутам передаются параметрами\nвида ` + "`" + `attributes.\u003cключ\u003e=\u003cзначение\u003e` + "`" + `, например ` + "`" + `attributes.city=Москва` + "`" + ` или ` + "`" + `attributes.premium=true` + "`" + `",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Получить список пользователей",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Имя пользователя",
+                        "name": "name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Фамилия пользователя",
+                        "name": "lastname",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            0,
+                            1
+                        ],
+                        "type": "integer",
+                        "description": "Пол пользователя, 0 - мужской, 1 - женский",
+                        "name": "sex",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Минимальный возраст пользователя, включительно",
+                        "name": "min_age",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Максимальный возраст пользователя, включительно",
+                        "name": "max_age",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Пометка об удалении",
+                        "name": "is_deleted",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Поле сортировки: user_id (по умолчанию), name, lastname или age, префикс - задаёт убывание",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Размер страницы, от 1 до 1000, по умолчанию 100",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Курсор страницы",
+                        "name": "cursor",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Страница списка пользователей",
+                        "schema": {
+                            "$ref": "#/definitions/internal_controller_http_v1.GetAllUsersResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Ошибка валидации данных запроса",
+                        "schema": {
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrUserValidationError"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrInternalServerError"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Создаёт пользователя на основе информации в теле запроса",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Создать пользователя",
+                "parameters": [
+                    {
+                        "description": "Структура с информацией о создаваемом пользователе",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/avito-rest-api_internal_service.UserCreateInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Идентификатор созданного пользователя",
+                        "schema": {
+                            "$ref": "#/definitions/internal_controller_http_v1.UserCreateResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Ошибка валидации данных запроса",
+                        "schema": {
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrUserValidationError"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrInternalServerError"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/users/addUserToSegments": {
+            "post": {
+                "description": "Добавляет пользователя с указанным ID в указанные сегменты",
+                "tags": [
+                    "users"
+                ],
+                "summary": "Добавить пользователя в сегменты",
+                "parameters": [
+                    {
+                        "description": "Структура, содержащая ID или внешний идентификатор пользователя и наименование сегментов, в которые необходимо добавить пользователя. Поле ` + "`" + `end_date` + "`" + ` у сегмента является опциональным, и, если не  установлено, сигнализирует о том, что время выхода пользователя из сегмента не определено (пока сегмент  не будет удалён или пользователь не будет удалён из этого сегмента)",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/internal_controller_http_v1.AddUserToSegmentsInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Сообщение об успехе",
+                        "schema": {
+                            "$ref": "#/definitions/internal_controller_http_v1.AddUserToSegmentsResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Ошибка валидации данных запроса",
+                        "schema": {
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrUserValidationError"
+                        }
+                    },
+                    "404": {
+                        "description": "Пользователь с указанным ID не был найден или некоторые из указанных сегментов не существуют",
+                        "schema": {
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrUserNotFound"
+                        }
+                    },
+                    "409": {
+                        "description": "Пользователь уже входит во взаимоисключающий сегмент",
+                        "schema": {
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrSegmentGroupConflict"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrInternalServerError"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/users/by-external-id/{ext}": {
+            "get": {
+                "description": "Возвращает пользователя с указанным внешним идентификатором",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Получить пользователя по внешнему идентификатору",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Внешний идентификатор пользователя",
+                        "name": "ext",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Пользователь с указанным внешним идентификатором",
+                        "schema": {
+                            "$ref": "#/definitions/internal_controller_http_v1.GetUserByIDResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Ошибка валидации данных запроса или пользователь удалён",
+                        "schema": {
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrUserValidationError"
+                        }
+                    },
+                    "404": {
+                        "description": "Пользователь с указанным внешним идентификатором не был найден",
+                        "schema": {
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrUserNotFound"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrInternalServerError"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Создаёт пользователя с указанным внешним идентификатором или, если он уже существует, заменяет его атрибуты переданными. Повторная отправка тех же данных не создаёт дубликатов",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Создать или обновить пользователя по внешнему идентификатору",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Внешний идентификатор пользователя",
+                        "name": "ext",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Структура с информацией о пользователе. Поле ` + "`" + `external_id` + "`" + ` можно не указывать",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/avito-rest-api_internal_service.UserCreateInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Пользователь был обновлён",
+                        "schema": {
+                            "$ref": "#/definitions/internal_controller_http_v1.UpsertUserByExternalIDResponse"
+                        }
+                    },
+                    "201": {
+                        "description": "Пользователь был создан",
+                        "schema": {
+                            "$ref": "#/definitions/internal_controller_http_v1.UpsertUserByExternalIDResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Ошибка валидации данных запроса или пользователь удалён",
+                        "schema": {
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrUserValidationError"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrInternalServerError"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/users/deleteUserFromSegments": {
+            "post": {
+                "description": "Удаляет пользователя с указанным ID из указанных сегментов",
+                "tags": [
+                    "users"
+                ],
+                "summary": "Удалить пользователя из сегментов",
+                "parameters": [
+                    {
+                        "description": "Структура, содержащая ID или внешний идентификатор пользователя и наименования сегментов, из которых пользователя необходимо удалить",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/internal_controller_http_v1.DeleteUserFromSegmentsInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Сообщение об успехе",
+                        "schema": {
+                            "$ref": "#/definitions/internal_controller_http_v1.DeleteUserFromSegmentsResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Ошибка валидации данных запроса, может возникать, если пользователь не входит в указанные сегменты",
+                        "schema": {
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrUserValidationError"
+                        }
+                    },
+                    "404": {
+                        "description": "Пользователь с указанным ID не был найден или некоторые из указанных сегментов не существуют",
+                        "schema": {
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrUserNotFound"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrInternalServerError"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/users/import": {
+            "post": {
+                "description": "Создаёт пользователей из тела запроса в формате CSV (заголовок ` + "`" + `name,lastname,sex,age` + "`" + `, необязательные\nстолбцы ` + "`" + `external_id` + "`" + ` и ` + "`" + `attributes` + "`" + ` с JSON-объектом атрибутов) или NDJSON (один JSON-объект пользователя\nна строку). Формат задаётся параметром ` + "`" + `format` + "`" + `, а при его отсутствии\nопределяется по заголовку ` + "`" + `Content-Type` + "`" + ` (` + "`" + `text/csv` + "`" + ` или ` + "`" + `application/x-ndjson` + "`" + `).\nКаждая строка валидируется по правилам создания пользователя: корректные строки импортируются,\nа для остальных в ответе возвращается номер строки и причина ошибки. Строки с уже занятым или повторяющимся\nвнешним идентификатором не импортируются.\nИмпортированные пользователи добавляются в сегменты с правилами и процентом раскатки и распределяются\nпо вариантам экспериментов",
+                "consumes": [
+                    "text/csv",
+                    "application/x-ndjson"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Импортировать пользователей",
+                "parameters": [
+                    {
+                        "enum": [
+                            "csv",
+                            "ndjson"
+                        ],
+                        "type": "string",
+                        "description": "Формат данных",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "description": "Импортируемые пользователи",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Отчёт об импорте",
+                        "schema": {
+                            "$ref": "#/definitions/internal_controller_http_v1.ImportUsersResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Неизвестный формат или ошибка разбора данных",
+                        "schema": {
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrUserValidationError"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrInternalServerError"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/users/withSegments": {
+            "get": {
+                "description": "Возвращает страницу списка пользователей, упорядоченного по ID, включая список активных для каждого\nпользователя сегментов на момент совершения запроса. Для получения следующей страницы передайте\n` + "`" + `next_cursor` + "`" + ` из ответа в параметр ` + "`" + `cursor` + "`" + `",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Получить список всех пользователей, включая их сегменты",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Размер страницы, от 1 до 1000, по умолчанию 100",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Курсор страницы",
+                        "name": "cursor",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Список пользователей с их активными сегментами",
+                        "schema": {
+                            "$ref": "#/definitions/internal_controller_http_v1.GetAllUsersWithSegmentsResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Ошибка валидации данных запроса",
+                        "schema": {
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrUserValidationError"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrInternalServerError"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/users/{id}": {
+            "get": {
+                "description": "Возвращает пользователя с указанным ID",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Получить пользователя по ID",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID пользователя",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Пользователь с указанным ID",
+                        "schema": {
+                            "$ref": "#/definitions/internal_controller_http_v1.GetUserByIDResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Ошибка валидации данных запроса",
+                        "schema": {
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrUserValidationError"
+                        }
+                    },
+                    "404": {
+                        "description": "Пользователь с указанным ID не был найден",
+                        "schema": {
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrUserNotFound"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrInternalServerError"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Заменяет все атрибуты пользователя с указанным ID значениями из тела запроса. Вхождение пользователя\nв сегменты с правилами приводится в соответствие с новыми атрибутами",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Изменить пользователя",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID пользователя",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Новые атрибуты пользователя",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/avito-rest-api_internal_service.UserCreateInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Изменённый пользователь",
+                        "schema": {
+                            "$ref": "#/definitions/internal_controller_http_v1.UpdateUserResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Ошибка валидации данных запроса",
+                        "schema": {
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrUserValidationError"
+                        }
+                    },
+                    "404": {
+                        "description": "Пользователь с указанным ID не был найден",
+                        "schema": {
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrUserNotFound"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrInternalServerError"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Помечает пользователя с указанным ID как удалённого. Пользователь выходит из всех сегментов,\nв которые входит на момент удаления, история его сегментов сохраняется",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Удалить пользователя",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID пользователя",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Сообщение об успешном удалении",
+                        "schema": {
+                            "$ref": "#/definitions/internal_controller_http_v1.DeleteUserResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Пользователь уже удалён",
+                        "schema": {
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrUserDeleted"
+                        }
+                    },
+                    "404": {
+                        "description": "Пользователь с указанным ID не был найден",
+                        "schema": {
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrUserNotFound"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrInternalServerError"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "description": "Изменяет атрибуты пользователя с указанным ID, переданные в теле запроса. Отсутствующие атрибуты\nне изменяются. Вхождение пользователя в сегменты с правилами приводится в соответствие с новыми атрибутами",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Частично изменить пользователя",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID пользователя",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Изменяемые атрибуты пользователя",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/avito-rest-api_internal_service.UserUpdateInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Изменённый пользователь",
+                        "schema": {
+                            "$ref": "#/definitions/internal_controller_http_v1.UpdateUserResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Ошибка валидации данных запроса",
+                        "schema": {
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrUserValidationError"
+                        }
+                    },
+                    "404": {
+                        "description": "Пользователь с указанным ID не был найден",
+                        "schema": {
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrUserNotFound"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrInternalServerError"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/users/{id}/restore": {
+            "post": {
+                "description": "Снимает с пользователя с указанным ID пометку об удалении. Пользователь возвращается в сегменты\nс правилами и процентом раскатки, которым удовлетворяет, и в сегменты вариантов экспериментов.\nСегменты, в которые пользователь был добавлен вручную, не восстанавливаются",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Восстановить пользователя",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID пользователя",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Восстановленный пользователь",
+                        "schema": {
+                            "$ref": "#/definitions/internal_controller_http_v1.RestoreUserResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Пользователь не удалён",
+                        "schema": {
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrUserValidationError"
+                        }
+                    },
+                    "404": {
+                        "description": "Пользователь с указанным ID не был найден",
+                        "schema": {
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrUserNotFound"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrInternalServerError"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/users/{id}/segments": {
+            "get": {
+                "description": "Возвращает сегменты, в которые пользователь с указанным ID входил в момент времени ` + "`" + `at` + "`" + `,\nв том числе сегменты, удалённые позднее. Если ` + "`" + `at` + "`" + ` не указан, возвращаются сегменты на момент совершения запроса",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Получить сегменты пользователя на момент времени",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID пользователя",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Момент времени в формате ` + "`" + `15:04:05 02.01.2006` + "`" + `",
+                        "name": "at",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Сегменты пользователя на указанный момент",
+                        "schema": {
+                            "$ref": "#/definitions/internal_controller_http_v1.GetUserSegmentsAtResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Ошибка валидации данных запроса",
+                        "schema": {
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrUserValidationError"
+                        }
+                    },
+                    "404": {
+                        "description": "Пользователь с указанным ID не был найден",
+                        "schema": {
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrUserNotFound"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrInternalServerError"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "description": "Добавляет пользователя с указанным ID в одни сегменты и удаляет из других в рамках одной транзакции.\nЕсли хотя бы одна из операций не может быть выполнена, не выполняется ни одна из них.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Добавить пользователя в сегменты и удалить из сегментов",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID пользователя",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Структура, содержащая сегменты, в которые необходимо добавить пользователя (поле ` + "`" + `end_date` + "`" + ` опционально), и сегменты, из которых пользователя необходимо удалить. Хотя бы один из списков должен быть непустым",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/internal_controller_http_v1.UpdateUserSegmentsInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Сообщение об успехе",
+                        "schema": {
+                            "$ref": "#/definitions/internal_controller_http_v1.UpdateUserSegmentsResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Ошибка валидации данных запроса",
+                        "schema": {
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrUserValidationError"
+                        }
+                    },
+                    "404": {
+                        "description": "Пользователь с указанным ID не был найден или некоторые из указанных сегментов не существуют",
+                        "schema": {
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrUserNotFound"
+                        }
+                    },
+                    "409": {
+                        "description": "Добавляемый сегмент взаимоисключающий с сегментом, в который входит пользователь",
+                        "schema": {
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrSegmentGroupConflict"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrInternalServerError"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/users/{id}/segments/{name}": {
+            "patch": {
+                "description": "Переносит дату выхода пользователя с указанным ID из сегмента в текущем вхождении или, если передана пустая строка, делает вхождение бессрочным.\nИзменение записывается в историю изменений вхождения",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Изменить дату выхода пользователя из сегмента",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID пользователя",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Наименование сегмента",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Структура, содержащая новую дату выхода пользователя из сегмента",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/internal_controller_http_v1.UpdateUserSegmentInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Изменённое вхождение пользователя в сегмент",
+                        "schema": {
+                            "$ref": "#/definitions/internal_controller_http_v1.UpdateUserSegmentResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Ошибка валидации данных запроса или пользователь не входит в сегмент",
+                        "schema": {
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrUserValidationError"
+                        }
+                    },
+                    "404": {
+                        "description": "Пользователь с указанным ID или сегмент не был найден",
+                        "schema": {
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrUserNotFound"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrInternalServerError"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/users/{id}/withSegments": {
+            "get": {
+                "description": "Возвращает пользователя с указанным ID, включая в тело ответа список сегментов, в которые пользователь входит на момент совершения запроса",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Получить пользователя с его сегментами по ID",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID пользователя",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Пользователь с его активными сегментами",
+                        "schema": {
+                            "$ref": "#/definitions/internal_controller_http_v1.GetUserByIDWithSegmentsResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Ошибка валидации данных запроса",
+                        "schema": {
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrUserValidationError"
+                        }
+                    },
+                    "404": {
+                        "description": "Пользователь с указанным ID не был найден",
+                        "schema": {
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrUserNotFound"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/avito-rest-api_internal_error.ErrInternalServerError"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "avito-rest-api_internal_entity.Experiment": {
+            "type": "object",
+            "properties": {
+                "experiment_id": {
+                    "type": "integer",
+                    "example": 7
+                },
+                "name": {
+                    "type": "string",
+                    "example": "AVITO_CHECKOUT"
+                },
+                "variants": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/avito-rest-api_internal_entity.ExperimentVariant"
+                    }
+                }
+            }
+        },
+        "avito-rest-api_internal_entity.ExperimentReport": {
+            "type": "object",
+            "properties": {
+                "experiment": {
+                    "type": "string",
+                    "example": "AVITO_CHECKOUT"
+                },
+                "total": {
+                    "description": "Общее количество пользователей, распределённых в варианты эксперимента",
+                    "type": "integer",
+                    "example": 1024
+                },
+                "variants": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/avito-rest-api_internal_entity.ExperimentVariantSize"
+                    }
+                }
+            }
+        },
+        "avito-rest-api_internal_entity.ExperimentVariant": {
+            "type": "object",
+            "properties": {
+                "name": {
+                    "type": "string",
+                    "example": "A"
+                },
+                "segment_id": {
+                    "description": "ID сегмента, в который входят пользователи варианта",
+                    "type": "integer",
+                    "example": 44
+                },
+                "segment_name": {
+                    "description": "Наименование сегмента, в который входят пользователи варианта",
+                    "type": "string",
+                    "example": "AVITO_CHECKOUT_A"
+                },
+                "variant_id": {
+                    "type": "integer",
+                    "example": 12
+                },
+                "weight": {
+                    "description": "Вес варианта, доля пользователей варианта равна его весу, делённому на сумму весов",
+                    "type": "integer",
+                    "example": 50
+                }
+            }
+        },
+        "avito-rest-api_internal_entity.ExperimentVariantSize": {
+            "type": "object",
+            "properties": {
+                "name": {
+                    "type": "string",
+                    "example": "A"
+                },
+                "segment_id": {
+                    "description": "ID сегмента, в который входят пользователи варианта",
+                    "type": "integer",
+                    "example": 44
+                },
+                "segment_name": {
+                    "description": "Наименование сегмента, в который входят пользователи варианта",
+                    "type": "string",
+                    "example": "AVITO_CHECKOUT_A"
+                },
+                "users": {
+                    "type": "integer",
+                    "example": 512
+                },
+                "variant_id": {
+                    "type": "integer",
+                    "example": 12
+                },
+                "weight": {
+                    "description": "Вес варианта, доля пользователей варианта равна его весу, делённому на сумму весов",
+                    "type": "integer",
+                    "example": 50
+                }
+            }
+        },
+        "avito-rest-api_internal_entity.Segment": {
+            "type": "object",
+            "properties": {
+                "active_until": {
+                    "description": "Дата и время, после которых сегмент автоматически удаляется",
+                    "type": "string",
+                    "example": "00:00:00 01.01.2024"
+                },
+                "created_at": {
+                    "description": "Дата и время создания сегмента",
+                    "type": "string",
+                    "example": "15:27:32 01.09.2023"
+                },
+                "default_ttl": {
+                    "description": "Срок вхождения пользователя в сегмент, если при добавлении не указана дата выхода",
+                    "type": "string",
+                    "example": "30d"
+                },
+                "deleted_at": {
+                    "description": "Дата и время удаления сегмента, если он удалён",
+                    "type": "string",
+                    "example": "15:27:32 01.09.2023"
+                },
+                "derivation": {
+                    "description": "Определение вычисляемого сегмента, состав которого вычисляется из других сегментов при каждом запросе",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/avito-rest-api_internal_entity.SegmentDerivation"
+                        }
+                    ]
+                },
+                "description": {
+                    "description": "Описание назначения сегмента",
+                    "type": "string",
+                    "example": "Скидка 45% на товары маркета"
+                },
+                "group_id": {
+                    "description": "ID группы взаимоисключающих сегментов, в которую входит сегмент",
+                    "type": "integer",
+                    "example": 3
+                },
+                "is_deleted": {
+                    "type": "boolean",
+                    "example": false
+                },
+                "name": {
+                    "type": "string",
+                    "example": "AVITO_MUSIC_SERVICE"
+                },
+                "owner": {
+                    "description": "Команда, владеющая сегментом",
+                    "type": "string",
+                    "example": "market-team"
+                },
+                "percentage": {
+                    "description": "Процент пользователей, автоматически попадающих в сегмент",
+                    "type": "integer",
+                    "example": 57
+                },
+                "rules": {
+                    "description": "Правила автоматического вхождения пользователей в сегмент, объединяемые через AND",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/avito-rest-api_internal_entity.SegmentRule"
+                    }
+                },
+                "segment_id": {
+                    "type": "integer",
+                    "example": 43
+                },
+                "tags": {
+                    "description": "Теги сегмента",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "market",
+                        "discount"
+                    ]
+                },
+                "updated_at": {
+                    "description": "Дата и время последнего изменения сегмента",
+                    "type": "string",
+                    "example": "15:27:32 01.09.2023"
+                }
+            }
+        },
+        "avito-rest-api_internal_entity.SegmentDerivation": {
+            "type": "object",
+            "properties": {
+                "operation": {
+                    "description": "Операция над множествами пользователей сегментов",
+                    "type": "string",
+                    "enum": [
+                        "union",
+                        "intersection",
+                        "difference"
+                    ],
+                    "example": "difference"
+                },
+                "segment_ids": {
+                    "description": "ID исходных сегментов, для разности первым указан уменьшаемый сегмент",
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    },
+                    "example": [
+                        2,
+                        3
+                    ]
+                }
+            }
+        },
+        "avito-rest-api_internal_entity.SegmentGroup": {
+            "type": "object",
+            "properties": {
+                "group_id": {
+                    "type": "integer",
+                    "example": 3
+                },
+                "name": {
+                    "type": "string",
+                    "example": "AVITO_DISCOUNT"
+                },
+                "segments": {
+                    "description": "Наименования сегментов группы",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "AVITO_DISCOUNT_30",
+                        "AVITO_DISCOUNT_50",
+                        "AVITO_DISCOUNT_70"
+                    ]
+                }
+            }
+        },
+        "avito-rest-api_internal_entity.SegmentMember": {
+            "type": "object",
+            "properties": {
+                "end_date": {
+                    "description": "Дата выхода пользователя из сегмента",
+                    "type": "string",
+                    "example": ""
+                },
+                "information_id": {
+                    "description": "ID записи в ассоциативной таблице, связывающей пользователей с сегментами",
+                    "type": "integer",
+                    "example": 179
+                },
+                "start_date": {
+                    "description": "Дата добавления пользователя в сегмент",
+                    "type": "string",
+                    "example": "15:27:32 01.09.2023"
+                },
+                "user": {
+                    "description": "Пользователь, входящий в сегмент",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/avito-rest-api_internal_entity.User"
+                        }
+                    ]
+                }
+            }
+        },
+        "avito-rest-api_internal_entity.SegmentNameChange": {
+            "type": "object",
+            "properties": {
+                "new_name": {
+                    "description": "Имя сегмента после переименования",
+                    "type": "string",
+                    "example": "AVITO_MUSIC_SERVICE"
+                },
+                "old_name": {
+                    "description": "Имя сегмента до переименования",
+                    "type": "string",
+                    "example": "AVITO_MUSIC"
+                },
+                "renamed_at": {
+                    "description": "Дата и время переименования",
+                    "type": "string",
+                    "example": "10:00:00 25.09.2023"
+                }
+            }
+        },
+        "avito-rest-api_internal_entity.SegmentRule": {
+            "type": "object",
+            "properties": {
+                "attribute": {
+                    "description": "Атрибут пользователя: name, lastname, sex, age или дополнительный атрибут attributes.\u003cключ\u003e",
+                    "type": "string",
+                    "example": "age"
+                },
+                "operator": {
+                    "description": "Оператор сравнения, для атрибутов name и lastname и логических значений допустимы только = и !=",
+                    "type": "string",
+                    "enum": [
+                        "=",
+                        "!=",
+                        "\u003e",
+                        "\u003e=",
+                        "\u003c",
+                        "\u003c="
+                    ],
+                    "example": "\u003e="
+                },
+                "value": {
+                    "description": "Значение, с которым сравнивается атрибут: целое число для sex и age, строка для name и lastname, строка, число или логическое значение для дополнительных атрибутов",
+                    "type": "string",
+                    "example": "18"
+                }
+            }
+        },
+        "avito-rest-api_internal_entity.User": {
+            "type": "object",
+            "properties": {
+                "age": {
+                    "type": "integer",
+                    "example": 26
+                },
+                "attributes": {
+                    "description": "Дополнительные атрибуты пользователя (город, платформа и т.п.): строки, числа или логические значения",
+                    "type": "object"
+                },
+                "external_id": {
+                    "description": "Идентификатор пользователя во внешней системе, отсутствует, если не задан",
+                    "type": "string",
+                    "example": "crm-1842"
+                },
+                "is_deleted": {
+                    "type": "boolean",
+                    "example": false
+                },
+                "lastname": {
+                    "type": "string",
+                    "example": "Иванов"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "Михаил"
+                },
+                "sex": {
+                    "description": "Пол, 0 - мужской, 1 - женский",
+                    "type": "integer",
+                    "enum": [
+                        0,
+                        1
+                    ],
+                    "example": 0
+                },
+                "sex_text": {
+                    "type": "string",
+                    "enum": [
+                        "мужской",
+                        "женский"
+                    ],
+                    "example": "мужской"
+                },
+                "user_id": {
+                    "type": "integer",
+                    "example": 16
+                }
+            }
+        },
+        "avito-rest-api_internal_entity.UserExperimentVariant": {
+            "type": "object",
+            "properties": {
+                "experiment": {
+                    "type": "string",
+                    "example": "AVITO_CHECKOUT"
+                },
+                "start_date": {
+                    "description": "Дата распределения пользователя в вариант",
+                    "type": "string",
+                    "example": "15:27:32 01.09.2023"
+                },
+                "user_id": {
+                    "type": "integer",
+                    "example": 16
+                },
+                "variant": {
+                    "$ref": "#/definitions/avito-rest-api_internal_entity.ExperimentVariant"
+                }
+            }
+        },
+        "avito-rest-api_internal_entity.UserImportError": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "description": "Причина, по которой строка не была импортирована",
+                    "type": "string",
+                    "example": "\"name\" field cannot be empty"
+                },
+                "row": {
+                    "description": "Номер строки: строки данных CSV (без заголовка) или строки NDJSON, начиная с 1",
+                    "type": "integer",
+                    "example": 3
+                }
+            }
+        },
+        "avito-rest-api_internal_entity.UserSegmentInformation": {
+            "type": "object",
+            "properties": {
+                "end_date": {
+                    "description": "Дата выхода пользователя из сегмента",
+                    "type": "string",
+                    "example": ""
+                },
+                "information_id": {
+                    "description": "ID записи в ассоциативной таблице, связывающей пользователей с сегментами",
+                    "type": "integer",
+                    "example": 179
+                },
+                "name": {
+                    "description": "Наименование сегмента",
+                    "type": "string",
+                    "example": "AVITO_MUSIC_SERVICE"
+                },
+                "segment_id": {
+                    "description": "ID сегмента",
+                    "type": "integer",
+                    "example": 43
+                },
+                "start_date": {
+                    "description": "Дата добавления пользователя в сегмент",
+                    "type": "string",
+                    "example": "15:27:32 01.09.2023"
+                },
+                "user_id": {
+                    "description": "ID пользователя, который входит в сегмент",
+                    "type": "integer",
+                    "example": 16
+                }
+            }
+        },
+        "avito-rest-api_internal_entity.UserWithSegments": {
+            "type": "object",
+            "properties": {
+                "segments": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/avito-rest-api_internal_entity.UserSegmentInformation"
+                    }
+                },
+                "user": {
+                    "$ref": "#/definitions/avito-rest-api_internal_entity.User"
+                }
+            }
+        },
+        "avito-rest-api_internal_error.ErrExperimentAlreadyExists": {
+            "type": "object",
+            "properties": {
+                "comment": {
+                    "type": "string"
+                },
+                "location": {
+                    "type": "string"
+                },
+                "origin_error_text": {
+                    "type": "string"
+                },
+                "title": {
+                    "type": "string"
+                }
+            }
+        },
+        "avito-rest-api_internal_error.ErrExperimentNotFound": {
+            "type": "object",
+            "properties": {
+                "comment": {
+                    "type": "string"
+                },
+                "location": {
+                    "type": "string"
+                },
+                "origin_error_text": {
+                    "type": "string"
+                },
+                "title": {
+                    "type": "string"
+                }
+            }
+        },
+        "avito-rest-api_internal_error.ErrExperimentValidationError": {
+            "type": "object",
+            "properties": {
+                "comment": {
+                    "type": "string"
+                },
+                "location": {
+                    "type": "string"
+                },
+                "origin_error_text": {
+                    "type": "string"
+                },
+                "title": {
+                    "type": "string"
+                }
+            }
+        },
+        "avito-rest-api_internal_error.ErrExperimentVariantNotFound": {
+            "type": "object",
+            "properties": {
+                "comment": {
+                    "type": "string"
+                },
+                "location": {
+                    "type": "string"
+                },
+                "origin_error_text": {
+                    "type": "string"
+                },
+                "title": {
+                    "type": "string"
+                }
+            }
+        },
+        "avito-rest-api_internal_error.ErrInternalServerError": {
+            "type": "object",
+            "properties": {
+                "comment": {
+                    "type": "string"
+                },
+                "location": {
+                    "type": "string"
+                },
+                "origin_error_text": {
+                    "type": "string"
+                },
+                "title": {
+                    "type": "string"
+                }
+            }
+        },
+        "avito-rest-api_internal_error.ErrReportValidationError": {
+            "type": "object",
+            "properties": {
+                "comment": {
+                    "type": "string"
+                },
+                "location": {
+                    "type": "string"
+                },
+                "origin_error_text": {
+                    "type": "string"
+                },
+                "title": {
+                    "type": "string"
+                }
+            }
+        },
+        "avito-rest-api_internal_error.ErrSegmentAlreadyExists": {
+            "type": "object",
+            "properties": {
+                "comment": {
+                    "type": "string"
+                },
+                "location": {
+                    "type": "string"
+                },
+                "origin_error_text": {
+                    "type": "string"
+                },
+                "title": {
+                    "type": "string"
+                }
+            }
+        },
+        "avito-rest-api_internal_error.ErrSegmentGroupAlreadyExists": {
+            "type": "object",
+            "properties": {
+                "comment": {
+                    "type": "string"
+                },
+                "location": {
+                    "type": "string"
+                },
+                "origin_error_text": {
+                    "type": "string"
+                },
+                "title": {
+                    "type": "string"
+                }
+            }
+        },
+        "avito-rest-api_internal_error.ErrSegmentGroupConflict": {
+            "type": "object",
+            "properties": {
+                "comment": {
+                    "type": "string"
+                },
+                "location": {
+                    "type": "string"
+                },
+                "origin_error_text": {
+                    "type": "string"
+                },
+                "title": {
+                    "type": "string"
+                }
+            }
+        },
+        "avito-rest-api_internal_error.ErrSegmentGroupNotFound": {
+            "type": "object",
+            "properties": {
+                "comment": {
+                    "type": "string"
+                },
+                "location": {
+                    "type": "string"
+                },
+                "origin_error_text": {
+                    "type": "string"
+                },
+                "title": {
+                    "type": "string"
+                }
+            }
+        },
+        "avito-rest-api_internal_error.ErrSegmentGroupValidationError": {
+            "type": "object",
+            "properties": {
+                "comment": {
+                    "type": "string"
+                },
+                "location": {
+                    "type": "string"
+                },
+                "origin_error_text": {
+                    "type": "string"
+                },
+                "title": {
+                    "type": "string"
+                }
+            }
+        },
+        "avito-rest-api_internal_error.ErrSegmentNotFound": {
+            "type": "object",
+            "properties": {
+                "comment": {
+                    "type": "string"
+                },
+                "location": {
+                    "type": "string"
+                },
+                "origin_error_text": {
+                    "type": "string"
+                },
+                "title": {
+                    "type": "string"
+                }
+            }
+        },
+        "avito-rest-api_internal_error.ErrSegmentValidationError": {
+            "type": "object",
+            "properties": {
+                "comment": {
+                    "type": "string"
+                },
+                "location": {
+                    "type": "string"
+                },
+                "origin_error_text": {
+                    "type": "string"
+                },
+                "title": {
+                    "type": "string"
+                }
+            }
+        },
+        "avito-rest-api_internal_error.ErrUserDeleted": {
+            "type": "object",
+            "properties": {
+                "comment": {
+                    "type": "string"
+                },
+                "location": {
+                    "type": "string"
+                },
+                "origin_error_text": {
+                    "type": "string"
+                },
+                "title": {
+                    "type": "string"
+                }
+            }
+        },
+        "avito-rest-api_internal_error.ErrUserNotFound": {
+            "type": "object",
+            "properties": {
+                "comment": {
+                    "type": "string"
+                },
+                "location": {
+                    "type": "string"
+                },
+                "origin_error_text": {
+                    "type": "string"
+                },
+                "title": {
+                    "type": "string"
+                }
+            }
+        },
+        "avito-rest-api_internal_error.ErrUserValidationError": {
+            "type": "object",
+            "properties": {
+                "comment": {
+                    "type": "string"
+                },
+                "location": {
+                    "type": "string"
+                },
+                "origin_error_text": {
+                    "type": "string"
+                },
+                "title": {
+                    "type": "string"
+                }
+            }
+        },
+        "avito-rest-api_internal_service.ExperimentCreateInput": {
+            "type": "object",
+            "required": [
+                "name",
+                "variants"
+            ],
+            "properties": {
+                "name": {
+                    "description": "Имя эксперимента, используется как префикс имён сегментов вариантов",
+                    "type": "string",
+                    "example": "AVITO_CHECKOUT"
+                },
+                "variants": {
+                    "description": "Варианты эксперимента, не менее двух",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/avito-rest-api_internal_service.ExperimentVariantInput"
+                    }
+                }
+            }
+        },
+        "avito-rest-api_internal_service.ExperimentVariantInput": {
+            "type": "object",
+            "required": [
+                "name",
+                "weight"
+            ],
+            "properties": {
+                "name": {
+                    "description": "Имя варианта, сегмент варианта получит имя ` + "`" + `\u003cимя эксперимента\u003e_\u003cимя варианта\u003e` + "`" + `",
+                    "type": "string",
+                    "example": "A"
+                },
+                "weight": {
+                    "description": "Вес варианта, целое положительное число",
+                    "type": "integer",
+                    "example": 50
+                }
+            }
+        },
+        "avito-rest-api_internal_service.SegmentCreateInput": {
+            "type": "object",
+            "required": [
+                "name"
+            ],
+            "properties": {
+                "active_until": {
+                    "description": "Необязательное поле, дата и время, после которых сегмент автоматически удаляется",
+                    "type": "string",
+                    "example": "00:00:00 01.01.2024"
+                },
+                "default_ttl": {
+                    "description": "Необязательное поле, срок вхождения пользователя в сегмент, если при добавлении не указана дата выхода,\nв виде \"30d\", \"12h\" или \"1d12h30m\"",
+                    "type": "string",
+                    "example": "30d"
+                },
+                "description": {
+                    "description": "Необязательное поле, описание назначения сегмента",
+                    "type": "string",
+                    "example": "Скидка 45% на товары маркета"
+                },
+                "name": {
+                    "description": "Имя сегмента",
+                    "type": "string",
+                    "example": "AVITO_MUSIC_SERVICE"
+                },
+                "owner": {
+                    "description": "Необязательное поле, команда, владеющая сегментом",
+                    "type": "string",
+                    "example": "market-team"
+                },
+                "percentage": {
+                    "description": "Необязательное поле, процент пользователей, которые автоматически войдут в сегмент - как существующих,\nтак и созданных позднее. Если заданы правила, процент отсчитывается от удовлетворяющих им пользователей",
+                    "type": "integer",
+                    "maximum": 100,
+                    "minimum": 0,
+                    "example": 57
+                },
+                "rules": {
+                    "description": "Необязательное поле, правила автоматического вхождения пользователей в сегмент, объединяемые через AND",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/avito-rest-api_internal_entity.SegmentRule"
+                    }
+                },
+                "salt": {
+                    "description": "Необязательное поле, соль раскатки на процент из ответа пробного запуска: с ней состав сегмента совпадает\nс предварительным. По умолчанию новому сегменту генерируется новая соль, а восстановленный сохраняет прежнюю",
+                    "type": "string",
+                    "example": "9e107d9d372bb6826bd81d3542a419d6"
+                },
+                "tags": {
+                    "description": "Необязательное поле, теги сегмента",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "market",
+                        "discount"
+                    ]
+                }
+            }
+        },
+        "avito-rest-api_internal_service.SegmentDeriveInput": {
+            "type": "object",
+            "required": [
+                "name",
+                "operation",
+                "segments"
+            ],
+            "properties": {
+                "live": {
+                    "description": "Необязательное поле, если true, сегмент вычисляется при каждом запросе сегментов пользователя,\nиначе в него однократно добавляются пользователи, входящие в результат операции на момент создания",
+                    "type": "boolean",
+                    "example": false
+                },
+                "name": {
+                    "description": "Имя создаваемого сегмента",
+                    "type": "string",
+                    "example": "AVITO_MUSIC_AND_VOICE"
+                },
+                "operation": {
+                    "description": "Операция над исходными сегментами: \"union\", \"intersection\" или \"difference\" (первый сегмент без остальных)",
+                    "type": "string",
+                    "enum": [
+                        "union",
+                        "intersection",
+                        "difference"
+                    ],
+                    "example": "intersection"
+                },
+                "segments": {
+                    "description": "Имена исходных сегментов, не менее двух",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "AVITO_MUSIC_SERVICE",
+                        "AVITO_VOICE_MESSAGES"
+                    ]
+                }
+            }
+        },
+        "avito-rest-api_internal_service.SegmentGroupCreateInput": {
+            "type": "object",
+            "required": [
+                "name"
+            ],
+            "properties": {
+                "name": {
+                    "description": "Имя группы",
+                    "type": "string",
+                    "example": "AVITO_DISCOUNT"
+                },
+                "segments": {
+                    "description": "Наименования сегментов, входящих в группу",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "AVITO_DISCOUNT_30",
+                        "AVITO_DISCOUNT_50",
+                        "AVITO_DISCOUNT_70"
+                    ]
+                }
+            }
+        },
+        "avito-rest-api_internal_service.SegmentUpdateInput": {
+            "type": "object",
+            "properties": {
+                "active_until": {
+                    "description": "Дата и время автоматического удаления сегмента, пустая строка отменяет автоматическое удаление",
+                    "type": "string",
+                    "example": "00:00:00 01.01.2024"
+                },
+                "default_ttl": {
+                    "description": "Срок вхождения пользователя в сегмент по умолчанию, пустая строка удаляет срок",
+                    "type": "string",
+                    "example": "30d"
+                },
+                "description": {
+                    "description": "Описание назначения сегмента, пустая строка удаляет описание",
+                    "type": "string",
+                    "example": "Скидка 45% на товары маркета"
+                },
+                "owner": {
+                    "description": "Команда, владеющая сегментом, пустая строка удаляет владельца",
+                    "type": "string",
+                    "example": "market-team"
+                },
+                "tags": {
+                    "description": "Теги сегмента, заменяющие прежние, пустой список удаляет теги",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "market",
+                        "discount"
+                    ]
+                }
+            }
+        },
+        "avito-rest-api_internal_service.SegmentUsersAddInput": {
             "type": "object",
             "properties": {
                 "end_date": {
-                    "description": "Дата выхода пользователя из сегмента",
+                    "description": "Необязательное поле, дата и время выхода пользователей из сегмента",
+                    "type": "string",
+                    "example": "15:00:00 31.12.2023"
+                },
+                "user_ids": {
+                    "description": "Идентификаторы добавляемых пользователей",
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    },
+                    "example": [
+                        1,
+                        2,
+                        3
+                    ]
+                }
+            }
+        },
+        "avito-rest-api_internal_service.SegmentUsersDeleteInput": {
+            "type": "object",
+            "properties": {
+                "user_ids": {
+                    "description": "Идентификаторы удаляемых пользователей",
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    },
+                    "example": [
+                        1,
+                        2,
+                        3
+                    ]
+                }
+            }
+        },
+        "avito-rest-api_internal_service.UserCreateInput": {
+            "type": "object",
+            "required": [
+                "age",
+                "lastname",
+                "name",
+                "sex"
+            ],
+            "properties": {
+                "age": {
+                    "description": "Целое положительное число",
+                    "type": "integer",
+                    "example": 27
+                },
+                "attributes": {
+                    "description": "Необязательное поле, дополнительные атрибуты пользователя: строки, числа или логические значения",
+                    "type": "object"
+                },
+                "external_id": {
+                    "description": "Необязательное поле, уникальный идентификатор пользователя во внешней системе",
+                    "type": "string",
+                    "example": "crm-1842"
+                },
+                "lastname": {
+                    "type": "string",
+                    "example": "Иванов"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "Михаил"
+                },
+                "sex": {
+                    "description": "Пол, 0 - мужской, 1 - женский",
+                    "type": "integer",
+                    "enum": [
+                        0,
+                        1
+                    ],
+                    "example": 0
+                }
+            }
+        },
+        "avito-rest-api_internal_service.UserUpdateInput": {
+            "type": "object",
+            "properties": {
+                "age": {
+                    "description": "Целое положительное число",
+                    "type": "integer",
+                    "example": 27
+                },
+                "attributes": {
+                    "description": "Новые дополнительные атрибуты пользователя, заменяющие текущие целиком",
+                    "type": "object"
+                },
+                "lastname": {
+                    "type": "string",
+                    "example": "Иванов"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "Михаил"
+                },
+                "sex": {
+                    "description": "Пол, 0 - мужской, 1 - женский",
+                    "type": "integer",
+                    "enum": [
+                        0,
+                        1
+                    ],
+                    "example": 0
+                }
+            }
+        },
+        "internal_controller_http_v1.AddUserToSegmentsInput": {
+            "type": "object",
+            "properties": {
+                "external_id": {
+                    "description": "Внешний идентификатор пользователя, указывается вместо \"id\"",
                     "type": "string",
-                    "example": ""
+                    "example": "crm-1842"
                 },
-                "information_id": {
-                    "description": "ID записи в ассоциативной таблице, связывающей пользователей с сегментами",
+                "id": {
+                    "description": "Идентификатор пользователя",
                     "type": "integer",
-                    "example": 179
+                    "example": 16
                 },
-                "name": {
-                    "description": "Наименование сегмента",
-                    "type": "string",
-                    "example": "AVITO_MUSIC_SERVICE"
+                "replace_exclusive": {
+                    "description": "Необязательное поле, если true, то при добавлении в сегмент группы взаимоисключающих сегментов пользователь\nвыходит из сегмента этой группы, в который входил ранее. Иначе такое добавление отклоняется",
+                    "type": "boolean",
+                    "example": false
                 },
-                "segment_id": {
-                    "description": "ID сегмента",
+                "segments": {
+                    "description": "Сегменты, в которые необходимо добавить пользователя",
+                    "type": "array",
+                    "items": {
+                        "type": "object",
+                        "required": [
+                            "name"
+                        ],
+                        "properties": {
+                            "end_date": {
+                                "description": "Необязательное поле, если отсутствует, значит дата выхода пользователя из сегмента не определена",
+                                "type": "string",
+                                "example": "10:00:00 25.09.2023"
+                            },
+                            "name": {
+                                "description": "Наименование сегмента, в который необходимо добавить пользователя",
+                                "type": "string",
+                                "example": "AVITO_MUSIC_SERVICE"
+                            },
+                            "start_date": {
+                                "description": "Необязательное поле, если отсутствует, значит пользователь входит в сегмент немедленно",
+                                "type": "string",
+                                "example": "10:00:00 18.09.2023"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "internal_controller_http_v1.AddUserToSegmentsResponse": {
+            "type": "object",
+            "properties": {
+                "message": {
+                    "type": "string",
+                    "example": "user 16 was successfully added to the segments"
+                }
+            }
+        },
+        "internal_controller_http_v1.AddUsersToSegmentResponse": {
+            "type": "object",
+            "properties": {
+                "added": {
+                    "description": "Количество добавленных пользователей",
                     "type": "integer",
-                    "example": 43
+                    "example": 49998
                 },
-                "start_date": {
-                    "description": "Дата добавления пользователя в сегмент",
+                "already_members": {
+                    "description": "Пропущенные пользователи, уже входящие в сегмент",
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    },
+                    "example": [
+                        12,
+                        40
+                    ]
+                },
+                "dry_run": {
+                    "description": "Признак пробного запуска, при котором изменения не сохраняются",
+                    "type": "boolean",
+                    "example": true
+                },
+                "user_ids": {
+                    "description": "При пробном запуске - пользователи, которые были бы добавлены",
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    },
+                    "example": [
+                        1,
+                        2,
+                        3
+                    ]
+                }
+            }
+        },
+        "internal_controller_http_v1.CreateDerivedSegmentResponse": {
+            "type": "object",
+            "properties": {
+                "name": {
                     "type": "string",
-                    "example": "15:27:32 01.09.2023"
+                    "example": "AVITO_MUSIC_AND_VOICE"
                 },
-                "user_id": {
-                    "description": "ID пользователя, который входит в сегмент",
+                "users_count": {
+                    "description": "Количество пользователей, входящих в сегмент на момент создания",
                     "type": "integer",
-                    "example": 16
+                    "example": 120
                 }
             }
         },
-        "avito-rest-api_internal_entity.UserWithSegments": {
+        "internal_controller_http_v1.CreateResponse": {
+            "type": "object",
+            "required": [
+                "name"
+            ],
+            "properties": {
+                "name": {
+                    "type": "string",
+                    "example": "AVITO_MUSIC_SERVICE"
+                }
+            }
+        },
+        "internal_controller_http_v1.DeleteSegmentByNameResponse": {
+            "type": "object",
+            "properties": {
+                "message": {
+                    "type": "string",
+                    "example": "successfully deleted segment \"AVITO_MUSIC_SERVICE\""
+                }
+            }
+        },
+        "internal_controller_http_v1.DeleteUserFromSegmentsInput": {
             "type": "object",
             "properties": {
+                "external_id": {
+                    "description": "Внешний идентификатор пользователя, указывается вместо \"id\"",
+                    "type": "string",
+                    "example": "crm-1842"
+                },
+                "id": {
+                    "description": "Идентификатор пользователя",
+                    "type": "integer",
+                    "example": 16
+                },
                 "segments": {
+                    "description": "Сегменты, из которых необходимо удалить пользователя",
                     "type": "array",
                     "items": {
-                        "$ref": "#/definitions/avito-rest-api_internal_entity.UserSegmentInformation"
+                        "type": "object",
+                        "required": [
+                            "name"
+                        ],
+                        "properties": {
+                            "name": {
+                                "description": "Наименование сегмента",
+                                "type": "string",
+                                "example": "AVITO_MUSIC_SERVICE"
+                            }
+                        }
                     }
-                },
-                "user": {
-                    "$ref": "#/definitions/avito-rest-api_internal_entity.User"
                 }
             }
         },
-        "avito-rest-api_internal_error.ErrInternalServerError": {
+        "internal_controller_http_v1.DeleteUserFromSegmentsResponse": {
             "type": "object",
             "properties": {
-                "comment": {
-                    "type": "string"
-                },
-                "location": {
-                    "type": "string"
-                },
-                "origin_error_text": {
-                    "type": "string"
-                },
-                "title": {
-                    "type": "string"
+                "message": {
+                    "type": "string",
+                    "example": "user 179 was successfully removed from segments"
                 }
             }
         },
-        "avito-rest-api_internal_error.ErrReportValidationError": {
+        "internal_controller_http_v1.DeleteUserResponse": {
             "type": "object",
             "properties": {
-                "comment": {
-                    "type": "string"
-                },
-                "location": {
-                    "type": "string"
-                },
-                "origin_error_text": {
-                    "type": "string"
-                },
-                "title": {
-                    "type": "string"
+                "message": {
+                    "type": "string",
+                    "example": "user 16 was successfully deleted"
                 }
             }
         },
-        "avito-rest-api_internal_error.ErrSegmentNotFound": {
+        "internal_controller_http_v1.DeleteUsersFromSegmentResponse": {
             "type": "object",
             "properties": {
-                "comment": {
-                    "type": "string"
+                "deleted": {
+                    "description": "Количество пользователей, вышедших из сегмента",
+                    "type": "integer",
+                    "example": 49998
                 },
-                "location": {
-                    "type": "string"
+                "dry_run": {
+                    "description": "Признак пробного запуска, при котором изменения не сохраняются",
+                    "type": "boolean",
+                    "example": true
                 },
-                "origin_error_text": {
-                    "type": "string"
+                "not_members": {
+                    "description": "Пропущенные пользователи, не входящие в сегмент",
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    },
+                    "example": [
+                        12,
+                        40
+                    ]
                 },
-                "title": {
-                    "type": "string"
+                "user_ids": {
+                    "description": "При пробном запуске - пользователи, которые вышли бы из сегмента",
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    },
+                    "example": [
+                        1,
+                        2,
+                        3
+                    ]
                 }
             }
         },
-        "avito-rest-api_internal_error.ErrSegmentValidationError": {
+        "internal_controller_http_v1.ExperimentReportResponse": {
             "type": "object",
             "properties": {
-                "comment": {
-                    "type": "string"
+                "report": {
+                    "$ref": "#/definitions/avito-rest-api_internal_entity.ExperimentReport"
+                }
+            }
+        },
+        "internal_controller_http_v1.ExperimentResponse": {
+            "type": "object",
+            "properties": {
+                "experiment": {
+                    "$ref": "#/definitions/avito-rest-api_internal_entity.Experiment"
+                }
+            }
+        },
+        "internal_controller_http_v1.GetAllSegmentsResponse": {
+            "type": "object",
+            "properties": {
+                "segments": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/avito-rest-api_internal_entity.Segment"
+                    }
+                }
+            }
+        },
+        "internal_controller_http_v1.GetAllUsersResponse": {
+            "type": "object",
+            "properties": {
+                "next_cursor": {
+                    "description": "Курсор следующей страницы, отсутствует на последней странице",
+                    "type": "string",
+                    "example": "eyJzIjoiYWdlIiwidiI6MjcsImlkIjoxNn0"
                 },
-                "location": {
-                    "type": "string"
+                "users": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/avito-rest-api_internal_entity.User"
+                    }
+                }
+            }
+        },
+        "internal_controller_http_v1.GetAllUsersWithSegmentsResponse": {
+            "type": "object",
+            "properties": {
+                "next_cursor": {
+                    "description": "Курсор следующей страницы, отсутствует на последней странице",
+                    "type": "string",
+                    "example": "MTY"
                 },
-                "origin_error_text": {
-                    "type": "string"
+                "users": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/avito-rest-api_internal_entity.UserWithSegments"
+                    }
+                }
+            }
+        },
+        "internal_controller_http_v1.GetSegmentByNameResponse": {
+            "type": "object",
+            "properties": {
+                "segment": {
+                    "$ref": "#/definitions/avito-rest-api_internal_entity.Segment"
+                }
+            }
+        },
+        "internal_controller_http_v1.GetSegmentUsersResponse": {
+            "type": "object",
+            "properties": {
+                "next_cursor": {
+                    "description": "Курсор следующей страницы, отсутствует на последней странице",
+                    "type": "string",
+                    "example": "MTc5"
                 },
-                "title": {
-                    "type": "string"
+                "users": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/avito-rest-api_internal_entity.SegmentMember"
+                    }
+                }
+            }
+        },
+        "internal_controller_http_v1.GetUserByIDResponse": {
+            "type": "object",
+            "properties": {
+                "user": {
+                    "$ref": "#/definitions/avito-rest-api_internal_entity.User"
+                }
+            }
+        },
+        "internal_controller_http_v1.GetUserByIDWithSegmentsResponse": {
+            "type": "object",
+            "properties": {
+                "user": {
+                    "$ref": "#/definitions/avito-rest-api_internal_entity.UserWithSegments"
+                }
+            }
+        },
+        "internal_controller_http_v1.GetUserSegmentsAtResponse": {
+            "type": "object",
+            "properties": {
+                "segments": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/avito-rest-api_internal_entity.UserSegmentInformation"
+                    }
                 }
             }
         },
-        "avito-rest-api_internal_error.ErrUserNotFound": {
+        "internal_controller_http_v1.ImportUsersResponse": {
             "type": "object",
             "properties": {
-                "comment": {
-                    "type": "string"
-                },
-                "location": {
-                    "type": "string"
+                "errors": {
+                    "description": "Ошибки строк, не прошедших валидацию",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/avito-rest-api_internal_entity.UserImportError"
+                    }
                 },
-                "origin_error_text": {
-                    "type": "string"
+                "failed": {
+                    "description": "Количество строк, не прошедших валидацию",
+                    "type": "integer",
+                    "example": 2
                 },
-                "title": {
-                    "type": "string"
+                "imported": {
+                    "description": "Количество импортированных пользователей",
+                    "type": "integer",
+                    "example": 9998
                 }
             }
         },
-        "avito-rest-api_internal_error.ErrUserValidationError": {
+        "internal_controller_http_v1.MakeReportResponse": {
             "type": "object",
             "properties": {
-                "comment": {
-                    "type": "string"
-                },
-                "location": {
-                    "type": "string"
-                },
-                "origin_error_text": {
+                "report": {
+                    "description": "Отчёт в виде csv-строки с разделителями \",\" и символом перехода на новую строку \"\\n\"",
                     "type": "string"
                 },
-                "title": {
+                "report_date": {
+                    "description": "Дата формирования отчёта",
                     "type": "string"
                 }
             }
         },
-        "avito-rest-api_internal_service.SegmentCreateInput": {
+        "internal_controller_http_v1.PreviewSegmentResponse": {
             "type": "object",
-            "required": [
-                "name"
-            ],
             "properties": {
+                "dry_run": {
+                    "type": "boolean",
+                    "example": true
+                },
                 "name": {
-                    "description": "Имя сегмента",
                     "type": "string",
                     "example": "AVITO_MUSIC_SERVICE"
                 },
-                "percentage": {
-                    "description": "Необязательное поле, процент пользователей, которое автоматически войдёт в сегмент при его создании",
+                "salt": {
+                    "description": "Соль раскатки, которую нужно передать при создании, чтобы состав совпал",
+                    "type": "string",
+                    "example": "9e107d9d372bb6826bd81d3542a419d6"
+                },
+                "user_ids": {
+                    "description": "Пользователи, которые вошли бы в сегмент, по возрастанию ID",
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    },
+                    "example": [
+                        1,
+                        2,
+                        3
+                    ]
+                },
+                "users_count": {
+                    "description": "Количество пользователей, которые вошли бы в сегмент",
                     "type": "integer",
-                    "maximum": 100,
-                    "minimum": 0,
-                    "example": 57
+                    "example": 3
                 }
             }
         },
-        "avito-rest-api_internal_service.UserCreateInput": {
+        "internal_controller_http_v1.PurgeSegmentResponse": {
             "type": "object",
-            "required": [
-                "age",
-                "lastname",
-                "name",
-                "sex"
-            ],
             "properties": {
-                "age": {
-                    "description": "Целое положительное число",
+                "archive": {
+                    "description": "Ссылка на файл архива либо, если облачное хранилище не настроено, содержимое архива",
+                    "type": "string",
+                    "example": "https://drive.google.com/file/d/1a2b3c/view?usp=sharing"
+                },
+                "archived_rows": {
+                    "description": "Количество записей о вхождении пользователей в архиве",
                     "type": "integer",
-                    "example": 27
+                    "example": 120
                 },
-                "lastname": {
+                "format": {
                     "type": "string",
-                    "example": "Иванов"
+                    "example": "csv"
                 },
                 "name": {
                     "type": "string",
-                    "example": "Михаил"
-                },
-                "sex": {
-                    "description": "Пол, 0 - мужской, 1 - женский",
-                    "type": "integer",
-                    "enum": [
-                        0,
-                        1
-                    ],
-                    "example": 0
+                    "example": "AVITO_MUSIC_SERVICE"
                 }
             }
         },
-        "internal_controller_http_v1.AddUserToSegmentsInput": {
+        "internal_controller_http_v1.RenameSegmentInput": {
             "type": "object",
             "required": [
-                "id"
+                "name"
             ],
             "properties": {
-                "id": {
-                    "description": "Идентификатор пользователя",
-                    "type": "integer",
-                    "example": 16
-                },
-                "segments": {
-                    "description": "Сегменты, в которые необходимо добавить пользователя",
-                    "type": "array",
-                    "items": {
-                        "type": "object",
-                        "required": [
-                            "name"
-                        ],
-                        "properties": {
-                            "end_date": {
-                                "description": "Необязательное поле, если отсутствует, значит дата выхода пользователя из сегмента не определена",
-                                "type": "string",
-                                "example": "10:00:00 25.09.2023"
-                            },
-                            "name": {
-                                "description": "Наименование сегмента, в который необходимо добавить пользователя",
-                                "type": "string",
-                                "example": "AVITO_MUSIC_SERVICE"
-                            }
-                        }
-                    }
+                "name": {
+                    "description": "Новое имя сегмента",
+                    "type": "string",
+                    "example": "AVITO_MUSIC_PREMIUM"
                 }
             }
         },
-        "internal_controller_http_v1.AddUserToSegmentsResponse": {
+        "internal_controller_http_v1.RenameSegmentResponse": {
             "type": "object",
             "properties": {
-                "message": {
-                    "type": "string",
-                    "example": "user 16 was successfully added to the segments"
+                "former_names": {
+                    "description": "История переименований сегмента от ранних к поздним",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/avito-rest-api_internal_entity.SegmentNameChange"
+                    }
+                },
+                "segment": {
+                    "$ref": "#/definitions/avito-rest-api_internal_entity.Segment"
                 }
             }
         },
-        "internal_controller_http_v1.CreateResponse": {
+        "internal_controller_http_v1.RestoreUserResponse": {
             "type": "object",
-            "required": [
-                "name"
-            ],
             "properties": {
-                "name": {
-                    "type": "string",
-                    "example": "AVITO_MUSIC_SERVICE"
+                "user": {
+                    "$ref": "#/definitions/avito-rest-api_internal_entity.User"
                 }
             }
         },
-        "internal_controller_http_v1.DeleteSegmentByNameResponse": {
+        "internal_controller_http_v1.SegmentGroupResponse": {
             "type": "object",
             "properties": {
-                "message": {
-                    "type": "string",
-                    "example": "successfully deleted segment \"AVITO_MUSIC_SERVICE\""
+                "group": {
+                    "$ref": "#/definitions/avito-rest-api_internal_entity.SegmentGroup"
                 }
             }
         },
-        "internal_controller_http_v1.DeleteUserFromSegmentsInput": {
+        "internal_controller_http_v1.UpdateSegmentGroupSegmentsInput": {
             "type": "object",
-            "required": [
-                "id"
-            ],
             "properties": {
-                "id": {
-                    "description": "Идентификатор пользователя",
-                    "type": "integer",
-                    "example": 16
-                },
                 "segments": {
-                    "description": "Сегменты, из которых необходимо удалить пользователя",
+                    "description": "Наименования сегментов группы",
                     "type": "array",
                     "items": {
-                        "type": "object",
-                        "required": [
-                            "name"
-                        ],
-                        "properties": {
-                            "name": {
-                                "description": "Наименование сегмента",
-                                "type": "string",
-                                "example": "AVITO_MUSIC_SERVICE"
-                            }
-                        }
-                    }
+                        "type": "string"
+                    },
+                    "example": [
+                        "AVITO_DISCOUNT_30",
+                        "AVITO_DISCOUNT_50"
+                    ]
                 }
             }
         },
-        "internal_controller_http_v1.DeleteUserFromSegmentsResponse": {
+        "internal_controller_http_v1.UpdateSegmentPercentageInput": {
             "type": "object",
             "properties": {
-                "message": {
-                    "type": "string",
-                    "example": "user 179 was successfully removed from segments"
+                "percentage": {
+                    "description": "Новый процент раскатки сегмента. Значение null отключает раскатку",
+                    "type": "integer",
+                    "maximum": 100,
+                    "minimum": 0,
+                    "x-nullable": true,
+                    "example": 20
                 }
             }
         },
-        "internal_controller_http_v1.GetAllSegmentsResponse": {
+        "internal_controller_http_v1.UpdateSegmentPercentageResponse": {
             "type": "object",
             "properties": {
-                "segments": {
-                    "type": "array",
-                    "items": {
-                        "$ref": "#/definitions/avito-rest-api_internal_entity.Segment"
-                    }
+                "segment": {
+                    "$ref": "#/definitions/avito-rest-api_internal_entity.Segment"
                 }
             }
         },
-        "internal_controller_http_v1.GetAllUsersResponse": {
+        "internal_controller_http_v1.UpdateSegmentResponse": {
             "type": "object",
             "properties": {
-                "users": {
-                    "type": "array",
-                    "items": {
-                        "$ref": "#/definitions/avito-rest-api_internal_entity.User"
-                    }
+                "segment": {
+                    "$ref": "#/definitions/avito-rest-api_internal_entity.Segment"
                 }
             }
         },
-        "internal_controller_http_v1.GetAllUsersWithSegmentsResponse": {
+        "internal_controller_http_v1.UpdateSegmentRulesInput": {
             "type": "object",
             "properties": {
-                "users": {
+                "rules": {
+                    "description": "Новые правила сегмента, объединяемые через AND. Пустой список удаляет правила",
                     "type": "array",
                     "items": {
-                        "$ref": "#/definitions/avito-rest-api_internal_entity.UserWithSegments"
+                        "$ref": "#/definitions/avito-rest-api_internal_entity.SegmentRule"
                     }
                 }
             }
         },
-        "internal_controller_http_v1.GetSegmentByNameResponse": {
+        "internal_controller_http_v1.UpdateSegmentRulesResponse": {
             "type": "object",
             "properties": {
                 "segment": {
@@ -931,7 +3694,7 @@ const docTemplate = `{
                 }
             }
         },
-        "internal_controller_http_v1.GetUserByIDResponse": {
+        "internal_controller_http_v1.UpdateUserResponse": {
             "type": "object",
             "properties": {
                 "user": {
@@ -939,24 +3702,92 @@ const docTemplate = `{
                 }
             }
         },
-        "internal_controller_http_v1.GetUserByIDWithSegmentsResponse": {
+        "internal_controller_http_v1.UpdateUserSegmentInput": {
             "type": "object",
             "properties": {
-                "user": {
-                    "$ref": "#/definitions/avito-rest-api_internal_entity.UserWithSegments"
+                "end_date": {
+                    "description": "Новая дата выхода пользователя из сегмента, пустая строка делает вхождение бессрочным",
+                    "type": "string",
+                    "example": "10:00:00 25.10.2023"
                 }
             }
         },
-        "internal_controller_http_v1.MakeReportResponse": {
+        "internal_controller_http_v1.UpdateUserSegmentResponse": {
             "type": "object",
             "properties": {
-                "report": {
-                    "description": "Отчёт в виде csv-строки с разделителями \",\" и символом перехода на новую строку \"\\n\"",
-                    "type": "string"
+                "segment": {
+                    "$ref": "#/definitions/avito-rest-api_internal_entity.UserSegmentInformation"
+                }
+            }
+        },
+        "internal_controller_http_v1.UpdateUserSegmentsInput": {
+            "type": "object",
+            "properties": {
+                "add": {
+                    "description": "Сегменты, в которые необходимо добавить пользователя",
+                    "type": "array",
+                    "items": {
+                        "type": "object",
+                        "required": [
+                            "name"
+                        ],
+                        "properties": {
+                            "end_date": {
+                                "description": "Необязательное поле, если отсутствует, значит дата выхода пользователя из сегмента не определена",
+                                "type": "string",
+                                "example": "10:00:00 25.09.2023"
+                            },
+                            "name": {
+                                "description": "Наименование сегмента, в который необходимо добавить пользователя",
+                                "type": "string",
+                                "example": "AVITO_MUSIC_SERVICE"
+                            },
+                            "start_date": {
+                                "description": "Необязательное поле, если отсутствует, значит пользователь входит в сегмент немедленно",
+                                "type": "string",
+                                "example": "10:00:00 18.09.2023"
+                            }
+                        }
+                    }
                 },
-                "report_date": {
-                    "description": "Дата формирования отчёта",
-                    "type": "string"
+                "delete": {
+                    "description": "Сегменты, из которых необходимо удалить пользователя",
+                    "type": "array",
+                    "items": {
+                        "type": "object",
+                        "required": [
+                            "name"
+                        ],
+                        "properties": {
+                            "name": {
+                                "description": "Наименование сегмента",
+                                "type": "string",
+                                "example": "AVITO_VOICE_MESSAGES"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "internal_controller_http_v1.UpdateUserSegmentsResponse": {
+            "type": "object",
+            "properties": {
+                "message": {
+                    "type": "string",
+                    "example": "segments of user 16 were successfully updated"
+                }
+            }
+        },
+        "internal_controller_http_v1.UpsertUserByExternalIDResponse": {
+            "type": "object",
+            "properties": {
+                "created": {
+                    "description": "true, если пользователь был создан, false - если обновлён",
+                    "type": "boolean",
+                    "example": true
+                },
+                "user": {
+                    "$ref": "#/definitions/avito-rest-api_internal_entity.User"
                 }
             }
         },
@@ -968,6 +3799,14 @@ const docTemplate = `{
                     "example": 26
                 }
             }
+        },
+        "internal_controller_http_v1.UserVariantResponse": {
+            "type": "object",
+            "properties": {
+                "user_variant": {
+                    "$ref": "#/definitions/avito-rest-api_internal_entity.UserExperimentVariant"
+                }
+            }
         }
     }
 }`
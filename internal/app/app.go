@@ -5,7 +5,9 @@ import (
 	v1 "avito-rest-api/internal/controller/http/v1"
 	"avito-rest-api/internal/repository"
 	"avito-rest-api/internal/service"
+	"avito-rest-api/internal/webapi/eventlog"
 	"avito-rest-api/internal/webapi/gdrive"
+	"avito-rest-api/internal/worker"
 	"avito-rest-api/package/httpserver"
 	"avito-rest-api/package/postgres"
 	"fmt"
@@ -14,6 +16,7 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 )
 
 func Run(configPath string) {
@@ -45,14 +48,36 @@ func Run(configPath string) {
 	log.Info("Initializing repositories...")
 	repositories := repository.NewRepositories(pg)
 
+	// Окончательное удаление сегментов по истечении срока хранения невозможно без хранилища архивов
+	segmentPurgeRetention := time.Duration(cfg.Segment.DeletedRetentionDays) * 24 * time.Hour
+	if segmentPurgeRetention > 0 && cfg.WebAPI.GDriveJSONFilePath == "" {
+		log.Warn("Purging of deleted segments is disabled because Google Drive is not configured")
+		segmentPurgeRetention = 0
+	}
+
 	// Инициализация сервисов
 	log.Info("Initializing services...")
 	dependencies := service.ServicesDependencies{
 		Repositories: repositories,
 		GDrive:       gdrive.New(cfg.WebAPI.GDriveJSONFilePath),
+		Events:       eventlog.New(log.StandardLogger()),
+
+		ExpiryBatchSize:          cfg.Worker.ExpiryBatchSize,
+		SegmentRenameGracePeriod: cfg.Segment.RenameGracePeriod,
+		SegmentPurgeRetention:    segmentPurgeRetention,
+		SegmentArchiveFormat:     cfg.Segment.ArchiveFormat,
+		SegmentPurgeBatchSize:    cfg.Worker.PurgeBatchSize,
 	}
 	services := service.NewService(dependencies)
 
+	// Фоновый обработчик истёкших вхождений пользователей в сегменты
+	log.Info("Starting expiry worker...")
+	expiryWorker := worker.NewExpiryWorker(services.Expiry, cfg.Worker.ExpiryInterval)
+
+	// Фоновый обработчик окончательного удаления сегментов по истечении срока хранения
+	log.Info("Starting purge worker...")
+	purgeWorker := worker.NewPurgeWorker(services.SegmentPurge, cfg.Worker.PurgeInterval)
+
 	// Echo-обработчик
 	log.Info("Initializing echo...")
 	handler := echo.New()
@@ -81,4 +106,6 @@ func Run(configPath string) {
 	if err != nil {
 		log.Errorf("app - Run - httpServer.Shutdown: %s", err)
 	}
+	expiryWorker.Shutdown()
+	purgeWorker.Shutdown()
 }
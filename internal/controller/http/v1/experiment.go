@@ -0,0 +1,142 @@
+package v1
+
+import (
+	"avito-rest-api/internal/entity"
+	customError "avito-rest-api/internal/error"
+	"avito-rest-api/internal/service"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"strconv"
+)
+
+type experimentRoutes struct {
+	experimentService service.Experiment
+}
+
+func newExperimentRoutes(g *echo.Group, experimentService service.Experiment) {
+	r := &experimentRoutes{
+		experimentService: experimentService,
+	}
+
+	g.POST("", r.create)
+	g.GET("/:name", r.getByName)
+	g.GET("/:name/report", r.getReport)
+	g.GET("/:name/users/:id", r.getUserVariant)
+}
+
+type ExperimentResponse struct {
+	Experiment entity.Experiment `json:"experiment"`
+}
+
+// @Summary Создать эксперимент
+// @Description Создаёт A/B эксперимент с указанными вариантами. Для каждого варианта создаётся сегмент
+// @Description с именем `<имя эксперимента>_<имя варианта>`; удалённый сегмент с таким именем восстанавливается,
+// @Description как при создании сегмента, если он не входит в группу взаимоисключающих сегментов. Каждый существующий и каждый созданный позднее
+// @Description пользователь детерминированно распределяется ровно в один вариант: доля пользователей варианта
+// @Description равна его весу, делённому на сумму весов всех вариантов.
+// @Tags experiments
+// @Accept json
+// @Produce json
+// @Param data body service.ExperimentCreateInput true "Структура с информацией о создаваемом эксперименте"
+// @Success 201 {object} ExperimentResponse "Созданный эксперимент"
+// @Failure 400 {object} customError.ErrExperimentValidationError "Ошибка валидации данных запроса"
+// @Failure 400 {object} customError.ErrExperimentAlreadyExists "Эксперимент с указанным именем уже существует"
+// @Failure 400 {object} customError.ErrSegmentAlreadyExists "Сегмент для одного из вариантов уже существует"
+// @Failure 500 {object} customError.ErrInternalServerError "Внутренняя ошибка сервера"
+// @Router /api/v1/experiments [post]
+func (r *experimentRoutes) create(c echo.Context) error {
+	var input service.ExperimentCreateInput
+
+	if err := c.Bind(&input); err != nil {
+		return errorHandler(c, customError.ErrExperimentValidationError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         "Failed to parse request's body",
+			Location:        "ExperimentRoutes.create - c.Bind",
+		}})
+	}
+
+	experiment, err := r.experimentService.CreateExperiment(c.Request().Context(), input)
+	if err != nil {
+		return errorHandler(c, err)
+	}
+
+	return c.JSON(http.StatusCreated, ExperimentResponse{Experiment: experiment})
+}
+
+// @Summary Получить эксперимент
+// @Description Возвращает эксперимент с указанным именем вместе с его вариантами
+// @Tags experiments
+// @Produce json
+// @Param name path string true "Наименование эксперимента"
+// @Success 200 {object} ExperimentResponse "Эксперимент"
+// @Failure 404 {object} customError.ErrExperimentNotFound "Эксперимент с указанным именем не был найден"
+// @Failure 500 {object} customError.ErrInternalServerError "Внутренняя ошибка сервера"
+// @Router /api/v1/experiments/{name} [get]
+func (r *experimentRoutes) getByName(c echo.Context) error {
+	experiment, err := r.experimentService.GetExperimentByName(c.Request().Context(), c.Param("name"))
+	if err != nil {
+		return errorHandler(c, err)
+	}
+
+	return c.JSON(http.StatusOK, ExperimentResponse{Experiment: experiment})
+}
+
+type ExperimentReportResponse struct {
+	Report entity.ExperimentReport `json:"report"`
+}
+
+// @Summary Получить отчёт по эксперименту
+// @Description Возвращает количество пользователей, входящих на текущий момент в каждый из вариантов эксперимента
+// @Tags experiments
+// @Produce json
+// @Param name path string true "Наименование эксперимента"
+// @Success 200 {object} ExperimentReportResponse "Размеры вариантов эксперимента"
+// @Failure 404 {object} customError.ErrExperimentNotFound "Эксперимент с указанным именем не был найден"
+// @Failure 500 {object} customError.ErrInternalServerError "Внутренняя ошибка сервера"
+// @Router /api/v1/experiments/{name}/report [get]
+func (r *experimentRoutes) getReport(c echo.Context) error {
+	report, err := r.experimentService.GetExperimentReport(c.Request().Context(), c.Param("name"))
+	if err != nil {
+		return errorHandler(c, err)
+	}
+
+	return c.JSON(http.StatusOK, ExperimentReportResponse{Report: report})
+}
+
+type UserVariantResponse struct {
+	UserVariant entity.UserExperimentVariant `json:"user_variant"`
+}
+
+// @Summary Получить вариант пользователя
+// @Description Возвращает вариант эксперимента, в который распределён пользователь с указанным ID
+// @Tags experiments
+// @Produce json
+// @Param name path string true "Наименование эксперимента"
+// @Param id path int true "ID пользователя"
+// @Success 200 {object} UserVariantResponse "Вариант пользователя"
+// @Failure 400 {object} customError.ErrUserValidationError "Некорректный ID пользователя"
+// @Failure 400 {object} customError.ErrUserDeleted "Пользователь удалён"
+// @Failure 404 {object} customError.ErrExperimentNotFound "Эксперимент с указанным именем не был найден"
+// @Failure 404 {object} customError.ErrUserNotFound "Пользователь с указанным ID не был найден"
+// @Failure 404 {object} customError.ErrExperimentVariantNotFound "Пользователь не распределён ни в один вариант эксперимента"
+// @Failure 500 {object} customError.ErrInternalServerError "Внутренняя ошибка сервера"
+// @Router /api/v1/experiments/{name}/users/{id} [get]
+func (r *experimentRoutes) getUserVariant(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return errorHandler(c, customError.ErrUserValidationError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         "Invalid \"id\" path-param value. \"id\" should be integer",
+			Location:        "ExperimentRoutes.getUserVariant - strconv.Atoi",
+		}})
+	}
+
+	userVariant, err := r.experimentService.GetUserVariant(c.Request().Context(), c.Param("name"), id)
+	if err != nil {
+		return errorHandler(c, err)
+	}
+
+	return c.JSON(http.StatusOK, UserVariantResponse{UserVariant: userVariant})
+}
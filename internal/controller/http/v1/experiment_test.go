@@ -0,0 +1,389 @@
+package v1
+
+import (
+	"avito-rest-api/internal/entity"
+	customError "avito-rest-api/internal/error"
+	"avito-rest-api/internal/service"
+	mock_service "avito-rest-api/internal/service/mocks"
+	"bytes"
+	"context"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestExperimentRoutes_create(t *testing.T) {
+	type args struct {
+		ctx   context.Context
+		input service.ExperimentCreateInput
+	}
+
+	type MockBehaviour func(m *mock_service.MockExperiment, args args)
+
+	testCases := []struct {
+		name                 string
+		args                 args
+		inputBody            string
+		mockBehaviour        MockBehaviour
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name: "Ok",
+			args: args{
+				ctx: context.Background(),
+				input: service.ExperimentCreateInput{
+					Name: "AVITO_CHECKOUT",
+					Variants: []service.ExperimentVariantInput{
+						{Name: "A", Weight: 50},
+						{Name: "B", Weight: 50},
+					},
+				},
+			},
+			inputBody: `{"name":"AVITO_CHECKOUT","variants":[{"name":"A","weight":50},{"name":"B","weight":50}]}`,
+			mockBehaviour: func(m *mock_service.MockExperiment, args args) {
+				m.EXPECT().CreateExperiment(args.ctx, args.input).Return(entity.Experiment{
+					ID:   1,
+					Name: "AVITO_CHECKOUT",
+					Variants: []entity.ExperimentVariant{
+						{ID: 1, Name: "A", Weight: 50, SegmentID: 10, SegmentName: "AVITO_CHECKOUT_A"},
+						{ID: 2, Name: "B", Weight: 50, SegmentID: 11, SegmentName: "AVITO_CHECKOUT_B"},
+					},
+				}, nil)
+			},
+			expectedStatusCode:   201,
+			expectedResponseBody: `{"experiment":{"experiment_id":1,"name":"AVITO_CHECKOUT","variants":[{"variant_id":1,"name":"A","weight":50,"segment_id":10,"segment_name":"AVITO_CHECKOUT_A"},{"variant_id":2,"name":"B","weight":50,"segment_id":11,"segment_name":"AVITO_CHECKOUT_B"}]}}` + "\n",
+		},
+		{
+			name: "Not enough variants",
+			args: args{
+				ctx: context.Background(),
+				input: service.ExperimentCreateInput{
+					Name:     "AVITO_CHECKOUT",
+					Variants: []service.ExperimentVariantInput{{Name: "A", Weight: 100}},
+				},
+			},
+			inputBody: `{"name":"AVITO_CHECKOUT","variants":[{"name":"A","weight":100}]}`,
+			mockBehaviour: func(m *mock_service.MockExperiment, args args) {
+				m.EXPECT().CreateExperiment(args.ctx, args.input).Return(entity.Experiment{}, customError.ErrExperimentValidationError{ErrBase: customError.ErrBase{
+					Comment:  "Validation of experiment's data failed, experiment must have at least 2 variants",
+					Location: "ExperimentService.CreateExperiment",
+				}})
+			},
+			expectedStatusCode:   400,
+			expectedResponseBody: `{"origin_error_text":"","title":"ErrExperimentValidationError","comment":"Validation of experiment's data failed, experiment must have at least 2 variants","location":"ExperimentService.CreateExperiment"}` + "\n",
+		},
+		{
+			name: "Experiment already exists",
+			args: args{
+				ctx: context.Background(),
+				input: service.ExperimentCreateInput{
+					Name: "AVITO_CHECKOUT",
+					Variants: []service.ExperimentVariantInput{
+						{Name: "A", Weight: 50},
+						{Name: "B", Weight: 25},
+						{Name: "C", Weight: 25},
+					},
+				},
+			},
+			inputBody: `{"name":"AVITO_CHECKOUT","variants":[{"name":"A","weight":50},{"name":"B","weight":25},{"name":"C","weight":25}]}`,
+			mockBehaviour: func(m *mock_service.MockExperiment, args args) {
+				m.EXPECT().CreateExperiment(args.ctx, args.input).Return(entity.Experiment{}, customError.ErrExperimentAlreadyExists{ErrBase: customError.ErrBase{
+					Comment:  "Experiment with the given name \"AVITO_CHECKOUT\" already exists",
+					Location: "ExperimentService.CreateExperiment - GetExperimentByName",
+				}})
+			},
+			expectedStatusCode:   400,
+			expectedResponseBody: `{"origin_error_text":"","title":"ErrExperimentAlreadyExists","comment":"Experiment with the given name \"AVITO_CHECKOUT\" already exists","location":"ExperimentService.CreateExperiment - GetExperimentByName"}` + "\n",
+		},
+		{
+			name:                 "Invalid body",
+			args:                 args{ctx: context.Background()},
+			inputBody:            `{"name":"AVITO_CHECKOUT","variants":[`,
+			mockBehaviour:        func(m *mock_service.MockExperiment, args args) {},
+			expectedStatusCode:   400,
+			expectedResponseBody: `{"origin_error_text":"code=400, message=unexpected EOF, internal=unexpected EOF","title":"ErrExperimentValidationError","comment":"Failed to parse request's body","location":"ExperimentRoutes.create - c.Bind"}` + "\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Инициализация зависимостей
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			// Инициализация мока сервиса
+			experiment := mock_service.NewMockExperiment(ctrl)
+			tc.mockBehaviour(experiment, tc.args)
+			services := &service.Services{Experiment: experiment}
+
+			// Создание тестового сервера
+			e := echo.New()
+			g := e.Group("/experiments")
+			newExperimentRoutes(g, services.Experiment)
+
+			// Создание запроса
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/experiments", bytes.NewBufferString(tc.inputBody))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+
+			// Выполнение запроса
+			e.ServeHTTP(w, req)
+
+			// Проверка ответа
+			assert.Equal(t, tc.expectedStatusCode, w.Code)
+			assert.Equal(t, tc.expectedResponseBody, w.Body.String())
+		})
+	}
+}
+
+func TestExperimentRoutes_getByName(t *testing.T) {
+	type args struct {
+		ctx  context.Context
+		name string
+	}
+
+	type MockBehaviour func(m *mock_service.MockExperiment, args args)
+
+	testCases := []struct {
+		name                 string
+		args                 args
+		mockBehaviour        MockBehaviour
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name: "Ok",
+			args: args{ctx: context.Background(), name: "AVITO_CHECKOUT"},
+			mockBehaviour: func(m *mock_service.MockExperiment, args args) {
+				m.EXPECT().GetExperimentByName(args.ctx, args.name).Return(entity.Experiment{
+					ID:   1,
+					Name: "AVITO_CHECKOUT",
+					Variants: []entity.ExperimentVariant{
+						{ID: 1, Name: "A", Weight: 50, SegmentID: 10, SegmentName: "AVITO_CHECKOUT_A"},
+						{ID: 2, Name: "B", Weight: 50, SegmentID: 11, SegmentName: "AVITO_CHECKOUT_B"},
+					},
+				}, nil)
+			},
+			expectedStatusCode:   200,
+			expectedResponseBody: `{"experiment":{"experiment_id":1,"name":"AVITO_CHECKOUT","variants":[{"variant_id":1,"name":"A","weight":50,"segment_id":10,"segment_name":"AVITO_CHECKOUT_A"},{"variant_id":2,"name":"B","weight":50,"segment_id":11,"segment_name":"AVITO_CHECKOUT_B"}]}}` + "\n",
+		},
+		{
+			name: "Experiment not found",
+			args: args{ctx: context.Background(), name: "AVITO_SEARCH"},
+			mockBehaviour: func(m *mock_service.MockExperiment, args args) {
+				m.EXPECT().GetExperimentByName(args.ctx, args.name).Return(entity.Experiment{}, customError.ErrExperimentNotFound{ErrBase: customError.ErrBase{
+					Comment:  "Experiment with name \"AVITO_SEARCH\" does not exist",
+					Location: "ExperimentRepository.GetExperimentByName",
+				}})
+			},
+			expectedStatusCode:   404,
+			expectedResponseBody: `{"origin_error_text":"","title":"ErrExperimentNotFound","comment":"Experiment with name \"AVITO_SEARCH\" does not exist","location":"ExperimentRepository.GetExperimentByName"}` + "\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Инициализация зависимостей
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			// Инициализация мока сервиса
+			experiment := mock_service.NewMockExperiment(ctrl)
+			tc.mockBehaviour(experiment, tc.args)
+			services := &service.Services{Experiment: experiment}
+
+			// Создание тестового сервера
+			e := echo.New()
+			g := e.Group("/experiments")
+			newExperimentRoutes(g, services.Experiment)
+
+			// Создание запроса
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/experiments/"+tc.args.name, nil)
+
+			// Выполнение запроса
+			e.ServeHTTP(w, req)
+
+			// Проверка ответа
+			assert.Equal(t, tc.expectedStatusCode, w.Code)
+			assert.Equal(t, tc.expectedResponseBody, w.Body.String())
+		})
+	}
+}
+
+func TestExperimentRoutes_getReport(t *testing.T) {
+	type args struct {
+		ctx  context.Context
+		name string
+	}
+
+	type MockBehaviour func(m *mock_service.MockExperiment, args args)
+
+	testCases := []struct {
+		name                 string
+		args                 args
+		mockBehaviour        MockBehaviour
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name: "Ok",
+			args: args{ctx: context.Background(), name: "AVITO_CHECKOUT"},
+			mockBehaviour: func(m *mock_service.MockExperiment, args args) {
+				m.EXPECT().GetExperimentReport(args.ctx, args.name).Return(entity.ExperimentReport{
+					Experiment: "AVITO_CHECKOUT",
+					Total:      16,
+					Variants: []entity.ExperimentVariantSize{
+						{ExperimentVariant: entity.ExperimentVariant{ID: 1, Name: "A", Weight: 50, SegmentID: 10, SegmentName: "AVITO_CHECKOUT_A"}, Users: 9},
+						{ExperimentVariant: entity.ExperimentVariant{ID: 2, Name: "B", Weight: 50, SegmentID: 11, SegmentName: "AVITO_CHECKOUT_B"}, Users: 7},
+					},
+				}, nil)
+			},
+			expectedStatusCode:   200,
+			expectedResponseBody: `{"report":{"experiment":"AVITO_CHECKOUT","total":16,"variants":[{"variant_id":1,"name":"A","weight":50,"segment_id":10,"segment_name":"AVITO_CHECKOUT_A","users":9},{"variant_id":2,"name":"B","weight":50,"segment_id":11,"segment_name":"AVITO_CHECKOUT_B","users":7}]}}` + "\n",
+		},
+		{
+			name: "Experiment not found",
+			args: args{ctx: context.Background(), name: "AVITO_SEARCH"},
+			mockBehaviour: func(m *mock_service.MockExperiment, args args) {
+				m.EXPECT().GetExperimentReport(args.ctx, args.name).Return(entity.ExperimentReport{}, customError.ErrExperimentNotFound{ErrBase: customError.ErrBase{
+					Comment:  "Experiment with name \"AVITO_SEARCH\" does not exist",
+					Location: "ExperimentRepository.GetExperimentByName",
+				}})
+			},
+			expectedStatusCode:   404,
+			expectedResponseBody: `{"origin_error_text":"","title":"ErrExperimentNotFound","comment":"Experiment with name \"AVITO_SEARCH\" does not exist","location":"ExperimentRepository.GetExperimentByName"}` + "\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Инициализация зависимостей
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			// Инициализация мока сервиса
+			experiment := mock_service.NewMockExperiment(ctrl)
+			tc.mockBehaviour(experiment, tc.args)
+			services := &service.Services{Experiment: experiment}
+
+			// Создание тестового сервера
+			e := echo.New()
+			g := e.Group("/experiments")
+			newExperimentRoutes(g, services.Experiment)
+
+			// Создание запроса
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/experiments/"+tc.args.name+"/report", nil)
+
+			// Выполнение запроса
+			e.ServeHTTP(w, req)
+
+			// Проверка ответа
+			assert.Equal(t, tc.expectedStatusCode, w.Code)
+			assert.Equal(t, tc.expectedResponseBody, w.Body.String())
+		})
+	}
+}
+
+func TestExperimentRoutes_getUserVariant(t *testing.T) {
+	type args struct {
+		ctx  context.Context
+		name string
+		id   int
+	}
+
+	type MockBehaviour func(m *mock_service.MockExperiment, args args)
+
+	testCases := []struct {
+		name                 string
+		args                 args
+		inputID              string
+		mockBehaviour        MockBehaviour
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name:    "Ok",
+			args:    args{ctx: context.Background(), name: "AVITO_CHECKOUT", id: 16},
+			inputID: "16",
+			mockBehaviour: func(m *mock_service.MockExperiment, args args) {
+				m.EXPECT().GetUserVariant(args.ctx, args.name, args.id).Return(entity.UserExperimentVariant{
+					UserID:     16,
+					Experiment: "AVITO_CHECKOUT",
+					Variant:    entity.ExperimentVariant{ID: 2, Name: "B", Weight: 50, SegmentID: 11, SegmentName: "AVITO_CHECKOUT_B"},
+					StartDate:  "15:27:32 01.09.2023",
+				}, nil)
+			},
+			expectedStatusCode:   200,
+			expectedResponseBody: `{"user_variant":{"user_id":16,"experiment":"AVITO_CHECKOUT","variant":{"variant_id":2,"name":"B","weight":50,"segment_id":11,"segment_name":"AVITO_CHECKOUT_B"},"start_date":"15:27:32 01.09.2023"}}` + "\n",
+		},
+		{
+			name:                 "Invalid user id",
+			args:                 args{ctx: context.Background(), name: "AVITO_CHECKOUT"},
+			inputID:              "abc",
+			mockBehaviour:        func(m *mock_service.MockExperiment, args args) {},
+			expectedStatusCode:   400,
+			expectedResponseBody: `{"origin_error_text":"strconv.Atoi: parsing \"abc\": invalid syntax","title":"ErrUserValidationError","comment":"Invalid \"id\" path-param value. \"id\" should be integer","location":"ExperimentRoutes.getUserVariant - strconv.Atoi"}` + "\n",
+		},
+		{
+			name:    "User is not assigned to any variant",
+			args:    args{ctx: context.Background(), name: "AVITO_CHECKOUT", id: 17},
+			inputID: "17",
+			mockBehaviour: func(m *mock_service.MockExperiment, args args) {
+				m.EXPECT().GetUserVariant(args.ctx, args.name, args.id).Return(entity.UserExperimentVariant{}, customError.ErrExperimentVariantNotFound{ErrBase: customError.ErrBase{
+					Comment:  "User with id 17 is not assigned to any variant of experiment \"AVITO_CHECKOUT\"",
+					Location: "ExperimentRepository.GetUserVariant",
+				}})
+			},
+			expectedStatusCode:   404,
+			expectedResponseBody: `{"origin_error_text":"","title":"ErrExperimentVariantNotFound","comment":"User with id 17 is not assigned to any variant of experiment \"AVITO_CHECKOUT\"","location":"ExperimentRepository.GetUserVariant"}` + "\n",
+		},
+		{
+			name:    "User not found",
+			args:    args{ctx: context.Background(), name: "AVITO_CHECKOUT", id: 1000},
+			inputID: "1000",
+			mockBehaviour: func(m *mock_service.MockExperiment, args args) {
+				m.EXPECT().GetUserVariant(args.ctx, args.name, args.id).Return(entity.UserExperimentVariant{}, customError.ErrUserNotFound{ErrBase: customError.ErrBase{
+					Comment:  "User with id 1000 not found",
+					Location: "UserRepository.GetUserByID",
+				}})
+			},
+			expectedStatusCode:   404,
+			expectedResponseBody: `{"origin_error_text":"","title":"ErrUserNotFound","comment":"User with id 1000 not found","location":"UserRepository.GetUserByID"}` + "\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Инициализация зависимостей
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			// Инициализация мока сервиса
+			experiment := mock_service.NewMockExperiment(ctrl)
+			tc.mockBehaviour(experiment, tc.args)
+			services := &service.Services{Experiment: experiment}
+
+			// Создание тестового сервера
+			e := echo.New()
+			g := e.Group("/experiments")
+			newExperimentRoutes(g, services.Experiment)
+
+			// Создание запроса
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/experiments/"+tc.args.name+"/users/"+tc.inputID, nil)
+
+			// Выполнение запроса
+			e.ServeHTTP(w, req)
+
+			// Проверка ответа
+			assert.Equal(t, tc.expectedStatusCode, w.Code)
+			assert.Equal(t, tc.expectedResponseBody, w.Body.String())
+		})
+	}
+}
@@ -1,10 +1,18 @@
 package v1
 
 import (
-	_ "avito-rest-api/internal/error"
+	customError "avito-rest-api/internal/error"
 	"avito-rest-api/internal/service"
+	"fmt"
 	"github.com/labstack/echo/v4"
 	"net/http"
+	"time"
+)
+
+const (
+	reportDateTimeLayout = "15:04:05 02.01.2006"
+	reportDateLayout     = "02.01.2006"
+	reportMonthLayout    = "2006-01"
 )
 
 type reportRoutes struct {
@@ -34,13 +42,67 @@ type MakeReportResponse struct {
 // @Description наименование сегмента, дату добавления пользователя в сегмент и
 // @Description дату выхода пользователя из сегмента соответственно. Строки отчёта
 // @Description отсортированы в порядке возрастания по дате добавления пользователя в сегмент.
+// @Description Параметры `from`, `to` и `month` позволяют ограничить отчёт записями,
+// @Description период действия которых пересекается с указанным периодом.
 // @Tags reports
+// @Param from query string false "Начало периода (включительно) в формате `15:04:05 02.01.2006` или `02.01.2006`"
+// @Param to query string false "Конец периода (включительно) в формате `15:04:05 02.01.2006` или `02.01.2006`"
+// @Param month query string false "Месяц в формате `2006-01`, сокращение для периода с первого по последний день месяца. Не может использоваться вместе с `from` и `to`"
 // @Success 200 {object} MakeReportResponse "Структура, содержащая дату формирования отчёта и ссылку на файл с отчётом или отчёт в виде csv-строки"
-// @Failure 400 {object} error.ErrReportValidationError "Ошибка валидации данных запроса"
-// @Failure 500 {object} error.ErrInternalServerError "Внутренняя ошибка сервера"
+// @Failure 400 {object} customError.ErrReportValidationError "Ошибка валидации данных запроса"
+// @Failure 500 {object} customError.ErrInternalServerError "Внутренняя ошибка сервера"
 // @Router /api/v1/reports [get]
 func (r *reportRoutes) makeReport(c echo.Context) error {
-	result, err := r.reportService.MakeReport(c.Request().Context())
+	var input service.MakeReportInput
+
+	from, to, month := c.QueryParam("from"), c.QueryParam("to"), c.QueryParam("month")
+	if month != "" {
+		if from != "" || to != "" {
+			return errorHandler(c, customError.ErrReportValidationError{ErrBase: customError.ErrBase{
+				Comment:  "Query param \"month\" cannot be combined with \"from\" or \"to\"",
+				Location: "ReportRoutes.makeReport - validation",
+			}})
+		}
+		monthStart, err := time.Parse(reportMonthLayout, month)
+		if err != nil {
+			return errorHandler(c, customError.ErrReportValidationError{ErrBase: customError.ErrBase{
+				OriginError:     err,
+				OriginErrorText: err.Error(),
+				Comment:         fmt.Sprintf("Invalid query param \"month\" = %s, expected format is %s", month, reportMonthLayout),
+				Location:        "ReportRoutes.makeReport - time.Parse",
+			}})
+		}
+		input.From = monthStart
+		input.To = monthStart.AddDate(0, 1, 0)
+	}
+	if from != "" {
+		lowerBound, _, err := parseReportDate(from)
+		if err != nil {
+			return errorHandler(c, customError.ErrReportValidationError{ErrBase: customError.ErrBase{
+				OriginError:     err,
+				OriginErrorText: err.Error(),
+				Comment: fmt.Sprintf("Invalid query param \"from\" = %s, expected format is %s or %s",
+					from, reportDateTimeLayout, reportDateLayout),
+				Location: "ReportRoutes.makeReport - parseReportDate",
+			}})
+		}
+		input.From = lowerBound
+	}
+	if to != "" {
+		_, upperBound, err := parseReportDate(to)
+		if err != nil {
+			return errorHandler(c, customError.ErrReportValidationError{ErrBase: customError.ErrBase{
+				OriginError:     err,
+				OriginErrorText: err.Error(),
+				Comment: fmt.Sprintf("Invalid query param \"to\" = %s, expected format is %s or %s",
+					to, reportDateTimeLayout, reportDateLayout),
+				Location: "ReportRoutes.makeReport - parseReportDate",
+			}})
+		}
+		input.To = upperBound
+	}
+
+	result, err := r.reportService.MakeReport(c.Request().Context(), input)
 	if err != nil {
 		return errorHandler(c, err)
 	}
@@ -50,3 +112,17 @@ func (r *reportRoutes) makeReport(c echo.Context) error {
 		Report:     result.Report,
 	})
 }
+
+// parseReportDate разбирает границу периода отчёта, заданную либо с точностью до
+// секунды, либо с точностью до дня, и возвращает полуинтервал [start, end),
+// покрывающий указанную секунду или день целиком.
+func parseReportDate(value string) (time.Time, time.Time, error) {
+	if t, err := time.Parse(reportDateTimeLayout, value); err == nil {
+		return t, t.Add(time.Second), nil
+	}
+	t, err := time.Parse(reportDateLayout, value)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	return t, t.AddDate(0, 0, 1), nil
+}
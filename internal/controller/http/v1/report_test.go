@@ -11,11 +11,13 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 )
 
 func TestReportRoutes_makeReport(t *testing.T) {
 	type args struct {
-		ctx context.Context
+		ctx   context.Context
+		input service.MakeReportInput
 	}
 
 	type MockBehaviour func(m *mock_service.MockReport, args args)
@@ -23,6 +25,7 @@ func TestReportRoutes_makeReport(t *testing.T) {
 	testCases := []struct {
 		name                 string
 		args                 args
+		query                string
 		mockBehaviour        MockBehaviour
 		expectedStatusCode   int
 		expectedResponseBody string
@@ -31,7 +34,7 @@ func TestReportRoutes_makeReport(t *testing.T) {
 			name: "Ok",
 			args: args{ctx: context.Background()},
 			mockBehaviour: func(m *mock_service.MockReport, args args) {
-				m.EXPECT().MakeReport(args.ctx).Return(entity.ReportCSV{
+				m.EXPECT().MakeReport(args.ctx, args.input).Return(entity.ReportCSV{
 					ReportDate: "19:52:04 02.09.2023",
 					Report:     "user_id,segment_name,start_date,end_date\n1,AVITO_VOICE_MESSAGES,12:35:50 01.01.2023,\n",
 				}, nil)
@@ -39,6 +42,87 @@ func TestReportRoutes_makeReport(t *testing.T) {
 			expectedStatusCode:   200,
 			expectedResponseBody: `{"report_date":"19:52:04 02.09.2023","report":"user_id,segment_name,start_date,end_date\n1,AVITO_VOICE_MESSAGES,12:35:50 01.01.2023,\n"}` + "\n",
 		},
+		{
+			name: "Ok, from and to dates",
+			args: args{
+				ctx: context.Background(),
+				input: service.MakeReportInput{
+					From: time.Date(2023, 9, 1, 0, 0, 0, 0, time.UTC),
+					To:   time.Date(2023, 9, 16, 0, 0, 0, 0, time.UTC),
+				},
+			},
+			query: "?from=01.09.2023&to=15.09.2023",
+			mockBehaviour: func(m *mock_service.MockReport, args args) {
+				m.EXPECT().MakeReport(args.ctx, args.input).Return(entity.ReportCSV{
+					ReportDate: "19:52:04 02.09.2023",
+					Report:     "user_id,segment_name,start_date,end_date\n",
+				}, nil)
+			},
+			expectedStatusCode:   200,
+			expectedResponseBody: `{"report_date":"19:52:04 02.09.2023","report":"user_id,segment_name,start_date,end_date\n"}` + "\n",
+		},
+		{
+			name: "Ok, from and to with time",
+			args: args{
+				ctx: context.Background(),
+				input: service.MakeReportInput{
+					From: time.Date(2023, 9, 1, 10, 0, 0, 0, time.UTC),
+					To:   time.Date(2023, 9, 1, 18, 30, 1, 0, time.UTC),
+				},
+			},
+			query: "?from=10:00:00%2001.09.2023&to=18:30:00%2001.09.2023",
+			mockBehaviour: func(m *mock_service.MockReport, args args) {
+				m.EXPECT().MakeReport(args.ctx, args.input).Return(entity.ReportCSV{
+					ReportDate: "19:52:04 02.09.2023",
+					Report:     "user_id,segment_name,start_date,end_date\n",
+				}, nil)
+			},
+			expectedStatusCode:   200,
+			expectedResponseBody: `{"report_date":"19:52:04 02.09.2023","report":"user_id,segment_name,start_date,end_date\n"}` + "\n",
+		},
+		{
+			name: "Ok, month",
+			args: args{
+				ctx: context.Background(),
+				input: service.MakeReportInput{
+					From: time.Date(2023, 12, 1, 0, 0, 0, 0, time.UTC),
+					To:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+				},
+			},
+			query: "?month=2023-12",
+			mockBehaviour: func(m *mock_service.MockReport, args args) {
+				m.EXPECT().MakeReport(args.ctx, args.input).Return(entity.ReportCSV{
+					ReportDate: "19:52:04 02.09.2023",
+					Report:     "user_id,segment_name,start_date,end_date\n",
+				}, nil)
+			},
+			expectedStatusCode:   200,
+			expectedResponseBody: `{"report_date":"19:52:04 02.09.2023","report":"user_id,segment_name,start_date,end_date\n"}` + "\n",
+		},
+		{
+			name:                 "Invalid from",
+			args:                 args{ctx: context.Background()},
+			query:                "?from=2023-09-01",
+			mockBehaviour:        func(m *mock_service.MockReport, args args) {},
+			expectedStatusCode:   400,
+			expectedResponseBody: `{"origin_error_text":"parsing time \"2023-09-01\" as \"02.01.2006\": cannot parse \"23-09-01\" as \".\"","title":"ErrReportValidationError","comment":"Invalid query param \"from\" = 2023-09-01, expected format is 15:04:05 02.01.2006 or 02.01.2006","location":"ReportRoutes.makeReport - parseReportDate"}` + "\n",
+		},
+		{
+			name:                 "Invalid month",
+			args:                 args{ctx: context.Background()},
+			query:                "?month=09.2023",
+			mockBehaviour:        func(m *mock_service.MockReport, args args) {},
+			expectedStatusCode:   400,
+			expectedResponseBody: `{"origin_error_text":"parsing time \"09.2023\" as \"2006-01\": cannot parse \"09.2023\" as \"2006\"","title":"ErrReportValidationError","comment":"Invalid query param \"month\" = 09.2023, expected format is 2006-01","location":"ReportRoutes.makeReport - time.Parse"}` + "\n",
+		},
+		{
+			name:                 "Month combined with from",
+			args:                 args{ctx: context.Background()},
+			query:                "?month=2023-09&from=01.09.2023",
+			mockBehaviour:        func(m *mock_service.MockReport, args args) {},
+			expectedStatusCode:   400,
+			expectedResponseBody: `{"origin_error_text":"","title":"ErrReportValidationError","comment":"Query param \"month\" cannot be combined with \"from\" or \"to\"","location":"ReportRoutes.makeReport - validation"}` + "\n",
+		},
 	}
 
 	for _, tc := range testCases {
@@ -59,7 +143,7 @@ func TestReportRoutes_makeReport(t *testing.T) {
 
 			// Создание запроса
 			w := httptest.NewRecorder()
-			req := httptest.NewRequest(http.MethodGet, "/reports", nil)
+			req := httptest.NewRequest(http.MethodGet, "/reports"+tc.query, nil)
 
 			// Выполнение запроса
 			e.ServeHTTP(w, req)
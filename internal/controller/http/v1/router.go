@@ -7,6 +7,7 @@ import (
 	"github.com/labstack/echo/v4"
 	echoSwagger "github.com/swaggo/echo-swagger"
 	"net/http"
+	"time"
 )
 
 func NewRouter(handler *echo.Echo, services *service.Services) {
@@ -17,6 +18,8 @@ func NewRouter(handler *echo.Echo, services *service.Services) {
 
 	newUserRoutes(v1.Group("/users"), services.User)
 	newSegmentRoutes(v1.Group("/segments"), services.Segment)
+	newSegmentGroupRoutes(v1.Group("/segment-groups"), services.SegmentGroup)
+	newExperimentRoutes(v1.Group("/experiments"), services.Experiment)
 	newReportRoutes(v1.Group("/reports"), services.Report)
 }
 
@@ -32,6 +35,9 @@ func errorHandler(c echo.Context, err error) error {
 	case customError.ErrUserDeleted:
 		t.Title = "ErrUserDeleted"
 		return c.JSON(http.StatusBadRequest, t)
+	case customError.ErrUserAlreadyExists:
+		t.Title = "ErrUserAlreadyExists"
+		return c.JSON(http.StatusBadRequest, t)
 
 	// Ошибки сегмента
 	case customError.ErrSegmentValidationError:
@@ -47,6 +53,34 @@ func errorHandler(c echo.Context, err error) error {
 		t.Title = "ErrSegmentAlreadyExists"
 		return c.JSON(http.StatusBadRequest, t)
 
+	// Ошибки группы сегментов
+	case customError.ErrSegmentGroupValidationError:
+		t.Title = "ErrSegmentGroupValidationError"
+		return c.JSON(http.StatusBadRequest, t)
+	case customError.ErrSegmentGroupNotFound:
+		t.Title = "ErrSegmentGroupNotFound"
+		return c.JSON(http.StatusNotFound, t)
+	case customError.ErrSegmentGroupAlreadyExists:
+		t.Title = "ErrSegmentGroupAlreadyExists"
+		return c.JSON(http.StatusBadRequest, t)
+	case customError.ErrSegmentGroupConflict:
+		t.Title = "ErrSegmentGroupConflict"
+		return c.JSON(http.StatusConflict, t)
+
+	// Ошибки эксперимента
+	case customError.ErrExperimentValidationError:
+		t.Title = "ErrExperimentValidationError"
+		return c.JSON(http.StatusBadRequest, t)
+	case customError.ErrExperimentNotFound:
+		t.Title = "ErrExperimentNotFound"
+		return c.JSON(http.StatusNotFound, t)
+	case customError.ErrExperimentAlreadyExists:
+		t.Title = "ErrExperimentAlreadyExists"
+		return c.JSON(http.StatusBadRequest, t)
+	case customError.ErrExperimentVariantNotFound:
+		t.Title = "ErrExperimentVariantNotFound"
+		return c.JSON(http.StatusNotFound, t)
+
 	case customError.ErrReportValidationError:
 		t.Title = "ErrReportValidationError"
 		return c.JSON(http.StatusBadRequest, t)
@@ -60,3 +94,12 @@ func errorHandler(c echo.Context, err error) error {
 		return c.JSON(http.StatusInternalServerError, err)
 	}
 }
+
+// parseMomentParam разбирает query-параметр, задающий момент времени в формате `15:04:05 02.01.2006`.
+// Для пустого значения возвращается нулевое время, обозначающее текущий момент.
+func parseMomentParam(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse("15:04:05 02.01.2006", value)
+}
@@ -4,9 +4,14 @@ import (
 	"avito-rest-api/internal/entity"
 	customError "avito-rest-api/internal/error"
 	"avito-rest-api/internal/service"
+	"encoding/csv"
 	"fmt"
 	"github.com/labstack/echo/v4"
+	"io"
+	"mime"
 	"net/http"
+	"strconv"
+	"strings"
 )
 
 type segmentRoutes struct {
@@ -19,22 +24,40 @@ func newSegmentRoutes(g *echo.Group, segmentService service.Segment) {
 	}
 
 	g.POST("", r.create)
+	g.POST("/derived", r.createDerived)
 	g.GET("", r.getAll)
 	g.GET("/:name", r.getByName)
+	g.GET("/:name/users", r.getUsers)
+	g.POST("/:name/users", r.addUsers)
+	g.DELETE("/:name/users", r.deleteUsers)
 	g.DELETE("/:name", r.deleteByName)
+	g.POST("/:name/purge", r.purge)
+	g.PATCH("/:name", r.update)
+	g.PUT("/:name/name", r.rename)
+	g.PUT("/:name/rules", r.updateRules)
+	g.PUT("/:name/percentage", r.updatePercentage)
 }
 
 type CreateResponse struct {
 	Name string `json:"name" example:"AVITO_MUSIC_SERVICE" validate:"required"`
 }
 
+type PreviewSegmentResponse struct {
+	DryRun bool `json:"dry_run" example:"true"`
+	entity.SegmentPreview
+}
+
 // @Summary Создать сегмент
-// @Description Создаёт сегмент на основе информации в теле запроса
+// @Description Создаёт сегмент на основе информации в теле запроса. Если `dry_run` равен true, сегмент не создаётся,
+// @Description а в ответе с кодом 200 возвращаются пользователи, которые вошли бы в него по правилам и проценту раскатки,
+// @Description и соль раскатки: при создании с этой солью в поле `salt` состав сегмента совпадёт с предварительным
 // @Tags segments
 // @Accept json
 // @Produce json
+// @Param dry_run query bool false "Пробный запуск без сохранения изменений"
 // @Param data body service.SegmentCreateInput true "Структура с информацией о создаваемом сегменте"
 // @Success 201 {object} CreateResponse "Наименование созданного сегмента"
+// @Success 200 {object} PreviewSegmentResponse "Результат пробного запуска"
 // @Failure 400 {object} customError.ErrSegmentValidationError "Ошибка валидации данных запроса"
 // @Failure 500 {object} customError.ErrInternalServerError "Внутренняя ошибка сервера"
 // @Router /api/v1/segments [post]
@@ -71,6 +94,18 @@ func (r *segmentRoutes) create(c echo.Context) error {
 			Location: "SegmentRoutes.create - validation",
 		}})
 	}
+	dryRun, err := parseDryRun(c, "SegmentRoutes.create")
+	if err != nil {
+		return errorHandler(c, err)
+	}
+
+	if dryRun {
+		preview, err := r.segmentService.PreviewSegment(c.Request().Context(), input)
+		if err != nil {
+			return errorHandler(c, err)
+		}
+		return c.JSON(http.StatusOK, PreviewSegmentResponse{DryRun: true, SegmentPreview: preview})
+	}
 
 	name, err := r.segmentService.CreateSegment(c.Request().Context(), input)
 	if err != nil {
@@ -82,6 +117,59 @@ func (r *segmentRoutes) create(c echo.Context) error {
 	})
 }
 
+type CreateDerivedSegmentResponse struct {
+	Name       string `json:"name" example:"AVITO_MUSIC_AND_VOICE"`
+	UsersCount int    `json:"users_count" example:"120"` // Количество пользователей, входящих в сегмент на момент создания
+}
+
+// @Summary Создать сегмент операцией над сегментами
+// @Description Создаёт сегмент из пользователей, входящих на текущий момент в исходные сегменты, применяя
+// @Description объединение (`union`), пересечение (`intersection`) или разность первого сегмента и остальных
+// @Description (`difference`). По умолчанию пользователи однократно добавляются в новый обычный сегмент.
+// @Description Если `live` равен true, сегмент остаётся вычисляемым: его состав определяется при каждом запросе
+// @Description сегментов пользователя, а добавлять в него пользователей вручную нельзя
+// @Tags segments
+// @Accept json
+// @Produce json
+// @Param data body service.SegmentDeriveInput true "Структура с информацией о создаваемом сегменте"
+// @Success 201 {object} CreateDerivedSegmentResponse "Наименование созданного сегмента и количество пользователей в нём"
+// @Failure 400 {object} customError.ErrSegmentValidationError "Ошибка валидации данных запроса, исходный сегмент удалён или имя занято"
+// @Failure 404 {object} customError.ErrSegmentNotFound "Исходный сегмент не был найден"
+// @Failure 500 {object} customError.ErrInternalServerError "Внутренняя ошибка сервера"
+// @Router /api/v1/segments/derived [post]
+func (r *segmentRoutes) createDerived(c echo.Context) error {
+	var input service.SegmentDeriveInput
+	if err := c.Bind(&input); err != nil {
+		return errorHandler(c, customError.ErrSegmentValidationError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         "Failed to parse request's body",
+			Location:        "SegmentRoutes.createDerived - c.Bind",
+		}})
+	}
+
+	// Валидация
+	if input.Name == "" {
+		return errorHandler(c, customError.ErrSegmentValidationError{ErrBase: customError.ErrBase{
+			Comment:  "Field \"name\" was not provided",
+			Location: "SegmentRoutes.createDerived - validation",
+		}})
+	}
+	if input.Operation == "" {
+		return errorHandler(c, customError.ErrSegmentValidationError{ErrBase: customError.ErrBase{
+			Comment:  "Field \"operation\" was not provided",
+			Location: "SegmentRoutes.createDerived - validation",
+		}})
+	}
+
+	name, usersCount, err := r.segmentService.CreateDerivedSegment(c.Request().Context(), input)
+	if err != nil {
+		return errorHandler(c, err)
+	}
+
+	return c.JSON(http.StatusCreated, CreateDerivedSegmentResponse{Name: name, UsersCount: usersCount})
+}
+
 type GetAllSegmentsResponse struct {
 	Segments []entity.Segment `json:"segments"`
 }
@@ -91,6 +179,8 @@ type GetAllSegmentsResponse struct {
 // @Tags segments
 // @Produce json
 // @Param segment_type query string false "Параметр, определяющий, сегменты какого типа (живые и(или) удалённые) необходимо вернуть. Значение `both` предполагает, что будут возвращены сегменты обоих типов (то есть абсолютно все сегменты, когда-либо созданные в системе). Значение `alive` предполагает, что будут возвращены только живые (то есть не помеченные как удалённые) сегменты. Значение `deleted` предполагает, что будут возвращены только сегменты, помеченные как удалённые. Отсутствие параметра равносильно параметру со значением `both`."
+// @Param tag query string false "Тег, который должен быть у сегмента"
+// @Param owner query string false "Команда, владеющая сегментом"
 // @Success 200 {object} GetAllSegmentsResponse "Список всех сегментов"
 // @Failure 400 {object} customError.ErrSegmentValidationError "Ошибка валидации данных запроса"
 // @Failure 500 {object} customError.ErrInternalServerError "Внутренняя ошибка сервера"
@@ -120,7 +210,12 @@ func (r *segmentRoutes) getAll(c echo.Context) error {
 		sTypeInt = 2
 	}
 
-	segments, err := r.segmentService.GetAllSegments(c.Request().Context(), sTypeInt)
+	filter := entity.SegmentsFilter{
+		Tag:   c.QueryParam("tag"),
+		Owner: c.QueryParam("owner"),
+	}
+
+	segments, err := r.segmentService.GetAllSegments(c.Request().Context(), sTypeInt, filter)
 	if err != nil {
 		return errorHandler(c, err)
 	}
@@ -161,6 +256,77 @@ func (r *segmentRoutes) getByName(c echo.Context) error {
 	return c.JSON(http.StatusOK, GetSegmentByNameResponse{segment})
 }
 
+type GetSegmentUsersResponse struct {
+	entity.SegmentUsersPage
+}
+
+// @Summary Получить пользователей сегмента
+// @Description Возвращает страницу записей о вхождении пользователей в сегмент с указанным именем вместе с информацией
+// @Description о пользователях. Статус записей определяется относительно момента времени `at` (по умолчанию - момент
+// @Description совершения запроса). Для получения следующей страницы передайте `next_cursor` из ответа в параметр `cursor`
+// @Tags segments
+// @Produce json
+// @Param name path string true "Наименование сегмента"
+// @Param at query string false "Момент времени в формате `15:04:05 02.01.2006`"
+// @Param status query string false "Статус записей: active - действующие (по умолчанию), expired - завершённые, all - все" Enums(active, expired, all)
+// @Param sex query int false "Пол пользователей, 0 - мужской, 1 - женский" Enums(0, 1)
+// @Param min_age query int false "Минимальный возраст пользователей, включительно"
+// @Param max_age query int false "Максимальный возраст пользователей, включительно"
+// @Param limit query int false "Размер страницы, от 1 до 1000, по умолчанию 100"
+// @Param cursor query string false "Курсор страницы"
+// @Success 200 {object} GetSegmentUsersResponse "Страница пользователей сегмента"
+// @Failure 400 {object} customError.ErrSegmentValidationError "Ошибка валидации данных запроса"
+// @Failure 404 {object} customError.ErrSegmentNotFound "Сегмент с указанным именем не был найден"
+// @Failure 500 {object} customError.ErrInternalServerError "Внутренняя ошибка сервера"
+// @Router /api/v1/segments/{name}/users [get]
+func (r *segmentRoutes) getUsers(c echo.Context) error {
+	name := c.Param("name")
+	input := service.SegmentUsersInput{
+		Status: c.QueryParam("status"),
+		Cursor: c.QueryParam("cursor"),
+	}
+
+	// Валидация
+	var err error
+	input.At, err = parseMomentParam(c.QueryParam("at"))
+	if err != nil {
+		return errorHandler(c, customError.ErrSegmentValidationError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         fmt.Sprintf("Invalid query param \"at\" = %s, expected format is 15:04:05 02.01.2006", c.QueryParam("at")),
+			Location:        "SegmentRoutes.getUsers - parseMomentParam",
+		}})
+	}
+	intParams := map[string]**int{"sex": &input.Sex, "min_age": &input.MinAge, "max_age": &input.MaxAge}
+	for _, param := range []string{"sex", "min_age", "max_age", "limit"} {
+		value := c.QueryParam(param)
+		if value == "" {
+			continue
+		}
+		number, err := strconv.Atoi(value)
+		if err != nil {
+			return errorHandler(c, customError.ErrSegmentValidationError{ErrBase: customError.ErrBase{
+				OriginError:     err,
+				OriginErrorText: err.Error(),
+				Comment:         fmt.Sprintf("Invalid query param \"%s\" = %s, \"%s\" should be integer", param, value, param),
+				Location:        "SegmentRoutes.getUsers - strconv.Atoi",
+			}})
+		}
+		if param == "limit" {
+			input.Limit = number
+		} else {
+			*intParams[param] = &number
+		}
+	}
+
+	page, err := r.segmentService.GetSegmentUsers(c.Request().Context(), name, input)
+	if err != nil {
+		return errorHandler(c, err)
+	}
+
+	return c.JSON(http.StatusOK, GetSegmentUsersResponse{page})
+}
+
 type DeleteSegmentByNameResponse struct {
 	Message string `json:"message" example:"successfully deleted segment \"AVITO_MUSIC_SERVICE\""`
 }
@@ -189,3 +355,394 @@ func (r *segmentRoutes) deleteByName(c echo.Context) error {
 
 	return c.JSON(http.StatusOK, DeleteSegmentByNameResponse{fmt.Sprintf("successfully deleted segment \"%s\"", name)})
 }
+
+type PurgeSegmentResponse struct {
+	entity.SegmentArchive
+}
+
+// @Summary Окончательно удалить сегмент с архивацией
+// @Description Архивирует историю вхождения пользователей в удалённый сегмент с указанным именем
+// @Description и окончательно удаляет сегмент вместе с этой историей. Архив загружается в Google Drive,
+// @Description в ответе возвращается ссылка на него; если Google Drive не настроен, в ответе возвращается
+// @Description содержимое архива. Сегмент должен быть предварительно удалён и не должен быть вариантом эксперимента.
+// @Tags segments
+// @Accept json
+// @Produce json
+// @Param name path string true "Наименование сегмента"
+// @Param format query string false "Формат архива, по умолчанию csv" Enums(csv, ndjson)
+// @Success 200 {object} PurgeSegmentResponse "Результат архивации"
+// @Failure 400 {object} customError.ErrSegmentValidationError "Ошибка валидации данных запроса"
+// @Failure 404 {object} customError.ErrSegmentNotFound "Сегмент с указанным именем не был найден"
+// @Failure 500 {object} customError.ErrInternalServerError "Внутренняя ошибка сервера"
+// @Router /api/v1/segments/{name}/purge [post]
+func (r *segmentRoutes) purge(c echo.Context) error {
+	name := c.Param("name")
+
+	archive, err := r.segmentService.PurgeSegment(c.Request().Context(), name, c.QueryParam("format"))
+	if err != nil {
+		return errorHandler(c, err)
+	}
+
+	return c.JSON(http.StatusOK, PurgeSegmentResponse{archive})
+}
+
+// UpdateSegmentRulesInput - DTO для маппинга данных из запроса на изменение
+// правил автоматического вхождения пользователей в сегмент
+type UpdateSegmentRulesInput struct {
+	Rules []entity.SegmentRule `json:"rules"` // Новые правила сегмента, объединяемые через AND. Пустой список удаляет правила
+}
+
+type UpdateSegmentRulesResponse struct {
+	Segment entity.Segment `json:"segment"`
+}
+
+// @Summary Изменить правила сегмента
+// @Description Заменяет правила автоматического вхождения пользователей в сегмент с указанным именем.
+// @Description Пользователи, не удовлетворяющие новым правилам, выходят из сегмента, а удовлетворяющие
+// @Description им пользователи, не входящие в сегмент, добавляются в него. Пустой список правил удаляет
+// @Description правила сегмента, не изменяя его состав.
+// @Tags segments
+// @Accept json
+// @Produce json
+// @Param name path string true "Наименование сегмента"
+// @Param data body UpdateSegmentRulesInput true "Структура с новыми правилами сегмента"
+// @Success 200 {object} UpdateSegmentRulesResponse "Сегмент с обновлёнными правилами"
+// @Failure 400 {object} customError.ErrSegmentValidationError "Ошибка валидации данных запроса"
+// @Failure 404 {object} customError.ErrSegmentNotFound "Сегмент с указанным именем не был найден"
+// @Failure 500 {object} customError.ErrInternalServerError "Внутренняя ошибка сервера"
+// @Router /api/v1/segments/{name}/rules [put]
+func (r *segmentRoutes) updateRules(c echo.Context) error {
+	name := c.Param("name")
+
+	var input UpdateSegmentRulesInput
+	if err := c.Bind(&input); err != nil {
+		return errorHandler(c, customError.ErrSegmentValidationError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         "Failed to parse request's body",
+			Location:        "SegmentRoutes.updateRules - c.Bind",
+		}})
+	}
+
+	segment, err := r.segmentService.UpdateSegmentRules(c.Request().Context(), name, input.Rules)
+	if err != nil {
+		return errorHandler(c, err)
+	}
+
+	return c.JSON(http.StatusOK, UpdateSegmentRulesResponse{Segment: segment})
+}
+
+// UpdateSegmentPercentageInput - DTO для получения из тела запроса нового
+// процента пользователей, автоматически попадающих в сегмент
+type UpdateSegmentPercentageInput struct {
+	Percentage *int `json:"percentage" example:"20" minimum:"0" maximum:"100" extensions:"x-nullable"` // Новый процент раскатки сегмента. Значение null отключает раскатку
+}
+
+type UpdateSegmentPercentageResponse struct {
+	Segment entity.Segment `json:"segment"`
+}
+
+// @Summary Изменить процент раскатки сегмента
+// @Description Изменяет процент пользователей, автоматически попадающих в сегмент с указанным именем.
+// @Description Попадание пользователя в сегмент определяется стабильным хешем от соли сегмента и ID пользователя,
+// @Description поэтому при увеличении процента пользователи, уже попавшие в сегмент, остаются в нём, а при
+// @Description уменьшении - выходят только пользователи вне нового процента. Значение null отключает раскатку,
+// @Description не изменяя состав сегмента.
+// @Tags segments
+// @Accept json
+// @Produce json
+// @Param name path string true "Наименование сегмента"
+// @Param data body UpdateSegmentPercentageInput true "Структура с новым процентом раскатки сегмента"
+// @Success 200 {object} UpdateSegmentPercentageResponse "Сегмент с обновлённым процентом раскатки"
+// @Failure 400 {object} customError.ErrSegmentValidationError "Ошибка валидации данных запроса"
+// @Failure 404 {object} customError.ErrSegmentNotFound "Сегмент с указанным именем не был найден"
+// @Failure 500 {object} customError.ErrInternalServerError "Внутренняя ошибка сервера"
+// @Router /api/v1/segments/{name}/percentage [put]
+func (r *segmentRoutes) updatePercentage(c echo.Context) error {
+	name := c.Param("name")
+
+	var input UpdateSegmentPercentageInput
+	if err := c.Bind(&input); err != nil {
+		return errorHandler(c, customError.ErrSegmentValidationError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         "Failed to parse request's body",
+			Location:        "SegmentRoutes.updatePercentage - c.Bind",
+		}})
+	}
+
+	segment, err := r.segmentService.UpdateSegmentPercentage(c.Request().Context(), name, input.Percentage)
+	if err != nil {
+		return errorHandler(c, err)
+	}
+
+	return c.JSON(http.StatusOK, UpdateSegmentPercentageResponse{Segment: segment})
+}
+
+type UpdateSegmentResponse struct {
+	Segment entity.Segment `json:"segment"`
+}
+
+// @Summary Изменить сегмент
+// @Description Изменяет описание, команду-владельца, теги, срок вхождения пользователей по умолчанию и дату
+// @Description автоматического удаления сегмента с указанным именем. Не указанные в запросе поля не изменяются,
+// @Description пустые значения удаляют соответствующее поле
+// @Tags segments
+// @Accept json
+// @Produce json
+// @Param name path string true "Наименование сегмента"
+// @Param data body service.SegmentUpdateInput true "Структура с изменяемыми полями сегмента"
+// @Success 200 {object} UpdateSegmentResponse "Изменённый сегмент"
+// @Failure 400 {object} customError.ErrSegmentValidationError "Ошибка валидации данных запроса или сегмент удалён"
+// @Failure 404 {object} customError.ErrSegmentNotFound "Сегмент с указанным именем не был найден"
+// @Failure 500 {object} customError.ErrInternalServerError "Внутренняя ошибка сервера"
+// @Router /api/v1/segments/{name} [patch]
+func (r *segmentRoutes) update(c echo.Context) error {
+	name := c.Param("name")
+
+	var input service.SegmentUpdateInput
+	if err := c.Bind(&input); err != nil {
+		return errorHandler(c, customError.ErrSegmentValidationError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         "Failed to parse request's body",
+			Location:        "SegmentRoutes.update - c.Bind",
+		}})
+	}
+
+	segment, err := r.segmentService.UpdateSegment(c.Request().Context(), name, input)
+	if err != nil {
+		return errorHandler(c, err)
+	}
+
+	return c.JSON(http.StatusOK, UpdateSegmentResponse{Segment: segment})
+}
+
+// RenameSegmentInput - DTO для получения нового имени сегмента
+type RenameSegmentInput struct {
+	Name string `json:"name" example:"AVITO_MUSIC_PREMIUM" validate:"required"` // Новое имя сегмента
+}
+
+type RenameSegmentResponse struct {
+	Segment     entity.Segment             `json:"segment"`
+	FormerNames []entity.SegmentNameChange `json:"former_names"` // История переименований сегмента от ранних к поздним
+}
+
+// @Summary Переименовать сегмент
+// @Description Переименовывает сегмент с указанным именем, сохраняя его идентификатор и историю вхождения пользователей.
+// @Description Прежнее имя записывается в историю имён сегмента, и в течение настраиваемого периода сегмент можно
+// @Description найти по нему, если имя не занято новым сегментом. Новое имя не должно быть занято другим сегментом,
+// @Description в том числе удалённым
+// @Tags segments
+// @Accept json
+// @Produce json
+// @Param name path string true "Наименование сегмента"
+// @Param data body RenameSegmentInput true "Структура с новым именем сегмента"
+// @Success 200 {object} RenameSegmentResponse "Переименованный сегмент и история его имён"
+// @Failure 400 {object} customError.ErrSegmentValidationError "Ошибка валидации данных запроса, сегмент удалён или новое имя занято"
+// @Failure 404 {object} customError.ErrSegmentNotFound "Сегмент с указанным именем не был найден"
+// @Failure 500 {object} customError.ErrInternalServerError "Внутренняя ошибка сервера"
+// @Router /api/v1/segments/{name}/name [put]
+func (r *segmentRoutes) rename(c echo.Context) error {
+	name := c.Param("name")
+
+	var input RenameSegmentInput
+	if err := c.Bind(&input); err != nil {
+		return errorHandler(c, customError.ErrSegmentValidationError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         "Failed to parse request's body",
+			Location:        "SegmentRoutes.rename - c.Bind",
+		}})
+	}
+
+	// Валидация
+	if input.Name == "" {
+		return errorHandler(c, customError.ErrSegmentValidationError{ErrBase: customError.ErrBase{
+			Comment:  "Field \"name\" was not provided",
+			Location: "SegmentRoutes.rename - validation",
+		}})
+	}
+
+	segment, formerNames, err := r.segmentService.RenameSegment(c.Request().Context(), name, input.Name)
+	if err != nil {
+		return errorHandler(c, err)
+	}
+
+	return c.JSON(http.StatusOK, RenameSegmentResponse{Segment: segment, FormerNames: formerNames})
+}
+
+type AddUsersToSegmentResponse struct {
+	entity.SegmentUsersAddReport
+}
+
+// @Summary Добавить пользователей в сегмент
+// @Description Добавляет в сегмент с указанным именем список пользователей, переданный в виде JSON или CSV
+// @Description (`Content-Type: text/csv`, по одному ID пользователя в строке, заголовок `user_id` необязателен).
+// @Description Для CSV дата выхода из сегмента передаётся в параметре `end_date`. Все пользователи должны существовать
+// @Description и не быть удалёнными, пользователи, уже входящие в сегмент, пропускаются и перечисляются в ответе.
+// @Description Операция выполняется в одной транзакции. Если `dry_run` равен true, изменения не сохраняются,
+// @Description а в ответе перечисляются пользователи, которые были бы добавлены
+// @Tags segments
+// @Accept json
+// @Accept text/csv
+// @Produce json
+// @Param name path string true "Наименование сегмента"
+// @Param end_date query string false "Дата и время выхода пользователей из сегмента для CSV (15:04:05 02.01.2006)"
+// @Param dry_run query bool false "Пробный запуск без сохранения изменений"
+// @Param data body service.SegmentUsersAddInput true "Список добавляемых пользователей"
+// @Success 200 {object} AddUsersToSegmentResponse "Результат добавления"
+// @Failure 400 {object} customError.ErrSegmentValidationError "Ошибка валидации данных запроса или пользователь удалён"
+// @Failure 404 {object} customError.ErrUserNotFound "Сегмент или пользователи не были найдены"
+// @Failure 409 {object} customError.ErrSegmentGroupConflict "Пользователи уже входят во взаимоисключающий сегмент"
+// @Failure 500 {object} customError.ErrInternalServerError "Внутренняя ошибка сервера"
+// @Router /api/v1/segments/{name}/users [post]
+func (r *segmentRoutes) addUsers(c echo.Context) error {
+	name := c.Param("name")
+
+	var input service.SegmentUsersAddInput
+	if isCSVRequest(c) {
+		userIDs, err := readUserIDsCSV(c, "SegmentRoutes.addUsers")
+		if err != nil {
+			return errorHandler(c, err)
+		}
+		input = service.SegmentUsersAddInput{UserIDs: userIDs, EndDate: c.QueryParam("end_date")}
+	} else if err := c.Bind(&input); err != nil {
+		return errorHandler(c, customError.ErrSegmentValidationError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         "Failed to parse request's body",
+			Location:        "SegmentRoutes.addUsers - c.Bind",
+		}})
+	}
+	dryRun, err := parseDryRun(c, "SegmentRoutes.addUsers")
+	if err != nil {
+		return errorHandler(c, err)
+	}
+	input.DryRun = dryRun
+
+	report, err := r.segmentService.AddUsersToSegment(c.Request().Context(), name, input)
+	if err != nil {
+		return errorHandler(c, err)
+	}
+
+	return c.JSON(http.StatusOK, AddUsersToSegmentResponse{SegmentUsersAddReport: report})
+}
+
+type DeleteUsersFromSegmentResponse struct {
+	entity.SegmentUsersDeleteReport
+}
+
+// @Summary Удалить пользователей из сегмента
+// @Description Удаляет из сегмента с указанным именем список пользователей, переданный в виде JSON или CSV
+// @Description (`Content-Type: text/csv`, по одному ID пользователя в строке, заголовок `user_id` необязателен).
+// @Description Все пользователи должны существовать, пользователи, не входящие в сегмент, пропускаются
+// @Description и перечисляются в ответе. Операция выполняется в одной транзакции. Если `dry_run` равен true,
+// @Description изменения не сохраняются, а в ответе перечисляются пользователи, которые вышли бы из сегмента
+// @Tags segments
+// @Accept json
+// @Accept text/csv
+// @Produce json
+// @Param name path string true "Наименование сегмента"
+// @Param dry_run query bool false "Пробный запуск без сохранения изменений"
+// @Param data body service.SegmentUsersDeleteInput true "Список удаляемых пользователей"
+// @Success 200 {object} DeleteUsersFromSegmentResponse "Результат удаления"
+// @Failure 400 {object} customError.ErrSegmentValidationError "Ошибка валидации данных запроса"
+// @Failure 404 {object} customError.ErrUserNotFound "Сегмент или пользователи не были найдены"
+// @Failure 500 {object} customError.ErrInternalServerError "Внутренняя ошибка сервера"
+// @Router /api/v1/segments/{name}/users [delete]
+func (r *segmentRoutes) deleteUsers(c echo.Context) error {
+	name := c.Param("name")
+
+	var input service.SegmentUsersDeleteInput
+	if isCSVRequest(c) {
+		userIDs, err := readUserIDsCSV(c, "SegmentRoutes.deleteUsers")
+		if err != nil {
+			return errorHandler(c, err)
+		}
+		input.UserIDs = userIDs
+	} else if err := c.Bind(&input); err != nil {
+		return errorHandler(c, customError.ErrSegmentValidationError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         "Failed to parse request's body",
+			Location:        "SegmentRoutes.deleteUsers - c.Bind",
+		}})
+	}
+	dryRun, err := parseDryRun(c, "SegmentRoutes.deleteUsers")
+	if err != nil {
+		return errorHandler(c, err)
+	}
+	input.DryRun = dryRun
+
+	report, err := r.segmentService.DeleteUsersFromSegment(c.Request().Context(), name, input)
+	if err != nil {
+		return errorHandler(c, err)
+	}
+
+	return c.JSON(http.StatusOK, DeleteUsersFromSegmentResponse{SegmentUsersDeleteReport: report})
+}
+
+// parseDryRun возвращает значение параметра запроса `dry_run`, отсутствие параметра означает false.
+func parseDryRun(c echo.Context, location string) (bool, error) {
+	value := c.QueryParam("dry_run")
+	if value == "" {
+		return false, nil
+	}
+	dryRun, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, customError.ErrSegmentValidationError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         fmt.Sprintf("Invalid \"dry_run\" = %s was provided, expected true or false", value),
+			Location:        location + " - strconv.ParseBool",
+		}}
+	}
+	return dryRun, nil
+}
+
+// isCSVRequest проверяет, что тело запроса передано в формате CSV.
+func isCSVRequest(c echo.Context) bool {
+	mediaType, _, _ := mime.ParseMediaType(c.Request().Header.Get(echo.HeaderContentType))
+	return mediaType == "text/csv"
+}
+
+// readUserIDsCSV читает идентификаторы пользователей из первого столбца CSV в теле запроса.
+// Первая строка пропускается, если содержит заголовок `user_id`.
+func readUserIDsCSV(c echo.Context, location string) ([]int, error) {
+	reader := csv.NewReader(c.Request().Body)
+	reader.FieldsPerRecord = -1
+
+	var userIDs []int
+	for row := 1; ; row++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, customError.ErrSegmentValidationError{ErrBase: customError.ErrBase{
+				OriginError:     err,
+				OriginErrorText: err.Error(),
+				Comment:         "Failed to parse request's body as CSV",
+				Location:        location + " - csv.Read",
+			}}
+		}
+
+		value := strings.TrimSpace(record[0])
+		if row == 1 && value == "user_id" {
+			continue
+		}
+		id, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, customError.ErrSegmentValidationError{ErrBase: customError.ErrBase{
+				OriginError:     err,
+				OriginErrorText: err.Error(),
+				Comment:         fmt.Sprintf("Invalid user id \"%s\" in row %d of CSV, expected integer number", value, row),
+				Location:        location + " - strconv.Atoi",
+			}}
+		}
+		userIDs = append(userIDs, id)
+	}
+
+	return userIDs, nil
+}
@@ -0,0 +1,120 @@
+package v1
+
+import (
+	"avito-rest-api/internal/entity"
+	customError "avito-rest-api/internal/error"
+	"avito-rest-api/internal/service"
+	"github.com/labstack/echo/v4"
+	"net/http"
+)
+
+type segmentGroupRoutes struct {
+	segmentGroupService service.SegmentGroup
+}
+
+func newSegmentGroupRoutes(g *echo.Group, segmentGroupService service.SegmentGroup) {
+	r := &segmentGroupRoutes{
+		segmentGroupService: segmentGroupService,
+	}
+
+	g.POST("", r.create)
+	g.GET("/:name", r.getByName)
+	g.PUT("/:name/segments", r.updateSegments)
+}
+
+type SegmentGroupResponse struct {
+	Group entity.SegmentGroup `json:"group"`
+}
+
+// @Summary Создать группу сегментов
+// @Description Создаёт группу взаимоисключающих сегментов: пользователь может одновременно входить не более
+// @Description чем в один сегмент группы. Сегменты должны существовать и не входить в другие группы, а ни один
+// @Description пользователь не должен входить одновременно в несколько из них.
+// @Tags segment-groups
+// @Accept json
+// @Produce json
+// @Param data body service.SegmentGroupCreateInput true "Структура с информацией о создаваемой группе"
+// @Success 201 {object} SegmentGroupResponse "Созданная группа сегментов"
+// @Failure 400 {object} customError.ErrSegmentGroupValidationError "Ошибка валидации данных запроса"
+// @Failure 400 {object} customError.ErrSegmentGroupAlreadyExists "Группа с указанным именем уже существует"
+// @Failure 404 {object} customError.ErrSegmentNotFound "Некоторые из указанных сегментов не существуют"
+// @Failure 409 {object} customError.ErrSegmentGroupConflict "Некоторые пользователи входят в несколько сегментов группы"
+// @Failure 500 {object} customError.ErrInternalServerError "Внутренняя ошибка сервера"
+// @Router /api/v1/segment-groups [post]
+func (r *segmentGroupRoutes) create(c echo.Context) error {
+	var input service.SegmentGroupCreateInput
+
+	if err := c.Bind(&input); err != nil {
+		return errorHandler(c, customError.ErrSegmentGroupValidationError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         "Failed to parse request's body",
+			Location:        "SegmentGroupRoutes.create - c.Bind",
+		}})
+	}
+
+	group, err := r.segmentGroupService.CreateSegmentGroup(c.Request().Context(), input)
+	if err != nil {
+		return errorHandler(c, err)
+	}
+
+	return c.JSON(http.StatusCreated, SegmentGroupResponse{Group: group})
+}
+
+// @Summary Получить группу сегментов
+// @Description Возвращает группу сегментов с указанным именем вместе с наименованиями её сегментов
+// @Tags segment-groups
+// @Produce json
+// @Param name path string true "Наименование группы"
+// @Success 200 {object} SegmentGroupResponse "Группа сегментов"
+// @Failure 404 {object} customError.ErrSegmentGroupNotFound "Группа с указанным именем не была найдена"
+// @Failure 500 {object} customError.ErrInternalServerError "Внутренняя ошибка сервера"
+// @Router /api/v1/segment-groups/{name} [get]
+func (r *segmentGroupRoutes) getByName(c echo.Context) error {
+	group, err := r.segmentGroupService.GetSegmentGroupByName(c.Request().Context(), c.Param("name"))
+	if err != nil {
+		return errorHandler(c, err)
+	}
+
+	return c.JSON(http.StatusOK, SegmentGroupResponse{Group: group})
+}
+
+// UpdateSegmentGroupSegmentsInput - DTO для получения из тела запроса
+// нового списка сегментов группы
+type UpdateSegmentGroupSegmentsInput struct {
+	Segments []string `json:"segments" example:"AVITO_DISCOUNT_30,AVITO_DISCOUNT_50"` // Наименования сегментов группы
+}
+
+// @Summary Изменить сегменты группы
+// @Description Заменяет список сегментов группы с указанным именем. Сегменты, отсутствующие в новом списке,
+// @Description исключаются из группы.
+// @Tags segment-groups
+// @Accept json
+// @Produce json
+// @Param name path string true "Наименование группы"
+// @Param data body UpdateSegmentGroupSegmentsInput true "Структура с новым списком сегментов группы"
+// @Success 200 {object} SegmentGroupResponse "Группа сегментов с обновлённым списком сегментов"
+// @Failure 400 {object} customError.ErrSegmentGroupValidationError "Ошибка валидации данных запроса"
+// @Failure 404 {object} customError.ErrSegmentGroupNotFound "Группа с указанным именем не была найдена"
+// @Failure 409 {object} customError.ErrSegmentGroupConflict "Некоторые пользователи входят в несколько сегментов группы"
+// @Failure 500 {object} customError.ErrInternalServerError "Внутренняя ошибка сервера"
+// @Router /api/v1/segment-groups/{name}/segments [put]
+func (r *segmentGroupRoutes) updateSegments(c echo.Context) error {
+	var input UpdateSegmentGroupSegmentsInput
+
+	if err := c.Bind(&input); err != nil {
+		return errorHandler(c, customError.ErrSegmentGroupValidationError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         "Failed to parse request's body",
+			Location:        "SegmentGroupRoutes.updateSegments - c.Bind",
+		}})
+	}
+
+	group, err := r.segmentGroupService.UpdateSegmentGroupSegments(c.Request().Context(), c.Param("name"), input.Segments)
+	if err != nil {
+		return errorHandler(c, err)
+	}
+
+	return c.JSON(http.StatusOK, SegmentGroupResponse{Group: group})
+}
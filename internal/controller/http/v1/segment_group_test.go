@@ -0,0 +1,280 @@
+package v1
+
+import (
+	"avito-rest-api/internal/entity"
+	customError "avito-rest-api/internal/error"
+	"avito-rest-api/internal/service"
+	mock_service "avito-rest-api/internal/service/mocks"
+	"bytes"
+	"context"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSegmentGroupRoutes_create(t *testing.T) {
+	type args struct {
+		ctx   context.Context
+		input service.SegmentGroupCreateInput
+	}
+
+	type MockBehaviour func(m *mock_service.MockSegmentGroup, args args)
+
+	testCases := []struct {
+		name                 string
+		args                 args
+		inputBody            string
+		mockBehaviour        MockBehaviour
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name: "Ok",
+			args: args{
+				ctx: context.Background(),
+				input: service.SegmentGroupCreateInput{
+					Name:     "AVITO_DISCOUNT",
+					Segments: []string{"AVITO_DISCOUNT_30", "AVITO_DISCOUNT_50"},
+				},
+			},
+			inputBody: `{"name":"AVITO_DISCOUNT","segments":["AVITO_DISCOUNT_30","AVITO_DISCOUNT_50"]}`,
+			mockBehaviour: func(m *mock_service.MockSegmentGroup, args args) {
+				m.EXPECT().CreateSegmentGroup(args.ctx, args.input).Return(entity.SegmentGroup{
+					ID:       1,
+					Name:     "AVITO_DISCOUNT",
+					Segments: []string{"AVITO_DISCOUNT_30", "AVITO_DISCOUNT_50"},
+				}, nil)
+			},
+			expectedStatusCode:   201,
+			expectedResponseBody: `{"group":{"group_id":1,"name":"AVITO_DISCOUNT","segments":["AVITO_DISCOUNT_30","AVITO_DISCOUNT_50"]}}` + "\n",
+		},
+		{
+			name: "Users already belong to several segments",
+			args: args{
+				ctx: context.Background(),
+				input: service.SegmentGroupCreateInput{
+					Name:     "AVITO_DISCOUNT",
+					Segments: []string{"AVITO_DISCOUNT_50", "AVITO_DISCOUNT_70"},
+				},
+			},
+			inputBody: `{"name":"AVITO_DISCOUNT","segments":["AVITO_DISCOUNT_50","AVITO_DISCOUNT_70"]}`,
+			mockBehaviour: func(m *mock_service.MockSegmentGroup, args args) {
+				m.EXPECT().CreateSegmentGroup(args.ctx, args.input).Return(entity.SegmentGroup{}, customError.ErrSegmentGroupConflict{ErrBase: customError.ErrBase{
+					Comment:  "Operation was canceled. 1 user(s) currently belong to more than one of the segments of the group",
+					Location: "SegmentGroupService.setGroupSegments - CountUsersInSeveralSegments",
+				}})
+			},
+			expectedStatusCode:   409,
+			expectedResponseBody: `{"origin_error_text":"","title":"ErrSegmentGroupConflict","comment":"Operation was canceled. 1 user(s) currently belong to more than one of the segments of the group","location":"SegmentGroupService.setGroupSegments - CountUsersInSeveralSegments"}` + "\n",
+		},
+		{
+			name: "Group already exists",
+			args: args{
+				ctx: context.Background(),
+				input: service.SegmentGroupCreateInput{
+					Name:     "AVITO_DISCOUNT",
+					Segments: []string{"AVITO_DISCOUNT_30"},
+				},
+			},
+			inputBody: `{"name":"AVITO_DISCOUNT","segments":["AVITO_DISCOUNT_30"]}`,
+			mockBehaviour: func(m *mock_service.MockSegmentGroup, args args) {
+				m.EXPECT().CreateSegmentGroup(args.ctx, args.input).Return(entity.SegmentGroup{}, customError.ErrSegmentGroupAlreadyExists{ErrBase: customError.ErrBase{
+					Comment:  "Segment group with the given name \"AVITO_DISCOUNT\" already exists",
+					Location: "SegmentGroupService.CreateSegmentGroup - GetSegmentGroupByName",
+				}})
+			},
+			expectedStatusCode:   400,
+			expectedResponseBody: `{"origin_error_text":"","title":"ErrSegmentGroupAlreadyExists","comment":"Segment group with the given name \"AVITO_DISCOUNT\" already exists","location":"SegmentGroupService.CreateSegmentGroup - GetSegmentGroupByName"}` + "\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Инициализация зависимостей
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			// Инициализация мока сервиса
+			segmentGroup := mock_service.NewMockSegmentGroup(ctrl)
+			tc.mockBehaviour(segmentGroup, tc.args)
+			services := &service.Services{SegmentGroup: segmentGroup}
+
+			// Создание тестового сервера
+			e := echo.New()
+			g := e.Group("/segment-groups")
+			newSegmentGroupRoutes(g, services.SegmentGroup)
+
+			// Создание запроса
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/segment-groups", bytes.NewBufferString(tc.inputBody))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+
+			// Выполнение запроса
+			e.ServeHTTP(w, req)
+
+			// Проверка ответа
+			assert.Equal(t, tc.expectedStatusCode, w.Code)
+			assert.Equal(t, tc.expectedResponseBody, w.Body.String())
+		})
+	}
+}
+
+func TestSegmentGroupRoutes_getByName(t *testing.T) {
+	type args struct {
+		ctx  context.Context
+		name string
+	}
+
+	type MockBehaviour func(m *mock_service.MockSegmentGroup, args args)
+
+	testCases := []struct {
+		name                 string
+		args                 args
+		mockBehaviour        MockBehaviour
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name: "Ok",
+			args: args{ctx: context.Background(), name: "AVITO_DISCOUNT"},
+			mockBehaviour: func(m *mock_service.MockSegmentGroup, args args) {
+				m.EXPECT().GetSegmentGroupByName(args.ctx, args.name).Return(entity.SegmentGroup{
+					ID:       1,
+					Name:     "AVITO_DISCOUNT",
+					Segments: []string{"AVITO_DISCOUNT_30", "AVITO_DISCOUNT_50"},
+				}, nil)
+			},
+			expectedStatusCode:   200,
+			expectedResponseBody: `{"group":{"group_id":1,"name":"AVITO_DISCOUNT","segments":["AVITO_DISCOUNT_30","AVITO_DISCOUNT_50"]}}` + "\n",
+		},
+		{
+			name: "Group not found",
+			args: args{ctx: context.Background(), name: "AVITO_DELIVERY"},
+			mockBehaviour: func(m *mock_service.MockSegmentGroup, args args) {
+				m.EXPECT().GetSegmentGroupByName(args.ctx, args.name).Return(entity.SegmentGroup{}, customError.ErrSegmentGroupNotFound{ErrBase: customError.ErrBase{
+					Comment:  "Segment group with name \"AVITO_DELIVERY\" does not exist",
+					Location: "SegmentGroupRepository.GetSegmentGroupByName",
+				}})
+			},
+			expectedStatusCode:   404,
+			expectedResponseBody: `{"origin_error_text":"","title":"ErrSegmentGroupNotFound","comment":"Segment group with name \"AVITO_DELIVERY\" does not exist","location":"SegmentGroupRepository.GetSegmentGroupByName"}` + "\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Инициализация зависимостей
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			// Инициализация мока сервиса
+			segmentGroup := mock_service.NewMockSegmentGroup(ctrl)
+			tc.mockBehaviour(segmentGroup, tc.args)
+			services := &service.Services{SegmentGroup: segmentGroup}
+
+			// Создание тестового сервера
+			e := echo.New()
+			g := e.Group("/segment-groups")
+			newSegmentGroupRoutes(g, services.SegmentGroup)
+
+			// Создание запроса
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/segment-groups/"+tc.args.name, nil)
+
+			// Выполнение запроса
+			e.ServeHTTP(w, req)
+
+			// Проверка ответа
+			assert.Equal(t, tc.expectedStatusCode, w.Code)
+			assert.Equal(t, tc.expectedResponseBody, w.Body.String())
+		})
+	}
+}
+
+func TestSegmentGroupRoutes_updateSegments(t *testing.T) {
+	type args struct {
+		ctx      context.Context
+		name     string
+		segments []string
+	}
+
+	type MockBehaviour func(m *mock_service.MockSegmentGroup, args args)
+
+	testCases := []struct {
+		name                 string
+		args                 args
+		inputBody            string
+		mockBehaviour        MockBehaviour
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name: "Ok",
+			args: args{
+				ctx:      context.Background(),
+				name:     "AVITO_DISCOUNT",
+				segments: []string{"AVITO_DISCOUNT_30", "AVITO_DISCOUNT_70"},
+			},
+			inputBody: `{"segments":["AVITO_DISCOUNT_30","AVITO_DISCOUNT_70"]}`,
+			mockBehaviour: func(m *mock_service.MockSegmentGroup, args args) {
+				m.EXPECT().UpdateSegmentGroupSegments(args.ctx, args.name, args.segments).Return(entity.SegmentGroup{
+					ID:       1,
+					Name:     "AVITO_DISCOUNT",
+					Segments: args.segments,
+				}, nil)
+			},
+			expectedStatusCode:   200,
+			expectedResponseBody: `{"group":{"group_id":1,"name":"AVITO_DISCOUNT","segments":["AVITO_DISCOUNT_30","AVITO_DISCOUNT_70"]}}` + "\n",
+		},
+		{
+			name: "Segment belongs to another group",
+			args: args{
+				ctx:      context.Background(),
+				name:     "AVITO_DISCOUNT",
+				segments: []string{"AVITO_MARKET_DISCOUNT_30"},
+			},
+			inputBody: `{"segments":["AVITO_MARKET_DISCOUNT_30"]}`,
+			mockBehaviour: func(m *mock_service.MockSegmentGroup, args args) {
+				m.EXPECT().UpdateSegmentGroupSegments(args.ctx, args.name, args.segments).Return(entity.SegmentGroup{}, customError.ErrSegmentGroupValidationError{ErrBase: customError.ErrBase{
+					Comment:  "Validation of segment group's data failed, segment \"AVITO_MARKET_DISCOUNT_30\" already belongs to another group",
+					Location: "SegmentGroupService.setGroupSegments - validation",
+				}})
+			},
+			expectedStatusCode:   400,
+			expectedResponseBody: `{"origin_error_text":"","title":"ErrSegmentGroupValidationError","comment":"Validation of segment group's data failed, segment \"AVITO_MARKET_DISCOUNT_30\" already belongs to another group","location":"SegmentGroupService.setGroupSegments - validation"}` + "\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Инициализация зависимостей
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			// Инициализация мока сервиса
+			segmentGroup := mock_service.NewMockSegmentGroup(ctrl)
+			tc.mockBehaviour(segmentGroup, tc.args)
+			services := &service.Services{SegmentGroup: segmentGroup}
+
+			// Создание тестового сервера
+			e := echo.New()
+			g := e.Group("/segment-groups")
+			newSegmentGroupRoutes(g, services.SegmentGroup)
+
+			// Создание запроса
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPut, "/segment-groups/"+tc.args.name+"/segments", bytes.NewBufferString(tc.inputBody))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+
+			// Выполнение запроса
+			e.ServeHTTP(w, req)
+
+			// Проверка ответа
+			assert.Equal(t, tc.expectedStatusCode, w.Code)
+			assert.Equal(t, tc.expectedResponseBody, w.Body.String())
+		})
+	}
+}
@@ -15,6 +15,7 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"testing"
+	"time"
 )
 
 func TestSegmentRoutes_create(t *testing.T) {
@@ -28,6 +29,7 @@ func TestSegmentRoutes_create(t *testing.T) {
 	testCases := []struct {
 		name                 string
 		args                 args
+		query                string
 		inputBody            string
 		mockBehaviour        MockBehaviour
 		expectedStatusCode   int
@@ -49,6 +51,95 @@ func TestSegmentRoutes_create(t *testing.T) {
 			expectedStatusCode:   201,
 			expectedResponseBody: `{"name":"AVITO_BAKERY"}` + "\n",
 		},
+		{
+			name: "Ok, with rules",
+			args: args{
+				ctx: context.Background(),
+				input: service.SegmentCreateInput{
+					Name: "AVITO_ADULTS",
+					Rules: []entity.SegmentRule{
+						{Attribute: "age", Operator: ">=", Value: float64(18)},
+						{Attribute: "sex", Operator: "=", Value: float64(1)},
+					},
+				},
+			},
+			inputBody: `{"name":"AVITO_ADULTS","rules":[{"attribute":"age","operator":">=","value":18},{"attribute":"sex","operator":"=","value":1}]}`,
+			mockBehaviour: func(m *mock_service.MockSegment, args args) {
+				m.EXPECT().CreateSegment(args.ctx, args.input).Return("AVITO_ADULTS", nil)
+			},
+			expectedStatusCode:   201,
+			expectedResponseBody: `{"name":"AVITO_ADULTS"}` + "\n",
+		},
+		{
+			name: "Ok, with default TTL and active window",
+			args: args{
+				ctx: context.Background(),
+				input: service.SegmentCreateInput{
+					Name:        "AVITO_PROMO",
+					DefaultTTL:  "30d",
+					ActiveUntil: "00:00:00 01.01.2030",
+				},
+			},
+			inputBody: `{"name":"AVITO_PROMO","default_ttl":"30d","active_until":"00:00:00 01.01.2030"}`,
+			mockBehaviour: func(m *mock_service.MockSegment, args args) {
+				m.EXPECT().CreateSegment(args.ctx, args.input).Return("AVITO_PROMO", nil)
+			},
+			expectedStatusCode:   201,
+			expectedResponseBody: `{"name":"AVITO_PROMO"}` + "\n",
+		},
+		{
+			name: "Dry run",
+			args: args{
+				ctx: context.Background(),
+				input: service.SegmentCreateInput{
+					Name:                   "AVITO_BAKERY",
+					PercentageOfUsersAdded: 20,
+				},
+			},
+			query:     "?dry_run=true",
+			inputBody: `{"name":"AVITO_BAKERY","percentage":20}`,
+			mockBehaviour: func(m *mock_service.MockSegment, args args) {
+				m.EXPECT().PreviewSegment(args.ctx, args.input).Return(entity.SegmentPreview{
+					Name:       "AVITO_BAKERY",
+					UsersCount: 2,
+					UserIDs:    []int{4, 16},
+					Salt:       "9e107d9d372bb6826bd81d3542a419d6",
+				}, nil)
+			},
+			expectedStatusCode:   200,
+			expectedResponseBody: `{"dry_run":true,"name":"AVITO_BAKERY","users_count":2,"user_ids":[4,16],"salt":"9e107d9d372bb6826bd81d3542a419d6"}` + "\n",
+		},
+		{
+			name: "Ok: salt from dry run",
+			args: args{
+				ctx: context.Background(),
+				input: service.SegmentCreateInput{
+					Name:                   "AVITO_BAKERY",
+					PercentageOfUsersAdded: 20,
+					Salt:                   "9e107d9d372bb6826bd81d3542a419d6",
+				},
+			},
+			inputBody: `{"name":"AVITO_BAKERY","percentage":20,"salt":"9e107d9d372bb6826bd81d3542a419d6"}`,
+			mockBehaviour: func(m *mock_service.MockSegment, args args) {
+				m.EXPECT().CreateSegment(args.ctx, args.input).Return("AVITO_BAKERY", nil)
+			},
+			expectedStatusCode:   201,
+			expectedResponseBody: `{"name":"AVITO_BAKERY"}` + "\n",
+		},
+		{
+			name: "Dry run disabled",
+			args: args{
+				ctx:   context.Background(),
+				input: service.SegmentCreateInput{Name: "AVITO_BAKERY"},
+			},
+			query:     "?dry_run=false",
+			inputBody: `{"name":"AVITO_BAKERY"}`,
+			mockBehaviour: func(m *mock_service.MockSegment, args args) {
+				m.EXPECT().CreateSegment(args.ctx, args.input).Return("AVITO_BAKERY", nil)
+			},
+			expectedStatusCode:   201,
+			expectedResponseBody: `{"name":"AVITO_BAKERY"}` + "\n",
+		},
 		{
 			name:                 "Invalid segment name: not provided",
 			args:                 args{},
@@ -120,7 +211,7 @@ func TestSegmentRoutes_create(t *testing.T) {
 
 			// Создание запроса
 			w := httptest.NewRecorder()
-			req := httptest.NewRequest(http.MethodPost, "/segments", bytes.NewBufferString(tc.inputBody))
+			req := httptest.NewRequest(http.MethodPost, "/segments"+tc.query, bytes.NewBufferString(tc.inputBody))
 			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
 
 			// Выполнение запроса
@@ -133,10 +224,122 @@ func TestSegmentRoutes_create(t *testing.T) {
 	}
 }
 
-func TestSegmentRoutes_getAll(t *testing.T) {
+func TestSegmentRoutes_createDerived(t *testing.T) {
 	type args struct {
 		ctx   context.Context
-		query string
+		input service.SegmentDeriveInput
+	}
+
+	type MockBehaviour func(m *mock_service.MockSegment, args args)
+
+	testCases := []struct {
+		name                 string
+		args                 args
+		inputBody            string
+		mockBehaviour        MockBehaviour
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name: "Ok",
+			args: args{
+				ctx: context.Background(),
+				input: service.SegmentDeriveInput{
+					Name:      "AVITO_MUSIC_AND_VOICE",
+					Operation: "intersection",
+					Segments:  []string{"AVITO_MUSIC_SERVICE", "AVITO_VOICE_MESSAGES"},
+				},
+			},
+			inputBody: `{"name":"AVITO_MUSIC_AND_VOICE","operation":"intersection","segments":["AVITO_MUSIC_SERVICE","AVITO_VOICE_MESSAGES"]}`,
+			mockBehaviour: func(m *mock_service.MockSegment, args args) {
+				m.EXPECT().CreateDerivedSegment(args.ctx, args.input).Return("AVITO_MUSIC_AND_VOICE", 120, nil)
+			},
+			expectedStatusCode:   201,
+			expectedResponseBody: `{"name":"AVITO_MUSIC_AND_VOICE","users_count":120}` + "\n",
+		},
+		{
+			name: "Live",
+			args: args{
+				ctx: context.Background(),
+				input: service.SegmentDeriveInput{
+					Name:      "AVITO_MUSIC_WITHOUT_VOICE",
+					Operation: "difference",
+					Segments:  []string{"AVITO_MUSIC_SERVICE", "AVITO_VOICE_MESSAGES"},
+					Live:      true,
+				},
+			},
+			inputBody: `{"name":"AVITO_MUSIC_WITHOUT_VOICE","operation":"difference","segments":["AVITO_MUSIC_SERVICE","AVITO_VOICE_MESSAGES"],"live":true}`,
+			mockBehaviour: func(m *mock_service.MockSegment, args args) {
+				m.EXPECT().CreateDerivedSegment(args.ctx, args.input).Return("AVITO_MUSIC_WITHOUT_VOICE", 0, nil)
+			},
+			expectedStatusCode:   201,
+			expectedResponseBody: `{"name":"AVITO_MUSIC_WITHOUT_VOICE","users_count":0}` + "\n",
+		},
+		{
+			name:                 "Operation not provided",
+			inputBody:            `{"name":"AVITO_MUSIC_AND_VOICE","segments":["AVITO_MUSIC_SERVICE","AVITO_VOICE_MESSAGES"]}`,
+			mockBehaviour:        func(m *mock_service.MockSegment, args args) {},
+			expectedStatusCode:   400,
+			expectedResponseBody: `{"origin_error_text":"","title":"ErrSegmentValidationError","comment":"Field \"operation\" was not provided","location":"SegmentRoutes.createDerived - validation"}` + "\n",
+		},
+		{
+			name: "Source segment not found",
+			args: args{
+				ctx: context.Background(),
+				input: service.SegmentDeriveInput{
+					Name:      "AVITO_MUSIC_AND_VOICE",
+					Operation: "union",
+					Segments:  []string{"AVITO_MUSIC_SERVICE", "AVITO_UNKNOWN"},
+				},
+			},
+			inputBody: `{"name":"AVITO_MUSIC_AND_VOICE","operation":"union","segments":["AVITO_MUSIC_SERVICE","AVITO_UNKNOWN"]}`,
+			mockBehaviour: func(m *mock_service.MockSegment, args args) {
+				m.EXPECT().CreateDerivedSegment(args.ctx, args.input).Return("", 0, customError.ErrSegmentNotFound{ErrBase: customError.ErrBase{
+					Comment:  "Operation was canceled. Segment \"AVITO_UNKNOWN\" does not exist",
+					Location: "SegmentService.CreateDerivedSegment - s.segmentRepository.GetSegmentByName",
+				}})
+			},
+			expectedStatusCode:   404,
+			expectedResponseBody: `{"origin_error_text":"","title":"ErrSegmentNotFound","comment":"Operation was canceled. Segment \"AVITO_UNKNOWN\" does not exist","location":"SegmentService.CreateDerivedSegment - s.segmentRepository.GetSegmentByName"}` + "\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Инициализация зависимостей
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			// Инициализация мока сервиса
+			segment := mock_service.NewMockSegment(ctrl)
+			tc.mockBehaviour(segment, tc.args)
+			services := &service.Services{Segment: segment}
+
+			// Создание тестового сервера
+			e := echo.New()
+			g := e.Group("/segments")
+			newSegmentRoutes(g, services.Segment)
+
+			// Создание запроса
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/segments/derived", bytes.NewBufferString(tc.inputBody))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+
+			// Выполнение запроса
+			e.ServeHTTP(w, req)
+
+			// Проверка ответа
+			assert.Equal(t, tc.expectedStatusCode, w.Code)
+			assert.Equal(t, tc.expectedResponseBody, w.Body.String())
+		})
+	}
+}
+
+func TestSegmentRoutes_getAll(t *testing.T) {
+	type args struct {
+		ctx    context.Context
+		query  string
+		filter entity.SegmentsFilter
 	}
 
 	getSegmentType := func(a args) int {
@@ -170,7 +373,7 @@ func TestSegmentRoutes_getAll(t *testing.T) {
 				query: "",
 			},
 			mockBehaviour: func(m *mock_service.MockSegment, args args) {
-				m.EXPECT().GetAllSegments(args.ctx, getSegmentType(args)).Return([]entity.Segment{
+				m.EXPECT().GetAllSegments(args.ctx, getSegmentType(args), args.filter).Return([]entity.Segment{
 					{
 						ID:        1,
 						Name:      "AVITO_BAKERY",
@@ -193,7 +396,7 @@ func TestSegmentRoutes_getAll(t *testing.T) {
 				query: "both",
 			},
 			mockBehaviour: func(m *mock_service.MockSegment, args args) {
-				m.EXPECT().GetAllSegments(args.ctx, getSegmentType(args)).Return([]entity.Segment{
+				m.EXPECT().GetAllSegments(args.ctx, getSegmentType(args), args.filter).Return([]entity.Segment{
 					{
 						ID:        1,
 						Name:      "AVITO_BAKERY",
@@ -216,7 +419,7 @@ func TestSegmentRoutes_getAll(t *testing.T) {
 				query: "alive",
 			},
 			mockBehaviour: func(m *mock_service.MockSegment, args args) {
-				m.EXPECT().GetAllSegments(args.ctx, getSegmentType(args)).Return([]entity.Segment{
+				m.EXPECT().GetAllSegments(args.ctx, getSegmentType(args), args.filter).Return([]entity.Segment{
 					{
 						ID:        1,
 						Name:      "AVITO_BAKERY",
@@ -234,7 +437,7 @@ func TestSegmentRoutes_getAll(t *testing.T) {
 				query: "deleted",
 			},
 			mockBehaviour: func(m *mock_service.MockSegment, args args) {
-				m.EXPECT().GetAllSegments(args.ctx, getSegmentType(args)).Return([]entity.Segment{
+				m.EXPECT().GetAllSegments(args.ctx, getSegmentType(args), args.filter).Return([]entity.Segment{
 					{
 						ID:        1,
 						Name:      "AVITO_AIRLINES",
@@ -245,6 +448,29 @@ func TestSegmentRoutes_getAll(t *testing.T) {
 			expectedStatusCode:   200,
 			expectedResponseBody: `{"segments":[{"segment_id":1,"name":"AVITO_AIRLINES","is_deleted":true}]}` + "\n",
 		},
+		{
+			name: "Ok, filtered by tag and owner",
+			args: args{
+				ctx:    context.Background(),
+				query:  "alive",
+				filter: entity.SegmentsFilter{Tag: "discount", Owner: "market-team"},
+			},
+			mockBehaviour: func(m *mock_service.MockSegment, args args) {
+				m.EXPECT().GetAllSegments(args.ctx, getSegmentType(args), args.filter).Return([]entity.Segment{
+					{
+						ID:          1,
+						Name:        "AVITO_MARKET_DISCOUNT_45",
+						Description: "Скидка 45% на товары маркета",
+						Owner:       "market-team",
+						Tags:        []string{"market", "discount"},
+						CreatedAt:   "15:27:32 01.09.2023",
+						UpdatedAt:   "15:27:32 01.09.2023",
+					},
+				}, nil)
+			},
+			expectedStatusCode:   200,
+			expectedResponseBody: `{"segments":[{"segment_id":1,"name":"AVITO_MARKET_DISCOUNT_45","is_deleted":false,"description":"Скидка 45% на товары маркета","owner":"market-team","tags":["market","discount"],"created_at":"15:27:32 01.09.2023","updated_at":"15:27:32 01.09.2023"}]}` + "\n",
+		},
 		{
 			name: "Invalid segment_type",
 			args: args{
@@ -275,9 +501,19 @@ func TestSegmentRoutes_getAll(t *testing.T) {
 
 			// Создание запроса
 			w := httptest.NewRecorder()
-			URL := "/segments"
+			query := url.Values{}
 			if tc.args.query != "" {
-				URL = fmt.Sprintf("%s?segment_type=%s", URL, tc.args.query)
+				query.Set("segment_type", tc.args.query)
+			}
+			if tc.args.filter.Tag != "" {
+				query.Set("tag", tc.args.filter.Tag)
+			}
+			if tc.args.filter.Owner != "" {
+				query.Set("owner", tc.args.filter.Owner)
+			}
+			URL := "/segments"
+			if len(query) > 0 {
+				URL = fmt.Sprintf("%s?%s", URL, query.Encode())
 			}
 			req := httptest.NewRequest(http.MethodGet, URL, nil)
 
@@ -322,6 +558,25 @@ func TestSegmentRoutes_getByName(t *testing.T) {
 			expectedStatusCode:   200,
 			expectedResponseBody: `{"segment":{"segment_id":1,"name":"AVITO_BAKERY","is_deleted":false}}` + "\n",
 		},
+		{
+			name: "Ok, segment with rules",
+			args: args{
+				ctx:  context.Background(),
+				name: "AVITO_ADULTS",
+			},
+			mockBehaviour: func(m *mock_service.MockSegment, args args) {
+				m.EXPECT().GetSegmentByName(args.ctx, args.name).Return(entity.Segment{
+					ID:        2,
+					Name:      "AVITO_ADULTS",
+					IsDeleted: false,
+					Rules: []entity.SegmentRule{
+						{Attribute: "age", Operator: ">=", Value: float64(18)},
+					},
+				}, nil)
+			},
+			expectedStatusCode:   200,
+			expectedResponseBody: `{"segment":{"segment_id":2,"name":"AVITO_ADULTS","is_deleted":false,"rules":[{"attribute":"age","operator":"\u003e=","value":18}]}}` + "\n",
+		},
 		{
 			name: "Segment with given name not found",
 			args: args{
@@ -389,3 +644,1006 @@ func TestSegmentRoutes_getByName(t *testing.T) {
 		})
 	}
 }
+
+func TestSegmentRoutes_getUsers(t *testing.T) {
+	type args struct {
+		ctx   context.Context
+		name  string
+		query string
+	}
+
+	type MockBehaviour func(m *mock_service.MockSegment, args args)
+
+	sex, minAge, maxAge := 1, 18, 30
+	member := entity.SegmentMember{
+		InfoID:    3,
+		User:      entity.User{ID: 16, Name: "Анна", Lastname: "Петрова", Sex: 1, SexText: "женский", Age: 25},
+		StartDate: "10:00:00 01.09.2023",
+		EndDate:   "18:00:00 01.09.2023",
+	}
+	memberJSON := `{"information_id":3,"user":{"user_id":16,"name":"Анна","lastname":"Петрова","sex":1,"sex_text":"женский","age":25,"is_deleted":false},"start_date":"10:00:00 01.09.2023","end_date":"18:00:00 01.09.2023"}`
+
+	testCases := []struct {
+		name                 string
+		args                 args
+		mockBehaviour        MockBehaviour
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name: "Ok",
+			args: args{
+				ctx:  context.Background(),
+				name: "AVITO_BAKERY",
+			},
+			mockBehaviour: func(m *mock_service.MockSegment, args args) {
+				m.EXPECT().GetSegmentUsers(args.ctx, args.name, service.SegmentUsersInput{}).Return(entity.SegmentUsersPage{
+					Users: []entity.SegmentMember{member},
+				}, nil)
+			},
+			expectedStatusCode:   200,
+			expectedResponseBody: `{"users":[` + memberJSON + `]}` + "\n",
+		},
+		{
+			name: "Ok, with filters and next page",
+			args: args{
+				ctx:   context.Background(),
+				name:  "AVITO_BAKERY",
+				query: "?at=" + url.QueryEscape("12:00:00 01.09.2023") + "&status=all&sex=1&min_age=18&max_age=30&limit=1&cursor=Mg",
+			},
+			mockBehaviour: func(m *mock_service.MockSegment, args args) {
+				at, _ := time.Parse("15:04:05 02.01.2006", "12:00:00 01.09.2023")
+				m.EXPECT().GetSegmentUsers(args.ctx, args.name, service.SegmentUsersInput{
+					At:     at,
+					Status: "all",
+					Sex:    &sex,
+					MinAge: &minAge,
+					MaxAge: &maxAge,
+					Cursor: "Mg",
+					Limit:  1,
+				}).Return(entity.SegmentUsersPage{
+					Users:      []entity.SegmentMember{member},
+					NextCursor: "Mw",
+				}, nil)
+			},
+			expectedStatusCode:   200,
+			expectedResponseBody: `{"users":[` + memberJSON + `],"next_cursor":"Mw"}` + "\n",
+		},
+		{
+			name: "Invalid \"at\" format",
+			args: args{
+				ctx:   context.Background(),
+				name:  "AVITO_BAKERY",
+				query: "?at=2023-09-01",
+			},
+			mockBehaviour:        func(m *mock_service.MockSegment, args args) {},
+			expectedStatusCode:   400,
+			expectedResponseBody: `{"origin_error_text":"parsing time \"2023-09-01\" as \"15:04:05 02.01.2006\": cannot parse \"23-09-01\" as \":\"","title":"ErrSegmentValidationError","comment":"Invalid query param \"at\" = 2023-09-01, expected format is 15:04:05 02.01.2006","location":"SegmentRoutes.getUsers - parseMomentParam"}` + "\n",
+		},
+		{
+			name: "Invalid integer param",
+			args: args{
+				ctx:   context.Background(),
+				name:  "AVITO_BAKERY",
+				query: "?limit=ten",
+			},
+			mockBehaviour:        func(m *mock_service.MockSegment, args args) {},
+			expectedStatusCode:   400,
+			expectedResponseBody: `{"origin_error_text":"strconv.Atoi: parsing \"ten\": invalid syntax","title":"ErrSegmentValidationError","comment":"Invalid query param \"limit\" = ten, \"limit\" should be integer","location":"SegmentRoutes.getUsers - strconv.Atoi"}` + "\n",
+		},
+		{
+			name: "Invalid status",
+			args: args{
+				ctx:   context.Background(),
+				name:  "AVITO_BAKERY",
+				query: "?status=old",
+			},
+			mockBehaviour: func(m *mock_service.MockSegment, args args) {
+				m.EXPECT().GetSegmentUsers(args.ctx, args.name, service.SegmentUsersInput{Status: "old"}).Return(entity.SegmentUsersPage{}, customError.ErrSegmentValidationError{ErrBase: customError.ErrBase{
+					Comment:  "Invalid status \"old\", expected one of: active, expired, all",
+					Location: "SegmentService.GetSegmentUsers - validation",
+				}})
+			},
+			expectedStatusCode:   400,
+			expectedResponseBody: `{"origin_error_text":"","title":"ErrSegmentValidationError","comment":"Invalid status \"old\", expected one of: active, expired, all","location":"SegmentService.GetSegmentUsers - validation"}` + "\n",
+		},
+		{
+			name: "Segment with given name not found",
+			args: args{
+				ctx:  context.Background(),
+				name: "AVITO_BAKERY",
+			},
+			mockBehaviour: func(m *mock_service.MockSegment, args args) {
+				m.EXPECT().GetSegmentUsers(args.ctx, args.name, service.SegmentUsersInput{}).Return(entity.SegmentUsersPage{}, customError.ErrSegmentNotFound{ErrBase: customError.ErrBase{
+					Comment:  fmt.Sprintf("Segment with name \"%s\" does not exist", args.name),
+					Location: "SegmentRepository.GetSegmentByName",
+				}})
+			},
+			expectedStatusCode:   404,
+			expectedResponseBody: `{"origin_error_text":"","title":"ErrSegmentNotFound","comment":"Segment with name \"AVITO_BAKERY\" does not exist","location":"SegmentRepository.GetSegmentByName"}` + "\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Инициализация зависимостей
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			// Инициализация мока сервиса
+			segment := mock_service.NewMockSegment(ctrl)
+			tc.mockBehaviour(segment, tc.args)
+			services := &service.Services{Segment: segment}
+
+			// Создание тестового сервера
+			e := echo.New()
+			g := e.Group("/segments")
+			newSegmentRoutes(g, services.Segment)
+
+			// Создание запроса
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/segments/%s/users%s", url.PathEscape(tc.args.name), tc.args.query), nil)
+
+			// Выполнение запроса
+			e.ServeHTTP(w, req)
+
+			// Проверка ответа
+			assert.Equal(t, tc.expectedStatusCode, w.Code)
+			assert.Equal(t, tc.expectedResponseBody, w.Body.String())
+		})
+	}
+}
+
+func TestSegmentRoutes_updateRules(t *testing.T) {
+	type args struct {
+		ctx   context.Context
+		name  string
+		rules []entity.SegmentRule
+	}
+
+	type MockBehaviour func(m *mock_service.MockSegment, args args)
+
+	testCases := []struct {
+		name                 string
+		args                 args
+		inputBody            string
+		mockBehaviour        MockBehaviour
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name: "Ok",
+			args: args{
+				ctx:  context.Background(),
+				name: "AVITO_ADULTS",
+				rules: []entity.SegmentRule{
+					{Attribute: "age", Operator: ">=", Value: float64(21)},
+				},
+			},
+			inputBody: `{"rules":[{"attribute":"age","operator":">=","value":21}]}`,
+			mockBehaviour: func(m *mock_service.MockSegment, args args) {
+				m.EXPECT().UpdateSegmentRules(args.ctx, args.name, args.rules).Return(entity.Segment{
+					ID:    2,
+					Name:  "AVITO_ADULTS",
+					Rules: []entity.SegmentRule{{Attribute: "age", Operator: ">=", Value: 21}},
+				}, nil)
+			},
+			expectedStatusCode:   200,
+			expectedResponseBody: `{"segment":{"segment_id":2,"name":"AVITO_ADULTS","is_deleted":false,"rules":[{"attribute":"age","operator":"\u003e=","value":21}]}}` + "\n",
+		},
+		{
+			name: "Ok, rules removed",
+			args: args{
+				ctx:   context.Background(),
+				name:  "AVITO_ADULTS",
+				rules: []entity.SegmentRule{},
+			},
+			inputBody: `{"rules":[]}`,
+			mockBehaviour: func(m *mock_service.MockSegment, args args) {
+				m.EXPECT().UpdateSegmentRules(args.ctx, args.name, args.rules).Return(entity.Segment{
+					ID:   2,
+					Name: "AVITO_ADULTS",
+				}, nil)
+			},
+			expectedStatusCode:   200,
+			expectedResponseBody: `{"segment":{"segment_id":2,"name":"AVITO_ADULTS","is_deleted":false}}` + "\n",
+		},
+		{
+			name: "Invalid rule attribute",
+			args: args{
+				ctx:  context.Background(),
+				name: "AVITO_ADULTS",
+				rules: []entity.SegmentRule{
+					{Attribute: "height", Operator: ">", Value: float64(180)},
+				},
+			},
+			inputBody: `{"rules":[{"attribute":"height","operator":">","value":180}]}`,
+			mockBehaviour: func(m *mock_service.MockSegment, args args) {
+				m.EXPECT().UpdateSegmentRules(args.ctx, args.name, args.rules).Return(entity.Segment{}, customError.ErrSegmentValidationError{ErrBase: customError.ErrBase{
+					Comment:  "Validation of segment's rules failed, rule #1 has unsupported attribute \"height\", valid values: [\"name\", \"lastname\", \"sex\", \"age\"]",
+					Location: "SegmentService.validateSegmentRules",
+				}})
+			},
+			expectedStatusCode:   400,
+			expectedResponseBody: `{"origin_error_text":"","title":"ErrSegmentValidationError","comment":"Validation of segment's rules failed, rule #1 has unsupported attribute \"height\", valid values: [\"name\", \"lastname\", \"sex\", \"age\"]","location":"SegmentService.validateSegmentRules"}` + "\n",
+		},
+		{
+			name: "Segment with given name not found",
+			args: args{
+				ctx:   context.Background(),
+				name:  "AVITO_BAKERY",
+				rules: []entity.SegmentRule{},
+			},
+			inputBody: `{"rules":[]}`,
+			mockBehaviour: func(m *mock_service.MockSegment, args args) {
+				m.EXPECT().UpdateSegmentRules(args.ctx, args.name, args.rules).Return(entity.Segment{}, customError.ErrSegmentNotFound{ErrBase: customError.ErrBase{
+					Comment:  fmt.Sprintf("Segment with provided name \"%s\" does not exist", args.name),
+					Location: "SegmentService.GetSegmentByName - doesSegmentExist",
+				}})
+			},
+			expectedStatusCode:   404,
+			expectedResponseBody: `{"origin_error_text":"","title":"ErrSegmentNotFound","comment":"Segment with provided name \"AVITO_BAKERY\" does not exist","location":"SegmentService.GetSegmentByName - doesSegmentExist"}` + "\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Инициализация зависимостей
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			// Инициализация мока сервиса
+			segment := mock_service.NewMockSegment(ctrl)
+			tc.mockBehaviour(segment, tc.args)
+			services := &service.Services{Segment: segment}
+
+			// Создание тестового сервера
+			e := echo.New()
+			g := e.Group("/segments")
+			newSegmentRoutes(g, services.Segment)
+
+			// Создание запроса
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPut, fmt.Sprintf("/segments/%s/rules", url.PathEscape(tc.args.name)), bytes.NewBufferString(tc.inputBody))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+
+			// Выполнение запроса
+			e.ServeHTTP(w, req)
+
+			// Проверка ответа
+			assert.Equal(t, tc.expectedStatusCode, w.Code)
+			assert.Equal(t, tc.expectedResponseBody, w.Body.String())
+		})
+	}
+}
+
+func TestSegmentRoutes_updatePercentage(t *testing.T) {
+	type args struct {
+		ctx        context.Context
+		name       string
+		percentage *int
+	}
+
+	type MockBehaviour func(m *mock_service.MockSegment, args args)
+
+	percentage := 20
+	invalidPercentage := 150
+
+	testCases := []struct {
+		name                 string
+		args                 args
+		inputBody            string
+		mockBehaviour        MockBehaviour
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name: "Ok",
+			args: args{
+				ctx:        context.Background(),
+				name:       "AVITO_MUSIC_SERVICE",
+				percentage: &percentage,
+			},
+			inputBody: `{"percentage":20}`,
+			mockBehaviour: func(m *mock_service.MockSegment, args args) {
+				m.EXPECT().UpdateSegmentPercentage(args.ctx, args.name, args.percentage).Return(entity.Segment{
+					ID:         43,
+					Name:       "AVITO_MUSIC_SERVICE",
+					Percentage: args.percentage,
+				}, nil)
+			},
+			expectedStatusCode:   200,
+			expectedResponseBody: `{"segment":{"segment_id":43,"name":"AVITO_MUSIC_SERVICE","is_deleted":false,"percentage":20}}` + "\n",
+		},
+		{
+			name: "Ok, rollout disabled",
+			args: args{
+				ctx:  context.Background(),
+				name: "AVITO_MUSIC_SERVICE",
+			},
+			inputBody: `{"percentage":null}`,
+			mockBehaviour: func(m *mock_service.MockSegment, args args) {
+				m.EXPECT().UpdateSegmentPercentage(args.ctx, args.name, args.percentage).Return(entity.Segment{
+					ID:   43,
+					Name: "AVITO_MUSIC_SERVICE",
+				}, nil)
+			},
+			expectedStatusCode:   200,
+			expectedResponseBody: `{"segment":{"segment_id":43,"name":"AVITO_MUSIC_SERVICE","is_deleted":false}}` + "\n",
+		},
+		{
+			name: "Percentage out of range",
+			args: args{
+				ctx:        context.Background(),
+				name:       "AVITO_MUSIC_SERVICE",
+				percentage: &invalidPercentage,
+			},
+			inputBody: `{"percentage":150}`,
+			mockBehaviour: func(m *mock_service.MockSegment, args args) {
+				m.EXPECT().UpdateSegmentPercentage(args.ctx, args.name, args.percentage).Return(entity.Segment{}, customError.ErrSegmentValidationError{ErrBase: customError.ErrBase{
+					Comment:  "Validation of segment's data failed, field \"percentage\" must be an integer number from range [0, 100]",
+					Location: "SegmentService.UpdateSegmentPercentage",
+				}})
+			},
+			expectedStatusCode:   400,
+			expectedResponseBody: `{"origin_error_text":"","title":"ErrSegmentValidationError","comment":"Validation of segment's data failed, field \"percentage\" must be an integer number from range [0, 100]","location":"SegmentService.UpdateSegmentPercentage"}` + "\n",
+		},
+		{
+			name: "Invalid body",
+			args: args{
+				ctx:  context.Background(),
+				name: "AVITO_MUSIC_SERVICE",
+			},
+			inputBody:            `{"percentage":"20"}`,
+			mockBehaviour:        func(m *mock_service.MockSegment, args args) {},
+			expectedStatusCode:   400,
+			expectedResponseBody: `{"origin_error_text":"code=400, message=Unmarshal type error: expected=int, got=string, field=percentage, offset=18, internal=json: cannot unmarshal string into Go struct field UpdateSegmentPercentageInput.percentage of type int","title":"ErrSegmentValidationError","comment":"Failed to parse request's body","location":"SegmentRoutes.updatePercentage - c.Bind"}` + "\n",
+		},
+		{
+			name: "Segment with given name not found",
+			args: args{
+				ctx:        context.Background(),
+				name:       "AVITO_BAKERY",
+				percentage: &percentage,
+			},
+			inputBody: `{"percentage":20}`,
+			mockBehaviour: func(m *mock_service.MockSegment, args args) {
+				m.EXPECT().UpdateSegmentPercentage(args.ctx, args.name, args.percentage).Return(entity.Segment{}, customError.ErrSegmentNotFound{ErrBase: customError.ErrBase{
+					Comment:  fmt.Sprintf("Segment with provided name \"%s\" does not exist", args.name),
+					Location: "SegmentService.GetSegmentByName - doesSegmentExist",
+				}})
+			},
+			expectedStatusCode:   404,
+			expectedResponseBody: `{"origin_error_text":"","title":"ErrSegmentNotFound","comment":"Segment with provided name \"AVITO_BAKERY\" does not exist","location":"SegmentService.GetSegmentByName - doesSegmentExist"}` + "\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Инициализация зависимостей
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			// Инициализация мока сервиса
+			segment := mock_service.NewMockSegment(ctrl)
+			tc.mockBehaviour(segment, tc.args)
+			services := &service.Services{Segment: segment}
+
+			// Создание тестового сервера
+			e := echo.New()
+			g := e.Group("/segments")
+			newSegmentRoutes(g, services.Segment)
+
+			// Создание запроса
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPut, fmt.Sprintf("/segments/%s/percentage", url.PathEscape(tc.args.name)), bytes.NewBufferString(tc.inputBody))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+
+			// Выполнение запроса
+			e.ServeHTTP(w, req)
+
+			// Проверка ответа
+			assert.Equal(t, tc.expectedStatusCode, w.Code)
+			assert.Equal(t, tc.expectedResponseBody, w.Body.String())
+		})
+	}
+}
+
+func TestSegmentRoutes_update(t *testing.T) {
+	type args struct {
+		ctx   context.Context
+		name  string
+		input service.SegmentUpdateInput
+	}
+
+	description := "Скидка 45% на товары маркета"
+	owner := ""
+	tags := []string{"market", "discount"}
+
+	type MockBehaviour func(m *mock_service.MockSegment, args args)
+
+	testCases := []struct {
+		name                 string
+		args                 args
+		inputBody            string
+		mockBehaviour        MockBehaviour
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name: "Ok",
+			args: args{
+				ctx:  context.Background(),
+				name: "AVITO_MARKET_DISCOUNT_45",
+				input: service.SegmentUpdateInput{
+					Description: &description,
+					Owner:       &owner,
+					Tags:        &tags,
+				},
+			},
+			inputBody: `{"description":"Скидка 45% на товары маркета","owner":"","tags":["market","discount"]}`,
+			mockBehaviour: func(m *mock_service.MockSegment, args args) {
+				m.EXPECT().UpdateSegment(args.ctx, args.name, args.input).Return(entity.Segment{
+					ID:          43,
+					Name:        "AVITO_MARKET_DISCOUNT_45",
+					Description: "Скидка 45% на товары маркета",
+					Tags:        []string{"market", "discount"},
+					CreatedAt:   "15:27:32 01.09.2023",
+					UpdatedAt:   "10:00:00 25.09.2023",
+				}, nil)
+			},
+			expectedStatusCode:   200,
+			expectedResponseBody: `{"segment":{"segment_id":43,"name":"AVITO_MARKET_DISCOUNT_45","is_deleted":false,"description":"Скидка 45% на товары маркета","tags":["market","discount"],"created_at":"15:27:32 01.09.2023","updated_at":"10:00:00 25.09.2023"}}` + "\n",
+		},
+		{
+			name: "Invalid body",
+			args: args{
+				ctx:  context.Background(),
+				name: "AVITO_MARKET_DISCOUNT_45",
+			},
+			inputBody:            `{"tags":"market"}`,
+			mockBehaviour:        func(m *mock_service.MockSegment, args args) {},
+			expectedStatusCode:   400,
+			expectedResponseBody: `{"origin_error_text":"code=400, message=Unmarshal type error: expected=[]string, got=string, field=tags, offset=16, internal=json: cannot unmarshal string into Go struct field SegmentUpdateInput.tags of type []string","title":"ErrSegmentValidationError","comment":"Failed to parse request's body","location":"SegmentRoutes.update - c.Bind"}` + "\n",
+		},
+		{
+			name: "Segment with given name not found",
+			args: args{
+				ctx:   context.Background(),
+				name:  "AVITO_BAKERY",
+				input: service.SegmentUpdateInput{Description: &description},
+			},
+			inputBody: `{"description":"Скидка 45% на товары маркета"}`,
+			mockBehaviour: func(m *mock_service.MockSegment, args args) {
+				m.EXPECT().UpdateSegment(args.ctx, args.name, args.input).Return(entity.Segment{}, customError.ErrSegmentNotFound{ErrBase: customError.ErrBase{
+					Comment:  fmt.Sprintf("Segment with provided name \"%s\" does not exist", args.name),
+					Location: "SegmentService.GetSegmentByName - doesSegmentExist",
+				}})
+			},
+			expectedStatusCode:   404,
+			expectedResponseBody: `{"origin_error_text":"","title":"ErrSegmentNotFound","comment":"Segment with provided name \"AVITO_BAKERY\" does not exist","location":"SegmentService.GetSegmentByName - doesSegmentExist"}` + "\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Инициализация зависимостей
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			// Инициализация мока сервиса
+			segment := mock_service.NewMockSegment(ctrl)
+			tc.mockBehaviour(segment, tc.args)
+			services := &service.Services{Segment: segment}
+
+			// Создание тестового сервера
+			e := echo.New()
+			g := e.Group("/segments")
+			newSegmentRoutes(g, services.Segment)
+
+			// Создание запроса
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPatch, fmt.Sprintf("/segments/%s", url.PathEscape(tc.args.name)), bytes.NewBufferString(tc.inputBody))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+
+			// Выполнение запроса
+			e.ServeHTTP(w, req)
+
+			// Проверка ответа
+			assert.Equal(t, tc.expectedStatusCode, w.Code)
+			assert.Equal(t, tc.expectedResponseBody, w.Body.String())
+		})
+	}
+}
+
+func TestSegmentRoutes_rename(t *testing.T) {
+	type args struct {
+		ctx     context.Context
+		name    string
+		newName string
+	}
+
+	type MockBehaviour func(m *mock_service.MockSegment, args args)
+
+	testCases := []struct {
+		name                 string
+		args                 args
+		inputBody            string
+		mockBehaviour        MockBehaviour
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name: "Ok",
+			args: args{
+				ctx:     context.Background(),
+				name:    "AVITO_MUSIC_SERVICE",
+				newName: "AVITO_MUSIC_PREMIUM",
+			},
+			inputBody: `{"name":"AVITO_MUSIC_PREMIUM"}`,
+			mockBehaviour: func(m *mock_service.MockSegment, args args) {
+				m.EXPECT().RenameSegment(args.ctx, args.name, args.newName).Return(entity.Segment{
+					ID:   43,
+					Name: "AVITO_MUSIC_PREMIUM",
+				}, []entity.SegmentNameChange{
+					{OldName: "AVITO_MUSIC_SERVICE", NewName: "AVITO_MUSIC_PREMIUM", RenamedAt: "10:00:00 25.09.2023"},
+				}, nil)
+			},
+			expectedStatusCode:   200,
+			expectedResponseBody: `{"segment":{"segment_id":43,"name":"AVITO_MUSIC_PREMIUM","is_deleted":false},"former_names":[{"old_name":"AVITO_MUSIC_SERVICE","new_name":"AVITO_MUSIC_PREMIUM","renamed_at":"10:00:00 25.09.2023"}]}` + "\n",
+		},
+		{
+			name: "New name not provided",
+			args: args{
+				ctx:  context.Background(),
+				name: "AVITO_MUSIC_SERVICE",
+			},
+			inputBody:            `{}`,
+			mockBehaviour:        func(m *mock_service.MockSegment, args args) {},
+			expectedStatusCode:   400,
+			expectedResponseBody: `{"origin_error_text":"","title":"ErrSegmentValidationError","comment":"Field \"name\" was not provided","location":"SegmentRoutes.rename - validation"}` + "\n",
+		},
+		{
+			name: "New name is taken",
+			args: args{
+				ctx:     context.Background(),
+				name:    "AVITO_MUSIC_SERVICE",
+				newName: "AVITO_MARKET",
+			},
+			inputBody: `{"name":"AVITO_MARKET"}`,
+			mockBehaviour: func(m *mock_service.MockSegment, args args) {
+				m.EXPECT().RenameSegment(args.ctx, args.name, args.newName).Return(entity.Segment{}, nil, customError.ErrSegmentAlreadyExists{ErrBase: customError.ErrBase{
+					Comment:  "Segment with the given name \"AVITO_MARKET\" already exists",
+					Location: "SegmentService.RenameSegment - doesSegmentExist",
+				}})
+			},
+			expectedStatusCode:   400,
+			expectedResponseBody: `{"origin_error_text":"","title":"ErrSegmentAlreadyExists","comment":"Segment with the given name \"AVITO_MARKET\" already exists","location":"SegmentService.RenameSegment - doesSegmentExist"}` + "\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Инициализация зависимостей
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			// Инициализация мока сервиса
+			segment := mock_service.NewMockSegment(ctrl)
+			tc.mockBehaviour(segment, tc.args)
+			services := &service.Services{Segment: segment}
+
+			// Создание тестового сервера
+			e := echo.New()
+			g := e.Group("/segments")
+			newSegmentRoutes(g, services.Segment)
+
+			// Создание запроса
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPut, fmt.Sprintf("/segments/%s/name", url.PathEscape(tc.args.name)), bytes.NewBufferString(tc.inputBody))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+
+			// Выполнение запроса
+			e.ServeHTTP(w, req)
+
+			// Проверка ответа
+			assert.Equal(t, tc.expectedStatusCode, w.Code)
+			assert.Equal(t, tc.expectedResponseBody, w.Body.String())
+		})
+	}
+}
+
+func TestSegmentRoutes_addUsers(t *testing.T) {
+	type args struct {
+		ctx   context.Context
+		name  string
+		input service.SegmentUsersAddInput
+	}
+
+	type MockBehaviour func(m *mock_service.MockSegment, args args)
+
+	testCases := []struct {
+		name                 string
+		args                 args
+		query                string
+		contentType          string
+		inputBody            string
+		mockBehaviour        MockBehaviour
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name: "Ok, JSON",
+			args: args{
+				ctx:  context.Background(),
+				name: "AVITO_MUSIC_SERVICE",
+				input: service.SegmentUsersAddInput{
+					UserIDs: []int{1, 2, 3},
+					EndDate: "15:00:00 31.12.2023",
+				},
+			},
+			contentType: echo.MIMEApplicationJSON,
+			inputBody:   `{"user_ids":[1,2,3],"end_date":"15:00:00 31.12.2023"}`,
+			mockBehaviour: func(m *mock_service.MockSegment, args args) {
+				m.EXPECT().AddUsersToSegment(args.ctx, args.name, args.input).Return(entity.SegmentUsersAddReport{
+					Added:          2,
+					AlreadyMembers: []int{2},
+				}, nil)
+			},
+			expectedStatusCode:   200,
+			expectedResponseBody: `{"added":2,"already_members":[2]}` + "\n",
+		},
+		{
+			name: "Ok, CSV with header",
+			args: args{
+				ctx:  context.Background(),
+				name: "AVITO_MUSIC_SERVICE",
+				input: service.SegmentUsersAddInput{
+					UserIDs: []int{1, 2, 3},
+					EndDate: "15:00:00 31.12.2023",
+				},
+			},
+			query:       "?end_date=" + url.QueryEscape("15:00:00 31.12.2023"),
+			contentType: "text/csv",
+			inputBody:   "user_id\n1\n2\n3\n",
+			mockBehaviour: func(m *mock_service.MockSegment, args args) {
+				m.EXPECT().AddUsersToSegment(args.ctx, args.name, args.input).Return(entity.SegmentUsersAddReport{
+					Added:          3,
+					AlreadyMembers: []int{},
+				}, nil)
+			},
+			expectedStatusCode:   200,
+			expectedResponseBody: `{"added":3,"already_members":[]}` + "\n",
+		},
+		{
+			name: "Dry run",
+			args: args{
+				ctx:   context.Background(),
+				name:  "AVITO_MUSIC_SERVICE",
+				input: service.SegmentUsersAddInput{UserIDs: []int{1, 2, 3}, DryRun: true},
+			},
+			query:       "?dry_run=true",
+			contentType: echo.MIMEApplicationJSON,
+			inputBody:   `{"user_ids":[1,2,3]}`,
+			mockBehaviour: func(m *mock_service.MockSegment, args args) {
+				m.EXPECT().AddUsersToSegment(args.ctx, args.name, args.input).Return(entity.SegmentUsersAddReport{
+					Added:          2,
+					AlreadyMembers: []int{2},
+					DryRun:         true,
+					UserIDs:        []int{1, 3},
+				}, nil)
+			},
+			expectedStatusCode:   200,
+			expectedResponseBody: `{"added":2,"already_members":[2],"dry_run":true,"user_ids":[1,3]}` + "\n",
+		},
+		{
+			name:                 "Invalid dry run",
+			args:                 args{ctx: context.Background(), name: "AVITO_MUSIC_SERVICE"},
+			query:                "?dry_run=maybe",
+			contentType:          echo.MIMEApplicationJSON,
+			inputBody:            `{"user_ids":[1,2,3]}`,
+			mockBehaviour:        func(m *mock_service.MockSegment, args args) {},
+			expectedStatusCode:   400,
+			expectedResponseBody: `{"origin_error_text":"strconv.ParseBool: parsing \"maybe\": invalid syntax","title":"ErrSegmentValidationError","comment":"Invalid \"dry_run\" = maybe was provided, expected true or false","location":"SegmentRoutes.addUsers - strconv.ParseBool"}` + "\n",
+		},
+		{
+			name:                 "Invalid user id in CSV",
+			args:                 args{ctx: context.Background(), name: "AVITO_MUSIC_SERVICE"},
+			contentType:          "text/csv",
+			inputBody:            "1\nabc\n",
+			mockBehaviour:        func(m *mock_service.MockSegment, args args) {},
+			expectedStatusCode:   400,
+			expectedResponseBody: `{"origin_error_text":"strconv.Atoi: parsing \"abc\": invalid syntax","title":"ErrSegmentValidationError","comment":"Invalid user id \"abc\" in row 2 of CSV, expected integer number","location":"SegmentRoutes.addUsers - strconv.Atoi"}` + "\n",
+		},
+		{
+			name: "Users not found",
+			args: args{
+				ctx:   context.Background(),
+				name:  "AVITO_MUSIC_SERVICE",
+				input: service.SegmentUsersAddInput{UserIDs: []int{1, 1000}},
+			},
+			contentType: echo.MIMEApplicationJSON,
+			inputBody:   `{"user_ids":[1,1000]}`,
+			mockBehaviour: func(m *mock_service.MockSegment, args args) {
+				m.EXPECT().AddUsersToSegment(args.ctx, args.name, args.input).Return(entity.SegmentUsersAddReport{}, customError.ErrUserNotFound{ErrBase: customError.ErrBase{
+					Comment:  "Operation was canceled because users [1000] do not exist",
+					Location: "SegmentService.AddUsersToSegment - s.userRepository.LockUsers",
+				}})
+			},
+			expectedStatusCode:   404,
+			expectedResponseBody: `{"origin_error_text":"","title":"ErrUserNotFound","comment":"Operation was canceled because users [1000] do not exist","location":"SegmentService.AddUsersToSegment - s.userRepository.LockUsers"}` + "\n",
+		},
+		{
+			name: "Users already have mutually exclusive segment",
+			args: args{
+				ctx:   context.Background(),
+				name:  "AVITO_DISCOUNT_50",
+				input: service.SegmentUsersAddInput{UserIDs: []int{4, 5}},
+			},
+			contentType: echo.MIMEApplicationJSON,
+			inputBody:   `{"user_ids":[4,5]}`,
+			mockBehaviour: func(m *mock_service.MockSegment, args args) {
+				m.EXPECT().AddUsersToSegment(args.ctx, args.name, args.input).Return(entity.SegmentUsersAddReport{}, customError.ErrSegmentGroupConflict{ErrBase: customError.ErrBase{
+					Comment:  "Operation was canceled. Segment \"AVITO_DISCOUNT_50\" cannot be added to users [4] since they already have mutually exclusive segments",
+					Location: "SegmentService.AddUsersToSegment - exclusivity",
+				}})
+			},
+			expectedStatusCode:   409,
+			expectedResponseBody: `{"origin_error_text":"","title":"ErrSegmentGroupConflict","comment":"Operation was canceled. Segment \"AVITO_DISCOUNT_50\" cannot be added to users [4] since they already have mutually exclusive segments","location":"SegmentService.AddUsersToSegment - exclusivity"}` + "\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Инициализация зависимостей
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			// Инициализация мока сервиса
+			segment := mock_service.NewMockSegment(ctrl)
+			tc.mockBehaviour(segment, tc.args)
+			services := &service.Services{Segment: segment}
+
+			// Создание тестового сервера
+			e := echo.New()
+			g := e.Group("/segments")
+			newSegmentRoutes(g, services.Segment)
+
+			// Создание запроса
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/segments/%s/users%s", url.PathEscape(tc.args.name), tc.query), bytes.NewBufferString(tc.inputBody))
+			req.Header.Set(echo.HeaderContentType, tc.contentType)
+
+			// Выполнение запроса
+			e.ServeHTTP(w, req)
+
+			// Проверка ответа
+			assert.Equal(t, tc.expectedStatusCode, w.Code)
+			assert.Equal(t, tc.expectedResponseBody, w.Body.String())
+		})
+	}
+}
+
+func TestSegmentRoutes_deleteUsers(t *testing.T) {
+	type args struct {
+		ctx   context.Context
+		name  string
+		input service.SegmentUsersDeleteInput
+	}
+
+	type MockBehaviour func(m *mock_service.MockSegment, args args)
+
+	testCases := []struct {
+		name                 string
+		args                 args
+		query                string
+		contentType          string
+		inputBody            string
+		mockBehaviour        MockBehaviour
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name:        "Ok, JSON",
+			args:        args{ctx: context.Background(), name: "AVITO_MUSIC_SERVICE", input: service.SegmentUsersDeleteInput{UserIDs: []int{1, 2, 3}}},
+			contentType: echo.MIMEApplicationJSON,
+			inputBody:   `{"user_ids":[1,2,3]}`,
+			mockBehaviour: func(m *mock_service.MockSegment, args args) {
+				m.EXPECT().DeleteUsersFromSegment(args.ctx, args.name, args.input).Return(entity.SegmentUsersDeleteReport{
+					Deleted:    2,
+					NotMembers: []int{3},
+				}, nil)
+			},
+			expectedStatusCode:   200,
+			expectedResponseBody: `{"deleted":2,"not_members":[3]}` + "\n",
+		},
+		{
+			name:        "Ok, CSV without header",
+			args:        args{ctx: context.Background(), name: "AVITO_MUSIC_SERVICE", input: service.SegmentUsersDeleteInput{UserIDs: []int{1, 2}}},
+			contentType: "text/csv; charset=utf-8",
+			inputBody:   "1\n2\n",
+			mockBehaviour: func(m *mock_service.MockSegment, args args) {
+				m.EXPECT().DeleteUsersFromSegment(args.ctx, args.name, args.input).Return(entity.SegmentUsersDeleteReport{
+					Deleted:    2,
+					NotMembers: []int{},
+				}, nil)
+			},
+			expectedStatusCode:   200,
+			expectedResponseBody: `{"deleted":2,"not_members":[]}` + "\n",
+		},
+		{
+			name: "Dry run",
+			args: args{
+				ctx:   context.Background(),
+				name:  "AVITO_MUSIC_SERVICE",
+				input: service.SegmentUsersDeleteInput{UserIDs: []int{1, 2, 3}, DryRun: true},
+			},
+			query:       "?dry_run=true",
+			contentType: echo.MIMEApplicationJSON,
+			inputBody:   `{"user_ids":[1,2,3]}`,
+			mockBehaviour: func(m *mock_service.MockSegment, args args) {
+				m.EXPECT().DeleteUsersFromSegment(args.ctx, args.name, args.input).Return(entity.SegmentUsersDeleteReport{
+					Deleted:    2,
+					NotMembers: []int{3},
+					DryRun:     true,
+					UserIDs:    []int{1, 2},
+				}, nil)
+			},
+			expectedStatusCode:   200,
+			expectedResponseBody: `{"deleted":2,"not_members":[3],"dry_run":true,"user_ids":[1,2]}` + "\n",
+		},
+		{
+			name:                 "Invalid body",
+			args:                 args{ctx: context.Background(), name: "AVITO_MUSIC_SERVICE"},
+			contentType:          echo.MIMEApplicationJSON,
+			inputBody:            `{"user_ids":"1"}`,
+			mockBehaviour:        func(m *mock_service.MockSegment, args args) {},
+			expectedStatusCode:   400,
+			expectedResponseBody: `{"origin_error_text":"code=400, message=Unmarshal type error: expected=[]int, got=string, field=user_ids, offset=15, internal=json: cannot unmarshal string into Go struct field SegmentUsersDeleteInput.user_ids of type []int","title":"ErrSegmentValidationError","comment":"Failed to parse request's body","location":"SegmentRoutes.deleteUsers - c.Bind"}` + "\n",
+		},
+		{
+			name:        "Empty list of users",
+			args:        args{ctx: context.Background(), name: "AVITO_MUSIC_SERVICE", input: service.SegmentUsersDeleteInput{UserIDs: []int{}}},
+			contentType: echo.MIMEApplicationJSON,
+			inputBody:   `{"user_ids":[]}`,
+			mockBehaviour: func(m *mock_service.MockSegment, args args) {
+				m.EXPECT().DeleteUsersFromSegment(args.ctx, args.name, args.input).Return(entity.SegmentUsersDeleteReport{}, customError.ErrSegmentValidationError{ErrBase: customError.ErrBase{
+					Comment:  "Operation was canceled. List of users cannot be empty",
+					Location: "SegmentService.DeleteUsersFromSegment - validation",
+				}})
+			},
+			expectedStatusCode:   400,
+			expectedResponseBody: `{"origin_error_text":"","title":"ErrSegmentValidationError","comment":"Operation was canceled. List of users cannot be empty","location":"SegmentService.DeleteUsersFromSegment - validation"}` + "\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Инициализация зависимостей
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			// Инициализация мока сервиса
+			segment := mock_service.NewMockSegment(ctrl)
+			tc.mockBehaviour(segment, tc.args)
+			services := &service.Services{Segment: segment}
+
+			// Создание тестового сервера
+			e := echo.New()
+			g := e.Group("/segments")
+			newSegmentRoutes(g, services.Segment)
+
+			// Создание запроса
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodDelete, fmt.Sprintf("/segments/%s/users%s", url.PathEscape(tc.args.name), tc.query), bytes.NewBufferString(tc.inputBody))
+			req.Header.Set(echo.HeaderContentType, tc.contentType)
+
+			// Выполнение запроса
+			e.ServeHTTP(w, req)
+
+			// Проверка ответа
+			assert.Equal(t, tc.expectedStatusCode, w.Code)
+			assert.Equal(t, tc.expectedResponseBody, w.Body.String())
+		})
+	}
+}
+
+func TestSegmentRoutes_purge(t *testing.T) {
+	type args struct {
+		ctx    context.Context
+		name   string
+		format string
+	}
+
+	type MockBehaviour func(m *mock_service.MockSegment, args args)
+
+	testCases := []struct {
+		name                 string
+		args                 args
+		mockBehaviour        MockBehaviour
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name: "Ok",
+			args: args{
+				ctx:    context.Background(),
+				name:   "AVITO_MUSIC_SERVICE",
+				format: "ndjson",
+			},
+			mockBehaviour: func(m *mock_service.MockSegment, args args) {
+				m.EXPECT().PurgeSegment(args.ctx, args.name, args.format).Return(entity.SegmentArchive{
+					Name:         "AVITO_MUSIC_SERVICE",
+					Format:       "ndjson",
+					ArchivedRows: 120,
+					Archive:      "https://drive.google.com/file/d/1a2b3c/view?usp=sharing",
+				}, nil)
+			},
+			expectedStatusCode:   200,
+			expectedResponseBody: `{"name":"AVITO_MUSIC_SERVICE","format":"ndjson","archived_rows":120,"archive":"https://drive.google.com/file/d/1a2b3c/view?usp=sharing"}` + "\n",
+		},
+		{
+			name: "Segment is not deleted",
+			args: args{
+				ctx:  context.Background(),
+				name: "AVITO_MUSIC_SERVICE",
+			},
+			mockBehaviour: func(m *mock_service.MockSegment, args args) {
+				m.EXPECT().PurgeSegment(args.ctx, args.name, args.format).Return(entity.SegmentArchive{}, customError.ErrSegmentValidationError{ErrBase: customError.ErrBase{
+					Comment:  fmt.Sprintf("Operation was canceled. Segment \"%s\" must be deleted before it is purged", args.name),
+					Location: "purgeSegment - validation",
+				}})
+			},
+			expectedStatusCode:   400,
+			expectedResponseBody: `{"origin_error_text":"","title":"ErrSegmentValidationError","comment":"Operation was canceled. Segment \"AVITO_MUSIC_SERVICE\" must be deleted before it is purged","location":"purgeSegment - validation"}` + "\n",
+		},
+		{
+			name: "Segment with given name not found",
+			args: args{
+				ctx:  context.Background(),
+				name: "AVITO_BAKERY",
+			},
+			mockBehaviour: func(m *mock_service.MockSegment, args args) {
+				m.EXPECT().PurgeSegment(args.ctx, args.name, args.format).Return(entity.SegmentArchive{}, customError.ErrSegmentNotFound{ErrBase: customError.ErrBase{
+					Comment:  fmt.Sprintf("Segment with provided name \"%s\" does not exist", args.name),
+					Location: "SegmentRepository.GetSegmentByName - r.Pool.QueryRow",
+				}})
+			},
+			expectedStatusCode:   404,
+			expectedResponseBody: `{"origin_error_text":"","title":"ErrSegmentNotFound","comment":"Segment with provided name \"AVITO_BAKERY\" does not exist","location":"SegmentRepository.GetSegmentByName - r.Pool.QueryRow"}` + "\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Инициализация зависимостей
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			// Инициализация мока сервиса
+			segment := mock_service.NewMockSegment(ctrl)
+			tc.mockBehaviour(segment, tc.args)
+			services := &service.Services{Segment: segment}
+
+			// Создание тестового сервера
+			e := echo.New()
+			g := e.Group("/segments")
+			newSegmentRoutes(g, services.Segment)
+
+			// Создание запроса
+			w := httptest.NewRecorder()
+			target := fmt.Sprintf("/segments/%s/purge", url.PathEscape(tc.args.name))
+			if tc.args.format != "" {
+				target += "?format=" + tc.args.format
+			}
+			req := httptest.NewRequest(http.MethodPost, target, nil)
+
+			// Выполнение запроса
+			e.ServeHTTP(w, req)
+
+			// Проверка ответа
+			assert.Equal(t, tc.expectedStatusCode, w.Code)
+			assert.Equal(t, tc.expectedResponseBody, w.Body.String())
+		})
+	}
+}
@@ -4,10 +4,14 @@ import (
 	"avito-rest-api/internal/entity"
 	customError "avito-rest-api/internal/error"
 	"avito-rest-api/internal/service"
+	"encoding/json"
 	"fmt"
 	"github.com/labstack/echo/v4"
+	"mime"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 )
 
 type userRoutes struct {
@@ -20,12 +24,22 @@ func newUserRoutes(g *echo.Group, userService service.User) {
 	}
 
 	g.POST("", r.create)
+	g.POST("/import", r.importUsers)
 	g.GET("", r.getAll)
 	g.GET("/withSegments", r.getAllWithSegments)
+	g.GET("/by-external-id/:ext", r.getByExternalID)
+	g.PUT("/by-external-id/:ext", r.upsertByExternalID)
 	g.GET("/:id", r.getByID)
 	g.GET("/:id/withSegments", r.getByIDWithSegments)
+	g.GET("/:id/segments", r.getSegmentsAt)
 	g.POST("/addUserToSegments", r.addUserToSegments)
 	g.POST("/deleteUserFromSegments", r.deleteUserFromSegments)
+	g.PATCH("/:id/segments", r.updateUserSegments)
+	g.PATCH("/:id/segments/:name", r.updateUserSegment)
+	g.PUT("/:id", r.update)
+	g.PATCH("/:id", r.patch)
+	g.DELETE("/:id", r.deleteByID)
+	g.POST("/:id/restore", r.restore)
 }
 
 type UserCreateResponse struct {
@@ -55,105 +69,274 @@ func (r *userRoutes) create(c echo.Context) error {
 	}
 
 	// Валидация
+	if err := validateUserCreateInput(input, "UserRoutes.create - validation"); err != nil {
+		return errorHandler(c, err)
+	}
+
+	id, err := r.userService.CreateUser(c.Request().Context(), service.UserCreateInput{
+		Name:       input.Name,
+		Lastname:   input.Lastname,
+		Sex:        input.Sex,
+		Age:        input.Age,
+		Attributes: input.Attributes,
+		ExternalID: input.ExternalID,
+	})
+
+	if err != nil {
+		return errorHandler(c, err)
+	}
+
+	return c.JSON(http.StatusCreated, UserCreateResponse{
+		ID: id,
+	})
+}
+
+// validateUserCreateInput проверяет, что в теле запроса указаны все атрибуты пользователя и их значения корректны.
+func validateUserCreateInput(input service.UserCreateInput, location string) error {
 	if input.Name == "" {
-		return errorHandler(c, customError.ErrUserValidationError{ErrBase: customError.ErrBase{
+		return customError.ErrUserValidationError{ErrBase: customError.ErrBase{
 			Comment:  "Invalid request body. Field \"name\" cannot be empty",
-			Location: "UserRoutes.create - validation",
-		}})
+			Location: location,
+		}}
 	} else if len(input.Name) > 1000 {
-		return errorHandler(c, customError.ErrUserValidationError{ErrBase: customError.ErrBase{
+		return customError.ErrUserValidationError{ErrBase: customError.ErrBase{
 			Comment:  "Invalid request body. Length of the field \"name\" cannot be over 1.000 symbols",
-			Location: "UserRoutes.create - validation",
-		}})
+			Location: location,
+		}}
 	}
 	if input.Lastname == "" {
-		return errorHandler(c, customError.ErrUserValidationError{ErrBase: customError.ErrBase{
+		return customError.ErrUserValidationError{ErrBase: customError.ErrBase{
 			Comment:  "Invalid request body. Field \"lastname\" cannot be empty",
-			Location: "UserRoutes.create - validation",
-		}})
+			Location: location,
+		}}
 	} else if len(input.Lastname) > 1000 {
-		return errorHandler(c, customError.ErrUserValidationError{ErrBase: customError.ErrBase{
+		return customError.ErrUserValidationError{ErrBase: customError.ErrBase{
 			Comment:  "Invalid request body. Length of the field \"lastname\" cannot be over 1.000 symbols",
-			Location: "UserRoutes.create - validation",
-		}})
+			Location: location,
+		}}
 	}
 	if input.Sex == -1 {
-		return errorHandler(c, customError.ErrUserValidationError{ErrBase: customError.ErrBase{
+		return customError.ErrUserValidationError{ErrBase: customError.ErrBase{
 			Comment:  "Invalid request body. Required field \"sex\" was not provided",
-			Location: "UserRoutes.create - validation",
-		}})
+			Location: location,
+		}}
 	} else if input.Sex < 0 || input.Sex > 1 {
-		return errorHandler(c, customError.ErrUserValidationError{ErrBase: customError.ErrBase{
+		return customError.ErrUserValidationError{ErrBase: customError.ErrBase{
 			Comment:  "Invalid request body. Field \"sex\" must equals to 0 (man) or 1 (woman)",
-			Location: "UserRoutes.create - validation",
-		}})
+			Location: location,
+		}}
 	}
 	if input.Age == -1 {
-		return errorHandler(c, customError.ErrUserValidationError{ErrBase: customError.ErrBase{
+		return customError.ErrUserValidationError{ErrBase: customError.ErrBase{
 			Comment:  "Invalid request body. Required field \"age\" was not provided",
-			Location: "UserRoutes.create - validation",
-		}})
+			Location: location,
+		}}
 	} else if input.Age <= 0 {
-		return errorHandler(c, customError.ErrUserValidationError{ErrBase: customError.ErrBase{
+		return customError.ErrUserValidationError{ErrBase: customError.ErrBase{
 			Comment:  "Invalid request body. Field \"age\" must be positive integer number",
-			Location: "UserRoutes.create - validation",
-		}})
+			Location: location,
+		}}
+	}
+	if len(input.ExternalID) > 255 {
+		return customError.ErrUserValidationError{ErrBase: customError.ErrBase{
+			Comment:  "Invalid request body. Length of the field \"external_id\" cannot be over 255 symbols",
+			Location: location,
+		}}
 	}
 
-	id, err := r.userService.CreateUser(c.Request().Context(), service.UserCreateInput{
-		Name:     input.Name,
-		Lastname: input.Lastname,
-		Sex:      input.Sex,
-		Age:      input.Age,
-	})
+	return nil
+}
+
+type ImportUsersResponse struct {
+	entity.UserImportReport
+}
 
+// @Summary Импортировать пользователей
+// @Description Создаёт пользователей из тела запроса в формате CSV (заголовок `name,lastname,sex,age`, необязательные
+// @Description столбцы `external_id` и `attributes` с JSON-объектом атрибутов) или NDJSON (один JSON-объект пользователя
+// @Description на строку). Формат задаётся параметром `format`, а при его отсутствии
+// @Description определяется по заголовку `Content-Type` (`text/csv` или `application/x-ndjson`).
+// @Description Каждая строка валидируется по правилам создания пользователя: корректные строки импортируются,
+// @Description а для остальных в ответе возвращается номер строки и причина ошибки. Строки с уже занятым или повторяющимся
+// @Description внешним идентификатором не импортируются.
+// @Description Импортированные пользователи добавляются в сегменты с правилами и процентом раскатки и распределяются
+// @Description по вариантам экспериментов
+// @Tags users
+// @Accept text/csv
+// @Accept application/x-ndjson
+// @Produce json
+// @Param format query string false "Формат данных" Enums(csv, ndjson)
+// @Param data body string true "Импортируемые пользователи"
+// @Success 200 {object} ImportUsersResponse "Отчёт об импорте"
+// @Failure 400 {object} customError.ErrUserValidationError "Неизвестный формат или ошибка разбора данных"
+// @Failure 500 {object} customError.ErrInternalServerError "Внутренняя ошибка сервера"
+// @Router /api/v1/users/import [post]
+func (r *userRoutes) importUsers(c echo.Context) error {
+	format := c.QueryParam("format")
+	if format == "" {
+		mediaType, _, _ := mime.ParseMediaType(c.Request().Header.Get(echo.HeaderContentType))
+		switch mediaType {
+		case "text/csv":
+			format = service.UserImportFormatCSV
+		case "application/x-ndjson":
+			format = service.UserImportFormatNDJSON
+		default:
+			return errorHandler(c, customError.ErrUserValidationError{ErrBase: customError.ErrBase{
+				Comment:  "Cannot detect import format. Provide \"format\" query-param or Content-Type text/csv or application/x-ndjson",
+				Location: "UserRoutes.importUsers - validation",
+			}})
+		}
+	}
+
+	report, err := r.userService.ImportUsers(c.Request().Context(), format, c.Request().Body)
 	if err != nil {
 		return errorHandler(c, err)
 	}
 
-	return c.JSON(http.StatusCreated, UserCreateResponse{
-		ID: id,
-	})
+	return c.JSON(http.StatusOK, ImportUsersResponse{UserImportReport: report})
 }
 
 type GetAllUsersResponse struct {
-	Users []entity.User `json:"users"`
+	entity.UsersPage
 }
 
-// @Summary Получить список всех пользователей
-// @Description Возвращает список абсолютно всех пользователей
+// @Summary Получить список пользователей
+// @Description Возвращает страницу списка пользователей, удовлетворяющих фильтрам, в заданном порядке.
+// @Description Для получения следующей страницы передайте `next_cursor` из ответа в параметр `cursor`,
+// @Description сохранив остальные параметры запроса. Фильтры по дополнительным атрибутам передаются параметрами
+// @Description вида `attributes.<ключ>=<значение>`, например `attributes.city=Москва` или `attributes.premium=true`
 // @Tags users
 // @Produce json
-// @Success 200 {object} GetAllUsersResponse "Список всех пользователей"
+// @Param name query string false "Имя пользователя"
+// @Param lastname query string false "Фамилия пользователя"
+// @Param sex query int false "Пол пользователя, 0 - мужской, 1 - женский" Enums(0, 1)
+// @Param min_age query int false "Минимальный возраст пользователя, включительно"
+// @Param max_age query int false "Максимальный возраст пользователя, включительно"
+// @Param is_deleted query bool false "Пометка об удалении"
+// @Param sort query string false "Поле сортировки: user_id (по умолчанию), name, lastname или age, префикс - задаёт убывание"
+// @Param limit query int false "Размер страницы, от 1 до 1000, по умолчанию 100"
+// @Param cursor query string false "Курсор страницы"
+// @Success 200 {object} GetAllUsersResponse "Страница списка пользователей"
 // @Failure 400 {object} customError.ErrUserValidationError "Ошибка валидации данных запроса"
 // @Failure 500 {object} customError.ErrInternalServerError "Внутренняя ошибка сервера"
 // @Router /api/v1/users [get]
 func (r *userRoutes) getAll(c echo.Context) error {
-	users, err := r.userService.GetAllUsers(c.Request().Context())
+	input := service.UsersInput{
+		Sort:      c.QueryParam("sort"),
+		PageInput: service.PageInput{Cursor: c.QueryParam("cursor")},
+	}
+	if name := c.QueryParam("name"); name != "" {
+		input.Name = &name
+	}
+	if lastname := c.QueryParam("lastname"); lastname != "" {
+		input.Lastname = &lastname
+	}
+	// Фильтры по дополнительным атрибутам передаются параметрами вида attributes.<ключ>=<значение>.
+	// Значение, являющееся JSON-литералом (число, true, false или строка в кавычках), сравнивается
+	// с атрибутом с учётом типа, иначе - как строка
+	for param, values := range c.QueryParams() {
+		key, ok := strings.CutPrefix(param, "attributes.")
+		if !ok || len(values) == 0 {
+			continue
+		}
+		if input.Attributes == nil {
+			input.Attributes = make(map[string]interface{})
+		}
+		input.Attributes[key] = attributeFilterValue(values[0])
+	}
+
+	// Валидация
+	if isDeleted := c.QueryParam("is_deleted"); isDeleted != "" {
+		value, err := strconv.ParseBool(isDeleted)
+		if err != nil {
+			return errorHandler(c, customError.ErrUserValidationError{ErrBase: customError.ErrBase{
+				OriginError:     err,
+				OriginErrorText: err.Error(),
+				Comment:         fmt.Sprintf("Invalid query param \"is_deleted\" = %s, \"is_deleted\" should be boolean", isDeleted),
+				Location:        "UserRoutes.getAll - strconv.ParseBool",
+			}})
+		}
+		input.IsDeleted = &value
+	}
+	intParams := map[string]**int{"sex": &input.Sex, "min_age": &input.MinAge, "max_age": &input.MaxAge}
+	for _, param := range []string{"sex", "min_age", "max_age", "limit"} {
+		value := c.QueryParam(param)
+		if value == "" {
+			continue
+		}
+		number, err := strconv.Atoi(value)
+		if err != nil {
+			return errorHandler(c, customError.ErrUserValidationError{ErrBase: customError.ErrBase{
+				OriginError:     err,
+				OriginErrorText: err.Error(),
+				Comment:         fmt.Sprintf("Invalid query param \"%s\" = %s, \"%s\" should be integer", param, value, param),
+				Location:        "UserRoutes.getAll - strconv.Atoi",
+			}})
+		}
+		if param == "limit" {
+			input.Limit = number
+		} else {
+			*intParams[param] = &number
+		}
+	}
+
+	page, err := r.userService.GetAllUsers(c.Request().Context(), input)
 	if err != nil {
 		return errorHandler(c, err)
 	}
-	return c.JSON(http.StatusOK, GetAllUsersResponse{users})
+	return c.JSON(http.StatusOK, GetAllUsersResponse{page})
+}
+
+// attributeFilterValue разбирает значение фильтра по дополнительному атрибуту пользователя.
+func attributeFilterValue(raw string) interface{} {
+	var value interface{}
+	if err := json.Unmarshal([]byte(raw), &value); err == nil {
+		switch value.(type) {
+		case string, float64, bool:
+			return value
+		}
+	}
+	return raw
 }
 
 type GetAllUsersWithSegmentsResponse struct {
-	Users []entity.UserWithSegments `json:"users"`
+	entity.UsersWithSegmentsPage
 }
 
 // @Summary Получить список всех пользователей, включая их сегменты
-// @Description Возвращает список всех пользователей, включая список активных для каждого пользователя сегментов на момент совершения запроса
+// @Description Возвращает страницу списка пользователей, упорядоченного по ID, включая список активных для каждого
+// @Description пользователя сегментов на момент совершения запроса. Для получения следующей страницы передайте
+// @Description `next_cursor` из ответа в параметр `cursor`
 // @Tags users
 // @Produce json
+// @Param limit query int false "Размер страницы, от 1 до 1000, по умолчанию 100"
+// @Param cursor query string false "Курсор страницы"
 // @Success 200 {object} GetAllUsersWithSegmentsResponse "Список пользователей с их активными сегментами"
 // @Failure 400 {object} customError.ErrUserValidationError "Ошибка валидации данных запроса"
 // @Failure 500 {object} customError.ErrInternalServerError "Внутренняя ошибка сервера"
 // @Router /api/v1/users/withSegments [get]
 func (r *userRoutes) getAllWithSegments(c echo.Context) error {
-	usersWithSegments, err := r.userService.GetAllUsersWithSegments(c.Request().Context())
+	input := service.PageInput{Cursor: c.QueryParam("cursor")}
+
+	// Валидация
+	if limit := c.QueryParam("limit"); limit != "" {
+		var err error
+		input.Limit, err = strconv.Atoi(limit)
+		if err != nil {
+			return errorHandler(c, customError.ErrUserValidationError{ErrBase: customError.ErrBase{
+				OriginError:     err,
+				OriginErrorText: err.Error(),
+				Comment:         fmt.Sprintf("Invalid query param \"limit\" = %s, \"limit\" should be integer", limit),
+				Location:        "UserRoutes.getAllWithSegments - strconv.Atoi",
+			}})
+		}
+	}
+
+	page, err := r.userService.GetAllUsersWithSegments(c.Request().Context(), input)
 	if err != nil {
 		return errorHandler(c, err)
 	}
-	return c.JSON(http.StatusOK, GetAllUsersWithSegmentsResponse{usersWithSegments})
+	return c.JSON(http.StatusOK, GetAllUsersWithSegmentsResponse{page})
 }
 
 type GetUserByIDResponse struct {
@@ -189,6 +372,113 @@ func (r *userRoutes) getByID(c echo.Context) error {
 	return c.JSON(http.StatusOK, GetUserByIDResponse{User: user})
 }
 
+// @Summary Получить пользователя по внешнему идентификатору
+// @Description Возвращает пользователя с указанным внешним идентификатором
+// @Tags users
+// @Produce json
+// @Param ext path string true "Внешний идентификатор пользователя"
+// @Success 200 {object} GetUserByIDResponse "Пользователь с указанным внешним идентификатором"
+// @Failure 400 {object} customError.ErrUserValidationError "Ошибка валидации данных запроса или пользователь удалён"
+// @Failure 404 {object} customError.ErrUserNotFound "Пользователь с указанным внешним идентификатором не был найден"
+// @Failure 500 {object} customError.ErrInternalServerError "Внутренняя ошибка сервера"
+// @Router /api/v1/users/by-external-id/{ext} [get]
+func (r *userRoutes) getByExternalID(c echo.Context) error {
+	externalID, err := externalIDParam(c, "UserRoutes.getByExternalID")
+	if err != nil {
+		return errorHandler(c, err)
+	}
+
+	user, err := r.userService.GetUserByExternalID(c.Request().Context(), externalID)
+	if err != nil {
+		return errorHandler(c, err)
+	}
+
+	return c.JSON(http.StatusOK, GetUserByIDResponse{User: user})
+}
+
+type UpsertUserByExternalIDResponse struct {
+	User    entity.User `json:"user"`
+	Created bool        `json:"created" example:"true"` // true, если пользователь был создан, false - если обновлён
+}
+
+// @Summary Создать или обновить пользователя по внешнему идентификатору
+// @Description Создаёт пользователя с указанным внешним идентификатором или, если он уже существует, заменяет его атрибуты переданными. Повторная отправка тех же данных не создаёт дубликатов
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param ext path string true "Внешний идентификатор пользователя"
+// @Param data body service.UserCreateInput true "Структура с информацией о пользователе. Поле `external_id` можно не указывать"
+// @Success 200 {object} UpsertUserByExternalIDResponse "Пользователь был обновлён"
+// @Success 201 {object} UpsertUserByExternalIDResponse "Пользователь был создан"
+// @Failure 400 {object} customError.ErrUserValidationError "Ошибка валидации данных запроса или пользователь удалён"
+// @Failure 500 {object} customError.ErrInternalServerError "Внутренняя ошибка сервера"
+// @Router /api/v1/users/by-external-id/{ext} [put]
+func (r *userRoutes) upsertByExternalID(c echo.Context) error {
+	externalID, err := externalIDParam(c, "UserRoutes.upsertByExternalID")
+	if err != nil {
+		return errorHandler(c, err)
+	}
+
+	input := service.UserCreateInput{Sex: -1, Age: -1}
+	if err = c.Bind(&input); err != nil {
+		return errorHandler(c, customError.ErrUserValidationError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         "invalid request body",
+			Location:        "UserRoutes.upsertByExternalID - c.Bind",
+		}})
+	}
+
+	// Валидация
+	if err = validateUserCreateInput(input, "UserRoutes.upsertByExternalID - validation"); err != nil {
+		return errorHandler(c, err)
+	}
+	if input.ExternalID != "" && input.ExternalID != externalID {
+		return errorHandler(c, customError.ErrUserValidationError{ErrBase: customError.ErrBase{
+			Comment:  "Invalid request body. Field \"external_id\" must match \"ext\" path-param value",
+			Location: "UserRoutes.upsertByExternalID - validation",
+		}})
+	}
+
+	user, created, err := r.userService.UpsertUserByExternalID(c.Request().Context(), externalID, service.UserCreateInput{
+		Name:       input.Name,
+		Lastname:   input.Lastname,
+		Sex:        input.Sex,
+		Age:        input.Age,
+		Attributes: input.Attributes,
+		ExternalID: externalID,
+	})
+	if err != nil {
+		return errorHandler(c, err)
+	}
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	return c.JSON(status, UpsertUserByExternalIDResponse{User: user, Created: created})
+}
+
+// externalIDParam возвращает внешний идентификатор пользователя из параметра пути "ext".
+func externalIDParam(c echo.Context, location string) (string, error) {
+	externalID, err := url.PathUnescape(c.Param("ext"))
+	if err != nil {
+		return "", customError.ErrUserValidationError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         "Invalid \"ext\" path-param value",
+			Location:        location + " - url.PathUnescape",
+		}}
+	}
+	if strings.TrimSpace(externalID) == "" || len(externalID) > 255 {
+		return "", customError.ErrUserValidationError{ErrBase: customError.ErrBase{
+			Comment:  "Invalid \"ext\" path-param value. \"ext\" should be non-empty string of at most 255 symbols",
+			Location: location,
+		}}
+	}
+	return externalID, nil
+}
+
 type GetUserByIDWithSegmentsResponse struct {
 	User entity.UserWithSegments `json:"user"`
 }
@@ -222,14 +512,66 @@ func (r *userRoutes) getByIDWithSegments(c echo.Context) error {
 	return c.JSON(http.StatusOK, GetUserByIDWithSegmentsResponse{User: userWithSegments})
 }
 
+type GetUserSegmentsAtResponse struct {
+	Segments []entity.UserSegmentInformation `json:"segments"`
+}
+
+// @Summary Получить сегменты пользователя на момент времени
+// @Description Возвращает сегменты, в которые пользователь с указанным ID входил в момент времени `at`,
+// @Description в том числе сегменты, удалённые позднее. Если `at` не указан, возвращаются сегменты на момент совершения запроса
+// @Tags users
+// @Produce json
+// @Param id path int true "ID пользователя"
+// @Param at query string false "Момент времени в формате `15:04:05 02.01.2006`"
+// @Success 200 {object} GetUserSegmentsAtResponse "Сегменты пользователя на указанный момент"
+// @Failure 400 {object} customError.ErrUserValidationError "Ошибка валидации данных запроса"
+// @Failure 404 {object} customError.ErrUserNotFound "Пользователь с указанным ID не был найден"
+// @Failure 500 {object} customError.ErrInternalServerError "Внутренняя ошибка сервера"
+// @Router /api/v1/users/{id}/segments [get]
+func (r *userRoutes) getSegmentsAt(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return errorHandler(c, customError.ErrUserValidationError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         "Invalid \"id\" path-param value. \"id\" should be integer",
+			Location:        "UserRoutes.getSegmentsAt - strconv.Atoi",
+		}})
+	}
+	at, err := parseMomentParam(c.QueryParam("at"))
+	if err != nil {
+		return errorHandler(c, customError.ErrUserValidationError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         fmt.Sprintf("Invalid query param \"at\" = %s, expected format is 15:04:05 02.01.2006", c.QueryParam("at")),
+			Location:        "UserRoutes.getSegmentsAt - parseMomentParam",
+		}})
+	}
+
+	segments, err := r.userService.GetUserSegmentsAt(c.Request().Context(), id, at)
+	if err != nil {
+		return errorHandler(c, err)
+	}
+	if segments == nil {
+		segments = []entity.UserSegmentInformation{}
+	}
+
+	return c.JSON(http.StatusOK, GetUserSegmentsAtResponse{Segments: segments})
+}
+
 // AddUserToSegmentsInput - DTO для маппинга данных
 // из запроса на добавление пользователя в определённые сегменты
 type AddUserToSegmentsInput struct {
-	ID       int `json:"id" example:"16" validate:"required"` // Идентификатор пользователя
-	Segments []struct {
-		Name    string `json:"name" example:"AVITO_MUSIC_SERVICE" validate:"required"` // Наименование сегмента, в который необходимо добавить пользователя
-		EndDate string `json:"end_date" example:"10:00:00 25.09.2023"`                 // Необязательное поле, если отсутствует, значит дата выхода пользователя из сегмента не определена
+	ID         int    `json:"id" example:"16"`                // Идентификатор пользователя
+	ExternalID string `json:"external_id" example:"crm-1842"` // Внешний идентификатор пользователя, указывается вместо "id"
+	Segments   []struct {
+		Name      string `json:"name" example:"AVITO_MUSIC_SERVICE" validate:"required"` // Наименование сегмента, в который необходимо добавить пользователя
+		StartDate string `json:"start_date" example:"10:00:00 18.09.2023"`               // Необязательное поле, если отсутствует, значит пользователь входит в сегмент немедленно
+		EndDate   string `json:"end_date" example:"10:00:00 25.09.2023"`                 // Необязательное поле, если отсутствует, значит дата выхода пользователя из сегмента не определена
 	} `json:"segments"` // Сегменты, в которые необходимо добавить пользователя
+	// Необязательное поле, если true, то при добавлении в сегмент группы взаимоисключающих сегментов пользователь
+	// выходит из сегмента этой группы, в который входил ранее. Иначе такое добавление отклоняется
+	ReplaceExclusive bool `json:"replace_exclusive" example:"false"`
 }
 
 type AddUserToSegmentsResponse struct {
@@ -239,10 +581,11 @@ type AddUserToSegmentsResponse struct {
 // @Summary Добавить пользователя в сегменты
 // @Description Добавляет пользователя с указанным ID в указанные сегменты
 // @Tags users
-// @Param data body AddUserToSegmentsInput true "Структура, содержащая ID пользователя и наименование сегментов, в которые необходимо добавить пользователя. Поле `end_date` у сегмента является опциональным, и, если не  установлено, сигнализирует о том, что время выхода пользователя из сегмента не определено (пока сегмент  не будет удалён или пользователь не будет удалён из этого сегмента)"
+// @Param data body AddUserToSegmentsInput true "Структура, содержащая ID или внешний идентификатор пользователя и наименование сегментов, в которые необходимо добавить пользователя. Поле `end_date` у сегмента является опциональным, и, если не  установлено, сигнализирует о том, что время выхода пользователя из сегмента не определено (пока сегмент  не будет удалён или пользователь не будет удалён из этого сегмента)"
 // @Success 200 {object} AddUserToSegmentsResponse "Сообщение об успехе"
 // @Failure 400 {object} customError.ErrUserValidationError "Ошибка валидации данных запроса"
 // @Failure 404 {object} customError.ErrUserNotFound "Пользователь с указанным ID не был найден или некоторые из указанных сегментов не существуют"
+// @Failure 409 {object} customError.ErrSegmentGroupConflict "Пользователь уже входит во взаимоисключающий сегмент"
 // @Failure 500 {object} customError.ErrInternalServerError "Внутренняя ошибка сервера"
 // @Router /api/v1/users/addUserToSegments [post]
 func (r *userRoutes) addUserToSegments(c echo.Context) error {
@@ -257,17 +600,21 @@ func (r *userRoutes) addUserToSegments(c echo.Context) error {
 		}})
 	}
 
-	var userID int = segmentsInput.ID
+	userID, err := r.resolveUserID(c, segmentsInput.ID, segmentsInput.ExternalID, "UserRoutes.addUserToSegments")
+	if err != nil {
+		return errorHandler(c, err)
+	}
 	var segments []entity.UserSegmentInformation
 
 	for _, s := range segmentsInput.Segments {
 		segments = append(segments, entity.UserSegmentInformation{
-			Name:    s.Name,
-			EndDate: s.EndDate,
+			Name:      s.Name,
+			StartDate: s.StartDate,
+			EndDate:   s.EndDate,
 		})
 	}
 
-	err = r.userService.AddUserToSegments(c.Request().Context(), userID, segments)
+	err = r.userService.AddUserToSegments(c.Request().Context(), userID, segments, segmentsInput.ReplaceExclusive)
 	if err != nil {
 		return errorHandler(c, err)
 	}
@@ -281,8 +628,9 @@ func (r *userRoutes) addUserToSegments(c echo.Context) error {
 // DeleteUserFromSegmentsInput - DTO для маппинга данных из запроса на
 // удаление пользователя из определённых сегментов
 type DeleteUserFromSegmentsInput struct {
-	ID       int `json:"id" example:"16" validate:"required"` // Идентификатор пользователя
-	Segments []struct {
+	ID         int    `json:"id" example:"16"`                // Идентификатор пользователя
+	ExternalID string `json:"external_id" example:"crm-1842"` // Внешний идентификатор пользователя, указывается вместо "id"
+	Segments   []struct {
 		Name string `json:"name" example:"AVITO_MUSIC_SERVICE" validate:"required"` // Наименование сегмента
 	} `json:"segments"` // Сегменты, из которых необходимо удалить пользователя
 }
@@ -294,7 +642,7 @@ type DeleteUserFromSegmentsResponse struct {
 // @Summary Удалить пользователя из сегментов
 // @Description Удаляет пользователя с указанным ID из указанных сегментов
 // @Tags users
-// @Param data body DeleteUserFromSegmentsInput true "Структура, содержащая ID пользователя и наименования сегментов, из которых пользователя необходимо удалить"
+// @Param data body DeleteUserFromSegmentsInput true "Структура, содержащая ID или внешний идентификатор пользователя и наименования сегментов, из которых пользователя необходимо удалить"
 // @Success 200 {object} DeleteUserFromSegmentsResponse "Сообщение об успехе"
 // @Failure 400 {object} customError.ErrUserValidationError "Ошибка валидации данных запроса, может возникать, если пользователь не входит в указанные сегменты"
 // @Failure 404 {object} customError.ErrUserNotFound "Пользователь с указанным ID не был найден или некоторые из указанных сегментов не существуют"
@@ -313,7 +661,10 @@ func (r *userRoutes) deleteUserFromSegments(c echo.Context) error {
 		}})
 	}
 
-	var id int = input.ID
+	id, err := r.resolveUserID(c, input.ID, input.ExternalID, "UserRoutes.deleteUserFromSegments")
+	if err != nil {
+		return errorHandler(c, err)
+	}
 
 	var segments []string
 	for _, s := range input.Segments {
@@ -329,3 +680,376 @@ func (r *userRoutes) deleteUserFromSegments(c echo.Context) error {
 		Message: fmt.Sprintf("user %d was successfully removed from segments", id),
 	})
 }
+
+// resolveUserID возвращает идентификатор пользователя, указанного в теле запроса
+// либо по `id`, либо по внешнему идентификатору `externalID`.
+func (r *userRoutes) resolveUserID(c echo.Context, id int, externalID string, location string) (int, error) {
+	if externalID == "" {
+		return id, nil
+	}
+	if id != 0 {
+		return 0, customError.ErrUserValidationError{ErrBase: customError.ErrBase{
+			Comment:  "Invalid request body. Only one of the fields \"id\" and \"external_id\" can be provided",
+			Location: location,
+		}}
+	}
+
+	user, err := r.userService.GetUserByExternalID(c.Request().Context(), externalID)
+	if err != nil {
+		return 0, err
+	}
+	return user.ID, nil
+}
+
+// UpdateUserSegmentsInput - DTO для маппинга данных из запроса на одновременное
+// добавление пользователя в одни сегменты и удаление из других
+type UpdateUserSegmentsInput struct {
+	SegmentsToAdd []struct {
+		Name      string `json:"name" example:"AVITO_MUSIC_SERVICE" validate:"required"` // Наименование сегмента, в который необходимо добавить пользователя
+		StartDate string `json:"start_date" example:"10:00:00 18.09.2023"`               // Необязательное поле, если отсутствует, значит пользователь входит в сегмент немедленно
+		EndDate   string `json:"end_date" example:"10:00:00 25.09.2023"`                 // Необязательное поле, если отсутствует, значит дата выхода пользователя из сегмента не определена
+	} `json:"add"` // Сегменты, в которые необходимо добавить пользователя
+	SegmentsToDelete []struct {
+		Name string `json:"name" example:"AVITO_VOICE_MESSAGES" validate:"required"` // Наименование сегмента
+	} `json:"delete"` // Сегменты, из которых необходимо удалить пользователя
+}
+
+type UpdateUserSegmentsResponse struct {
+	Message string `json:"message" example:"segments of user 16 were successfully updated"`
+}
+
+// @Summary Добавить пользователя в сегменты и удалить из сегментов
+// @Description Добавляет пользователя с указанным ID в одни сегменты и удаляет из других в рамках одной транзакции.
+// @Description Если хотя бы одна из операций не может быть выполнена, не выполняется ни одна из них.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param id path int true "ID пользователя"
+// @Param data body UpdateUserSegmentsInput true "Структура, содержащая сегменты, в которые необходимо добавить пользователя (поле `end_date` опционально), и сегменты, из которых пользователя необходимо удалить. Хотя бы один из списков должен быть непустым"
+// @Success 200 {object} UpdateUserSegmentsResponse "Сообщение об успехе"
+// @Failure 400 {object} customError.ErrUserValidationError "Ошибка валидации данных запроса"
+// @Failure 404 {object} customError.ErrUserNotFound "Пользователь с указанным ID не был найден или некоторые из указанных сегментов не существуют"
+// @Failure 409 {object} customError.ErrSegmentGroupConflict "Добавляемый сегмент взаимоисключающий с сегментом, в который входит пользователь"
+// @Failure 500 {object} customError.ErrInternalServerError "Внутренняя ошибка сервера"
+// @Router /api/v1/users/{id}/segments [patch]
+func (r *userRoutes) updateUserSegments(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return errorHandler(c, customError.ErrUserValidationError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         "Invalid \"id\" path-param value. \"id\" should be integer",
+			Location:        "UserRoutes.updateUserSegments - strconv.Atoi",
+		}})
+	}
+
+	var input UpdateUserSegmentsInput
+	if err = c.Bind(&input); err != nil {
+		return errorHandler(c, customError.ErrUserValidationError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         "Failed to parse request's body",
+			Location:        "UserRoutes.updateUserSegments - c.Bind",
+		}})
+	}
+
+	var segmentsToAdd []entity.UserSegmentInformation
+	for _, s := range input.SegmentsToAdd {
+		segmentsToAdd = append(segmentsToAdd, entity.UserSegmentInformation{
+			Name:      s.Name,
+			StartDate: s.StartDate,
+			EndDate:   s.EndDate,
+		})
+	}
+
+	var segmentsToDelete []string
+	for _, s := range input.SegmentsToDelete {
+		segmentsToDelete = append(segmentsToDelete, s.Name)
+	}
+
+	err = r.userService.UpdateUserSegments(c.Request().Context(), id, segmentsToAdd, segmentsToDelete)
+	if err != nil {
+		return errorHandler(c, err)
+	}
+
+	return c.JSON(http.StatusOK, UpdateUserSegmentsResponse{
+		Message: fmt.Sprintf("segments of user %d were successfully updated", id),
+	})
+}
+
+// UpdateUserSegmentInput - DTO для маппинга данных из запроса на изменение
+// даты выхода пользователя из сегмента
+type UpdateUserSegmentInput struct {
+	EndDate *string `json:"end_date" example:"10:00:00 25.10.2023"` // Новая дата выхода пользователя из сегмента, пустая строка делает вхождение бессрочным
+}
+
+type UpdateUserSegmentResponse struct {
+	Segment entity.UserSegmentInformation `json:"segment"`
+}
+
+// @Summary Изменить дату выхода пользователя из сегмента
+// @Description Переносит дату выхода пользователя с указанным ID из сегмента в текущем вхождении или, если передана пустая строка, делает вхождение бессрочным.
+// @Description Изменение записывается в историю изменений вхождения
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param id path int true "ID пользователя"
+// @Param name path string true "Наименование сегмента"
+// @Param data body UpdateUserSegmentInput true "Структура, содержащая новую дату выхода пользователя из сегмента"
+// @Success 200 {object} UpdateUserSegmentResponse "Изменённое вхождение пользователя в сегмент"
+// @Failure 400 {object} customError.ErrUserValidationError "Ошибка валидации данных запроса или пользователь не входит в сегмент"
+// @Failure 404 {object} customError.ErrUserNotFound "Пользователь с указанным ID или сегмент не был найден"
+// @Failure 500 {object} customError.ErrInternalServerError "Внутренняя ошибка сервера"
+// @Router /api/v1/users/{id}/segments/{name} [patch]
+func (r *userRoutes) updateUserSegment(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return errorHandler(c, customError.ErrUserValidationError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         "Invalid \"id\" path-param value. \"id\" should be integer",
+			Location:        "UserRoutes.updateUserSegment - strconv.Atoi",
+		}})
+	}
+
+	var input UpdateUserSegmentInput
+	if err = c.Bind(&input); err != nil {
+		return errorHandler(c, customError.ErrUserValidationError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         "Failed to parse request's body",
+			Location:        "UserRoutes.updateUserSegment - c.Bind",
+		}})
+	}
+	if input.EndDate == nil {
+		return errorHandler(c, customError.ErrUserValidationError{ErrBase: customError.ErrBase{
+			Comment:  "Invalid request body. Required field \"end_date\" was not provided",
+			Location: "UserRoutes.updateUserSegment - validation",
+		}})
+	}
+
+	segment, err := r.userService.UpdateUserSegmentEndDate(c.Request().Context(), id, c.Param("name"), *input.EndDate)
+	if err != nil {
+		return errorHandler(c, err)
+	}
+
+	return c.JSON(http.StatusOK, UpdateUserSegmentResponse{Segment: segment})
+}
+
+type UpdateUserResponse struct {
+	User entity.User `json:"user"`
+}
+
+// @Summary Изменить пользователя
+// @Description Заменяет все атрибуты пользователя с указанным ID значениями из тела запроса. Вхождение пользователя
+// @Description в сегменты с правилами приводится в соответствие с новыми атрибутами
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param id path int true "ID пользователя"
+// @Param data body service.UserCreateInput true "Новые атрибуты пользователя"
+// @Success 200 {object} UpdateUserResponse "Изменённый пользователь"
+// @Failure 400 {object} customError.ErrUserValidationError "Ошибка валидации данных запроса"
+// @Failure 404 {object} customError.ErrUserNotFound "Пользователь с указанным ID не был найден"
+// @Failure 500 {object} customError.ErrInternalServerError "Внутренняя ошибка сервера"
+// @Router /api/v1/users/{id} [put]
+func (r *userRoutes) update(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return errorHandler(c, customError.ErrUserValidationError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         "Invalid \"id\" path-param value. \"id\" should be integer",
+			Location:        "UserRoutes.update - strconv.Atoi",
+		}})
+	}
+
+	input := service.UserCreateInput{Sex: -1, Age: -1}
+	if err = c.Bind(&input); err != nil {
+		return errorHandler(c, customError.ErrUserValidationError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         "invalid request body",
+			Location:        "UserRoutes.update - c.Bind",
+		}})
+	}
+
+	// Валидация
+	if err = validateUserCreateInput(input, "UserRoutes.update - validation"); err != nil {
+		return errorHandler(c, err)
+	}
+
+	// Отсутствующие дополнительные атрибуты при полной замене удаляются
+	if input.Attributes == nil {
+		input.Attributes = map[string]interface{}{}
+	}
+
+	user, err := r.userService.UpdateUser(c.Request().Context(), id, service.UserUpdateInput{
+		Name:       &input.Name,
+		Lastname:   &input.Lastname,
+		Sex:        &input.Sex,
+		Age:        &input.Age,
+		Attributes: input.Attributes,
+	})
+	if err != nil {
+		return errorHandler(c, err)
+	}
+
+	return c.JSON(http.StatusOK, UpdateUserResponse{User: user})
+}
+
+// @Summary Частично изменить пользователя
+// @Description Изменяет атрибуты пользователя с указанным ID, переданные в теле запроса. Отсутствующие атрибуты
+// @Description не изменяются. Вхождение пользователя в сегменты с правилами приводится в соответствие с новыми атрибутами
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param id path int true "ID пользователя"
+// @Param data body service.UserUpdateInput true "Изменяемые атрибуты пользователя"
+// @Success 200 {object} UpdateUserResponse "Изменённый пользователь"
+// @Failure 400 {object} customError.ErrUserValidationError "Ошибка валидации данных запроса"
+// @Failure 404 {object} customError.ErrUserNotFound "Пользователь с указанным ID не был найден"
+// @Failure 500 {object} customError.ErrInternalServerError "Внутренняя ошибка сервера"
+// @Router /api/v1/users/{id} [patch]
+func (r *userRoutes) patch(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return errorHandler(c, customError.ErrUserValidationError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         "Invalid \"id\" path-param value. \"id\" should be integer",
+			Location:        "UserRoutes.patch - strconv.Atoi",
+		}})
+	}
+
+	var input service.UserUpdateInput
+	if err = c.Bind(&input); err != nil {
+		return errorHandler(c, customError.ErrUserValidationError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         "invalid request body",
+			Location:        "UserRoutes.patch - c.Bind",
+		}})
+	}
+
+	// Валидация
+	if input.Name == nil && input.Lastname == nil && input.Sex == nil && input.Age == nil && input.Attributes == nil {
+		return errorHandler(c, customError.ErrUserValidationError{ErrBase: customError.ErrBase{
+			Comment:  "Invalid request body. At least one of the fields \"name\", \"lastname\", \"sex\", \"age\", \"attributes\" must be provided",
+			Location: "UserRoutes.patch - validation",
+		}})
+	}
+	if input.Name != nil {
+		if *input.Name == "" {
+			return errorHandler(c, customError.ErrUserValidationError{ErrBase: customError.ErrBase{
+				Comment:  "Invalid request body. Field \"name\" cannot be empty",
+				Location: "UserRoutes.patch - validation",
+			}})
+		} else if len(*input.Name) > 1000 {
+			return errorHandler(c, customError.ErrUserValidationError{ErrBase: customError.ErrBase{
+				Comment:  "Invalid request body. Length of the field \"name\" cannot be over 1.000 symbols",
+				Location: "UserRoutes.patch - validation",
+			}})
+		}
+	}
+	if input.Lastname != nil {
+		if *input.Lastname == "" {
+			return errorHandler(c, customError.ErrUserValidationError{ErrBase: customError.ErrBase{
+				Comment:  "Invalid request body. Field \"lastname\" cannot be empty",
+				Location: "UserRoutes.patch - validation",
+			}})
+		} else if len(*input.Lastname) > 1000 {
+			return errorHandler(c, customError.ErrUserValidationError{ErrBase: customError.ErrBase{
+				Comment:  "Invalid request body. Length of the field \"lastname\" cannot be over 1.000 symbols",
+				Location: "UserRoutes.patch - validation",
+			}})
+		}
+	}
+	if input.Sex != nil && (*input.Sex < 0 || *input.Sex > 1) {
+		return errorHandler(c, customError.ErrUserValidationError{ErrBase: customError.ErrBase{
+			Comment:  "Invalid request body. Field \"sex\" must equals to 0 (man) or 1 (woman)",
+			Location: "UserRoutes.patch - validation",
+		}})
+	}
+	if input.Age != nil && *input.Age <= 0 {
+		return errorHandler(c, customError.ErrUserValidationError{ErrBase: customError.ErrBase{
+			Comment:  "Invalid request body. Field \"age\" must be positive integer number",
+			Location: "UserRoutes.patch - validation",
+		}})
+	}
+
+	user, err := r.userService.UpdateUser(c.Request().Context(), id, input)
+	if err != nil {
+		return errorHandler(c, err)
+	}
+
+	return c.JSON(http.StatusOK, UpdateUserResponse{User: user})
+}
+
+type DeleteUserResponse struct {
+	Message string `json:"message" example:"user 16 was successfully deleted"`
+}
+
+// @Summary Удалить пользователя
+// @Description Помечает пользователя с указанным ID как удалённого. Пользователь выходит из всех сегментов,
+// @Description в которые входит на момент удаления, история его сегментов сохраняется
+// @Tags users
+// @Produce json
+// @Param id path int true "ID пользователя"
+// @Success 200 {object} DeleteUserResponse "Сообщение об успешном удалении"
+// @Failure 400 {object} customError.ErrUserDeleted "Пользователь уже удалён"
+// @Failure 404 {object} customError.ErrUserNotFound "Пользователь с указанным ID не был найден"
+// @Failure 500 {object} customError.ErrInternalServerError "Внутренняя ошибка сервера"
+// @Router /api/v1/users/{id} [delete]
+func (r *userRoutes) deleteByID(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return errorHandler(c, customError.ErrUserValidationError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         "Invalid \"id\" path-param value. \"id\" should be integer",
+			Location:        "UserRoutes.deleteByID - strconv.Atoi",
+		}})
+	}
+
+	if err = r.userService.DeleteUser(c.Request().Context(), id); err != nil {
+		return errorHandler(c, err)
+	}
+
+	return c.JSON(http.StatusOK, DeleteUserResponse{Message: fmt.Sprintf("user %d was successfully deleted", id)})
+}
+
+type RestoreUserResponse struct {
+	User entity.User `json:"user"`
+}
+
+// @Summary Восстановить пользователя
+// @Description Снимает с пользователя с указанным ID пометку об удалении. Пользователь возвращается в сегменты
+// @Description с правилами и процентом раскатки, которым удовлетворяет, и в сегменты вариантов экспериментов.
+// @Description Сегменты, в которые пользователь был добавлен вручную, не восстанавливаются
+// @Tags users
+// @Produce json
+// @Param id path int true "ID пользователя"
+// @Success 200 {object} RestoreUserResponse "Восстановленный пользователь"
+// @Failure 400 {object} customError.ErrUserValidationError "Пользователь не удалён"
+// @Failure 404 {object} customError.ErrUserNotFound "Пользователь с указанным ID не был найден"
+// @Failure 500 {object} customError.ErrInternalServerError "Внутренняя ошибка сервера"
+// @Router /api/v1/users/{id}/restore [post]
+func (r *userRoutes) restore(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return errorHandler(c, customError.ErrUserValidationError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         "Invalid \"id\" path-param value. \"id\" should be integer",
+			Location:        "UserRoutes.restore - strconv.Atoi",
+		}})
+	}
+
+	user, err := r.userService.RestoreUser(c.Request().Context(), id)
+	if err != nil {
+		return errorHandler(c, err)
+	}
+
+	return c.JSON(http.StatusOK, RestoreUserResponse{User: user})
+}
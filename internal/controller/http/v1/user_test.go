@@ -13,8 +13,10 @@ import (
 	"go.uber.org/mock/gomock"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"strconv"
 	"testing"
+	"time"
 )
 
 func TestUserRoutes_create(t *testing.T) {
@@ -51,6 +53,47 @@ func TestUserRoutes_create(t *testing.T) {
 			expectedStatusCode:   201,
 			expectedResponseBody: `{"id":1}` + "\n",
 		},
+		{
+			name: "Ok, with attributes",
+			args: args{
+				ctx: context.Background(),
+				input: service.UserCreateInput{
+					Name:       "Дмитрий",
+					Lastname:   "Поплавский",
+					Sex:        0,
+					Age:        45,
+					Attributes: map[string]interface{}{"city": "Москва", "platform": "ios", "registered_at": "2023-08-01"},
+				},
+			},
+			inputBody: `{"name":"Дмитрий","lastname":"Поплавский","sex":0,"age":45,"attributes":{"city":"Москва","platform":"ios","registered_at":"2023-08-01"}}`,
+			mockBehaviour: func(m *mock_service.MockUser, args args) {
+				m.EXPECT().CreateUser(args.ctx, args.input).Return(2, nil)
+			},
+			expectedStatusCode:   201,
+			expectedResponseBody: `{"id":2}` + "\n",
+		},
+		{
+			name: "Invalid attributes",
+			args: args{
+				ctx: context.Background(),
+				input: service.UserCreateInput{
+					Name:       "Дмитрий",
+					Lastname:   "Поплавский",
+					Sex:        0,
+					Age:        45,
+					Attributes: map[string]interface{}{"city": map[string]interface{}{"name": "Москва"}},
+				},
+			},
+			inputBody: `{"name":"Дмитрий","lastname":"Поплавский","sex":0,"age":45,"attributes":{"city":{"name":"Москва"}}}`,
+			mockBehaviour: func(m *mock_service.MockUser, args args) {
+				m.EXPECT().CreateUser(args.ctx, args.input).Return(0, customError.ErrUserValidationError{ErrBase: customError.ErrBase{
+					Comment:  "Invalid value of attribute \"city\", expected string, number or boolean",
+					Location: "UserService.CreateUser",
+				}})
+			},
+			expectedStatusCode:   400,
+			expectedResponseBody: `{"origin_error_text":"","title":"ErrUserValidationError","comment":"Invalid value of attribute \"city\", expected string, number or boolean","location":"UserService.CreateUser"}` + "\n",
+		},
 		{
 			name:                 "Invalid name: empty name",
 			args:                 args{ctx: context.Background()},
@@ -151,11 +194,23 @@ func TestUserRoutes_create(t *testing.T) {
 
 func TestUserRoutes_getAll(t *testing.T) {
 	type args struct {
-		ctx context.Context
+		ctx   context.Context
+		query string
 	}
 
 	type MockBehaviour func(m *mock_service.MockUser, args args)
 
+	name, sex, minAge, maxAge, isDeleted := "Дмитрий", 0, 40, 50, false
+	user := entity.User{
+		ID:       1,
+		Name:     "Дмитрий",
+		Lastname: "Поплавский",
+		Sex:      0,
+		SexText:  "мужской",
+		Age:      45,
+	}
+	userJSON := `{"user_id":1,"name":"Дмитрий","lastname":"Поплавский","sex":0,"sex_text":"мужской","age":45,"is_deleted":false}`
+
 	testCases := []struct {
 		name                 string
 		args                 args
@@ -167,19 +222,77 @@ func TestUserRoutes_getAll(t *testing.T) {
 			name: "Ok",
 			args: args{ctx: context.Background()},
 			mockBehaviour: func(m *mock_service.MockUser, args args) {
-				m.EXPECT().GetAllUsers(args.ctx).Return([]entity.User{
-					{
-						ID:       1,
-						Name:     "Дмитрий",
-						Lastname: "Поплавский",
-						Sex:      0,
-						SexText:  "мужской",
-						Age:      45,
-					},
+				m.EXPECT().GetAllUsers(args.ctx, service.UsersInput{}).Return(entity.UsersPage{
+					Users: []entity.User{user},
+				}, nil)
+			},
+			expectedStatusCode:   200,
+			expectedResponseBody: `{"users":[` + userJSON + `]}` + "\n",
+		},
+		{
+			name: "Ok, with filters, sort and next page",
+			args: args{
+				ctx:   context.Background(),
+				query: "?name=" + url.QueryEscape(name) + "&sex=0&min_age=40&max_age=50&is_deleted=false&sort=-age&limit=1&cursor=abc",
+			},
+			mockBehaviour: func(m *mock_service.MockUser, args args) {
+				m.EXPECT().GetAllUsers(args.ctx, service.UsersInput{
+					Name:      &name,
+					Sex:       &sex,
+					MinAge:    &minAge,
+					MaxAge:    &maxAge,
+					IsDeleted: &isDeleted,
+					Sort:      "-age",
+					PageInput: service.PageInput{Cursor: "abc", Limit: 1},
+				}).Return(entity.UsersPage{
+					Users:      []entity.User{user},
+					NextCursor: "def",
+				}, nil)
+			},
+			expectedStatusCode:   200,
+			expectedResponseBody: `{"users":[` + userJSON + `],"next_cursor":"def"}` + "\n",
+		},
+		{
+			name: "Ok, with attributes filters",
+			args: args{
+				ctx:   context.Background(),
+				query: "?attributes.city=" + url.QueryEscape("Москва") + "&attributes.premium=true&attributes.level=3&attributes.code=" + url.QueryEscape(`"007"`),
+			},
+			mockBehaviour: func(m *mock_service.MockUser, args args) {
+				m.EXPECT().GetAllUsers(args.ctx, service.UsersInput{
+					Attributes: map[string]interface{}{"city": "Москва", "premium": true, "level": float64(3), "code": "007"},
+				}).Return(entity.UsersPage{
+					Users: []entity.User{user},
 				}, nil)
 			},
 			expectedStatusCode:   200,
-			expectedResponseBody: `{"users":[{"user_id":1,"name":"Дмитрий","lastname":"Поплавский","sex":0,"sex_text":"мужской","age":45,"is_deleted":false}]}` + "\n",
+			expectedResponseBody: `{"users":[` + userJSON + `]}` + "\n",
+		},
+		{
+			name:                 "Invalid is_deleted",
+			args:                 args{ctx: context.Background(), query: "?is_deleted=maybe"},
+			mockBehaviour:        func(m *mock_service.MockUser, args args) {},
+			expectedStatusCode:   400,
+			expectedResponseBody: `{"origin_error_text":"strconv.ParseBool: parsing \"maybe\": invalid syntax","title":"ErrUserValidationError","comment":"Invalid query param \"is_deleted\" = maybe, \"is_deleted\" should be boolean","location":"UserRoutes.getAll - strconv.ParseBool"}` + "\n",
+		},
+		{
+			name:                 "Invalid integer param",
+			args:                 args{ctx: context.Background(), query: "?min_age=old"},
+			mockBehaviour:        func(m *mock_service.MockUser, args args) {},
+			expectedStatusCode:   400,
+			expectedResponseBody: `{"origin_error_text":"strconv.Atoi: parsing \"old\": invalid syntax","title":"ErrUserValidationError","comment":"Invalid query param \"min_age\" = old, \"min_age\" should be integer","location":"UserRoutes.getAll - strconv.Atoi"}` + "\n",
+		},
+		{
+			name: "Invalid sort",
+			args: args{ctx: context.Background(), query: "?sort=sex"},
+			mockBehaviour: func(m *mock_service.MockUser, args args) {
+				m.EXPECT().GetAllUsers(args.ctx, service.UsersInput{Sort: "sex"}).Return(entity.UsersPage{}, customError.ErrUserValidationError{ErrBase: customError.ErrBase{
+					Comment:  "Invalid sort \"sex\", expected one of: user_id, name, lastname, age, optionally prefixed with \"-\"",
+					Location: "UserService.GetAllUsers - validation",
+				}})
+			},
+			expectedStatusCode:   400,
+			expectedResponseBody: `{"origin_error_text":"","title":"ErrUserValidationError","comment":"Invalid sort \"sex\", expected one of: user_id, name, lastname, age, optionally prefixed with \"-\"","location":"UserService.GetAllUsers - validation"}` + "\n",
 		},
 	}
 
@@ -201,7 +314,7 @@ func TestUserRoutes_getAll(t *testing.T) {
 
 			// Создание запроса
 			w := httptest.NewRecorder()
-			req := httptest.NewRequest(http.MethodGet, "/users", nil)
+			req := httptest.NewRequest(http.MethodGet, "/users"+tc.args.query, nil)
 
 			// Выполнение запроса
 			e.ServeHTTP(w, req)
@@ -215,11 +328,33 @@ func TestUserRoutes_getAll(t *testing.T) {
 
 func TestUserRoutes_getAllWithSegments(t *testing.T) {
 	type args struct {
-		ctx context.Context
+		ctx   context.Context
+		query string
 	}
 
 	type MockBehaviour func(m *mock_service.MockUser, args args)
 
+	userWithSegments := entity.UserWithSegments{
+		User: entity.User{
+			ID:       1,
+			Name:     "Дмитрий",
+			Lastname: "Поплавский",
+			Sex:      0,
+			SexText:  "мужской",
+			Age:      45,
+		},
+		Segments: []entity.UserSegmentInformation{
+			{
+				InfoID:    1,
+				UserID:    1,
+				SegmentID: 1,
+				Name:      "AVITO_BAKERY",
+				StartDate: "10:05:24 07.09.2023",
+			},
+		},
+	}
+	userWithSegmentsJSON := `{"user":{"user_id":1,"name":"Дмитрий","lastname":"Поплавский","sex":0,"sex_text":"мужской","age":45,"is_deleted":false},"segments":[{"information_id":1,"user_id":1,"segment_id":1,"name":"AVITO_BAKERY","start_date":"10:05:24 07.09.2023","end_date":""}]}`
+
 	testCases := []struct {
 		name                 string
 		args                 args
@@ -231,30 +366,43 @@ func TestUserRoutes_getAllWithSegments(t *testing.T) {
 			name: "Ok",
 			args: args{ctx: context.Background()},
 			mockBehaviour: func(m *mock_service.MockUser, args args) {
-				m.EXPECT().GetAllUsersWithSegments(args.ctx).Return([]entity.UserWithSegments{
-					{
-						User: entity.User{
-							ID:       1,
-							Name:     "Дмитрий",
-							Lastname: "Поплавский",
-							Sex:      0,
-							SexText:  "мужской",
-							Age:      45,
-						},
-						Segments: []entity.UserSegmentInformation{
-							{
-								InfoID:    1,
-								UserID:    1,
-								SegmentID: 1,
-								Name:      "AVITO_BAKERY",
-								StartDate: "10:05:24 07.09.2023",
-							},
-						},
-					},
+				m.EXPECT().GetAllUsersWithSegments(args.ctx, service.PageInput{}).Return(entity.UsersWithSegmentsPage{
+					Users: []entity.UserWithSegments{userWithSegments},
+				}, nil)
+			},
+			expectedStatusCode:   200,
+			expectedResponseBody: `{"users":[` + userWithSegmentsJSON + `]}` + "\n",
+		},
+		{
+			name: "Ok, with next page",
+			args: args{ctx: context.Background(), query: "?limit=1&cursor=MA"},
+			mockBehaviour: func(m *mock_service.MockUser, args args) {
+				m.EXPECT().GetAllUsersWithSegments(args.ctx, service.PageInput{Cursor: "MA", Limit: 1}).Return(entity.UsersWithSegmentsPage{
+					Users:      []entity.UserWithSegments{userWithSegments},
+					NextCursor: "MQ",
 				}, nil)
 			},
 			expectedStatusCode:   200,
-			expectedResponseBody: `{"users":[{"user":{"user_id":1,"name":"Дмитрий","lastname":"Поплавский","sex":0,"sex_text":"мужской","age":45,"is_deleted":false},"segments":[{"information_id":1,"user_id":1,"segment_id":1,"name":"AVITO_BAKERY","start_date":"10:05:24 07.09.2023","end_date":""}]}]}` + "\n",
+			expectedResponseBody: `{"users":[` + userWithSegmentsJSON + `],"next_cursor":"MQ"}` + "\n",
+		},
+		{
+			name:                 "Invalid limit",
+			args:                 args{ctx: context.Background(), query: "?limit=all"},
+			mockBehaviour:        func(m *mock_service.MockUser, args args) {},
+			expectedStatusCode:   400,
+			expectedResponseBody: `{"origin_error_text":"strconv.Atoi: parsing \"all\": invalid syntax","title":"ErrUserValidationError","comment":"Invalid query param \"limit\" = all, \"limit\" should be integer","location":"UserRoutes.getAllWithSegments - strconv.Atoi"}` + "\n",
+		},
+		{
+			name: "Limit out of range",
+			args: args{ctx: context.Background(), query: "?limit=5000"},
+			mockBehaviour: func(m *mock_service.MockUser, args args) {
+				m.EXPECT().GetAllUsersWithSegments(args.ctx, service.PageInput{Limit: 5000}).Return(entity.UsersWithSegmentsPage{}, customError.ErrUserValidationError{ErrBase: customError.ErrBase{
+					Comment:  "Invalid limit 5000, expected integer number from range [1, 1000]",
+					Location: "UserService.GetAllUsersWithSegments - validation",
+				}})
+			},
+			expectedStatusCode:   400,
+			expectedResponseBody: `{"origin_error_text":"","title":"ErrUserValidationError","comment":"Invalid limit 5000, expected integer number from range [1, 1000]","location":"UserService.GetAllUsersWithSegments - validation"}` + "\n",
 		},
 	}
 
@@ -276,7 +424,7 @@ func TestUserRoutes_getAllWithSegments(t *testing.T) {
 
 			// Создание запроса
 			w := httptest.NewRecorder()
-			req := httptest.NewRequest(http.MethodGet, "/users/withSegments", nil)
+			req := httptest.NewRequest(http.MethodGet, "/users/withSegments"+tc.args.query, nil)
 
 			// Выполнение запроса
 			e.ServeHTTP(w, req)
@@ -538,10 +686,127 @@ func TestUserRoutes_getByIWithSegments(t *testing.T) {
 	}
 }
 
+func TestUserRoutes_getSegmentsAt(t *testing.T) {
+	type args struct {
+		ctx context.Context
+		id  string
+		at  string
+	}
+
+	type MockBehaviour func(m *mock_service.MockUser, args args)
+
+	testCases := []struct {
+		name                 string
+		args                 args
+		mockBehaviour        MockBehaviour
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name: "Ok",
+			args: args{
+				ctx: context.Background(),
+				id:  "16",
+				at:  "12:00:00 01.09.2023",
+			},
+			mockBehaviour: func(m *mock_service.MockUser, args args) {
+				at, _ := time.Parse("15:04:05 02.01.2006", args.at)
+				m.EXPECT().GetUserSegmentsAt(args.ctx, 16, at).Return([]entity.UserSegmentInformation{
+					{InfoID: 3, UserID: 16, SegmentID: 1, Name: "AVITO_BAKERY", StartDate: "10:00:00 01.09.2023", EndDate: "18:00:00 01.09.2023"},
+				}, nil)
+			},
+			expectedStatusCode:   200,
+			expectedResponseBody: `{"segments":[{"information_id":3,"user_id":16,"segment_id":1,"name":"AVITO_BAKERY","start_date":"10:00:00 01.09.2023","end_date":"18:00:00 01.09.2023"}]}` + "\n",
+		},
+		{
+			name: "Ok, current moment when \"at\" is not provided",
+			args: args{
+				ctx: context.Background(),
+				id:  "16",
+			},
+			mockBehaviour: func(m *mock_service.MockUser, args args) {
+				m.EXPECT().GetUserSegmentsAt(args.ctx, 16, time.Time{}).Return(nil, nil)
+			},
+			expectedStatusCode:   200,
+			expectedResponseBody: `{"segments":[]}` + "\n",
+		},
+		{
+			name: "Invalid id",
+			args: args{
+				ctx: context.Background(),
+				id:  "abc",
+			},
+			mockBehaviour:        func(m *mock_service.MockUser, args args) {},
+			expectedStatusCode:   400,
+			expectedResponseBody: `{"origin_error_text":"strconv.Atoi: parsing \"abc\": invalid syntax","title":"ErrUserValidationError","comment":"Invalid \"id\" path-param value. \"id\" should be integer","location":"UserRoutes.getSegmentsAt - strconv.Atoi"}` + "\n",
+		},
+		{
+			name: "Invalid \"at\" format",
+			args: args{
+				ctx: context.Background(),
+				id:  "16",
+				at:  "01.09.2023",
+			},
+			mockBehaviour:        func(m *mock_service.MockUser, args args) {},
+			expectedStatusCode:   400,
+			expectedResponseBody: `{"origin_error_text":"parsing time \"01.09.2023\" as \"15:04:05 02.01.2006\": cannot parse \".09.2023\" as \":\"","title":"ErrUserValidationError","comment":"Invalid query param \"at\" = 01.09.2023, expected format is 15:04:05 02.01.2006","location":"UserRoutes.getSegmentsAt - parseMomentParam"}` + "\n",
+		},
+		{
+			name: "User not found",
+			args: args{
+				ctx: context.Background(),
+				id:  "16",
+			},
+			mockBehaviour: func(m *mock_service.MockUser, args args) {
+				m.EXPECT().GetUserSegmentsAt(args.ctx, 16, time.Time{}).Return(nil, customError.ErrUserNotFound{ErrBase: customError.ErrBase{
+					Comment:  "User with id 16 does not exist",
+					Location: "UserRepository.GetUserByID",
+				}})
+			},
+			expectedStatusCode:   404,
+			expectedResponseBody: `{"origin_error_text":"","title":"ErrUserNotFound","comment":"User with id 16 does not exist","location":"UserRepository.GetUserByID"}` + "\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Инициализация зависимостей
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			// Инициализация мока сервиса
+			user := mock_service.NewMockUser(ctrl)
+			tc.mockBehaviour(user, tc.args)
+			services := &service.Services{User: user}
+
+			// Создание тестового сервера
+			e := echo.New()
+			g := e.Group("/users")
+			newUserRoutes(g, services.User)
+
+			// Создание запроса
+			w := httptest.NewRecorder()
+			target := fmt.Sprintf("/users/%s/segments", tc.args.id)
+			if tc.args.at != "" {
+				target += "?at=" + url.QueryEscape(tc.args.at)
+			}
+			req := httptest.NewRequest(http.MethodGet, target, nil)
+
+			// Выполнение запроса
+			e.ServeHTTP(w, req)
+
+			// Проверка ответа
+			assert.Equal(t, tc.expectedStatusCode, w.Code)
+			assert.Equal(t, tc.expectedResponseBody, w.Body.String())
+		})
+	}
+}
+
 func TestUserRoutes_addUserToSegments(t *testing.T) {
 	type argsInput struct {
-		UserID   int
-		Segments []entity.UserSegmentInformation
+		UserID           int
+		Segments         []entity.UserSegmentInformation
+		ReplaceExclusive bool
 	}
 
 	type args struct {
@@ -575,11 +840,107 @@ func TestUserRoutes_addUserToSegments(t *testing.T) {
 			},
 			inputBody: `{"id":1,"segments":[{"name":"AVITO_BAKERY","end_date":"10:00:00 25.09.2023"}]}`,
 			mockBehaviour: func(m *mock_service.MockUser, args args) {
-				m.EXPECT().AddUserToSegments(args.ctx, args.input.UserID, args.input.Segments).Return(nil)
+				m.EXPECT().AddUserToSegments(args.ctx, args.input.UserID, args.input.Segments, args.input.ReplaceExclusive).Return(nil)
+			},
+			expectedStatusCode:   200,
+			expectedResponseBody: fmt.Sprintf(`{"message":"user %d was successfully added to the segments"}`, 1) + "\n",
+		},
+		{
+			name: "Ok, scheduled start",
+			args: args{
+				ctx: context.Background(),
+				input: argsInput{
+					UserID: 1,
+					Segments: []entity.UserSegmentInformation{
+						{
+							Name:      "AVITO_BAKERY",
+							StartDate: "10:00:00 18.09.2023",
+							EndDate:   "10:00:00 25.09.2023",
+						},
+					},
+				},
+			},
+			inputBody: `{"id":1,"segments":[{"name":"AVITO_BAKERY","start_date":"10:00:00 18.09.2023","end_date":"10:00:00 25.09.2023"}]}`,
+			mockBehaviour: func(m *mock_service.MockUser, args args) {
+				m.EXPECT().AddUserToSegments(args.ctx, args.input.UserID, args.input.Segments, args.input.ReplaceExclusive).Return(nil)
 			},
 			expectedStatusCode:   200,
 			expectedResponseBody: fmt.Sprintf(`{"message":"user %d was successfully added to the segments"}`, 1) + "\n",
 		},
+		{
+			name: "Ok, user addressed by external id",
+			args: args{
+				ctx: context.Background(),
+				input: argsInput{
+					UserID:   7,
+					Segments: []entity.UserSegmentInformation{{Name: "AVITO_BAKERY"}},
+				},
+			},
+			inputBody: `{"external_id":"crm-1842","segments":[{"name":"AVITO_BAKERY"}]}`,
+			mockBehaviour: func(m *mock_service.MockUser, args args) {
+				m.EXPECT().GetUserByExternalID(args.ctx, "crm-1842").Return(entity.User{ID: 7}, nil)
+				m.EXPECT().AddUserToSegments(args.ctx, args.input.UserID, args.input.Segments, args.input.ReplaceExclusive).Return(nil)
+			},
+			expectedStatusCode:   200,
+			expectedResponseBody: fmt.Sprintf(`{"message":"user %d was successfully added to the segments"}`, 7) + "\n",
+		},
+		{
+			name:                 "Both id and external id provided",
+			args:                 args{ctx: context.Background()},
+			inputBody:            `{"id":7,"external_id":"crm-1842","segments":[{"name":"AVITO_BAKERY"}]}`,
+			mockBehaviour:        func(m *mock_service.MockUser, args args) {},
+			expectedStatusCode:   400,
+			expectedResponseBody: `{"origin_error_text":"","title":"ErrUserValidationError","comment":"Invalid request body. Only one of the fields \"id\" and \"external_id\" can be provided","location":"UserRoutes.addUserToSegments"}` + "\n",
+		},
+		{
+			name:      "User with given external id does not exist",
+			args:      args{ctx: context.Background()},
+			inputBody: `{"external_id":"crm-0","segments":[{"name":"AVITO_BAKERY"}]}`,
+			mockBehaviour: func(m *mock_service.MockUser, args args) {
+				m.EXPECT().GetUserByExternalID(args.ctx, "crm-0").Return(entity.User{}, customError.ErrUserNotFound{ErrBase: customError.ErrBase{
+					Comment:  "User with external id \"crm-0\" not found",
+					Location: "UserRepository.GetUserByExternalID",
+				}})
+			},
+			expectedStatusCode:   404,
+			expectedResponseBody: `{"origin_error_text":"","title":"ErrUserNotFound","comment":"User with external id \"crm-0\" not found","location":"UserRepository.GetUserByExternalID"}` + "\n",
+		},
+		{
+			name: "Ok, replace mutually exclusive segment",
+			args: args{
+				ctx: context.Background(),
+				input: argsInput{
+					UserID:           4,
+					Segments:         []entity.UserSegmentInformation{{Name: "AVITO_DISCOUNT_30"}},
+					ReplaceExclusive: true,
+				},
+			},
+			inputBody: `{"id":4,"segments":[{"name":"AVITO_DISCOUNT_30"}],"replace_exclusive":true}`,
+			mockBehaviour: func(m *mock_service.MockUser, args args) {
+				m.EXPECT().AddUserToSegments(args.ctx, args.input.UserID, args.input.Segments, args.input.ReplaceExclusive).Return(nil)
+			},
+			expectedStatusCode:   200,
+			expectedResponseBody: fmt.Sprintf(`{"message":"user %d was successfully added to the segments"}`, 4) + "\n",
+		},
+		{
+			name: "User already has mutually exclusive segment",
+			args: args{
+				ctx: context.Background(),
+				input: argsInput{
+					UserID:   4,
+					Segments: []entity.UserSegmentInformation{{Name: "AVITO_DISCOUNT_30"}},
+				},
+			},
+			inputBody: `{"id":4,"segments":[{"name":"AVITO_DISCOUNT_30"}]}`,
+			mockBehaviour: func(m *mock_service.MockUser, args args) {
+				m.EXPECT().AddUserToSegments(args.ctx, args.input.UserID, args.input.Segments, args.input.ReplaceExclusive).Return(customError.ErrSegmentGroupConflict{ErrBase: customError.ErrBase{
+					Comment:  "Operation was canceled. Segment \"AVITO_DISCOUNT_30\" cannot be added to user (id = 4) since user already has mutually exclusive segment \"AVITO_DISCOUNT_50\"",
+					Location: "UserService.AddUserToSegments - exclusivity",
+				}})
+			},
+			expectedStatusCode:   409,
+			expectedResponseBody: `{"origin_error_text":"","title":"ErrSegmentGroupConflict","comment":"Operation was canceled. Segment \"AVITO_DISCOUNT_30\" cannot be added to user (id = 4) since user already has mutually exclusive segment \"AVITO_DISCOUNT_50\"","location":"UserService.AddUserToSegments - exclusivity"}` + "\n",
+		},
 		{
 			name: "User with given id does not exist",
 			args: args{
@@ -596,7 +957,7 @@ func TestUserRoutes_addUserToSegments(t *testing.T) {
 			},
 			inputBody: `{"id":1,"segments":[{"name":"AVITO_BAKERY","end_date":"10:00:00 25.09.2023"}]}`,
 			mockBehaviour: func(m *mock_service.MockUser, args args) {
-				m.EXPECT().AddUserToSegments(args.ctx, args.input.UserID, args.input.Segments).Return(customError.ErrUserNotFound{ErrBase: customError.ErrBase{
+				m.EXPECT().AddUserToSegments(args.ctx, args.input.UserID, args.input.Segments, args.input.ReplaceExclusive).Return(customError.ErrUserNotFound{ErrBase: customError.ErrBase{
 					Comment:  fmt.Sprintf("Operation was canceled because user with id = %d does not exist", args.input.UserID),
 					Location: "UserService.AddUserToSegments - us.GetUserByID",
 				}})
@@ -623,7 +984,7 @@ func TestUserRoutes_addUserToSegments(t *testing.T) {
 			},
 			inputBody: `{"id":1,"segments":[{"name":"AVITO_BAKERY","end_date":"10:00:00 25.09.2023"}]}`,
 			mockBehaviour: func(m *mock_service.MockUser, args args) {
-				m.EXPECT().AddUserToSegments(args.ctx, args.input.UserID, args.input.Segments).Return(customError.ErrUserDeleted{ErrBase: customError.ErrBase{
+				m.EXPECT().AddUserToSegments(args.ctx, args.input.UserID, args.input.Segments, args.input.ReplaceExclusive).Return(customError.ErrUserDeleted{ErrBase: customError.ErrBase{
 					Comment:  fmt.Sprintf("Operation was canceled because user with id = %d is deleted", args.input.UserID),
 					Location: "UserService.AddUserToSegments - us.GetUserByID",
 				}})
@@ -650,7 +1011,7 @@ func TestUserRoutes_addUserToSegments(t *testing.T) {
 			},
 			inputBody: `{"id":1,"segments":[{"name":"AVITO_BAKERY","end_date":"10:00:00 25.09.2023"}]}`,
 			mockBehaviour: func(m *mock_service.MockUser, args args) {
-				m.EXPECT().AddUserToSegments(args.ctx, args.input.UserID, args.input.Segments).Return(customError.ErrSegmentNotFound{ErrBase: customError.ErrBase{
+				m.EXPECT().AddUserToSegments(args.ctx, args.input.UserID, args.input.Segments, args.input.ReplaceExclusive).Return(customError.ErrSegmentNotFound{ErrBase: customError.ErrBase{
 					Comment: fmt.Sprintf(
 						"Operation was canceled. Failed to add user (id=%d) to the segment \"%s\" because segment does not exist",
 						args.input.UserID,
@@ -682,7 +1043,7 @@ func TestUserRoutes_addUserToSegments(t *testing.T) {
 			},
 			inputBody: `{"id":1,"segments":[{"name":"AVITO_BAKERY","end_date":"10:00:00 25.09.2023"}]}`,
 			mockBehaviour: func(m *mock_service.MockUser, args args) {
-				m.EXPECT().AddUserToSegments(args.ctx, args.input.UserID, args.input.Segments).Return(
+				m.EXPECT().AddUserToSegments(args.ctx, args.input.UserID, args.input.Segments, args.input.ReplaceExclusive).Return(
 					customError.ErrSegmentDeleted{ErrBase: customError.ErrBase{
 						Comment: fmt.Sprintf("Operation was canceled. Failed to add user (id=%d) to the "+
 							"segment \"%s\" because segment does not exist "+
@@ -718,7 +1079,7 @@ func TestUserRoutes_addUserToSegments(t *testing.T) {
 			},
 			inputBody: `{"id":1,"segments":[{"name":"AVITO_MUSIC"},{"name":"AVITO_MUSIC"}]}`,
 			mockBehaviour: func(m *mock_service.MockUser, args args) {
-				m.EXPECT().AddUserToSegments(args.ctx, args.input.UserID, args.input.Segments).Return(
+				m.EXPECT().AddUserToSegments(args.ctx, args.input.UserID, args.input.Segments, args.input.ReplaceExclusive).Return(
 					customError.ErrUserValidationError{ErrBase: customError.ErrBase{
 						Comment: fmt.Sprintf(
 							"Operation was canceled. The segment \"%s\" occurs more than 1 time in the list",
@@ -961,3 +1322,894 @@ func TestUserRoutes_deleteUserFromSegments(t *testing.T) {
 		})
 	}
 }
+
+func TestUserRoutes_updateUserSegments(t *testing.T) {
+	type argsInput struct {
+		UserID           int
+		SegmentsToAdd    []entity.UserSegmentInformation
+		SegmentsToDelete []string
+	}
+
+	type args struct {
+		ctx   context.Context
+		input argsInput
+	}
+
+	type MockBehaviour func(m *mock_service.MockUser, args args)
+
+	testCases := []struct {
+		name                 string
+		args                 args
+		userID               string
+		inputBody            string
+		mockBehaviour        MockBehaviour
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name: "Ok",
+			args: args{
+				ctx: context.Background(),
+				input: argsInput{
+					UserID: 1,
+					SegmentsToAdd: []entity.UserSegmentInformation{
+						{
+							Name:    "AVITO_BAKERY",
+							EndDate: "10:00:00 25.09.2023",
+						},
+					},
+					SegmentsToDelete: []string{"AVITO_MUSIC"},
+				},
+			},
+			userID:    "1",
+			inputBody: `{"add":[{"name":"AVITO_BAKERY","end_date":"10:00:00 25.09.2023"}],"delete":[{"name":"AVITO_MUSIC"}]}`,
+			mockBehaviour: func(m *mock_service.MockUser, args args) {
+				m.EXPECT().UpdateUserSegments(args.ctx, args.input.UserID, args.input.SegmentsToAdd, args.input.SegmentsToDelete).Return(nil)
+			},
+			expectedStatusCode:   200,
+			expectedResponseBody: fmt.Sprintf(`{"message":"segments of user %d were successfully updated"}`, 1) + "\n",
+		},
+		{
+			name: "Ok, only segments to delete",
+			args: args{
+				ctx: context.Background(),
+				input: argsInput{
+					UserID:           1,
+					SegmentsToDelete: []string{"AVITO_MUSIC"},
+				},
+			},
+			userID:    "1",
+			inputBody: `{"delete":[{"name":"AVITO_MUSIC"}]}`,
+			mockBehaviour: func(m *mock_service.MockUser, args args) {
+				m.EXPECT().UpdateUserSegments(args.ctx, args.input.UserID, args.input.SegmentsToAdd, args.input.SegmentsToDelete).Return(nil)
+			},
+			expectedStatusCode:   200,
+			expectedResponseBody: fmt.Sprintf(`{"message":"segments of user %d were successfully updated"}`, 1) + "\n",
+		},
+		{
+			name:                 "Invalid user id",
+			args:                 args{ctx: context.Background()},
+			userID:               "abc",
+			inputBody:            `{"delete":[{"name":"AVITO_MUSIC"}]}`,
+			mockBehaviour:        func(m *mock_service.MockUser, args args) {},
+			expectedStatusCode:   400,
+			expectedResponseBody: `{"origin_error_text":"strconv.Atoi: parsing \"abc\": invalid syntax","title":"ErrUserValidationError","comment":"Invalid \"id\" path-param value. \"id\" should be integer","location":"UserRoutes.updateUserSegments - strconv.Atoi"}` + "\n",
+		},
+		{
+			name: "Segment occurs in both lists",
+			args: args{
+				ctx: context.Background(),
+				input: argsInput{
+					UserID: 1,
+					SegmentsToAdd: []entity.UserSegmentInformation{
+						{
+							Name: "AVITO_MUSIC",
+						},
+					},
+					SegmentsToDelete: []string{"AVITO_MUSIC"},
+				},
+			},
+			userID:    "1",
+			inputBody: `{"add":[{"name":"AVITO_MUSIC"}],"delete":[{"name":"AVITO_MUSIC"}]}`,
+			mockBehaviour: func(m *mock_service.MockUser, args args) {
+				m.EXPECT().UpdateUserSegments(args.ctx, args.input.UserID, args.input.SegmentsToAdd, args.input.SegmentsToDelete).Return(
+					customError.ErrUserValidationError{ErrBase: customError.ErrBase{
+						Comment:  "Operation was canceled. The segment \"AVITO_MUSIC\" occurs both in segments to add and in segments to delete",
+						Location: "UserService.UpdateUserSegments - validation",
+					}})
+			},
+			expectedStatusCode:   400,
+			expectedResponseBody: `{"origin_error_text":"","title":"ErrUserValidationError","comment":"Operation was canceled. The segment \"AVITO_MUSIC\" occurs both in segments to add and in segments to delete","location":"UserService.UpdateUserSegments - validation"}` + "\n",
+		},
+		{
+			name: "One of segments does not exist",
+			args: args{
+				ctx: context.Background(),
+				input: argsInput{
+					UserID: 1,
+					SegmentsToAdd: []entity.UserSegmentInformation{
+						{
+							Name: "AVITO_BAKERY",
+						},
+					},
+				},
+			},
+			userID:    "1",
+			inputBody: `{"add":[{"name":"AVITO_BAKERY"}]}`,
+			mockBehaviour: func(m *mock_service.MockUser, args args) {
+				m.EXPECT().UpdateUserSegments(args.ctx, args.input.UserID, args.input.SegmentsToAdd, args.input.SegmentsToDelete).Return(customError.ErrSegmentNotFound{ErrBase: customError.ErrBase{
+					Comment:  "Operation was canceled. Failed to add user (id=1) to the segment \"AVITO_BAKERY\" because segment does not exist",
+					Location: "UserService.AddUserToSegments - us.segmentRepository.GetSegmentByName",
+				}})
+			},
+			expectedStatusCode:   404,
+			expectedResponseBody: `{"origin_error_text":"","title":"ErrSegmentNotFound","comment":"Operation was canceled. Failed to add user (id=1) to the segment \"AVITO_BAKERY\" because segment does not exist","location":"UserService.AddUserToSegments - us.segmentRepository.GetSegmentByName"}` + "\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Инициализация зависимостей
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			// Инициализация мока сервиса
+			user := mock_service.NewMockUser(ctrl)
+			tc.mockBehaviour(user, tc.args)
+			services := &service.Services{User: user}
+
+			// Создание тестового сервера
+			e := echo.New()
+			g := e.Group("/users")
+			newUserRoutes(g, services.User)
+
+			// Создание запроса
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPatch, "/users/"+tc.userID+"/segments", bytes.NewBufferString(tc.inputBody))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+
+			// Выполнение запроса
+			e.ServeHTTP(w, req)
+
+			// Проверка ответа
+			assert.Equal(t, tc.expectedStatusCode, w.Code)
+			assert.Equal(t, tc.expectedResponseBody, w.Body.String())
+		})
+	}
+}
+
+func TestUserRoutes_updateUserSegment(t *testing.T) {
+	type args struct {
+		ctx     context.Context
+		userID  int
+		name    string
+		endDate string
+	}
+
+	type MockBehaviour func(m *mock_service.MockUser, args args)
+
+	testCases := []struct {
+		name                 string
+		args                 args
+		userID               string
+		inputBody            string
+		mockBehaviour        MockBehaviour
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name:      "Ok, end date moved",
+			args:      args{ctx: context.Background(), userID: 16, name: "AVITO_MUSIC_SERVICE", endDate: "10:00:00 25.10.2023"},
+			userID:    "16",
+			inputBody: `{"end_date":"10:00:00 25.10.2023"}`,
+			mockBehaviour: func(m *mock_service.MockUser, args args) {
+				m.EXPECT().UpdateUserSegmentEndDate(args.ctx, args.userID, args.name, args.endDate).Return(entity.UserSegmentInformation{
+					InfoID:    179,
+					UserID:    16,
+					SegmentID: 43,
+					Name:      "AVITO_MUSIC_SERVICE",
+					StartDate: "15:27:32 01.09.2023",
+					EndDate:   "10:00:00 25.10.2023",
+				}, nil)
+			},
+			expectedStatusCode:   200,
+			expectedResponseBody: `{"segment":{"information_id":179,"user_id":16,"segment_id":43,"name":"AVITO_MUSIC_SERVICE","start_date":"15:27:32 01.09.2023","end_date":"10:00:00 25.10.2023"}}` + "\n",
+		},
+		{
+			name:      "Ok, end date cleared",
+			args:      args{ctx: context.Background(), userID: 16, name: "AVITO_MUSIC_SERVICE", endDate: ""},
+			userID:    "16",
+			inputBody: `{"end_date":""}`,
+			mockBehaviour: func(m *mock_service.MockUser, args args) {
+				m.EXPECT().UpdateUserSegmentEndDate(args.ctx, args.userID, args.name, args.endDate).Return(entity.UserSegmentInformation{
+					InfoID:    179,
+					UserID:    16,
+					SegmentID: 43,
+					Name:      "AVITO_MUSIC_SERVICE",
+					StartDate: "15:27:32 01.09.2023",
+				}, nil)
+			},
+			expectedStatusCode:   200,
+			expectedResponseBody: `{"segment":{"information_id":179,"user_id":16,"segment_id":43,"name":"AVITO_MUSIC_SERVICE","start_date":"15:27:32 01.09.2023","end_date":""}}` + "\n",
+		},
+		{
+			name:                 "End date was not provided",
+			args:                 args{ctx: context.Background(), name: "AVITO_MUSIC_SERVICE"},
+			userID:               "16",
+			inputBody:            `{}`,
+			mockBehaviour:        func(m *mock_service.MockUser, args args) {},
+			expectedStatusCode:   400,
+			expectedResponseBody: `{"origin_error_text":"","title":"ErrUserValidationError","comment":"Invalid request body. Required field \"end_date\" was not provided","location":"UserRoutes.updateUserSegment - validation"}` + "\n",
+		},
+		{
+			name:                 "Invalid id path param",
+			args:                 args{ctx: context.Background(), name: "AVITO_MUSIC_SERVICE"},
+			userID:               "sobaka",
+			inputBody:            `{"end_date":""}`,
+			mockBehaviour:        func(m *mock_service.MockUser, args args) {},
+			expectedStatusCode:   400,
+			expectedResponseBody: `{"origin_error_text":"strconv.Atoi: parsing \"sobaka\": invalid syntax","title":"ErrUserValidationError","comment":"Invalid \"id\" path-param value. \"id\" should be integer","location":"UserRoutes.updateUserSegment - strconv.Atoi"}` + "\n",
+		},
+		{
+			name:      "User does not belong to segment",
+			args:      args{ctx: context.Background(), userID: 16, name: "AVITO_MUSIC_SERVICE", endDate: "10:00:00 25.10.2023"},
+			userID:    "16",
+			inputBody: `{"end_date":"10:00:00 25.10.2023"}`,
+			mockBehaviour: func(m *mock_service.MockUser, args args) {
+				m.EXPECT().UpdateUserSegmentEndDate(args.ctx, args.userID, args.name, args.endDate).Return(entity.UserSegmentInformation{}, customError.ErrUserValidationError{ErrBase: customError.ErrBase{
+					Comment:  "Operation was canceled. User (id = 16) does not belong to segment \"AVITO_MUSIC_SERVICE\"",
+					Location: "UserService.UpdateUserSegmentEndDate - us.userRepository.GetUserSegmentsByUserID",
+				}})
+			},
+			expectedStatusCode:   400,
+			expectedResponseBody: `{"origin_error_text":"","title":"ErrUserValidationError","comment":"Operation was canceled. User (id = 16) does not belong to segment \"AVITO_MUSIC_SERVICE\"","location":"UserService.UpdateUserSegmentEndDate - us.userRepository.GetUserSegmentsByUserID"}` + "\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Инициализация зависимостей
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			// Инициализация мока сервиса
+			user := mock_service.NewMockUser(ctrl)
+			tc.mockBehaviour(user, tc.args)
+			services := &service.Services{User: user}
+
+			// Создание тестового сервера
+			e := echo.New()
+			g := e.Group("/users")
+			newUserRoutes(g, services.User)
+
+			// Создание запроса
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPatch, fmt.Sprintf("/users/%s/segments/%s", tc.userID, tc.args.name), bytes.NewBufferString(tc.inputBody))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+
+			// Выполнение запроса
+			e.ServeHTTP(w, req)
+
+			// Проверка ответа
+			assert.Equal(t, tc.expectedStatusCode, w.Code)
+			assert.Equal(t, tc.expectedResponseBody, w.Body.String())
+		})
+	}
+}
+
+func TestUserRoutes_update(t *testing.T) {
+	type args struct {
+		ctx context.Context
+		id  string
+	}
+
+	type MockBehaviour func(m *mock_service.MockUser, args args)
+
+	name, lastname, sex, age := "Дмитрий", "Поплавский", 0, 46
+
+	testCases := []struct {
+		name                 string
+		args                 args
+		inputBody            string
+		mockBehaviour        MockBehaviour
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name:      "Ok",
+			args:      args{ctx: context.Background(), id: "1"},
+			inputBody: `{"name":"Дмитрий","lastname":"Поплавский","sex":0,"age":46}`,
+			mockBehaviour: func(m *mock_service.MockUser, args args) {
+				m.EXPECT().UpdateUser(args.ctx, 1, service.UserUpdateInput{
+					Name:       &name,
+					Lastname:   &lastname,
+					Sex:        &sex,
+					Age:        &age,
+					Attributes: map[string]interface{}{},
+				}).Return(entity.User{ID: 1, Name: name, Lastname: lastname, Sex: sex, SexText: "мужской", Age: age}, nil)
+			},
+			expectedStatusCode:   200,
+			expectedResponseBody: `{"user":{"user_id":1,"name":"Дмитрий","lastname":"Поплавский","sex":0,"sex_text":"мужской","age":46,"is_deleted":false}}` + "\n",
+		},
+		{
+			name:      "Ok, with attributes",
+			args:      args{ctx: context.Background(), id: "1"},
+			inputBody: `{"name":"Дмитрий","lastname":"Поплавский","sex":0,"age":46,"attributes":{"city":"Москва","premium":true}}`,
+			mockBehaviour: func(m *mock_service.MockUser, args args) {
+				attributes := map[string]interface{}{"city": "Москва", "premium": true}
+				m.EXPECT().UpdateUser(args.ctx, 1, service.UserUpdateInput{
+					Name:       &name,
+					Lastname:   &lastname,
+					Sex:        &sex,
+					Age:        &age,
+					Attributes: attributes,
+				}).Return(entity.User{ID: 1, Name: name, Lastname: lastname, Sex: sex, SexText: "мужской", Age: age, Attributes: attributes}, nil)
+			},
+			expectedStatusCode:   200,
+			expectedResponseBody: `{"user":{"user_id":1,"name":"Дмитрий","lastname":"Поплавский","sex":0,"sex_text":"мужской","age":46,"is_deleted":false,"attributes":{"city":"Москва","premium":true}}}` + "\n",
+		},
+		{
+			name:                 "Invalid id",
+			args:                 args{ctx: context.Background(), id: "abc"},
+			inputBody:            `{"name":"Дмитрий","lastname":"Поплавский","sex":0,"age":46}`,
+			mockBehaviour:        func(m *mock_service.MockUser, args args) {},
+			expectedStatusCode:   400,
+			expectedResponseBody: `{"origin_error_text":"strconv.Atoi: parsing \"abc\": invalid syntax","title":"ErrUserValidationError","comment":"Invalid \"id\" path-param value. \"id\" should be integer","location":"UserRoutes.update - strconv.Atoi"}` + "\n",
+		},
+		{
+			name:                 "Invalid age: empty age",
+			args:                 args{ctx: context.Background(), id: "1"},
+			inputBody:            `{"name":"Дмитрий","lastname":"Поплавский","sex":0}`,
+			mockBehaviour:        func(m *mock_service.MockUser, args args) {},
+			expectedStatusCode:   400,
+			expectedResponseBody: `{"origin_error_text":"","title":"ErrUserValidationError","comment":"Invalid request body. Required field \"age\" was not provided","location":"UserRoutes.update - validation"}` + "\n",
+		},
+		{
+			name:      "User is deleted",
+			args:      args{ctx: context.Background(), id: "1"},
+			inputBody: `{"name":"Дмитрий","lastname":"Поплавский","sex":0,"age":46}`,
+			mockBehaviour: func(m *mock_service.MockUser, args args) {
+				m.EXPECT().UpdateUser(args.ctx, 1, gomock.Any()).Return(entity.User{}, customError.ErrUserDeleted{ErrBase: customError.ErrBase{
+					Comment:  "User with id 1 is deleted",
+					Location: "UserService.UpdateUser - us.userRepository.GetUserByID",
+				}})
+			},
+			expectedStatusCode:   400,
+			expectedResponseBody: `{"origin_error_text":"","title":"ErrUserDeleted","comment":"User with id 1 is deleted","location":"UserService.UpdateUser - us.userRepository.GetUserByID"}` + "\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Инициализация зависимостей
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			// Инициализация мока сервиса
+			user := mock_service.NewMockUser(ctrl)
+			tc.mockBehaviour(user, tc.args)
+			services := &service.Services{User: user}
+
+			// Создание тестового сервера
+			e := echo.New()
+			g := e.Group("/users")
+			newUserRoutes(g, services.User)
+
+			// Создание запроса
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPut, fmt.Sprintf("/users/%s", tc.args.id), bytes.NewBufferString(tc.inputBody))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+
+			// Выполнение запроса
+			e.ServeHTTP(w, req)
+
+			// Проверка ответа
+			assert.Equal(t, tc.expectedStatusCode, w.Code)
+			assert.Equal(t, tc.expectedResponseBody, w.Body.String())
+		})
+	}
+}
+
+func TestUserRoutes_patch(t *testing.T) {
+	type args struct {
+		ctx context.Context
+		id  string
+	}
+
+	type MockBehaviour func(m *mock_service.MockUser, args args)
+
+	age := 46
+
+	testCases := []struct {
+		name                 string
+		args                 args
+		inputBody            string
+		mockBehaviour        MockBehaviour
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name:      "Ok",
+			args:      args{ctx: context.Background(), id: "1"},
+			inputBody: `{"age":46}`,
+			mockBehaviour: func(m *mock_service.MockUser, args args) {
+				m.EXPECT().UpdateUser(args.ctx, 1, service.UserUpdateInput{Age: &age}).
+					Return(entity.User{ID: 1, Name: "Дмитрий", Lastname: "Поплавский", Sex: 0, SexText: "мужской", Age: age}, nil)
+			},
+			expectedStatusCode:   200,
+			expectedResponseBody: `{"user":{"user_id":1,"name":"Дмитрий","lastname":"Поплавский","sex":0,"sex_text":"мужской","age":46,"is_deleted":false}}` + "\n",
+		},
+		{
+			name:                 "Empty body",
+			args:                 args{ctx: context.Background(), id: "1"},
+			inputBody:            `{}`,
+			mockBehaviour:        func(m *mock_service.MockUser, args args) {},
+			expectedStatusCode:   400,
+			expectedResponseBody: `{"origin_error_text":"","title":"ErrUserValidationError","comment":"Invalid request body. At least one of the fields \"name\", \"lastname\", \"sex\", \"age\", \"attributes\" must be provided","location":"UserRoutes.patch - validation"}` + "\n",
+		},
+		{
+			name:                 "Invalid name: empty name",
+			args:                 args{ctx: context.Background(), id: "1"},
+			inputBody:            `{"name":""}`,
+			mockBehaviour:        func(m *mock_service.MockUser, args args) {},
+			expectedStatusCode:   400,
+			expectedResponseBody: `{"origin_error_text":"","title":"ErrUserValidationError","comment":"Invalid request body. Field \"name\" cannot be empty","location":"UserRoutes.patch - validation"}` + "\n",
+		},
+		{
+			name:                 "Invalid sex: value out of range [0, 1]",
+			args:                 args{ctx: context.Background(), id: "1"},
+			inputBody:            `{"sex":3}`,
+			mockBehaviour:        func(m *mock_service.MockUser, args args) {},
+			expectedStatusCode:   400,
+			expectedResponseBody: `{"origin_error_text":"","title":"ErrUserValidationError","comment":"Invalid request body. Field \"sex\" must equals to 0 (man) or 1 (woman)","location":"UserRoutes.patch - validation"}` + "\n",
+		},
+		{
+			name:      "User not found",
+			args:      args{ctx: context.Background(), id: "1"},
+			inputBody: `{"age":46}`,
+			mockBehaviour: func(m *mock_service.MockUser, args args) {
+				m.EXPECT().UpdateUser(args.ctx, 1, service.UserUpdateInput{Age: &age}).Return(entity.User{}, customError.ErrUserNotFound{ErrBase: customError.ErrBase{
+					Comment:  "User with id 1 does not exist",
+					Location: "UserRepository.GetUserByID",
+				}})
+			},
+			expectedStatusCode:   404,
+			expectedResponseBody: `{"origin_error_text":"","title":"ErrUserNotFound","comment":"User with id 1 does not exist","location":"UserRepository.GetUserByID"}` + "\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Инициализация зависимостей
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			// Инициализация мока сервиса
+			user := mock_service.NewMockUser(ctrl)
+			tc.mockBehaviour(user, tc.args)
+			services := &service.Services{User: user}
+
+			// Создание тестового сервера
+			e := echo.New()
+			g := e.Group("/users")
+			newUserRoutes(g, services.User)
+
+			// Создание запроса
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPatch, fmt.Sprintf("/users/%s", tc.args.id), bytes.NewBufferString(tc.inputBody))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+
+			// Выполнение запроса
+			e.ServeHTTP(w, req)
+
+			// Проверка ответа
+			assert.Equal(t, tc.expectedStatusCode, w.Code)
+			assert.Equal(t, tc.expectedResponseBody, w.Body.String())
+		})
+	}
+}
+
+func TestUserRoutes_deleteByID(t *testing.T) {
+	type args struct {
+		ctx context.Context
+		id  string
+	}
+
+	type MockBehaviour func(m *mock_service.MockUser, args args)
+
+	testCases := []struct {
+		name                 string
+		args                 args
+		mockBehaviour        MockBehaviour
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name: "Ok",
+			args: args{ctx: context.Background(), id: "16"},
+			mockBehaviour: func(m *mock_service.MockUser, args args) {
+				m.EXPECT().DeleteUser(args.ctx, 16).Return(nil)
+			},
+			expectedStatusCode:   200,
+			expectedResponseBody: `{"message":"user 16 was successfully deleted"}` + "\n",
+		},
+		{
+			name: "User is already deleted",
+			args: args{ctx: context.Background(), id: "16"},
+			mockBehaviour: func(m *mock_service.MockUser, args args) {
+				m.EXPECT().DeleteUser(args.ctx, 16).Return(customError.ErrUserDeleted{ErrBase: customError.ErrBase{
+					Comment:  "User with id 16 is already deleted",
+					Location: "UserService.DeleteUser - us.userRepository.GetUserByID",
+				}})
+			},
+			expectedStatusCode:   400,
+			expectedResponseBody: `{"origin_error_text":"","title":"ErrUserDeleted","comment":"User with id 16 is already deleted","location":"UserService.DeleteUser - us.userRepository.GetUserByID"}` + "\n",
+		},
+		{
+			name: "User not found",
+			args: args{ctx: context.Background(), id: "16"},
+			mockBehaviour: func(m *mock_service.MockUser, args args) {
+				m.EXPECT().DeleteUser(args.ctx, 16).Return(customError.ErrUserNotFound{ErrBase: customError.ErrBase{
+					Comment:  "User with id 16 does not exist",
+					Location: "UserRepository.GetUserByID",
+				}})
+			},
+			expectedStatusCode:   404,
+			expectedResponseBody: `{"origin_error_text":"","title":"ErrUserNotFound","comment":"User with id 16 does not exist","location":"UserRepository.GetUserByID"}` + "\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Инициализация зависимостей
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			// Инициализация мока сервиса
+			user := mock_service.NewMockUser(ctrl)
+			tc.mockBehaviour(user, tc.args)
+			services := &service.Services{User: user}
+
+			// Создание тестового сервера
+			e := echo.New()
+			g := e.Group("/users")
+			newUserRoutes(g, services.User)
+
+			// Создание запроса
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodDelete, fmt.Sprintf("/users/%s", tc.args.id), nil)
+
+			// Выполнение запроса
+			e.ServeHTTP(w, req)
+
+			// Проверка ответа
+			assert.Equal(t, tc.expectedStatusCode, w.Code)
+			assert.Equal(t, tc.expectedResponseBody, w.Body.String())
+		})
+	}
+}
+
+func TestUserRoutes_restore(t *testing.T) {
+	type args struct {
+		ctx context.Context
+		id  string
+	}
+
+	type MockBehaviour func(m *mock_service.MockUser, args args)
+
+	testCases := []struct {
+		name                 string
+		args                 args
+		mockBehaviour        MockBehaviour
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name: "Ok",
+			args: args{ctx: context.Background(), id: "16"},
+			mockBehaviour: func(m *mock_service.MockUser, args args) {
+				m.EXPECT().RestoreUser(args.ctx, 16).Return(entity.User{ID: 16, Name: "Михаил", Lastname: "Иванов", Sex: 0, SexText: "мужской", Age: 27}, nil)
+			},
+			expectedStatusCode:   200,
+			expectedResponseBody: `{"user":{"user_id":16,"name":"Михаил","lastname":"Иванов","sex":0,"sex_text":"мужской","age":27,"is_deleted":false}}` + "\n",
+		},
+		{
+			name: "User is not deleted",
+			args: args{ctx: context.Background(), id: "16"},
+			mockBehaviour: func(m *mock_service.MockUser, args args) {
+				m.EXPECT().RestoreUser(args.ctx, 16).Return(entity.User{}, customError.ErrUserValidationError{ErrBase: customError.ErrBase{
+					Comment:  "User with id 16 is not deleted",
+					Location: "UserService.RestoreUser - us.userRepository.GetUserByID",
+				}})
+			},
+			expectedStatusCode:   400,
+			expectedResponseBody: `{"origin_error_text":"","title":"ErrUserValidationError","comment":"User with id 16 is not deleted","location":"UserService.RestoreUser - us.userRepository.GetUserByID"}` + "\n",
+		},
+		{
+			name:                 "Invalid id",
+			args:                 args{ctx: context.Background(), id: "abc"},
+			mockBehaviour:        func(m *mock_service.MockUser, args args) {},
+			expectedStatusCode:   400,
+			expectedResponseBody: `{"origin_error_text":"strconv.Atoi: parsing \"abc\": invalid syntax","title":"ErrUserValidationError","comment":"Invalid \"id\" path-param value. \"id\" should be integer","location":"UserRoutes.restore - strconv.Atoi"}` + "\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Инициализация зависимостей
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			// Инициализация мока сервиса
+			user := mock_service.NewMockUser(ctrl)
+			tc.mockBehaviour(user, tc.args)
+			services := &service.Services{User: user}
+
+			// Создание тестового сервера
+			e := echo.New()
+			g := e.Group("/users")
+			newUserRoutes(g, services.User)
+
+			// Создание запроса
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/users/%s/restore", tc.args.id), nil)
+
+			// Выполнение запроса
+			e.ServeHTTP(w, req)
+
+			// Проверка ответа
+			assert.Equal(t, tc.expectedStatusCode, w.Code)
+			assert.Equal(t, tc.expectedResponseBody, w.Body.String())
+		})
+	}
+}
+
+func TestUserRoutes_import(t *testing.T) {
+	type args struct {
+		ctx    context.Context
+		format string
+	}
+
+	type MockBehaviour func(m *mock_service.MockUser, args args)
+
+	testCases := []struct {
+		name                 string
+		args                 args
+		query                string
+		contentType          string
+		inputBody            string
+		mockBehaviour        MockBehaviour
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name:        "Ok: CSV by Content-Type",
+			args:        args{ctx: context.Background(), format: service.UserImportFormatCSV},
+			contentType: "text/csv; charset=utf-8",
+			inputBody:   "name,lastname,sex,age\nДмитрий,Поплавский,0,45\n,Иванов,0,20\n",
+			mockBehaviour: func(m *mock_service.MockUser, args args) {
+				m.EXPECT().ImportUsers(args.ctx, args.format, gomock.Any()).Return(entity.UserImportReport{
+					Imported: 1,
+					Failed:   1,
+					Errors:   []entity.UserImportError{{Row: 2, Error: "\"name\" field cannot be empty"}},
+				}, nil)
+			},
+			expectedStatusCode:   200,
+			expectedResponseBody: `{"imported":1,"failed":1,"errors":[{"row":2,"error":"\"name\" field cannot be empty"}]}` + "\n",
+		},
+		{
+			name:        "Ok: NDJSON by query-param",
+			args:        args{ctx: context.Background(), format: service.UserImportFormatNDJSON},
+			query:       "?format=ndjson",
+			contentType: echo.MIMETextPlain,
+			inputBody:   `{"name":"Дмитрий","lastname":"Поплавский","sex":0,"age":45}` + "\n",
+			mockBehaviour: func(m *mock_service.MockUser, args args) {
+				m.EXPECT().ImportUsers(args.ctx, args.format, gomock.Any()).Return(entity.UserImportReport{
+					Imported: 1,
+					Errors:   []entity.UserImportError{},
+				}, nil)
+			},
+			expectedStatusCode:   200,
+			expectedResponseBody: `{"imported":1,"failed":0,"errors":[]}` + "\n",
+		},
+		{
+			name:                 "Unknown Content-Type",
+			args:                 args{ctx: context.Background()},
+			contentType:          echo.MIMEApplicationJSON,
+			inputBody:            `[]`,
+			mockBehaviour:        func(m *mock_service.MockUser, args args) {},
+			expectedStatusCode:   400,
+			expectedResponseBody: `{"origin_error_text":"","title":"ErrUserValidationError","comment":"Cannot detect import format. Provide \"format\" query-param or Content-Type text/csv or application/x-ndjson","location":"UserRoutes.importUsers - validation"}` + "\n",
+		},
+		{
+			name:        "Unsupported format",
+			args:        args{ctx: context.Background(), format: "xml"},
+			query:       "?format=xml",
+			contentType: echo.MIMEApplicationXML,
+			inputBody:   `<users></users>`,
+			mockBehaviour: func(m *mock_service.MockUser, args args) {
+				m.EXPECT().ImportUsers(args.ctx, args.format, gomock.Any()).Return(entity.UserImportReport{}, customError.ErrUserValidationError{ErrBase: customError.ErrBase{
+					Comment:  "Unsupported import format \"xml\", expected csv or ndjson",
+					Location: "UserService.ImportUsers - validation",
+				}})
+			},
+			expectedStatusCode:   400,
+			expectedResponseBody: `{"origin_error_text":"","title":"ErrUserValidationError","comment":"Unsupported import format \"xml\", expected csv or ndjson","location":"UserService.ImportUsers - validation"}` + "\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Инициализация зависимостей
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			// Инициализация мока сервиса
+			user := mock_service.NewMockUser(ctrl)
+			tc.mockBehaviour(user, tc.args)
+			services := &service.Services{User: user}
+
+			// Создание тестового сервера
+			e := echo.New()
+			g := e.Group("/users")
+			newUserRoutes(g, services.User)
+
+			// Создание запроса
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/users/import"+tc.query, bytes.NewBufferString(tc.inputBody))
+			req.Header.Set(echo.HeaderContentType, tc.contentType)
+
+			// Выполнение запроса
+			e.ServeHTTP(w, req)
+
+			// Проверка ответа
+			assert.Equal(t, tc.expectedStatusCode, w.Code)
+			assert.Equal(t, tc.expectedResponseBody, w.Body.String())
+		})
+	}
+}
+
+func TestUserRoutes_upsertByExternalID(t *testing.T) {
+	type args struct {
+		ctx        context.Context
+		externalID string
+		input      service.UserCreateInput
+	}
+
+	type MockBehaviour func(m *mock_service.MockUser, args args)
+
+	testCases := []struct {
+		name                 string
+		args                 args
+		inputBody            string
+		mockBehaviour        MockBehaviour
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name: "Ok: created",
+			args: args{
+				ctx:        context.Background(),
+				externalID: "crm-1842",
+				input: service.UserCreateInput{
+					Name:       "Дмитрий",
+					Lastname:   "Поплавский",
+					Sex:        0,
+					Age:        45,
+					ExternalID: "crm-1842",
+				},
+			},
+			inputBody: `{"name":"Дмитрий","lastname":"Поплавский","sex":0,"age":45}`,
+			mockBehaviour: func(m *mock_service.MockUser, args args) {
+				m.EXPECT().UpsertUserByExternalID(args.ctx, args.externalID, args.input).Return(entity.User{
+					ID:         1,
+					ExternalID: &args.externalID,
+					Name:       "Дмитрий",
+					Lastname:   "Поплавский",
+					Sex:        0,
+					SexText:    "мужской",
+					Age:        45,
+				}, true, nil)
+			},
+			expectedStatusCode:   201,
+			expectedResponseBody: `{"user":{"user_id":1,"external_id":"crm-1842","name":"Дмитрий","lastname":"Поплавский","sex":0,"sex_text":"мужской","age":45,"is_deleted":false},"created":true}` + "\n",
+		},
+		{
+			name: "Ok: updated",
+			args: args{
+				ctx:        context.Background(),
+				externalID: "crm-1842",
+				input: service.UserCreateInput{
+					Name:       "Дмитрий",
+					Lastname:   "Поплавский",
+					Sex:        0,
+					Age:        46,
+					ExternalID: "crm-1842",
+				},
+			},
+			inputBody: `{"external_id":"crm-1842","name":"Дмитрий","lastname":"Поплавский","sex":0,"age":46}`,
+			mockBehaviour: func(m *mock_service.MockUser, args args) {
+				m.EXPECT().UpsertUserByExternalID(args.ctx, args.externalID, args.input).Return(entity.User{
+					ID:         1,
+					ExternalID: &args.externalID,
+					Name:       "Дмитрий",
+					Lastname:   "Поплавский",
+					Sex:        0,
+					SexText:    "мужской",
+					Age:        46,
+				}, false, nil)
+			},
+			expectedStatusCode:   200,
+			expectedResponseBody: `{"user":{"user_id":1,"external_id":"crm-1842","name":"Дмитрий","lastname":"Поплавский","sex":0,"sex_text":"мужской","age":46,"is_deleted":false},"created":false}` + "\n",
+		},
+		{
+			name:                 "External id in body does not match path",
+			args:                 args{ctx: context.Background(), externalID: "crm-1842"},
+			inputBody:            `{"external_id":"crm-1","name":"Дмитрий","lastname":"Поплавский","sex":0,"age":45}`,
+			mockBehaviour:        func(m *mock_service.MockUser, args args) {},
+			expectedStatusCode:   400,
+			expectedResponseBody: `{"origin_error_text":"","title":"ErrUserValidationError","comment":"Invalid request body. Field \"external_id\" must match \"ext\" path-param value","location":"UserRoutes.upsertByExternalID - validation"}` + "\n",
+		},
+		{
+			name:                 "Required field was not provided",
+			args:                 args{ctx: context.Background(), externalID: "crm-1842"},
+			inputBody:            `{"name":"Дмитрий","lastname":"Поплавский","sex":0}`,
+			mockBehaviour:        func(m *mock_service.MockUser, args args) {},
+			expectedStatusCode:   400,
+			expectedResponseBody: `{"origin_error_text":"","title":"ErrUserValidationError","comment":"Invalid request body. Required field \"age\" was not provided","location":"UserRoutes.upsertByExternalID - validation"}` + "\n",
+		},
+		{
+			name: "User with given external id is deleted",
+			args: args{
+				ctx:        context.Background(),
+				externalID: "crm-1842",
+				input: service.UserCreateInput{
+					Name:       "Дмитрий",
+					Lastname:   "Поплавский",
+					Sex:        0,
+					Age:        45,
+					ExternalID: "crm-1842",
+				},
+			},
+			inputBody: `{"name":"Дмитрий","lastname":"Поплавский","sex":0,"age":45}`,
+			mockBehaviour: func(m *mock_service.MockUser, args args) {
+				m.EXPECT().UpsertUserByExternalID(args.ctx, args.externalID, args.input).Return(entity.User{}, false, customError.ErrUserDeleted{ErrBase: customError.ErrBase{
+					Comment:  "User with external id \"crm-1842\" is deleted",
+					Location: "UserRepository.UpsertUserByExternalID",
+				}})
+			},
+			expectedStatusCode:   400,
+			expectedResponseBody: `{"origin_error_text":"","title":"ErrUserDeleted","comment":"User with external id \"crm-1842\" is deleted","location":"UserRepository.UpsertUserByExternalID"}` + "\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Инициализация зависимостей
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			// Инициализация мока сервиса
+			user := mock_service.NewMockUser(ctrl)
+			tc.mockBehaviour(user, tc.args)
+			services := &service.Services{User: user}
+
+			// Создание тестового сервера
+			e := echo.New()
+			g := e.Group("/users")
+			newUserRoutes(g, services.User)
+
+			// Создание запроса
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPut, "/users/by-external-id/"+tc.args.externalID, bytes.NewBufferString(tc.inputBody))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+
+			// Выполнение запроса
+			e.ServeHTTP(w, req)
+
+			// Проверка ответа
+			assert.Equal(t, tc.expectedStatusCode, w.Code)
+			assert.Equal(t, tc.expectedResponseBody, w.Body.String())
+		})
+	}
+}
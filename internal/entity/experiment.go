@@ -0,0 +1,38 @@
+package entity
+
+// Experiment - A/B эксперимент, построенный поверх сегментов: каждому варианту эксперимента
+// соответствует сегмент, а каждый пользователь детерминированно распределяется ровно в один вариант.
+type Experiment struct {
+	ID       int                 `json:"experiment_id" example:"7"`
+	Name     string              `json:"name" example:"AVITO_CHECKOUT"`
+	Variants []ExperimentVariant `json:"variants"`
+	Salt     string              `json:"-"` // Соль, от которой зависит распределение пользователей по вариантам
+}
+
+type ExperimentVariant struct {
+	ID          int    `json:"variant_id" example:"12"`
+	Name        string `json:"name" example:"A"`
+	Weight      int    `json:"weight" example:"50"`                     // Вес варианта, доля пользователей варианта равна его весу, делённому на сумму весов
+	SegmentID   int    `json:"segment_id" example:"44"`                 // ID сегмента, в который входят пользователи варианта
+	SegmentName string `json:"segment_name" example:"AVITO_CHECKOUT_A"` // Наименование сегмента, в который входят пользователи варианта
+}
+
+// UserExperimentVariant - вариант эксперимента, в который распределён пользователь.
+type UserExperimentVariant struct {
+	UserID     int               `json:"user_id" example:"16"`
+	Experiment string            `json:"experiment" example:"AVITO_CHECKOUT"`
+	Variant    ExperimentVariant `json:"variant"`
+	StartDate  string            `json:"start_date" example:"15:27:32 01.09.2023"` // Дата распределения пользователя в вариант
+}
+
+// ExperimentVariantSize - количество пользователей, распределённых в вариант эксперимента.
+type ExperimentVariantSize struct {
+	ExperimentVariant
+	Users int `json:"users" example:"512"`
+}
+
+type ExperimentReport struct {
+	Experiment string                  `json:"experiment" example:"AVITO_CHECKOUT"`
+	Total      int                     `json:"total" example:"1024"` // Общее количество пользователей, распределённых в варианты эксперимента
+	Variants   []ExperimentVariantSize `json:"variants"`
+}
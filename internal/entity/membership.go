@@ -0,0 +1,29 @@
+package entity
+
+// Причины выхода пользователя из сегмента, хранимые в столбце `end_reason`
+const (
+	MembershipEndReasonExpired = "expired" // Наступила дата выхода `end_date`, указанная при добавлении
+	MembershipEndReasonRemoved = "removed" // Пользователь удалён из сегмента, удалён сам или удалён сегмент
+)
+
+// Действия над вхождением пользователя в сегмент, записываемые в историю изменений `users_segments_history`
+const (
+	MembershipHistoryEndDateChanged = "end_date_changed" // Изменена дата выхода пользователя из сегмента
+)
+
+// Типы событий вхождения пользователя в сегмент
+const (
+	MembershipEventExpired = "membership.expired" // Истёк срок вхождения пользователя в сегмент
+)
+
+// MembershipEvent - событие, произошедшее с вхождением пользователя в сегмент.
+type MembershipEvent struct {
+	Type        string `json:"type" example:"membership.expired"`
+	InfoID      int    `json:"information_id" example:"179"`               // ID записи в ассоциативной таблице, связывающей пользователей с сегментами
+	UserID      int    `json:"user_id" example:"16"`                       // ID пользователя
+	SegmentID   int    `json:"segment_id" example:"43"`                    // ID сегмента
+	SegmentName string `json:"segment_name" example:"AVITO_MUSIC_SERVICE"` // Наименование сегмента
+	StartDate   string `json:"start_date" example:"15:27:32 01.09.2023"`   // Дата добавления пользователя в сегмент
+	EndDate     string `json:"end_date" example:"10:00:00 25.09.2023"`     // Дата выхода пользователя из сегмента
+	OccurredAt  string `json:"occurred_at" example:"10:00:07 25.09.2023"`  // Момент обработки события сервисом
+}
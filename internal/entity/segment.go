@@ -1,7 +1,164 @@
 package entity
 
+import "time"
+
 type Segment struct {
-	ID        int    `json:"segment_id" example:"43"`
-	Name      string `json:"name" example:"AVITO_MUSIC_SERVICE"`
-	IsDeleted bool   `json:"is_deleted" example:"false"`
+	ID          int                `json:"segment_id" example:"43"`
+	Name        string             `json:"name" example:"AVITO_MUSIC_SERVICE"`
+	IsDeleted   bool               `json:"is_deleted" example:"false"`
+	Rules       []SegmentRule      `json:"rules,omitempty"`                                              // Правила автоматического вхождения пользователей в сегмент, объединяемые через AND
+	Percentage  *int               `json:"percentage,omitempty" example:"57"`                            // Процент пользователей, автоматически попадающих в сегмент
+	Salt        string             `json:"-"`                                                            // Соль, от которой зависит распределение пользователей при раскатке на процент
+	GroupID     *int               `json:"group_id,omitempty" example:"3"`                               // ID группы взаимоисключающих сегментов, в которую входит сегмент
+	DefaultTTL  string             `json:"default_ttl,omitempty" example:"30d"`                          // Срок вхождения пользователя в сегмент, если при добавлении не указана дата выхода
+	ActiveUntil string             `json:"active_until,omitempty" example:"00:00:00 01.01.2024"`         // Дата и время, после которых сегмент автоматически удаляется
+	Description string             `json:"description,omitempty" example:"Скидка 45% на товары маркета"` // Описание назначения сегмента
+	Owner       string             `json:"owner,omitempty" example:"market-team"`                        // Команда, владеющая сегментом
+	Tags        []string           `json:"tags,omitempty" example:"market,discount"`                     // Теги сегмента
+	CreatedAt   string             `json:"created_at,omitempty" example:"15:27:32 01.09.2023"`           // Дата и время создания сегмента
+	UpdatedAt   string             `json:"updated_at,omitempty" example:"15:27:32 01.09.2023"`           // Дата и время последнего изменения сегмента
+	DeletedAt   string             `json:"deleted_at,omitempty" example:"15:27:32 01.09.2023"`           // Дата и время удаления сегмента, если он удалён
+	Derivation  *SegmentDerivation `json:"derivation,omitempty"`                                         // Определение вычисляемого сегмента, состав которого вычисляется из других сегментов при каждом запросе
+}
+
+// Операции над множествами пользователей сегментов, из которых выводится новый сегмент
+const (
+	SegmentOperationUnion        = "union"        // Пользователи, входящие хотя бы в один из сегментов
+	SegmentOperationIntersection = "intersection" // Пользователи, входящие во все сегменты
+	SegmentOperationDifference   = "difference"   // Пользователи первого сегмента, не входящие ни в один из остальных
+)
+
+// SegmentDerivation - определение вычисляемого сегмента: операция над множествами пользователей,
+// входящих в сегменты `SegmentIDs` в данный момент.
+type SegmentDerivation struct {
+	Operation  string `json:"operation" example:"difference" enums:"union,intersection,difference"` // Операция над множествами пользователей сегментов
+	SegmentIDs []int  `json:"segment_ids" example:"2,3"`                                            // ID исходных сегментов, для разности первым указан уменьшаемый сегмент
+}
+
+// SegmentNameChange - запись истории переименования сегмента.
+type SegmentNameChange struct {
+	OldName   string `json:"old_name" example:"AVITO_MUSIC"`           // Имя сегмента до переименования
+	NewName   string `json:"new_name" example:"AVITO_MUSIC_SERVICE"`   // Имя сегмента после переименования
+	RenamedAt string `json:"renamed_at" example:"10:00:00 25.09.2023"` // Дата и время переименования
+}
+
+// SegmentsFilter - условия выборки сегментов по метаданным. Пустые значения не ограничивают выборку.
+type SegmentsFilter struct {
+	Tag   string // Тег, который должен быть у сегмента
+	Owner string // Команда, владеющая сегментом
+}
+
+// SegmentRule - условие над атрибутом пользователя, например `age >= 18`.
+// Пользователь входит в сегмент с правилами, если удовлетворяет всем его правилам.
+type SegmentRule struct {
+	Attribute string      `json:"attribute" example:"age"`                      // Атрибут пользователя: name, lastname, sex, age или дополнительный атрибут attributes.<ключ>
+	Operator  string      `json:"operator" example:">=" enums:"=,!=,>,>=,<,<="` // Оператор сравнения, для атрибутов name и lastname и логических значений допустимы только = и !=
+	Value     interface{} `json:"value" swaggertype:"string" example:"18"`      // Значение, с которым сравнивается атрибут: целое число для sex и age, строка для name и lastname, строка, число или логическое значение для дополнительных атрибутов
+}
+
+// SegmentRuleAttributePrefix - префикс атрибута правила сегмента, ссылающегося на ключ дополнительных
+// атрибутов пользователя (столбец `attributes`).
+const SegmentRuleAttributePrefix = "attributes."
+
+// SegmentRuleAttributes сопоставляет атрибутам пользователя, допустимым в правилах сегмента, признак
+// числового атрибута. Атрибуты совпадают с именами столбцов таблицы `users`.
+var SegmentRuleAttributes = map[string]bool{
+	"name":     false,
+	"lastname": false,
+	"sex":      true,
+	"age":      true,
+}
+
+// SegmentRuleOperators сопоставляет операторам сравнения, допустимым в правилах сегмента, признак оператора
+// порядка: такие операторы неприменимы к строковым атрибутам name, lastname и к логическим значениям.
+var SegmentRuleOperators = map[string]bool{
+	"=":  false,
+	"!=": false,
+	">":  true,
+	">=": true,
+	"<":  true,
+	"<=": true,
+}
+
+// Статусы вхождения пользователя в сегмент относительно момента времени
+const (
+	MembershipActive  = "active"  // Пользователь входит в сегмент в данный момент
+	MembershipExpired = "expired" // Пользователь вышел из сегмента к данному моменту
+	MembershipAll     = "all"     // Пользователь был добавлен в сегмент к данному моменту
+)
+
+// SegmentMember - запись о вхождении пользователя в сегмент вместе с информацией о пользователе.
+type SegmentMember struct {
+	InfoID    int    `json:"information_id" example:"179"`             // ID записи в ассоциативной таблице, связывающей пользователей с сегментами
+	User      User   `json:"user"`                                     // Пользователь, входящий в сегмент
+	StartDate string `json:"start_date" example:"15:27:32 01.09.2023"` // Дата добавления пользователя в сегмент
+	EndDate   string `json:"end_date" example:""`                      // Дата выхода пользователя из сегмента
+}
+
+// SegmentUsersFilter - условия выборки записей о вхождении пользователей в сегмент.
+type SegmentUsersFilter struct {
+	At      time.Time // Момент времени, относительно которого определяется статус записи, нулевое значение - текущий момент
+	Status  string    // Статус записи: MembershipActive, MembershipExpired или MembershipAll
+	Sex     *int      // Пол пользователя
+	MinAge  *int      // Минимальный возраст пользователя, включительно
+	MaxAge  *int      // Максимальный возраст пользователя, включительно
+	AfterID int       // Выбираются только записи с ID больше указанного
+	Limit   int       // Максимальное количество записей
+}
+
+// SegmentUsersPage - страница списка записей о вхождении пользователей в сегмент.
+type SegmentUsersPage struct {
+	Users      []SegmentMember `json:"users"`
+	NextCursor string          `json:"next_cursor,omitempty" example:"MTc5"` // Курсор следующей страницы, отсутствует на последней странице
+}
+
+// SegmentUsersAddReport - результат массового добавления пользователей в сегмент.
+// При пробном запуске отчёт описывает изменения, которые были бы сделаны.
+type SegmentUsersAddReport struct {
+	Added          int   `json:"added" example:"49998"`              // Количество добавленных пользователей
+	AlreadyMembers []int `json:"already_members" example:"12,40"`    // Пропущенные пользователи, уже входящие в сегмент
+	DryRun         bool  `json:"dry_run,omitempty" example:"true"`   // Признак пробного запуска, при котором изменения не сохраняются
+	UserIDs        []int `json:"user_ids,omitempty" example:"1,2,3"` // При пробном запуске - пользователи, которые были бы добавлены
+}
+
+// SegmentUsersDeleteReport - результат массового удаления пользователей из сегмента.
+// При пробном запуске отчёт описывает изменения, которые были бы сделаны.
+type SegmentUsersDeleteReport struct {
+	Deleted    int   `json:"deleted" example:"49998"`            // Количество пользователей, вышедших из сегмента
+	NotMembers []int `json:"not_members" example:"12,40"`        // Пропущенные пользователи, не входящие в сегмент
+	DryRun     bool  `json:"dry_run,omitempty" example:"true"`   // Признак пробного запуска, при котором изменения не сохраняются
+	UserIDs    []int `json:"user_ids,omitempty" example:"1,2,3"` // При пробном запуске - пользователи, которые вышли бы из сегмента
+}
+
+// SegmentPreview - результат пробного создания сегмента: пользователи, которые вошли бы в сегмент.
+type SegmentPreview struct {
+	Name       string `json:"name" example:"AVITO_MUSIC_SERVICE"`
+	UsersCount int    `json:"users_count" example:"3"`                         // Количество пользователей, которые вошли бы в сегмент
+	UserIDs    []int  `json:"user_ids" example:"1,2,3"`                        // Пользователи, которые вошли бы в сегмент, по возрастанию ID
+	Salt       string `json:"salt" example:"9e107d9d372bb6826bd81d3542a419d6"` // Соль раскатки, которую нужно передать при создании, чтобы состав совпал
+}
+
+// Форматы архива истории вхождения пользователей в окончательно удаляемый сегмент
+const (
+	SegmentArchiveFormatCSV    = "csv"    // CSV с заголовком
+	SegmentArchiveFormatNDJSON = "ndjson" // По одному JSON-объекту в строке
+)
+
+// SegmentArchiveRow - запись о вхождении пользователя в сегмент в архиве окончательно удаляемого сегмента.
+type SegmentArchiveRow struct {
+	InfoID      int    `json:"information_id" csv:"information_id"`
+	UserID      int    `json:"user_id" csv:"user_id"`
+	SegmentID   int    `json:"segment_id" csv:"segment_id"`
+	SegmentName string `json:"segment_name" csv:"segment_name"`
+	StartDate   string `json:"start_date" csv:"start_date"` // Дата добавления пользователя в сегмент
+	EndDate     string `json:"end_date" csv:"end_date"`     // Дата выхода пользователя из сегмента, может быть пустой
+	EndReason   string `json:"end_reason" csv:"end_reason"` // Причина выхода из сегмента: "expired", "removed" или пустая строка
+}
+
+// SegmentArchive - результат архивации истории вхождения пользователей в сегмент и его окончательного удаления.
+type SegmentArchive struct {
+	Name         string `json:"name" example:"AVITO_MUSIC_SERVICE"`
+	Format       string `json:"format" example:"csv"`
+	ArchivedRows int    `json:"archived_rows" example:"120"`                                               // Количество записей о вхождении пользователей в архиве
+	Archive      string `json:"archive" example:"https://drive.google.com/file/d/1a2b3c/view?usp=sharing"` // Ссылка на файл архива либо, если облачное хранилище не настроено, содержимое архива
 }
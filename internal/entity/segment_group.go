@@ -0,0 +1,9 @@
+package entity
+
+// SegmentGroup - группа взаимоисключающих сегментов: пользователь может
+// одновременно входить не более чем в один сегмент группы.
+type SegmentGroup struct {
+	ID       int      `json:"group_id" example:"3"`
+	Name     string   `json:"name" example:"AVITO_DISCOUNT"`
+	Segments []string `json:"segments" example:"AVITO_DISCOUNT_30,AVITO_DISCOUNT_50,AVITO_DISCOUNT_70"` // Наименования сегментов группы
+}
@@ -1,13 +1,16 @@
 package entity
 
 type User struct {
-	ID        int    `json:"user_id" example:"16"`
-	Name      string `json:"name" example:"Михаил"`
-	Lastname  string `json:"lastname" example:"Иванов"`
-	Sex       int    `json:"sex" example:"0" enums:"0,1"` // Пол, 0 - мужской, 1 - женский
-	SexText   string `json:"sex_text" example:"мужской" enums:"мужской,женский"`
-	Age       int    `json:"age" example:"26"`
-	IsDeleted bool   `json:"is_deleted" example:"false"`
+	ID         int     `json:"user_id" example:"16"`
+	ExternalID *string `json:"external_id,omitempty" example:"crm-1842"` // Идентификатор пользователя во внешней системе, отсутствует, если не задан
+	Name       string  `json:"name" example:"Михаил"`
+	Lastname   string  `json:"lastname" example:"Иванов"`
+	Sex        int     `json:"sex" example:"0" enums:"0,1"` // Пол, 0 - мужской, 1 - женский
+	SexText    string  `json:"sex_text" example:"мужской" enums:"мужской,женский"`
+	Age        int     `json:"age" example:"26"`
+	IsDeleted  bool    `json:"is_deleted" example:"false"`
+	// Дополнительные атрибуты пользователя (город, платформа и т.п.): строки, числа или логические значения
+	Attributes map[string]interface{} `json:"attributes,omitempty" swaggertype:"object"`
 }
 
 type UserSegmentInformation struct {
@@ -23,3 +26,44 @@ type UserWithSegments struct {
 	User     User                     `json:"user"`
 	Segments []UserSegmentInformation `json:"segments"`
 }
+
+// UsersWithSegmentsPage - страница списка пользователей с их сегментами.
+type UsersWithSegmentsPage struct {
+	Users      []UserWithSegments `json:"users"`
+	NextCursor string             `json:"next_cursor,omitempty" example:"MTY"` // Курсор следующей страницы, отсутствует на последней странице
+}
+
+// UsersFilter - условия выборки и порядок списка пользователей.
+type UsersFilter struct {
+	Name       *string                // Имя пользователя
+	Lastname   *string                // Фамилия пользователя
+	Sex        *int                   // Пол пользователя
+	MinAge     *int                   // Минимальный возраст пользователя, включительно
+	MaxAge     *int                   // Максимальный возраст пользователя, включительно
+	IsDeleted  *bool                  // Пометка об удалении
+	Attributes map[string]interface{} // Значения дополнительных атрибутов пользователя
+	SortBy     string                 // Поле сортировки: user_id, name, lastname или age
+	Desc       bool                   // Сортировка по убыванию
+	AfterValue interface{}            // Значение поля сортировки у последнего пользователя предыдущей страницы, nil - первая страница
+	AfterID    int                    // ID последнего пользователя предыдущей страницы
+	Limit      int                    // Максимальное количество пользователей
+}
+
+// UsersPage - страница списка пользователей.
+type UsersPage struct {
+	Users      []User `json:"users"`
+	NextCursor string `json:"next_cursor,omitempty" example:"eyJzIjoiYWdlIiwidiI6MjcsImlkIjoxNn0"` // Курсор следующей страницы, отсутствует на последней странице
+}
+
+// UserImportError - ошибка импорта одной строки входных данных.
+type UserImportError struct {
+	Row   int    `json:"row" example:"3"`                                // Номер строки: строки данных CSV (без заголовка) или строки NDJSON, начиная с 1
+	Error string `json:"error" example:"\"name\" field cannot be empty"` // Причина, по которой строка не была импортирована
+}
+
+// UserImportReport - результат массового импорта пользователей.
+type UserImportReport struct {
+	Imported int               `json:"imported" example:"9998"` // Количество импортированных пользователей
+	Failed   int               `json:"failed" example:"2"`      // Количество строк, не прошедших валидацию
+	Errors   []UserImportError `json:"errors"`                  // Ошибки строк, не прошедших валидацию
+}
@@ -45,6 +45,13 @@ type ErrUserDeleted struct {
 	ErrBase
 }
 
+// ErrUserAlreadyExists используется, когда
+// происходит попытка создать пользователя с
+// внешним идентификатором, который уже занят.
+type ErrUserAlreadyExists struct {
+	ErrBase
+}
+
 // ErrSegmentValidationError обозначает ошибку
 // валидации данных сегмента.
 type ErrSegmentValidationError struct {
@@ -77,3 +84,55 @@ type ErrSegmentAlreadyExists struct {
 type ErrReportValidationError struct {
 	ErrBase
 }
+
+// ErrExperimentValidationError обозначает
+// ошибку валидации данных эксперимента.
+type ErrExperimentValidationError struct {
+	ErrBase
+}
+
+// ErrExperimentNotFound обозначает ошибку
+// при обращении к несуществующему эксперименту.
+type ErrExperimentNotFound struct {
+	ErrBase
+}
+
+// ErrExperimentAlreadyExists используется, когда
+// происходит попытка создать эксперимент, который
+// уже существует в базе данных.
+type ErrExperimentAlreadyExists struct {
+	ErrBase
+}
+
+// ErrExperimentVariantNotFound используется, когда
+// пользователь не распределён ни в один из вариантов
+// эксперимента.
+type ErrExperimentVariantNotFound struct {
+	ErrBase
+}
+
+// ErrSegmentGroupValidationError обозначает ошибку
+// валидации данных группы сегментов.
+type ErrSegmentGroupValidationError struct {
+	ErrBase
+}
+
+// ErrSegmentGroupNotFound обозначает ошибку
+// при обращении к несуществующей группе сегментов.
+type ErrSegmentGroupNotFound struct {
+	ErrBase
+}
+
+// ErrSegmentGroupAlreadyExists используется, когда
+// происходит попытка создать группу сегментов, которая
+// уже существует в базе данных.
+type ErrSegmentGroupAlreadyExists struct {
+	ErrBase
+}
+
+// ErrSegmentGroupConflict используется, когда операция
+// нарушает взаимоисключаемость сегментов группы: пользователь
+// оказался бы одновременно в нескольких сегментах одной группы.
+type ErrSegmentGroupConflict struct {
+	ErrBase
+}
@@ -0,0 +1,341 @@
+package pgdb
+
+import (
+	"avito-rest-api/internal/entity"
+	customError "avito-rest-api/internal/error"
+	"avito-rest-api/package/postgres"
+	"context"
+	"fmt"
+	"github.com/Masterminds/squirrel"
+)
+
+type ExperimentRepository struct {
+	*postgres.PostgreDB
+}
+
+// NewExperimentRepository инициализирует репозиторий `experiment`, инкапсулирующий
+// логику хранения A/B экспериментов и их вариантов.
+func NewExperimentRepository(pg *postgres.PostgreDB) *ExperimentRepository {
+	return &ExperimentRepository{pg}
+}
+
+// CreateExperiment добавляет в базу данных эксперимент с указанным в `experiment` именем и его варианты
+// и возвращает `id` добавленного эксперимента. Сегменты вариантов должны быть созданы заранее.
+func (r *ExperimentRepository) CreateExperiment(ctx context.Context, experiment entity.Experiment) (int, error) {
+	sql, args, err := r.Builder.
+		Insert("experiments").
+		Columns("name").
+		Values(experiment.Name).
+		Suffix("RETURNING experiment_id").
+		ToSql()
+	if err != nil {
+		return 0, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         fmt.Sprintf("Failed to build sql query for creating experiment \"%s\"", experiment.Name),
+			Location:        "ExperimentRepository.CreateExperiment - r.Builder",
+		}}
+	}
+
+	var id int
+	if err = conn(ctx, r.Pool).QueryRow(ctx, sql, args...).Scan(&id); err != nil {
+		return 0, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         fmt.Sprintf("Failed to create experiment \"%s\"", experiment.Name),
+			Location:        "ExperimentRepository.CreateExperiment - conn.QueryRow",
+		}}
+	}
+
+	query := r.Builder.
+		Insert("experiment_variants").
+		Columns("experiment_id", "segment_id", "name", "weight")
+	for _, variant := range experiment.Variants {
+		query = query.Values(id, variant.SegmentID, variant.Name, variant.Weight)
+	}
+
+	sql, args, err = query.ToSql()
+	if err != nil {
+		return 0, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         fmt.Sprintf("Failed to build sql query for creating variants of experiment \"%s\"", experiment.Name),
+			Location:        "ExperimentRepository.CreateExperiment - query.ToSql",
+		}}
+	}
+
+	if _, err = conn(ctx, r.Pool).Exec(ctx, sql, args...); err != nil {
+		return 0, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         fmt.Sprintf("Failed to create variants of experiment \"%s\"", experiment.Name),
+			Location:        "ExperimentRepository.CreateExperiment - conn.Exec",
+		}}
+	}
+
+	return id, nil
+}
+
+// GetExperimentByName возвращает эксперимент с указанным именем вместе с его вариантами.
+func (r *ExperimentRepository) GetExperimentByName(ctx context.Context, name string) (entity.Experiment, error) {
+	experiments, err := r.getExperiments(ctx, squirrel.Eq{"e.name": name}, "ExperimentRepository.GetExperimentByName")
+	if err != nil {
+		return entity.Experiment{}, err
+	}
+	if len(experiments) == 0 {
+		return entity.Experiment{}, customError.ErrExperimentNotFound{ErrBase: customError.ErrBase{
+			Comment:  fmt.Sprintf("Experiment with name \"%s\" does not exist", name),
+			Location: "ExperimentRepository.GetExperimentByName",
+		}}
+	}
+	return experiments[0], nil
+}
+
+// GetAllExperiments возвращает все эксперименты вместе с их вариантами.
+func (r *ExperimentRepository) GetAllExperiments(ctx context.Context) ([]entity.Experiment, error) {
+	return r.getExperiments(ctx, nil, "ExperimentRepository.GetAllExperiments")
+}
+
+// getExperiments выбирает эксперименты, удовлетворяющие условию `where` (все эксперименты, если условие
+// не задано), вместе с их вариантами, упорядоченными по порядку создания.
+func (r *ExperimentRepository) getExperiments(ctx context.Context, where squirrel.Sqlizer, location string) ([]entity.Experiment, error) {
+	query := r.Builder.
+		Select("e.experiment_id", "e.name", "e.salt", "v.variant_id", "v.name", "v.weight", "s.segment_id", "s.name").
+		From("experiments e").
+		Join("experiment_variants v on v.experiment_id = e.experiment_id").
+		Join("segments s on s.segment_id = v.segment_id").
+		OrderBy("e.experiment_id", "v.variant_id")
+	if where != nil {
+		query = query.Where(where)
+	}
+
+	sql, args, err := query.ToSql()
+	if err != nil {
+		return nil, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         "Failed to build sql query for fetching experiments",
+			Location:        location + " - r.Builder",
+		}}
+	}
+
+	rows, err := conn(ctx, r.Pool).Query(ctx, sql, args...)
+	if err != nil {
+		return nil, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         "Failed to query experiments, inspect origin error text",
+			Location:        location + " - conn.Query",
+		}}
+	}
+	defer rows.Close()
+
+	var experiments []entity.Experiment
+	for rows.Next() {
+		var experiment entity.Experiment
+		var variant entity.ExperimentVariant
+		err = rows.Scan(
+			&experiment.ID,
+			&experiment.Name,
+			&experiment.Salt,
+			&variant.ID,
+			&variant.Name,
+			&variant.Weight,
+			&variant.SegmentID,
+			&variant.SegmentName,
+		)
+		if err != nil {
+			return nil, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+				OriginError:     err,
+				OriginErrorText: err.Error(),
+				Comment:         "Failed to scan experiment to structure",
+				Location:        location + " - rows.Scan",
+			}}
+		}
+
+		// Строки одного эксперимента идут подряд, так как выборка упорядочена по experiment_id
+		if len(experiments) == 0 || experiments[len(experiments)-1].ID != experiment.ID {
+			experiments = append(experiments, experiment)
+		}
+		last := &experiments[len(experiments)-1]
+		last.Variants = append(last.Variants, variant)
+	}
+
+	return experiments, nil
+}
+
+// AssignUsersToExperiment распределяет не удалённых пользователей с идентификаторами `userIDs` (если `userIDs`
+// равен nil - всех не удалённых пользователей) по вариантам эксперимента, добавляя их в сегменты вариантов.
+// Вариант пользователя определяется хешем пользователя по соли эксперимента по модулю суммы весов вариантов:
+// каждому варианту соответствует диапазон значений длиной в его вес. Пользователи, уже входящие в какой-либо
+// вариант эксперимента, не распределяются повторно.
+func (r *ExperimentRepository) AssignUsersToExperiment(ctx context.Context, experiment entity.Experiment, userIDs []int) error {
+	var totalWeight int
+	for _, variant := range experiment.Variants {
+		totalWeight += variant.Weight
+	}
+
+	bucketExpr := fmt.Sprintf("%s %% %d", userHashExpr, totalWeight)
+	users := squirrel.
+		Select("u.user_id").
+		From("users u").
+		Where("u.is_deleted = false")
+	if userIDs != nil {
+		users = users.Where("u.user_id = any(?)", userIDs)
+	}
+
+	lowerBound := 0
+	for _, variant := range experiment.Variants {
+		upperBound := lowerBound + variant.Weight
+
+		sql, args, err := r.Builder.
+			Insert("users_segments").
+			Columns("user_id", "segment_id").
+			Select(users.
+				Column(fmt.Sprint(variant.SegmentID)).
+				Where(squirrel.Expr(fmt.Sprintf("%s between ? and ?", bucketExpr), experiment.Salt, lowerBound, upperBound-1)).
+				Where("not exists (select 1 from users_segments us join experiment_variants v on v.segment_id = us.segment_id "+
+					"where us.user_id = u.user_id and v.experiment_id = ? "+
+					"and "+openMembership("us.")+")", experiment.ID),
+			).
+			ToSql()
+		if err != nil {
+			return customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+				OriginError:     err,
+				OriginErrorText: err.Error(),
+				Comment:         fmt.Sprintf("Failed to build sql query for assigning users to variant \"%s\" of experiment \"%s\"", variant.Name, experiment.Name),
+				Location:        "ExperimentRepository.AssignUsersToExperiment - r.Builder",
+			}}
+		}
+
+		if _, err = conn(ctx, r.Pool).Exec(ctx, sql, args...); err != nil {
+			return customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+				OriginError:     err,
+				OriginErrorText: err.Error(),
+				Comment:         fmt.Sprintf("Failed to assign users to variant \"%s\" of experiment \"%s\"", variant.Name, experiment.Name),
+				Location:        "ExperimentRepository.AssignUsersToExperiment - conn.Exec",
+			}}
+		}
+
+		lowerBound = upperBound
+	}
+
+	return nil
+}
+
+// GetUserVariant возвращает вариант эксперимента, в который распределён пользователь с указанным `userID`.
+func (r *ExperimentRepository) GetUserVariant(ctx context.Context, experiment entity.Experiment, userID int) (entity.UserExperimentVariant, error) {
+	sql, args, err := r.Builder.
+		Select("v.variant_id", "v.name", "v.weight", "s.segment_id", "s.name",
+			"to_char(us.start_date, 'HH24:MI:SS DD.MM.YYYY')").
+		From("experiment_variants v").
+		Join("segments s on s.segment_id = v.segment_id").
+		Join("users_segments us on us.segment_id = v.segment_id").
+		Where("v.experiment_id = ? and us.user_id = ?", experiment.ID, userID).
+		Where(openMembership("us.")).
+		OrderBy("us.start_date desc").
+		Limit(1).
+		ToSql()
+	if err != nil {
+		return entity.UserExperimentVariant{}, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         fmt.Sprintf("Failed to build sql query for fetching variant of user (id = %d)", userID),
+			Location:        "ExperimentRepository.GetUserVariant - r.Builder",
+		}}
+	}
+
+	rows, err := conn(ctx, r.Pool).Query(ctx, sql, args...)
+	if err != nil {
+		return entity.UserExperimentVariant{}, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         fmt.Sprintf("Failed to query variant of user (id = %d)", userID),
+			Location:        "ExperimentRepository.GetUserVariant - conn.Query",
+		}}
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		userVariant := entity.UserExperimentVariant{UserID: userID, Experiment: experiment.Name}
+		err = rows.Scan(
+			&userVariant.Variant.ID,
+			&userVariant.Variant.Name,
+			&userVariant.Variant.Weight,
+			&userVariant.Variant.SegmentID,
+			&userVariant.Variant.SegmentName,
+			&userVariant.StartDate,
+		)
+		if err != nil {
+			return entity.UserExperimentVariant{}, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+				OriginError:     err,
+				OriginErrorText: err.Error(),
+				Comment:         "Failed to scan user's variant to structure",
+				Location:        "ExperimentRepository.GetUserVariant - rows.Scan",
+			}}
+		}
+		return userVariant, nil
+	}
+
+	return entity.UserExperimentVariant{}, customError.ErrExperimentVariantNotFound{ErrBase: customError.ErrBase{
+		Comment:  fmt.Sprintf("User with id %d is not assigned to any variant of experiment \"%s\"", userID, experiment.Name),
+		Location: "ExperimentRepository.GetUserVariant",
+	}}
+}
+
+// GetVariantSizes возвращает количество пользователей, входящих на текущий момент
+// в каждый из вариантов эксперимента с указанным `id`.
+func (r *ExperimentRepository) GetVariantSizes(ctx context.Context, id int) ([]entity.ExperimentVariantSize, error) {
+	sql, args, err := r.Builder.
+		Select("v.variant_id", "v.name", "v.weight", "s.segment_id", "s.name", "count(us.user_segment_id)").
+		From("experiment_variants v").
+		Join("segments s on s.segment_id = v.segment_id").
+		LeftJoin("users_segments us on us.segment_id = v.segment_id and " + openMembership("us.")).
+		Where("v.experiment_id = ?", id).
+		GroupBy("v.variant_id", "s.segment_id").
+		OrderBy("v.variant_id").
+		ToSql()
+	if err != nil {
+		return nil, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         fmt.Sprintf("Failed to build sql query for fetching variant sizes of experiment (id = %d)", id),
+			Location:        "ExperimentRepository.GetVariantSizes - r.Builder",
+		}}
+	}
+
+	rows, err := conn(ctx, r.Pool).Query(ctx, sql, args...)
+	if err != nil {
+		return nil, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         fmt.Sprintf("Failed to query variant sizes of experiment (id = %d)", id),
+			Location:        "ExperimentRepository.GetVariantSizes - conn.Query",
+		}}
+	}
+	defer rows.Close()
+
+	var sizes []entity.ExperimentVariantSize
+	for rows.Next() {
+		var size entity.ExperimentVariantSize
+		err = rows.Scan(
+			&size.ID,
+			&size.Name,
+			&size.Weight,
+			&size.SegmentID,
+			&size.SegmentName,
+			&size.Users,
+		)
+		if err != nil {
+			return nil, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+				OriginError:     err,
+				OriginErrorText: err.Error(),
+				Comment:         "Failed to scan variant size to structure",
+				Location:        "ExperimentRepository.GetVariantSizes - rows.Scan",
+			}}
+		}
+		sizes = append(sizes, size)
+	}
+
+	return sizes, nil
+}
@@ -17,15 +17,26 @@ func NewReportRepository(pg *postgres.PostgreDB) *ReportRepository {
 }
 
 // MakeReport формирует новый отчёт об истории вхождения-выхождения пользователей из сегментов,
-// содержащий столбцы `user_id`, `segment_name`, `start_date`, `end_date`
-func (r *ReportRepository) MakeReport(ctx context.Context) (entity.Report, error) {
-	sql, args, err := r.Builder.
+// содержащий столбцы `user_id`, `segment_name`, `start_date`, `end_date`.
+// В отчёт попадают только те записи, период действия которых пересекается с полуинтервалом
+// [`from`, `to`). Нулевое значение `from` или `to` означает, что соответствующая граница не задана.
+// Отменённые до начала запланированные вхождения в отчёт не попадают.
+func (r *ReportRepository) MakeReport(ctx context.Context, from, to time.Time) (entity.Report, error) {
+	query := r.Builder.
 		Select("u.user_id, s.name as segment_name, to_char(us.start_date, 'HH24:MI:SS DD.MM.YYYY'), coalesce(to_char(us.end_date, 'HH24:MI:SS DD.MM.YYYY'), '')").
 		From("users u").
 		Join("users_segments us on us.user_id = u.user_id").
 		Join("segments s on s.segment_id = us.segment_id").
-		OrderBy("us.start_date asc").
-		ToSql()
+		Where("(us.end_date is null or us.end_date > us.start_date)").
+		OrderBy("us.start_date asc")
+	if !from.IsZero() {
+		query = query.Where("(us.end_date is null or us.end_date > ?)", from)
+	}
+	if !to.IsZero() {
+		query = query.Where("us.start_date < ?", to)
+	}
+
+	sql, args, err := query.ToSql()
 	if err != nil {
 		return entity.Report{}, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
 			OriginError:     err,
@@ -35,7 +46,7 @@ func (r *ReportRepository) MakeReport(ctx context.Context) (entity.Report, error
 		}}
 	}
 
-	rows, err := r.Pool.Query(ctx, sql, args...)
+	rows, err := conn(ctx, r.Pool).Query(ctx, sql, args...)
 	if err != nil {
 		return entity.Report{}, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
 			OriginError:     err,
@@ -44,6 +55,7 @@ func (r *ReportRepository) MakeReport(ctx context.Context) (entity.Report, error
 			Location:        "ReportRepository.MakeReport: r.Pool.Query",
 		}}
 	}
+	defer rows.Close()
 
 	report := entity.Report{ReportDate: time.Now().Format("15:04:05 02.01.2006")}
 	for rows.Next() {
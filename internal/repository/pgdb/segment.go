@@ -5,11 +5,21 @@ import (
 	customError "avito-rest-api/internal/error"
 	"avito-rest-api/package/postgres"
 	"context"
+	"errors"
 	"fmt"
+	"github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"strings"
 	"time"
 )
 
+// segmentColumns - столбцы, из которых читается сегмент, в порядке полей, сканируемых в entity.Segment.
+const segmentColumns = "segment_id, name, is_deleted, rules, percentage, salt, group_id, default_ttl, " +
+	"coalesce(to_char(active_until, 'HH24:MI:SS DD.MM.YYYY'), ''), description, owner, tags, " +
+	"to_char(created_at, 'HH24:MI:SS DD.MM.YYYY'), to_char(updated_at, 'HH24:MI:SS DD.MM.YYYY'), " +
+	"coalesce(to_char(deleted_at, 'HH24:MI:SS DD.MM.YYYY'), ''), derived_operation, derived_from"
+
 type SegmentRepository struct {
 	*postgres.PostgreDB
 }
@@ -20,19 +30,30 @@ func NewSegmentRepository(pg *postgres.PostgreDB) *SegmentRepository {
 	return &SegmentRepository{pg}
 }
 
-// CreateSegment добавляет в базу данных новый сегмент с указанным в `segment` именем
-// и возвращает имя добавленного сегмента.
+// CreateSegment добавляет в базу данных новый сегмент с указанными в `segment` именем, правилами,
+// процентом раскатки, солью и, для вычисляемого сегмента, операцией над исходными сегментами и возвращает
+// имя добавленного сегмента. Если соль не указана, она генерируется базой данных.
 func (r *SegmentRepository) CreateSegment(ctx context.Context, segment entity.Segment) (string, error) {
+	var derivedOperation interface{}
+	var derivedFrom interface{}
+	if segment.Derivation != nil {
+		derivedOperation, derivedFrom = segment.Derivation.Operation, segment.Derivation.SegmentIDs
+	}
+	columns := []string{"name", "rules", "percentage", "derived_operation", "derived_from"}
+	values := []interface{}{segment.Name, segmentRulesValue(segment.Rules), segment.Percentage, derivedOperation, derivedFrom}
+	if segment.Salt != "" {
+		columns, values = append(columns, "salt"), append(values, segment.Salt)
+	}
 	sql, args, _ := r.Builder.
 		Insert("segments").
-		Columns("name").
-		Values(segment.Name).
+		Columns(columns...).
+		Values(values...).
 		Suffix("RETURNING name").
 		ToSql()
 
 	var name string
 
-	if err := r.Pool.QueryRow(ctx, sql, args...).Scan(&name); err != nil {
+	if err := conn(ctx, r.Pool).QueryRow(ctx, sql, args...).Scan(&name); err != nil {
 		return "", customError.ErrInternalServerError{ErrBase: customError.ErrBase{
 			OriginError: err,
 			Comment:     fmt.Sprintf("Failed to create or recover segment %s", segment.Name),
@@ -43,8 +64,8 @@ func (r *SegmentRepository) CreateSegment(ctx context.Context, segment entity.Se
 }
 
 // GetAllSegments используется для получения всех сегментов указанного типа `sType` ("alive" - только
-// не удалённые, "deleted" - только удалённые, "both" - все).
-func (r *SegmentRepository) GetAllSegments(ctx context.Context, sType int) ([]entity.Segment, error) {
+// не удалённые, "deleted" - только удалённые, "both" - все), удовлетворяющих условиям `filter`.
+func (r *SegmentRepository) GetAllSegments(ctx context.Context, sType int, filter entity.SegmentsFilter) ([]entity.Segment, error) {
 	var condition []interface{}
 	switch sType {
 	case 0: // только не удалённые
@@ -69,11 +90,17 @@ func (r *SegmentRepository) GetAllSegments(ctx context.Context, sType int) ([]en
 		sqlPlaceholders = append(sqlPlaceholders, "?")
 	}
 
-	sql, args, err := r.Builder.
-		Select("*").
+	query := r.Builder.
+		Select(segmentColumns).
 		From("segments").
-		Where(fmt.Sprintf("is_deleted in (%s)", strings.Join(sqlPlaceholders, ", ")), condition...).
-		ToSql()
+		Where(fmt.Sprintf("is_deleted in (%s)", strings.Join(sqlPlaceholders, ", ")), condition...)
+	if filter.Tag != "" {
+		query = query.Where("? = any(tags)", filter.Tag)
+	}
+	if filter.Owner != "" {
+		query = query.Where("owner = ?", filter.Owner)
+	}
+	sql, args, err := query.OrderBy("segment_id").ToSql()
 	if err != nil {
 		return nil, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
 			OriginError:     err,
@@ -83,7 +110,7 @@ func (r *SegmentRepository) GetAllSegments(ctx context.Context, sType int) ([]en
 		}}
 	}
 
-	rows, err := r.Pool.Query(ctx, sql, args...)
+	rows, err := conn(ctx, r.Pool).Query(ctx, sql, args...)
 	if err != nil {
 		return nil, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
 			OriginError:     err,
@@ -92,16 +119,12 @@ func (r *SegmentRepository) GetAllSegments(ctx context.Context, sType int) ([]en
 			Location:        "SegmentRepository.GetAllSegments - r.Pool.Query",
 		}}
 	}
+	defer rows.Close()
 
 	var segments []entity.Segment
 
 	for rows.Next() {
-		var segment entity.Segment
-		err := rows.Scan(
-			&segment.ID,
-			&segment.Name,
-			&segment.IsDeleted,
-		)
+		segment, err := scanSegment(rows)
 		if err != nil {
 			return nil, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
 				OriginError:     err,
@@ -121,12 +144,12 @@ func (r *SegmentRepository) GetAllSegments(ctx context.Context, sType int) ([]en
 // на уровне сервиса).
 func (r *SegmentRepository) GetSegmentByName(ctx context.Context, name string) (entity.Segment, error) {
 	sql, args, _ := r.Builder.
-		Select("segment_id, name, is_deleted").
+		Select(segmentColumns).
 		From("segments").
 		Where("name = ?", name).
 		ToSql()
 
-	rows, err := r.Pool.Query(ctx, sql, args...)
+	rows, err := conn(ctx, r.Pool).Query(ctx, sql, args...)
 	if err != nil {
 		return entity.Segment{}, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
 			OriginError:     err,
@@ -135,14 +158,10 @@ func (r *SegmentRepository) GetSegmentByName(ctx context.Context, name string) (
 			Location:        "SegmentRepository.GetSegmentByName - r.Pool.Query",
 		}}
 	}
+	defer rows.Close()
 
 	for rows.Next() {
-		var segment entity.Segment
-		err = rows.Scan(
-			&segment.ID,
-			&segment.Name,
-			&segment.IsDeleted,
-		)
+		segment, err := scanSegment(rows)
 		if err != nil {
 			return entity.Segment{}, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
 				OriginError:     err,
@@ -165,11 +184,12 @@ func (r *SegmentRepository) GetSegmentByName(ctx context.Context, name string) (
 // DeleteSegment не проверяет существование сегмента перед выполнением операции (проверка
 // реализуется на уровне сервиса).
 func (r *SegmentRepository) DeleteSegment(ctx context.Context, name string) error {
-	// Получим id сегмента
+	// Получим id сегмента, заблокировав его запись до конца транзакции
 	sql, args, err := r.Builder.
 		Select("segment_id").
 		From("segments").
 		Where("name = ?", name).
+		Suffix("FOR UPDATE").
 		ToSql()
 	if err != nil {
 		return customError.ErrInternalServerError{ErrBase: customError.ErrBase{
@@ -181,7 +201,7 @@ func (r *SegmentRepository) DeleteSegment(ctx context.Context, name string) erro
 	}
 
 	var id int
-	err = r.Pool.QueryRow(ctx, sql, args...).Scan(&id)
+	err = conn(ctx, r.Pool).QueryRow(ctx, sql, args...).Scan(&id)
 	if err != nil {
 		return customError.ErrInternalServerError{ErrBase: customError.ErrBase{
 			OriginError:     err,
@@ -195,6 +215,8 @@ func (r *SegmentRepository) DeleteSegment(ctx context.Context, name string) erro
 	sql, args, err = r.Builder.
 		Update("segments").
 		Set("is_deleted", true).
+		Set("updated_at", squirrel.Expr("current_timestamp")).
+		Set("deleted_at", squirrel.Expr("current_timestamp")).
 		Where("segment_id = ?", id).
 		ToSql()
 	if err != nil {
@@ -206,7 +228,7 @@ func (r *SegmentRepository) DeleteSegment(ctx context.Context, name string) erro
 		}}
 	}
 
-	res, err := r.Pool.Exec(ctx, sql, args...)
+	res, err := conn(ctx, r.Pool).Exec(ctx, sql, args...)
 	if err != nil {
 		return customError.ErrInternalServerError{ErrBase: customError.ErrBase{
 			OriginError:     err,
@@ -229,8 +251,10 @@ func (r *SegmentRepository) DeleteSegment(ctx context.Context, name string) erro
 	// время выхода из сегмента, равное моменту удаления сегмента (текущему времени)
 	sql, args, err = r.Builder.
 		Update("users_segments").
-		Set("end_date", time.Now()).
+		Set("end_date", membershipEndNow()).
+		Set("end_reason", entity.MembershipEndReasonRemoved).
 		Where("segment_id = ?", id).
+		Where(openMembership("")).
 		ToSql()
 	if err != nil {
 		return customError.ErrInternalServerError{ErrBase: customError.ErrBase{
@@ -241,7 +265,7 @@ func (r *SegmentRepository) DeleteSegment(ctx context.Context, name string) erro
 		}}
 	}
 
-	res, err = r.Pool.Exec(ctx, sql, args...)
+	res, err = conn(ctx, r.Pool).Exec(ctx, sql, args...)
 	if err != nil {
 		return customError.ErrInternalServerError{ErrBase: customError.ErrBase{
 			OriginError:     err,
@@ -256,15 +280,24 @@ func (r *SegmentRepository) DeleteSegment(ctx context.Context, name string) erro
 
 // RecoverSegment используется при вызове операции создания сегмента в том случае,
 // если сегмент с указанным именем существовал ранее и был удалён.
-// RecoverSegment меняет флаг `is_deleted` у сегмента на `false`.
-func (r *SegmentRepository) RecoverSegment(ctx context.Context, name string) (string, error) {
-	sql, args, _ := r.Builder.
+// RecoverSegment меняет флаг `is_deleted` у сегмента на `false`. Восстановленный сегмент
+// перестаёт быть вычисляемым. Непустая соль `salt` заменяет прежнюю соль сегмента.
+func (r *SegmentRepository) RecoverSegment(ctx context.Context, name string, salt string) (string, error) {
+	query := r.Builder.
 		Update("segments").
 		Set("is_deleted", false).
+		Set("deleted_at", nil).
+		Set("derived_operation", nil).
+		Set("derived_from", nil).
+		Set("updated_at", squirrel.Expr("current_timestamp"))
+	if salt != "" {
+		query = query.Set("salt", salt)
+	}
+	sql, args, _ := query.
 		Where("name = ?", name).
 		ToSql()
 
-	res, err := r.Pool.Exec(ctx, sql, args...)
+	res, err := conn(ctx, r.Pool).Exec(ctx, sql, args...)
 	if err != nil {
 		return "", customError.ErrInternalServerError{ErrBase: customError.ErrBase{
 			OriginError:     err,
@@ -284,83 +317,1265 @@ func (r *SegmentRepository) RecoverSegment(ctx context.Context, name string) (st
 	return name, nil
 }
 
-// AddUsersToSegmentByRandomPercent используется для добавления случайных `percent`% пользователей в
-// указанный сегмент.
-func (r *SegmentRepository) AddUsersToSegmentByRandomPercent(ctx context.Context, name string, percent int) error {
-	// Получим percent% случайных пользователей из БД
+// UpdateSegmentRules заменяет правила автоматического вхождения пользователей в сегмент с указанным `id`.
+// Пустой список `rules` удаляет правила сегмента. UpdateSegmentRules не изменяет состав сегмента,
+// для этого используется SyncSegmentUsers.
+func (r *SegmentRepository) UpdateSegmentRules(ctx context.Context, id int, rules []entity.SegmentRule) error {
 	sql, args, err := r.Builder.
-		Select("user_id").
-		From("users").
-		Suffix(fmt.Sprintf("tablesample bernoulli (%d)", percent)).
+		Update("segments").
+		Set("rules", segmentRulesValue(rules)).
+		Set("updated_at", squirrel.Expr("current_timestamp")).
+		Where("segment_id = ?", id).
+		ToSql()
+	if err != nil {
+		return customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         fmt.Sprintf("Failed to build sql query for updating rules of segment (id = %d)", id),
+			Location:        "SegmentRepository.UpdateSegmentRules - r.Builder",
+		}}
+	}
+
+	_, err = conn(ctx, r.Pool).Exec(ctx, sql, args...)
+	if err != nil {
+		return customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         fmt.Sprintf("Failed to update rules of segment (id = %d)", id),
+			Location:        "SegmentRepository.UpdateSegmentRules - conn.Exec",
+		}}
+	}
+
+	return nil
+}
+
+// UpdateSegmentPercentage заменяет процент пользователей, автоматически попадающих в сегмент с указанным `id`.
+// Значение nil отключает раскатку сегмента на процент. UpdateSegmentPercentage не изменяет состав сегмента,
+// для этого используется SyncSegmentUsers.
+func (r *SegmentRepository) UpdateSegmentPercentage(ctx context.Context, id int, percentage *int) error {
+	sql, args, err := r.Builder.
+		Update("segments").
+		Set("percentage", percentage).
+		Set("updated_at", squirrel.Expr("current_timestamp")).
+		Where("segment_id = ?", id).
+		ToSql()
+	if err != nil {
+		return customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         fmt.Sprintf("Failed to build sql query for updating percentage of segment (id = %d)", id),
+			Location:        "SegmentRepository.UpdateSegmentPercentage - r.Builder",
+		}}
+	}
+
+	_, err = conn(ctx, r.Pool).Exec(ctx, sql, args...)
+	if err != nil {
+		return customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         fmt.Sprintf("Failed to update percentage of segment (id = %d)", id),
+			Location:        "SegmentRepository.UpdateSegmentPercentage - conn.Exec",
+		}}
+	}
+
+	return nil
+}
+
+// UpdateSegmentMetadata заменяет описание, команду-владельца и теги сегмента с указанным `id`.
+func (r *SegmentRepository) UpdateSegmentMetadata(ctx context.Context, id int, description string, owner string, tags []string) error {
+	if tags == nil {
+		tags = []string{}
+	}
+
+	sql, args, err := r.Builder.
+		Update("segments").
+		Set("description", description).
+		Set("owner", owner).
+		Set("tags", tags).
+		Set("updated_at", squirrel.Expr("current_timestamp")).
+		Where("segment_id = ?", id).
+		ToSql()
+	if err != nil {
+		return customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         fmt.Sprintf("Failed to build sql query for updating metadata of segment (id = %d)", id),
+			Location:        "SegmentRepository.UpdateSegmentMetadata - r.Builder",
+		}}
+	}
+
+	_, err = conn(ctx, r.Pool).Exec(ctx, sql, args...)
+	if err != nil {
+		return customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         fmt.Sprintf("Failed to update metadata of segment (id = %d)", id),
+			Location:        "SegmentRepository.UpdateSegmentMetadata - conn.Exec",
+		}}
+	}
+
+	return nil
+}
+
+// UpdateSegmentLifetime заменяет срок вхождения пользователей в сегмент с указанным `id`, применяемый, если
+// при добавлении не указана дата выхода, и дату автоматического удаления сегмента. Значение nil
+// удаляет соответствующую настройку. Срок хранится с точностью до секунды.
+func (r *SegmentRepository) UpdateSegmentLifetime(ctx context.Context, id int, defaultTTL *time.Duration, activeUntil *time.Time) error {
+	var ttlSeconds *int64
+	if defaultTTL != nil {
+		seconds := int64(*defaultTTL / time.Second)
+		ttlSeconds = &seconds
+	}
+
+	sql, args, err := r.Builder.
+		Update("segments").
+		Set("default_ttl", ttlSeconds).
+		Set("active_until", activeUntil).
+		Set("updated_at", squirrel.Expr("current_timestamp")).
+		Where("segment_id = ?", id).
 		ToSql()
 	if err != nil {
 		return customError.ErrInternalServerError{ErrBase: customError.ErrBase{
 			OriginError:     err,
 			OriginErrorText: err.Error(),
-			Comment:         "Failed to build sql query for random fetching % of users",
-			Location:        "SegmentRepository.AddUsersToSegmentByRandomPercent - r.Builder",
+			Comment:         fmt.Sprintf("Failed to build sql query for updating lifetime of segment (id = %d)", id),
+			Location:        "SegmentRepository.UpdateSegmentLifetime - r.Builder",
 		}}
 	}
 
-	rows, err := r.Pool.Query(ctx, sql, args...)
+	_, err = conn(ctx, r.Pool).Exec(ctx, sql, args...)
 	if err != nil {
 		return customError.ErrInternalServerError{ErrBase: customError.ErrBase{
 			OriginError:     err,
 			OriginErrorText: err.Error(),
-			Comment:         "Failed to perform sql query for random fetching % of users",
-			Location:        "SegmentRepository.AddUsersToSegmentByRandomPercent - r.Pool.Query",
+			Comment:         fmt.Sprintf("Failed to update lifetime of segment (id = %d)", id),
+			Location:        "SegmentRepository.UpdateSegmentLifetime - conn.Exec",
+		}}
+	}
+
+	return nil
+}
+
+// GetAutomaticSegments возвращает все не удалённые сегменты, для которых заданы правила
+// автоматического вхождения пользователей или процент раскатки.
+func (r *SegmentRepository) GetAutomaticSegments(ctx context.Context) ([]entity.Segment, error) {
+	sql, args, err := r.Builder.
+		Select(segmentColumns).
+		From("segments").
+		Where("is_deleted = false and (rules is not null or percentage is not null)").
+		ToSql()
+	if err != nil {
+		return nil, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         "Failed to build sql query for fetching segments with rules or percentage",
+			Location:        "SegmentRepository.GetAutomaticSegments - r.Builder",
 		}}
 	}
 
-	var userIDs []int
+	rows, err := conn(ctx, r.Pool).Query(ctx, sql, args...)
+	if err != nil {
+		return nil, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         "Failed to query segments with rules or percentage, inspect origin error text",
+			Location:        "SegmentRepository.GetAutomaticSegments - conn.Query",
+		}}
+	}
+	defer rows.Close()
+
+	var segments []entity.Segment
 	for rows.Next() {
-		var id int
-		err = rows.Scan(&id)
+		segment, err := scanSegment(rows)
 		if err != nil {
-			return customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			return nil, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
 				OriginError:     err,
 				OriginErrorText: err.Error(),
-				Comment:         "Failed to scan user to structure",
-				Location:        "SegmentRepository.AddUsersToSegmentByRandomPercent - rows.Scan",
+				Comment:         "Failed to scan segment with rules or percentage to structure",
+				Location:        "SegmentRepository.GetAutomaticSegments - rows.Scan",
 			}}
 		}
-		userIDs = append(userIDs, id)
+		segments = append(segments, segment)
 	}
 
-	// Получим ID сегмента
-	segment, err := r.GetSegmentByName(ctx, name)
+	return segments, nil
+}
+
+// SyncSegmentUsers приводит состав сегмента в соответствие с его правилами `segment.Rules` и процентом
+// раскатки `segment.Percentage`: пользователи, не подходящие сегменту, выходят из него, а не удалённые
+// подходящие пользователи, не входящие в сегмент, добавляются в него. Правила должны быть
+// предварительно провалидированы на уровне сервиса.
+func (r *SegmentRepository) SyncSegmentUsers(ctx context.Context, segment entity.Segment) error {
+	// Подзапрос, выбирающий пользователей, подходящих сегменту
+	matchingUsers, err := matchingUsersQuery(segment)
+	if err != nil {
+		return customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         fmt.Sprintf("Failed to build condition from rules of segment \"%s\"", segment.Name),
+			Location:        "SegmentRepository.SyncSegmentUsers - matchingUsersQuery",
+		}}
+	}
+	matchingUsersSql, matchingUsersArgs, err := matchingUsers.ToSql()
 	if err != nil {
-		return err
+		return customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         fmt.Sprintf("Failed to build sql query for fetching users matching rules of segment \"%s\"", segment.Name),
+			Location:        "SegmentRepository.SyncSegmentUsers - matchingUsers.ToSql",
+		}}
 	}
 
-	// Сформируем запрос на добавление выбранных пользователей в сегмент
-	insertQuery := r.Builder.
-		Insert("users_segments").
-		Columns("user_id", "segment_id")
+	// Пользователи, переставшие подходить сегменту, выходят из него
+	sql, args, err := r.Builder.
+		Update("users_segments").
+		Set("end_date", membershipEndNow()).
+		Set("end_reason", entity.MembershipEndReasonRemoved).
+		Where("segment_id = ?", segment.ID).
+		Where(openMembership("")).
+		Where(squirrel.Expr(fmt.Sprintf("user_id not in (%s)", matchingUsersSql), matchingUsersArgs...)).
+		ToSql()
+	if err != nil {
+		return customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         "Failed to build sql query for removing users which do not match segment's rules or percentage",
+			Location:        "SegmentRepository.SyncSegmentUsers - r.Builder",
+		}}
+	}
+
+	_, err = conn(ctx, r.Pool).Exec(ctx, sql, args...)
+	if err != nil {
+		return customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         "Failed to remove users which do not match segment's rules or percentage",
+			Location:        "SegmentRepository.SyncSegmentUsers - conn.Exec",
+		}}
+	}
+
+	// Подходящие пользователи, ещё не входящие в сегмент, добавляются в него
+	return r.addMatchingUsers(ctx, segment, matchingUsers, "SyncSegmentUsers")
+}
 
-	for _, userID := range userIDs {
-		insertQuery = insertQuery.Values(userID, segment.ID)
+// AddMatchingUsersToSegment добавляет в сегмент пользователей из `userIDs`, подходящих ему по правилам
+// `segment.Rules` и проценту раскатки `segment.Percentage` и ещё не входящих в него. В отличие от
+// SyncSegmentUsers, не затрагивает остальных пользователей и не исключает никого из сегмента.
+func (r *SegmentRepository) AddMatchingUsersToSegment(ctx context.Context, segment entity.Segment, userIDs []int) error {
+	matchingUsers, err := matchingUsersQuery(segment)
+	if err != nil {
+		return customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         fmt.Sprintf("Failed to build condition from rules of segment \"%s\"", segment.Name),
+			Location:        "SegmentRepository.AddMatchingUsersToSegment - matchingUsersQuery",
+		}}
 	}
+	return r.addMatchingUsers(ctx, segment, matchingUsers.Where("user_id = any(?)", userIDs), "AddMatchingUsersToSegment")
+}
 
-	sql, args, err = insertQuery.ToSql()
+// addMatchingUsers добавляет в сегмент пользователей, выбираемых запросом `matchingUsers`, которые ещё
+// не входят в сегмент.
+func (r *SegmentRepository) addMatchingUsers(ctx context.Context, segment entity.Segment, matchingUsers squirrel.SelectBuilder, method string) error {
+	sql, args, err := r.Builder.
+		Insert("users_segments").
+		Columns("user_id", "segment_id").
+		Select(squirrel.
+			Select("u.user_id", fmt.Sprint(segment.ID)).
+			FromSelect(matchingUsers, "u").
+			Where("not exists (select 1 from users_segments us where us.user_id = u.user_id and us.segment_id = ? "+
+				"and "+openMembership("us.")+")", segment.ID),
+		).
+		ToSql()
 	if err != nil {
 		return customError.ErrInternalServerError{ErrBase: customError.ErrBase{
 			OriginError:     err,
 			OriginErrorText: err.Error(),
-			Comment:         "Failed to build sql query for adding users to segment",
-			Location:        "SegmentRepository.AddUsersToSegmentByRandomPercent - insertQuery.ToSql()",
+			Comment:         "Failed to build sql query for adding users which match segment's rules and percentage",
+			Location:        fmt.Sprintf("SegmentRepository.%s - r.Builder", method),
 		}}
 	}
 
-	_, err = r.Pool.Exec(ctx, sql, args...)
+	_, err = conn(ctx, r.Pool).Exec(ctx, sql, args...)
 	if err != nil {
 		return customError.ErrInternalServerError{ErrBase: customError.ErrBase{
 			OriginError:     err,
 			OriginErrorText: err.Error(),
-			Comment:         "Failed to perform sql query for adding users to segment",
-			Location:        "SegmentRepository.AddUsersToSegmentByRandomPercent - r.Pool.Exec",
+			Comment:         "Failed to add users which match segment's rules and percentage",
+			Location:        fmt.Sprintf("SegmentRepository.%s - conn.Exec", method),
 		}}
 	}
 
 	return nil
 }
+
+// GetSegmentUsers возвращает записи о вхождении пользователей в сегмент с указанным `id` вместе с информацией
+// о пользователях, удовлетворяющие фильтру `filter`, в порядке возрастания ID записи.
+func (r *SegmentRepository) GetSegmentUsers(ctx context.Context, id int, filter entity.SegmentUsersFilter) ([]entity.SegmentMember, error) {
+	builder := r.Builder.
+		Select("us.user_segment_id", "u.user_id", "u.external_id", "u.name", "u.lastname", "u.sex", "u.sex_text", "u.age", "u.is_deleted", "u.attributes",
+			"to_char(us.start_date, 'HH24:MI:SS DD.MM.YYYY')",
+			"coalesce(to_char(us.end_date, 'HH24:MI:SS DD.MM.YYYY'), '')").
+		From("users_segments us").
+		Join("users u on u.user_id = us.user_id").
+		Where("us.segment_id = ?", id).
+		Where(membershipAt("us", filter.Status, filter.At)).
+		Where("us.user_segment_id > ?", filter.AfterID).
+		OrderBy("us.user_segment_id").
+		Limit(uint64(filter.Limit))
+	if filter.Sex != nil {
+		builder = builder.Where("u.sex = ?", *filter.Sex)
+	}
+	if filter.MinAge != nil {
+		builder = builder.Where("u.age >= ?", *filter.MinAge)
+	}
+	if filter.MaxAge != nil {
+		builder = builder.Where("u.age <= ?", *filter.MaxAge)
+	}
+
+	sql, args, err := builder.ToSql()
+	if err != nil {
+		return nil, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         fmt.Sprintf("Failed to build sql query for fetching users of segment (id = %d)", id),
+			Location:        "SegmentRepository.GetSegmentUsers - r.Builder",
+		}}
+	}
+
+	rows, err := conn(ctx, r.Pool).Query(ctx, sql, args...)
+	if err != nil {
+		return nil, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         fmt.Sprintf("Failed to query users of segment (id = %d)", id),
+			Location:        "SegmentRepository.GetSegmentUsers - conn.Query",
+		}}
+	}
+	defer rows.Close()
+
+	var members []entity.SegmentMember
+	for rows.Next() {
+		var member entity.SegmentMember
+		err = rows.Scan(
+			&member.InfoID,
+			&member.User.ID,
+			&member.User.ExternalID,
+			&member.User.Name,
+			&member.User.Lastname,
+			&member.User.Sex,
+			&member.User.SexText,
+			&member.User.Age,
+			&member.User.IsDeleted,
+			&member.User.Attributes,
+			&member.StartDate,
+			&member.EndDate,
+		)
+		if err != nil {
+			return nil, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+				OriginError:     err,
+				OriginErrorText: err.Error(),
+				Comment:         "Failed to scan segment's user to structure",
+				Location:        "SegmentRepository.GetSegmentUsers - rows.Scan",
+			}}
+		}
+		members = append(members, member)
+	}
+
+	return members, nil
+}
+
+// openMembership возвращает условие на запись `users_segments` с префиксом столбцов `prefix`, вхождение
+// по которой не завершено: дата выхода не наступила и вхождение не было завершено досрочно. Период вхождения -
+// полуинтервал [start_date, end_date), как и в membershipAt: в момент end_date пользователь уже вышел. Все запросы
+// текущего вхождения используют это условие, чтобы одинаково определять текущих участников сегментов: запись,
+// завершённая ранее в той же транзакции, получает дату выхода не раньше current_timestamp, поэтому одной
+// проверки даты выхода недостаточно.
+func openMembership(prefix string) string {
+	return fmt.Sprintf("(%[1]send_date > current_timestamp or %[1]send_date is null) and %[1]send_reason is null", prefix)
+}
+
+// GetSegmentMemberIDs возвращает идентификаторы пользователей из списка `userIDs`, входящих на текущий момент
+// в сегмент с указанным `id`.
+func (r *SegmentRepository) GetSegmentMemberIDs(ctx context.Context, id int, userIDs []int) ([]int, error) {
+	sql, args, _ := r.Builder.
+		Select("distinct user_id").
+		From("users_segments").
+		Where("segment_id = ? and user_id = any(?)", id, userIDs).
+		Where(openMembership("")).
+		ToSql()
+
+	return r.queryUserIDs(ctx, sql, args, "GetSegmentMemberIDs", fmt.Sprintf("members of segment (id = %d)", id))
+}
+
+// GetAllSegmentMemberIDs возвращает идентификаторы всех пользователей, входящих на текущий момент в сегмент
+// с указанным `id`, в порядке возрастания.
+func (r *SegmentRepository) GetAllSegmentMemberIDs(ctx context.Context, id int) ([]int, error) {
+	sql, args, _ := r.Builder.
+		Select("distinct user_id").
+		From("users_segments").
+		Where("segment_id = ? and start_date <= current_timestamp", id).
+		Where(openMembership("")).
+		OrderBy("user_id").
+		ToSql()
+
+	return r.queryUserIDs(ctx, sql, args, "GetAllSegmentMemberIDs", fmt.Sprintf("members of segment (id = %d)", id))
+}
+
+// GetExclusiveMemberIDs возвращает идентификаторы пользователей из списка `userIDs`, входящих на текущий момент
+// в другие сегменты группы взаимоисключающих сегментов `groupID`, кроме сегмента с указанным `id`.
+func (r *SegmentRepository) GetExclusiveMemberIDs(ctx context.Context, id int, groupID int, userIDs []int) ([]int, error) {
+	sql, args, _ := r.Builder.
+		Select("distinct us.user_id").
+		From("users_segments us").
+		Join("segments s on s.segment_id = us.segment_id").
+		Where("s.group_id = ? and s.segment_id <> ? and us.user_id = any(?)", groupID, id, userIDs).
+		Where(openMembership("us.")).
+		ToSql()
+
+	return r.queryUserIDs(ctx, sql, args, "GetExclusiveMemberIDs", fmt.Sprintf("members of segments exclusive with segment (id = %d)", id))
+}
+
+// GetDerivedSegmentUserIDs возвращает идентификаторы не удалённых пользователей, полученных применением
+// операции `derivation` к множествам пользователей, входящих на текущий момент в исходные сегменты:
+// объединение, пересечение или разность первого сегмента и остальных.
+func (r *SegmentRepository) GetDerivedSegmentUserIDs(ctx context.Context, derivation entity.SegmentDerivation) ([]int, error) {
+	members := r.Builder.
+		Select("us.user_id", "us.segment_id").
+		From("users_segments us").
+		Join("users u on u.user_id = us.user_id").
+		Join("segments s on s.segment_id = us.segment_id").
+		Where("us.segment_id = any(?) and u.is_deleted = false and s.is_deleted = false", derivation.SegmentIDs).
+		Where("us.start_date <= current_timestamp and " + openMembership("us."))
+
+	var query squirrel.SelectBuilder
+	switch derivation.Operation {
+	case entity.SegmentOperationUnion:
+		query = r.Builder.
+			Select("distinct m.user_id").
+			FromSelect(members, "m")
+	case entity.SegmentOperationIntersection:
+		query = r.Builder.
+			Select("m.user_id").
+			FromSelect(members, "m").
+			GroupBy("m.user_id").
+			Having("count(distinct m.segment_id) = ?", len(derivation.SegmentIDs))
+	case entity.SegmentOperationDifference:
+		query = r.Builder.
+			Select("m.user_id").
+			FromSelect(members, "m").
+			GroupBy("m.user_id").
+			Having("bool_and(m.segment_id = ?)", derivation.SegmentIDs[0])
+	default:
+		return nil, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			Comment:  fmt.Sprintf("Unknown segment operation \"%s\"", derivation.Operation),
+			Location: "SegmentRepository.GetDerivedSegmentUserIDs - switch",
+		}}
+	}
+	sql, args, _ := query.OrderBy("m.user_id").ToSql()
+
+	return r.queryUserIDs(ctx, sql, args, "GetDerivedSegmentUserIDs", fmt.Sprintf("users of %s of segments %v", derivation.Operation, derivation.SegmentIDs))
+}
+
+// queryUserIDs выполняет запрос `sql`, возвращающий единственный столбец с идентификаторами пользователей.
+func (r *SegmentRepository) queryUserIDs(ctx context.Context, sql string, args []interface{}, method string, what string) ([]int, error) {
+	rows, err := conn(ctx, r.Pool).Query(ctx, sql, args...)
+	if err != nil {
+		return nil, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         fmt.Sprintf("Failed to query %s", what),
+			Location:        fmt.Sprintf("SegmentRepository.%s - conn.Query", method),
+		}}
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err = rows.Scan(&id); err != nil {
+			return nil, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+				OriginError:     err,
+				OriginErrorText: err.Error(),
+				Comment:         "Failed to scan user's id",
+				Location:        fmt.Sprintf("SegmentRepository.%s - rows.Scan", method),
+			}}
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+// AddUsersToSegment добавляет пользователей с идентификаторами `userIDs` в сегмент с указанным `id`
+// с датой окончания `endDate` (nil - бессрочно) с помощью COPY, не проверяя пользователей
+// и сегмент на существование.
+func (r *SegmentRepository) AddUsersToSegment(ctx context.Context, id int, userIDs []int, endDate *time.Time) (int64, error) {
+	added, err := conn(ctx, r.Pool).CopyFrom(
+		ctx,
+		pgx.Identifier{"users_segments"},
+		[]string{"user_id", "segment_id", "end_date"},
+		pgx.CopyFromSlice(len(userIDs), func(i int) ([]any, error) {
+			if endDate == nil {
+				return []any{userIDs[i], id, nil}, nil
+			}
+			return []any{userIDs[i], id, *endDate}, nil
+		}),
+	)
+	if err != nil {
+		return 0, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         fmt.Sprintf("Failed to copy %d users to segment (id = %d)", len(userIDs), id),
+			Location:        "SegmentRepository.AddUsersToSegment - conn.CopyFrom",
+		}}
+	}
+
+	return added, nil
+}
+
+// DeleteUsersFromSegment завершает текущее вхождение пользователей с идентификаторами `userIDs`
+// в сегмент с указанным `id` и возвращает количество завершённых записей.
+func (r *SegmentRepository) DeleteUsersFromSegment(ctx context.Context, id int, userIDs []int) (int64, error) {
+	sql, args, err := r.Builder.
+		Update("users_segments").
+		Set("end_date", membershipEndNow()).
+		Set("end_reason", entity.MembershipEndReasonRemoved).
+		Where("segment_id = ? and user_id = any(?)", id, userIDs).
+		Where(openMembership("")).
+		ToSql()
+	if err != nil {
+		return 0, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         fmt.Sprintf("Failed to build sql query for deleting users from segment (id = %d)", id),
+			Location:        "SegmentRepository.DeleteUsersFromSegment - r.Builder",
+		}}
+	}
+
+	tag, err := conn(ctx, r.Pool).Exec(ctx, sql, args...)
+	if err != nil {
+		return 0, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         fmt.Sprintf("Failed to delete users from segment (id = %d)", id),
+			Location:        "SegmentRepository.DeleteUsersFromSegment - conn.Exec",
+		}}
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+// ExpireMemberships помечает как истёкшие не более `limit` вхождений пользователей в сегменты, дата выхода
+// из которых уже наступила, и возвращает помеченные записи. Записи, заблокированные другой транзакцией,
+// пропускаются и будут обработаны при следующем вызове.
+func (r *SegmentRepository) ExpireMemberships(ctx context.Context, limit int) ([]entity.UserSegmentInformation, error) {
+	sql, args, err := r.Builder.
+		Update("users_segments us").
+		Set("end_reason", entity.MembershipEndReasonExpired).
+		Where(squirrel.Expr(
+			"us.user_segment_id in ("+
+				"select user_segment_id from users_segments "+
+				"where end_reason is null and end_date is not null and end_date <= current_timestamp "+
+				"order by end_date, user_segment_id limit ? for update skip locked)",
+			limit,
+		)).
+		Suffix("RETURNING us.user_segment_id, us.user_id, us.segment_id, " +
+			"(select s.name from segments s where s.segment_id = us.segment_id), " +
+			"to_char(us.start_date, 'HH24:MI:SS DD.MM.YYYY'), " +
+			"to_char(us.end_date, 'HH24:MI:SS DD.MM.YYYY')").
+		ToSql()
+	if err != nil {
+		return nil, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         "Failed to build sql query for expiring users' memberships in segments",
+			Location:        "SegmentRepository.ExpireMemberships - r.Builder",
+		}}
+	}
+
+	rows, err := conn(ctx, r.Pool).Query(ctx, sql, args...)
+	if err != nil {
+		return nil, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         "Failed to expire users' memberships in segments",
+			Location:        "SegmentRepository.ExpireMemberships - conn.Query",
+		}}
+	}
+	defer rows.Close()
+
+	var expired []entity.UserSegmentInformation
+	for rows.Next() {
+		var membership entity.UserSegmentInformation
+		err = rows.Scan(
+			&membership.InfoID,
+			&membership.UserID,
+			&membership.SegmentID,
+			&membership.Name,
+			&membership.StartDate,
+			&membership.EndDate,
+		)
+		if err != nil {
+			return nil, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+				OriginError:     err,
+				OriginErrorText: err.Error(),
+				Comment:         "Failed to scan expired membership",
+				Location:        "SegmentRepository.ExpireMemberships - rows.Scan",
+			}}
+		}
+		expired = append(expired, membership)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         "Failed to read expired memberships",
+			Location:        "SegmentRepository.ExpireMemberships - rows.Err",
+		}}
+	}
+
+	return expired, nil
+}
+
+// GetExpiredSegmentNames возвращает имена не удалённых сегментов, дата автоматического удаления которых
+// уже наступила, блокируя их записи до конца транзакции. Сегменты, заблокированные другой транзакцией,
+// пропускаются, как и сегменты, из которых построены неудалённые вычисляемые сегменты.
+func (r *SegmentRepository) GetExpiredSegmentNames(ctx context.Context) ([]string, error) {
+	sql, args, err := r.Builder.
+		Select("name").
+		From("segments s").
+		Where("s.is_deleted = false and s.active_until <= current_timestamp").
+		Where("not exists (select 1 from segments d where d.is_deleted = false and s.segment_id = any(d.derived_from))").
+		OrderBy("active_until", "segment_id").
+		Suffix("FOR UPDATE SKIP LOCKED").
+		ToSql()
+	if err != nil {
+		return nil, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         "Failed to build sql query for fetching segments past their active window",
+			Location:        "SegmentRepository.GetExpiredSegmentNames - r.Builder",
+		}}
+	}
+
+	rows, err := conn(ctx, r.Pool).Query(ctx, sql, args...)
+	if err != nil {
+		return nil, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         "Failed to query segments past their active window",
+			Location:        "SegmentRepository.GetExpiredSegmentNames - conn.Query",
+		}}
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err = rows.Scan(&name); err != nil {
+			return nil, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+				OriginError:     err,
+				OriginErrorText: err.Error(),
+				Comment:         "Failed to scan name of segment past its active window",
+				Location:        "SegmentRepository.GetExpiredSegmentNames - rows.Scan",
+			}}
+		}
+		names = append(names, name)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         "Failed to read segments past their active window",
+			Location:        "SegmentRepository.GetExpiredSegmentNames - rows.Err",
+		}}
+	}
+
+	return names, nil
+}
+
+// GetSegmentExperimentName возвращает имя эксперимента, вариантом которого является сегмент с указанным `id`,
+// или пустую строку, если сегмент не принадлежит ни одному эксперименту.
+func (r *SegmentRepository) GetSegmentExperimentName(ctx context.Context, id int) (string, error) {
+	sql, args, err := r.Builder.
+		Select("e.name").
+		From("experiment_variants v").
+		Join("experiments e on e.experiment_id = v.experiment_id").
+		Where("v.segment_id = ?", id).
+		ToSql()
+	if err != nil {
+		return "", customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         fmt.Sprintf("Failed to build sql query for fetching experiment of segment (id = %d)", id),
+			Location:        "SegmentRepository.GetSegmentExperimentName - r.Builder",
+		}}
+	}
+
+	rows, err := conn(ctx, r.Pool).Query(ctx, sql, args...)
+	if err != nil {
+		return "", customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         fmt.Sprintf("Failed to query experiment of segment (id = %d)", id),
+			Location:        "SegmentRepository.GetSegmentExperimentName - conn.Query",
+		}}
+	}
+	defer rows.Close()
+
+	var name string
+	for rows.Next() {
+		if err = rows.Scan(&name); err != nil {
+			return "", customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+				OriginError:     err,
+				OriginErrorText: err.Error(),
+				Comment:         "Failed to scan experiment's name",
+				Location:        "SegmentRepository.GetSegmentExperimentName - rows.Scan",
+			}}
+		}
+	}
+
+	return name, nil
+}
+
+// GetDependentSegmentNames возвращает имена неудалённых вычисляемых сегментов, в операции которых участвует
+// сегмент с указанным `id`.
+func (r *SegmentRepository) GetDependentSegmentNames(ctx context.Context, id int) ([]string, error) {
+	sql, args, err := r.Builder.
+		Select("name").
+		From("segments").
+		Where("is_deleted = false and ? = any(derived_from)", id).
+		OrderBy("segment_id").
+		ToSql()
+	if err != nil {
+		return nil, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         fmt.Sprintf("Failed to build sql query for fetching dependent segments of segment (id = %d)", id),
+			Location:        "SegmentRepository.GetDependentSegmentNames - r.Builder",
+		}}
+	}
+
+	rows, err := conn(ctx, r.Pool).Query(ctx, sql, args...)
+	if err != nil {
+		return nil, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         fmt.Sprintf("Failed to query dependent segments of segment (id = %d)", id),
+			Location:        "SegmentRepository.GetDependentSegmentNames - conn.Query",
+		}}
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err = rows.Scan(&name); err != nil {
+			return nil, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+				OriginError:     err,
+				OriginErrorText: err.Error(),
+				Comment:         "Failed to scan dependent segment's name",
+				Location:        "SegmentRepository.GetDependentSegmentNames - rows.Scan",
+			}}
+		}
+		names = append(names, name)
+	}
+
+	return names, nil
+}
+
+// userHashExpr вычисляет стабильный хеш пользователя по соли: первые 4 байта md5-хеша строки
+// "<соль>:<user_id>", прочитанные как беззнаковое число. Вычисление должно совпадать с service.userHash.
+const userHashExpr = "('x' || substr(md5(?::text || ':' || user_id::text), 1, 8))::bit(32)::bigint"
+
+// rolloutBucketExpr вычисляет номер корзины пользователя от 0 до 99 для раскатки сегмента на процент.
+const rolloutBucketExpr = userHashExpr + " % 100"
+
+// matchingUsersQuery формирует запрос, выбирающий идентификаторы не удалённых пользователей,
+// удовлетворяющих всем правилам сегмента и попадающих в процент его раскатки. Запрос использует
+// плейсхолдеры `?`, так как предназначен для вложения в другие запросы.
+func matchingUsersQuery(segment entity.Segment) (squirrel.SelectBuilder, error) {
+	query := squirrel.
+		Select("user_id").
+		From("users").
+		Where("is_deleted = false")
+
+	if segment.Percentage != nil {
+		query = query.Where(rolloutBucketExpr+" < ?", segment.Salt, *segment.Percentage)
+	}
+
+	for _, rule := range segment.Rules {
+		if strings.HasPrefix(rule.Attribute, entity.SegmentRuleAttributePrefix) {
+			condition, err := attributeRuleCondition(rule)
+			if err != nil {
+				return query, err
+			}
+			query = query.Where(condition)
+			continue
+		}
+
+		// Атрибуты правил совпадают с именами столбцов таблицы `users`
+		if _, ok := entity.SegmentRuleAttributes[rule.Attribute]; !ok {
+			return query, fmt.Errorf("unsupported rule attribute \"%s\"", rule.Attribute)
+		}
+		if _, ok := entity.SegmentRuleOperators[rule.Operator]; !ok {
+			return query, fmt.Errorf("unsupported rule operator \"%s\"", rule.Operator)
+		}
+		value := rule.Value
+		// Числа из JSON декодируются как float64, столбцы же имеют целочисленный тип
+		if number, ok := value.(float64); ok {
+			value = int(number)
+		}
+		query = query.Where(fmt.Sprintf("%s %s ?", rule.Attribute, rule.Operator), value)
+	}
+
+	return query, nil
+}
+
+// attributeRuleCondition формирует условие правила над дополнительным атрибутом пользователя. Пользователь
+// без атрибута не удовлетворяет условию, значения разных типов не равны, а сравнение на больше/меньше
+// выполняется только со значениями того же типа: числа сравниваются численно, строки - побайтово
+// (collate "C"). Условие должно совпадать с проверкой service.matchAttributeRule.
+func attributeRuleCondition(rule entity.SegmentRule) (squirrel.Sqlizer, error) {
+	key := strings.TrimPrefix(rule.Attribute, entity.SegmentRuleAttributePrefix)
+	if _, ok := entity.SegmentRuleOperators[rule.Operator]; !ok {
+		return nil, fmt.Errorf("unsupported rule operator \"%s\"", rule.Operator)
+	}
+	operator := rule.Operator
+	if operator == "!=" {
+		operator = "<>"
+	}
+	isEquality := operator == "=" || operator == "<>"
+
+	switch value := rule.Value.(type) {
+	case string:
+		if isEquality {
+			return squirrel.Expr(fmt.Sprintf("attributes->? %s to_jsonb(?::text)", operator), key, value), nil
+		}
+		return squirrel.Expr(fmt.Sprintf("(jsonb_typeof(attributes->?) = 'string' and (attributes->>?) collate \"C\" %s ?)", operator),
+			key, key, value), nil
+	case float64, int:
+		if isEquality {
+			return squirrel.Expr(fmt.Sprintf("attributes->? %s to_jsonb(?::numeric)", operator), key, value), nil
+		}
+		return squirrel.Expr(fmt.Sprintf("(jsonb_typeof(attributes->?) = 'number' and attributes->? %s to_jsonb(?::numeric))", operator),
+			key, key, value), nil
+	case bool:
+		if isEquality {
+			return squirrel.Expr(fmt.Sprintf("attributes->? %s to_jsonb(?::boolean)", operator), key, value), nil
+		}
+	}
+	return nil, fmt.Errorf("unsupported value of rule attribute \"%s\"", rule.Attribute)
+}
+
+// RenameSegment переименовывает сегмент с указанным `id` из `oldName` в `newName`, сохраняя его `segment_id`,
+// и записывает переименование в историю имён сегмента. Если имя `newName` уже занято другим сегментом,
+// в том числе удалённым, возвращает ErrSegmentAlreadyExists.
+func (r *SegmentRepository) RenameSegment(ctx context.Context, id int, oldName string, newName string) error {
+	sql, args, err := r.Builder.
+		Insert("segment_names_history").
+		Columns("segment_id", "old_name", "new_name").
+		Values(id, oldName, newName).
+		ToSql()
+	if err != nil {
+		return customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         fmt.Sprintf("Failed to build sql query for recording rename of segment (id = %d)", id),
+			Location:        "SegmentRepository.RenameSegment - r.Builder",
+		}}
+	}
+
+	if _, err = conn(ctx, r.Pool).Exec(ctx, sql, args...); err != nil {
+		return customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         fmt.Sprintf("Failed to record rename of segment (id = %d)", id),
+			Location:        "SegmentRepository.RenameSegment - conn.Exec",
+		}}
+	}
+
+	sql, args, err = r.Builder.
+		Update("segments").
+		Set("name", newName).
+		Set("updated_at", squirrel.Expr("current_timestamp")).
+		Where("segment_id = ?", id).
+		ToSql()
+	if err != nil {
+		return customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         fmt.Sprintf("Failed to build sql query for renaming segment (id = %d)", id),
+			Location:        "SegmentRepository.RenameSegment - r.Builder",
+		}}
+	}
+
+	if _, err = conn(ctx, r.Pool).Exec(ctx, sql, args...); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolationCode {
+			return customError.ErrSegmentAlreadyExists{ErrBase: customError.ErrBase{
+				OriginError:     err,
+				OriginErrorText: err.Error(),
+				Comment:         fmt.Sprintf("Segment with the given name \"%s\" already exists", newName),
+				Location:        "SegmentRepository.RenameSegment - conn.Exec",
+			}}
+		}
+		return customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         fmt.Sprintf("Failed to rename segment (id = %d)", id),
+			Location:        "SegmentRepository.RenameSegment - conn.Exec",
+		}}
+	}
+
+	return nil
+}
+
+// GetSegmentByFormerName возвращает сегмент, который последним носил имя `name` и был переименован
+// не ранее, чем `gracePeriod` назад. Если такого сегмента нет, возвращает ErrSegmentNotFound.
+// Текущие имена сегментов GetSegmentByFormerName не проверяет, для этого используется GetSegmentByName.
+func (r *SegmentRepository) GetSegmentByFormerName(ctx context.Context, name string, gracePeriod time.Duration) (entity.Segment, error) {
+	sql, args, err := r.Builder.
+		Select(segmentColumns).
+		From("segments").
+		Where(squirrel.Expr(
+			"segment_id = (select segment_id from segment_names_history "+
+				"where old_name = ? and renamed_at >= current_timestamp - ? * interval '1 second' "+
+				"order by renamed_at desc, history_id desc limit 1)",
+			name, int64(gracePeriod/time.Second),
+		)).
+		ToSql()
+	if err != nil {
+		return entity.Segment{}, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         fmt.Sprintf("Failed to build sql query for fetching segment by former name %s", name),
+			Location:        "SegmentRepository.GetSegmentByFormerName - r.Builder",
+		}}
+	}
+
+	rows, err := conn(ctx, r.Pool).Query(ctx, sql, args...)
+	if err != nil {
+		return entity.Segment{}, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         "Failed to perform sql query",
+			Location:        "SegmentRepository.GetSegmentByFormerName - conn.Query",
+		}}
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		segment, err := scanSegment(rows)
+		if err != nil {
+			return entity.Segment{}, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+				OriginError:     err,
+				OriginErrorText: err.Error(),
+				Comment:         "Failed to scan segment to structure",
+				Location:        "SegmentRepository.GetSegmentByFormerName - rows.Scan",
+			}}
+		}
+		return segment, nil
+	}
+
+	return entity.Segment{}, customError.ErrSegmentNotFound{ErrBase: customError.ErrBase{
+		Comment:  fmt.Sprintf("Segment with former name %s does not exist", name),
+		Location: "SegmentRepository.GetSegmentByFormerName",
+	}}
+}
+
+// GetSegmentNameHistory возвращает историю переименований сегмента с указанным `id` от ранних к поздним.
+func (r *SegmentRepository) GetSegmentNameHistory(ctx context.Context, id int) ([]entity.SegmentNameChange, error) {
+	sql, args, err := r.Builder.
+		Select("old_name, new_name, to_char(renamed_at, 'HH24:MI:SS DD.MM.YYYY')").
+		From("segment_names_history").
+		Where("segment_id = ?", id).
+		OrderBy("history_id").
+		ToSql()
+	if err != nil {
+		return nil, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         fmt.Sprintf("Failed to build sql query for fetching name history of segment (id = %d)", id),
+			Location:        "SegmentRepository.GetSegmentNameHistory - r.Builder",
+		}}
+	}
+
+	rows, err := conn(ctx, r.Pool).Query(ctx, sql, args...)
+	if err != nil {
+		return nil, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         fmt.Sprintf("Failed to query name history of segment (id = %d)", id),
+			Location:        "SegmentRepository.GetSegmentNameHistory - conn.Query",
+		}}
+	}
+	defer rows.Close()
+
+	history := []entity.SegmentNameChange{}
+	for rows.Next() {
+		var change entity.SegmentNameChange
+		if err = rows.Scan(&change.OldName, &change.NewName, &change.RenamedAt); err != nil {
+			return nil, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+				OriginError:     err,
+				OriginErrorText: err.Error(),
+				Comment:         "Failed to scan segment name change to structure",
+				Location:        "SegmentRepository.GetSegmentNameHistory - rows.Scan",
+			}}
+		}
+		history = append(history, change)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         "Failed to read segment name history",
+			Location:        "SegmentRepository.GetSegmentNameHistory - rows.Err",
+		}}
+	}
+
+	return history, nil
+}
+
+// LockSegment блокирует запись сегмента с указанным `id` до конца текущей транзакции, тем самым
+// упорядочивая окончательное удаление сегмента с его восстановлением и изменением.
+func (r *SegmentRepository) LockSegment(ctx context.Context, id int) error {
+	sql, args, _ := r.Builder.
+		Select("segment_id").
+		From("segments").
+		Where("segment_id = ?", id).
+		Suffix("FOR UPDATE").
+		ToSql()
+
+	_, err := conn(ctx, r.Pool).Exec(ctx, sql, args...)
+	if err != nil {
+		return customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         fmt.Sprintf("Failed to lock segment with id = %d", id),
+			Location:        "SegmentRepository.LockSegment - conn.Exec",
+		}}
+	}
+
+	return nil
+}
+
+// GetSegmentArchiveRows возвращает все записи о вхождении пользователей в сегмент с указанным `id`,
+// включая завершённые, в порядке возрастания ID записи.
+func (r *SegmentRepository) GetSegmentArchiveRows(ctx context.Context, id int) ([]entity.SegmentArchiveRow, error) {
+	sql, args, _ := r.Builder.
+		Select("us.user_segment_id", "us.user_id", "s.segment_id", "s.name",
+			"to_char(us.start_date, 'HH24:MI:SS DD.MM.YYYY')",
+			"coalesce(to_char(us.end_date, 'HH24:MI:SS DD.MM.YYYY'), '')",
+			"coalesce(us.end_reason, '')").
+		From("users_segments us").
+		Join("segments s on s.segment_id = us.segment_id").
+		Where("us.segment_id = ?", id).
+		OrderBy("us.user_segment_id").
+		ToSql()
+
+	rows, err := conn(ctx, r.Pool).Query(ctx, sql, args...)
+	if err != nil {
+		return nil, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         fmt.Sprintf("Failed to query membership history of segment (id = %d)", id),
+			Location:        "SegmentRepository.GetSegmentArchiveRows - conn.Query",
+		}}
+	}
+	defer rows.Close()
+
+	var archiveRows []entity.SegmentArchiveRow
+	for rows.Next() {
+		var row entity.SegmentArchiveRow
+		err = rows.Scan(&row.InfoID, &row.UserID, &row.SegmentID, &row.SegmentName, &row.StartDate, &row.EndDate, &row.EndReason)
+		if err != nil {
+			return nil, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+				OriginError:     err,
+				OriginErrorText: err.Error(),
+				Comment:         "Failed to scan segment's membership record",
+				Location:        "SegmentRepository.GetSegmentArchiveRows - rows.Scan",
+			}}
+		}
+		archiveRows = append(archiveRows, row)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         fmt.Sprintf("Failed to read membership history of segment (id = %d)", id),
+			Location:        "SegmentRepository.GetSegmentArchiveRows - rows.Err",
+		}}
+	}
+
+	return archiveRows, nil
+}
+
+// PurgeSegment окончательно удаляет сегмент с указанным `id` вместе с записями о вхождении пользователей
+// в него, историей изменения этих записей и историей имён сегмента. Удаляется только сегмент, помеченный
+// как удалённый.
+func (r *SegmentRepository) PurgeSegment(ctx context.Context, id int) error {
+	memberships := "user_segment_id in (select user_segment_id from users_segments where segment_id = ?)"
+	queries := []squirrel.Sqlizer{
+		r.Builder.Delete("users_segments_history").Where(memberships, id),
+		r.Builder.Delete("users_segments").Where("segment_id = ?", id),
+		r.Builder.Delete("segment_names_history").Where("segment_id = ?", id),
+	}
+	for _, query := range queries {
+		sql, args, _ := query.ToSql()
+		if _, err := conn(ctx, r.Pool).Exec(ctx, sql, args...); err != nil {
+			return customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+				OriginError:     err,
+				OriginErrorText: err.Error(),
+				Comment:         fmt.Sprintf("Failed to purge records of segment (id = %d)", id),
+				Location:        "SegmentRepository.PurgeSegment - conn.Exec",
+			}}
+		}
+	}
+
+	sql, args, _ := r.Builder.
+		Delete("segments").
+		Where("segment_id = ? and is_deleted = true", id).
+		ToSql()
+
+	res, err := conn(ctx, r.Pool).Exec(ctx, sql, args...)
+	if err != nil {
+		return customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         fmt.Sprintf("Failed to purge segment (id = %d)", id),
+			Location:        "SegmentRepository.PurgeSegment - conn.Exec",
+		}}
+	}
+	if res.RowsAffected() == 0 {
+		return customError.ErrSegmentNotFound{ErrBase: customError.ErrBase{
+			Comment:  fmt.Sprintf("Deleted segment with id = %d does not exist", id),
+			Location: "SegmentRepository.PurgeSegment - conn.Exec",
+		}}
+	}
+
+	return nil
+}
+
+// GetPurgeableSegmentNames возвращает имена не более `limit` сегментов, удалённых более `retention` назад,
+// в порядке удаления. Сегменты вариантов экспериментов и исходные сегменты неудалённых вычисляемых сегментов
+// не возвращаются: их нельзя удалить окончательно.
+func (r *SegmentRepository) GetPurgeableSegmentNames(ctx context.Context, retention time.Duration, limit int) ([]string, error) {
+	sql, args, _ := r.Builder.
+		Select("name").
+		From("segments").
+		Where("is_deleted = true and deleted_at <= current_timestamp - ? * interval '1 second'", int64(retention/time.Second)).
+		Where("not exists (select 1 from experiment_variants v where v.segment_id = segments.segment_id)").
+		Where("not exists (select 1 from segments d where d.is_deleted = false and segments.segment_id = any(d.derived_from))").
+		OrderBy("deleted_at", "segment_id").
+		Limit(uint64(limit)).
+		ToSql()
+
+	rows, err := conn(ctx, r.Pool).Query(ctx, sql, args...)
+	if err != nil {
+		return nil, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         "Failed to query segments past their retention period",
+			Location:        "SegmentRepository.GetPurgeableSegmentNames - conn.Query",
+		}}
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err = rows.Scan(&name); err != nil {
+			return nil, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+				OriginError:     err,
+				OriginErrorText: err.Error(),
+				Comment:         "Failed to scan name of segment past its retention period",
+				Location:        "SegmentRepository.GetPurgeableSegmentNames - rows.Scan",
+			}}
+		}
+		names = append(names, name)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         "Failed to read segments past their retention period",
+			Location:        "SegmentRepository.GetPurgeableSegmentNames - rows.Err",
+		}}
+	}
+
+	return names, nil
+}
+
+// scanSegment сканирует строку, выбранную из столбцов segmentColumns, в сегмент.
+func scanSegment(row pgx.Row) (entity.Segment, error) {
+	var segment entity.Segment
+	var defaultTTL *int64
+	var derivedOperation *string
+	var derivedFrom []int
+	err := row.Scan(
+		&segment.ID,
+		&segment.Name,
+		&segment.IsDeleted,
+		&segment.Rules,
+		&segment.Percentage,
+		&segment.Salt,
+		&segment.GroupID,
+		&defaultTTL,
+		&segment.ActiveUntil,
+		&segment.Description,
+		&segment.Owner,
+		&segment.Tags,
+		&segment.CreatedAt,
+		&segment.UpdatedAt,
+		&segment.DeletedAt,
+		&derivedOperation,
+		&derivedFrom,
+	)
+	if err != nil {
+		return entity.Segment{}, err
+	}
+	segment.DefaultTTL = formatSegmentTTL(defaultTTL)
+	if derivedOperation != nil {
+		segment.Derivation = &entity.SegmentDerivation{Operation: *derivedOperation, SegmentIDs: derivedFrom}
+	}
+	return segment, nil
+}
+
+// segmentRulesValue возвращает значение для записи правил в столбец `rules`:
+// пустой список правил хранится как NULL.
+func segmentRulesValue(rules []entity.SegmentRule) interface{} {
+	if len(rules) == 0 {
+		return nil
+	}
+	return rules
+}
+
+// segmentTTLUnits - единицы, в которых форматируется срок вхождения в сегмент, от большей к меньшей.
+var segmentTTLUnits = []struct {
+	suffix  string
+	seconds int64
+}{
+	{"d", 24 * 60 * 60},
+	{"h", 60 * 60},
+	{"m", 60},
+	{"s", 1},
+}
+
+// formatSegmentTTL форматирует срок вхождения в сегмент, хранящийся в столбце `default_ttl` в секундах,
+// в виде "1d12h30m", опуская нулевые единицы. Отсутствующий срок форматируется пустой строкой.
+func formatSegmentTTL(seconds *int64) string {
+	if seconds == nil {
+		return ""
+	}
+	var b strings.Builder
+	rest := *seconds
+	for _, unit := range segmentTTLUnits {
+		if rest >= unit.seconds {
+			fmt.Fprintf(&b, "%d%s", rest/unit.seconds, unit.suffix)
+			rest %= unit.seconds
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,261 @@
+package pgdb
+
+import (
+	"avito-rest-api/internal/entity"
+	customError "avito-rest-api/internal/error"
+	"avito-rest-api/package/postgres"
+	"context"
+	"fmt"
+	"github.com/Masterminds/squirrel"
+)
+
+type SegmentGroupRepository struct {
+	*postgres.PostgreDB
+}
+
+// NewSegmentGroupRepository инициализирует репозиторий `segment group`, инкапсулирующий
+// логику хранения групп взаимоисключающих сегментов.
+func NewSegmentGroupRepository(pg *postgres.PostgreDB) *SegmentGroupRepository {
+	return &SegmentGroupRepository{pg}
+}
+
+// CreateSegmentGroup добавляет в базу данных пустую группу сегментов с указанным именем
+// и возвращает `id` добавленной группы.
+func (r *SegmentGroupRepository) CreateSegmentGroup(ctx context.Context, name string) (int, error) {
+	sql, args, err := r.Builder.
+		Insert("segment_groups").
+		Columns("name").
+		Values(name).
+		Suffix("RETURNING group_id").
+		ToSql()
+	if err != nil {
+		return 0, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         fmt.Sprintf("Failed to build sql query for creating segment group \"%s\"", name),
+			Location:        "SegmentGroupRepository.CreateSegmentGroup - r.Builder",
+		}}
+	}
+
+	var id int
+	if err = conn(ctx, r.Pool).QueryRow(ctx, sql, args...).Scan(&id); err != nil {
+		return 0, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         fmt.Sprintf("Failed to create segment group \"%s\"", name),
+			Location:        "SegmentGroupRepository.CreateSegmentGroup - conn.QueryRow",
+		}}
+	}
+
+	return id, nil
+}
+
+// GetSegmentGroupByName возвращает группу сегментов с указанным именем вместе с наименованиями
+// входящих в неё сегментов.
+func (r *SegmentGroupRepository) GetSegmentGroupByName(ctx context.Context, name string) (entity.SegmentGroup, error) {
+	sql, args, err := r.Builder.
+		Select("g.group_id", "g.name", "s.name").
+		From("segment_groups g").
+		LeftJoin("segments s on s.group_id = g.group_id").
+		Where("g.name = ?", name).
+		OrderBy("s.segment_id").
+		ToSql()
+	if err != nil {
+		return entity.SegmentGroup{}, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         fmt.Sprintf("Failed to build sql query for fetching segment group \"%s\"", name),
+			Location:        "SegmentGroupRepository.GetSegmentGroupByName - r.Builder",
+		}}
+	}
+
+	rows, err := conn(ctx, r.Pool).Query(ctx, sql, args...)
+	if err != nil {
+		return entity.SegmentGroup{}, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         fmt.Sprintf("Failed to query segment group \"%s\"", name),
+			Location:        "SegmentGroupRepository.GetSegmentGroupByName - conn.Query",
+		}}
+	}
+	defer rows.Close()
+
+	var group entity.SegmentGroup
+	var found bool
+	for rows.Next() {
+		var segmentName *string
+		if err = rows.Scan(&group.ID, &group.Name, &segmentName); err != nil {
+			return entity.SegmentGroup{}, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+				OriginError:     err,
+				OriginErrorText: err.Error(),
+				Comment:         "Failed to scan segment group to structure",
+				Location:        "SegmentGroupRepository.GetSegmentGroupByName - rows.Scan",
+			}}
+		}
+		found = true
+		// У пустой группы единственная строка выборки не содержит сегмента
+		if segmentName != nil {
+			group.Segments = append(group.Segments, *segmentName)
+		}
+	}
+
+	if !found {
+		return entity.SegmentGroup{}, customError.ErrSegmentGroupNotFound{ErrBase: customError.ErrBase{
+			Comment:  fmt.Sprintf("Segment group with name \"%s\" does not exist", name),
+			Location: "SegmentGroupRepository.GetSegmentGroupByName",
+		}}
+	}
+	if group.Segments == nil {
+		group.Segments = []string{}
+	}
+
+	return group, nil
+}
+
+// SetGroupSegments делает сегменты с идентификаторами `segmentIDs` единственными сегментами группы
+// с указанным `id`: сегменты, ранее входившие в группу и отсутствующие в списке, исключаются из неё.
+func (r *SegmentGroupRepository) SetGroupSegments(ctx context.Context, id int, segmentIDs []int) error {
+	sql, args, err := r.Builder.
+		Update("segments").
+		Set("group_id", nil).
+		Where("group_id = ?", id).
+		ToSql()
+	if err != nil {
+		return customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         fmt.Sprintf("Failed to build sql query for clearing segments of group (id = %d)", id),
+			Location:        "SegmentGroupRepository.SetGroupSegments - r.Builder",
+		}}
+	}
+
+	if _, err = conn(ctx, r.Pool).Exec(ctx, sql, args...); err != nil {
+		return customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         fmt.Sprintf("Failed to clear segments of group (id = %d)", id),
+			Location:        "SegmentGroupRepository.SetGroupSegments - conn.Exec",
+		}}
+	}
+
+	if len(segmentIDs) == 0 {
+		return nil
+	}
+
+	sql, args, err = r.Builder.
+		Update("segments").
+		Set("group_id", id).
+		Where(squirrel.Eq{"segment_id": segmentIDs}).
+		ToSql()
+	if err != nil {
+		return customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         fmt.Sprintf("Failed to build sql query for adding segments to group (id = %d)", id),
+			Location:        "SegmentGroupRepository.SetGroupSegments - r.Builder",
+		}}
+	}
+
+	if _, err = conn(ctx, r.Pool).Exec(ctx, sql, args...); err != nil {
+		return customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         fmt.Sprintf("Failed to add segments to group (id = %d)", id),
+			Location:        "SegmentGroupRepository.SetGroupSegments - conn.Exec",
+		}}
+	}
+
+	return nil
+}
+
+// CountUsersInSeveralSegments возвращает количество пользователей, входящих на текущий момент
+// более чем в один из сегментов с идентификаторами `segmentIDs`.
+func (r *SegmentGroupRepository) CountUsersInSeveralSegments(ctx context.Context, segmentIDs []int) (int, error) {
+	sql, args, err := r.Builder.
+		Select("count(*)").
+		FromSelect(squirrel.
+			Select("user_id").
+			From("users_segments").
+			Where(squirrel.Eq{"segment_id": segmentIDs}).
+			Where(openMembership("")).
+			GroupBy("user_id").
+			Having("count(distinct segment_id) > 1"), "t").
+		ToSql()
+	if err != nil {
+		return 0, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         "Failed to build sql query for counting users in several segments",
+			Location:        "SegmentGroupRepository.CountUsersInSeveralSegments - r.Builder",
+		}}
+	}
+
+	var count int
+	if err = conn(ctx, r.Pool).QueryRow(ctx, sql, args...).Scan(&count); err != nil {
+		return 0, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         "Failed to count users in several segments",
+			Location:        "SegmentGroupRepository.CountUsersInSeveralSegments - conn.QueryRow",
+		}}
+	}
+
+	return count, nil
+}
+
+// GetUserSegmentsInGroup возвращает актуальные на текущий момент и запланированные записи о вхождении
+// пользователя с указанным `userID` в сегменты группы с указанным `id`.
+func (r *SegmentGroupRepository) GetUserSegmentsInGroup(ctx context.Context, id int, userID int) ([]entity.UserSegmentInformation, error) {
+	sql, args, err := r.Builder.
+		Select("us.user_segment_id", "us.user_id", "s.segment_id", "s.name",
+			"to_char(us.start_date, 'HH24:MI:SS DD.MM.YYYY')",
+			"coalesce(to_char(us.end_date, 'HH24:MI:SS DD.MM.YYYY'), '')").
+		From("users_segments us").
+		Join("segments s on s.segment_id = us.segment_id").
+		Where("s.group_id = ? and us.user_id = ?", id, userID).
+		Where(openMembership("us.")).
+		ToSql()
+	if err != nil {
+		return nil, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         fmt.Sprintf("Failed to build sql query for fetching segments of user (id = %d) in group (id = %d)", userID, id),
+			Location:        "SegmentGroupRepository.GetUserSegmentsInGroup - r.Builder",
+		}}
+	}
+
+	rows, err := conn(ctx, r.Pool).Query(ctx, sql, args...)
+	if err != nil {
+		return nil, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         fmt.Sprintf("Failed to query segments of user (id = %d) in group (id = %d)", userID, id),
+			Location:        "SegmentGroupRepository.GetUserSegmentsInGroup - conn.Query",
+		}}
+	}
+	defer rows.Close()
+
+	var userSegments []entity.UserSegmentInformation
+	for rows.Next() {
+		var segmentInfo entity.UserSegmentInformation
+		err = rows.Scan(
+			&segmentInfo.InfoID,
+			&segmentInfo.UserID,
+			&segmentInfo.SegmentID,
+			&segmentInfo.Name,
+			&segmentInfo.StartDate,
+			&segmentInfo.EndDate,
+		)
+		if err != nil {
+			return nil, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+				OriginError:     err,
+				OriginErrorText: err.Error(),
+				Comment:         "Failed to scan user's segment to structure",
+				Location:        "SegmentGroupRepository.GetUserSegmentsInGroup - rows.Scan",
+			}}
+		}
+		userSegments = append(userSegments, segmentInfo)
+	}
+
+	return userSegments, nil
+}
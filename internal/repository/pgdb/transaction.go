@@ -0,0 +1,78 @@
+package pgdb
+
+import (
+	customError "avito-rest-api/internal/error"
+	"avito-rest-api/package/postgres"
+	"context"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// txKey - ключ, по которому открытая транзакция хранится в контексте.
+type txKey struct{}
+
+// executor - общий для пула соединений и транзакции набор методов,
+// используемых репозиториями для выполнения запросов.
+type executor interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults
+	CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error)
+}
+
+// conn возвращает транзакцию, открытую в контексте `ctx` с помощью TxManager,
+// или пул соединений `pool`, если запрос выполняется вне транзакции.
+func conn(ctx context.Context, pool postgres.PgxPool) executor {
+	if tx, ok := ctx.Value(txKey{}).(pgx.Tx); ok {
+		return tx
+	}
+	return pool
+}
+
+type TxManager struct {
+	*postgres.PostgreDB
+}
+
+// NewTxManager инициализирует менеджер транзакций, позволяющий сервисам выполнять
+// несколько операций над репозиториями атомарно.
+func NewTxManager(pg *postgres.PostgreDB) *TxManager {
+	return &TxManager{pg}
+}
+
+// WithinTransaction выполняет `fn` в рамках транзакции: все запросы репозиториев, выполняемые
+// с контекстом, переданным в `fn`, используют эту транзакцию. Если `fn` возвращает ошибку,
+// транзакция откатывается, в противном случае - фиксируется. Если в `ctx` уже открыта транзакция,
+// `fn` выполняется в её рамках, а фиксацией управляет внешний вызов WithinTransaction.
+func (m *TxManager) WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(pgx.Tx); ok {
+		return fn(ctx)
+	}
+
+	tx, err := m.Pool.Begin(ctx)
+	if err != nil {
+		return customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         "Failed to begin transaction, inspect origin error text",
+			Location:        "TxManager.WithinTransaction - m.Pool.Begin",
+		}}
+	}
+	// После фиксации транзакции откат ничего не делает
+	defer tx.Rollback(ctx)
+
+	if err = fn(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         "Failed to commit transaction, inspect origin error text",
+			Location:        "TxManager.WithinTransaction - tx.Commit",
+		}}
+	}
+
+	return nil
+}
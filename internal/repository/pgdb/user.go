@@ -6,11 +6,19 @@ import (
 	"avito-rest-api/package/postgres"
 	"context"
 	sqlLibrary "database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"strings"
 	"time"
 )
 
+// uniqueViolationCode - код ошибки PostgreSQL о нарушении ограничения уникальности.
+const uniqueViolationCode = "23505"
+
 type UserRepository struct {
 	*postgres.PostgreDB
 }
@@ -26,14 +34,23 @@ func NewUserRepository(pg *postgres.PostgreDB) *UserRepository {
 func (r *UserRepository) CreateUser(ctx context.Context, user entity.User) (int, error) {
 	sql, args, _ := r.Builder.
 		Insert("users").
-		Columns("name", "lastname", "sex", "age").
-		Values(user.Name, user.Lastname, user.Sex, user.Age).
+		Columns("name", "lastname", "sex", "age", "attributes", "external_id").
+		Values(user.Name, user.Lastname, user.Sex, user.Age, user.Attributes, user.ExternalID).
 		Suffix("RETURNING user_id").
 		ToSql()
 
 	var id int
 
-	if err := r.Pool.QueryRow(ctx, sql, args...).Scan(&id); err != nil {
+	if err := conn(ctx, r.Pool).QueryRow(ctx, sql, args...).Scan(&id); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolationCode && user.ExternalID != nil {
+			return 0, customError.ErrUserAlreadyExists{ErrBase: customError.ErrBase{
+				OriginError:     err,
+				OriginErrorText: err.Error(),
+				Comment:         fmt.Sprintf("User with external id \"%s\" already exists", *user.ExternalID),
+				Location:        "UserRepository.CreateUser - r.Pool.QueryRow",
+			}}
+		}
 		return 0, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
 			OriginError:     err,
 			OriginErrorText: err.Error(),
@@ -45,42 +62,163 @@ func (r *UserRepository) CreateUser(ctx context.Context, user entity.User) (int,
 	return id, nil
 }
 
-// GetAllUsers возвращает всех пользователей из базы данных.
-func (r *UserRepository) GetAllUsers(ctx context.Context) ([]entity.User, error) {
-	sql, args, err := r.Builder.
-		Select("*").
-		From("users").
+// ImportUsers добавляет пользователей `users` в базу данных с помощью COPY и возвращает идентификаторы
+// добавленных пользователей в порядке `users`. Атрибуты пользователей должны быть предварительно
+// провалидированы на уровне сервиса, а их внешние идентификаторы - не заняты другими пользователями.
+func (r *UserRepository) ImportUsers(ctx context.Context, users []entity.User) ([]int, error) {
+	// COPY не возвращает сгенерированные значения, поэтому идентификаторы резервируются заранее
+	sql, args, _ := r.Builder.
+		Select("nextval(pg_get_serial_sequence('users', 'user_id'))").
+		From(fmt.Sprintf("generate_series(1, %d)", len(users))).
 		ToSql()
+
+	rows, err := conn(ctx, r.Pool).Query(ctx, sql, args...)
 	if err != nil {
 		return nil, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
 			OriginError:     err,
 			OriginErrorText: err.Error(),
-			Comment:         "Failed to build query to fetch all users, inspect origin error text",
+			Comment:         fmt.Sprintf("Failed to reserve ids for %d imported users", len(users)),
+			Location:        "UserRepository.ImportUsers - conn.Query",
+		}}
+	}
+	ids := make([]int, 0, len(users))
+	for rows.Next() {
+		var id int
+		if err = rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+				OriginError:     err,
+				OriginErrorText: err.Error(),
+				Comment:         "Failed to scan reserved user's id",
+				Location:        "UserRepository.ImportUsers - rows.Scan",
+			}}
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         fmt.Sprintf("Failed to reserve ids for %d imported users", len(users)),
+			Location:        "UserRepository.ImportUsers - rows.Err",
+		}}
+	}
+
+	_, err = conn(ctx, r.Pool).CopyFrom(
+		ctx,
+		pgx.Identifier{"users"},
+		[]string{"user_id", "external_id", "name", "lastname", "sex", "age", "attributes"},
+		pgx.CopyFromSlice(len(users), func(i int) ([]any, error) {
+			return []any{ids[i], users[i].ExternalID, users[i].Name, users[i].Lastname, users[i].Sex, users[i].Age, users[i].Attributes}, nil
+		}),
+	)
+	if err != nil {
+		return nil, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         fmt.Sprintf("Failed to import %d users", len(users)),
+			Location:        "UserRepository.ImportUsers - conn.CopyFrom",
+		}}
+	}
+
+	return ids, nil
+}
+
+// userSortColumns перечисляет поля, по которым допускается сортировка списка пользователей.
+var userSortColumns = map[string]bool{
+	"user_id":  true,
+	"name":     true,
+	"lastname": true,
+	"age":      true,
+}
+
+// GetAllUsers возвращает не более `filter.Limit` пользователей, удовлетворяющих фильтру `filter`, упорядоченных
+// по полю `filter.SortBy` и затем по ID. Если задано `filter.AfterValue`, выборка начинается с пользователя,
+// следующего в этом порядке за пользователем с ID `filter.AfterID`.
+func (r *UserRepository) GetAllUsers(ctx context.Context, filter entity.UsersFilter) ([]entity.User, error) {
+	sortBy := filter.SortBy
+	if !userSortColumns[sortBy] {
+		sortBy = "user_id"
+	}
+	direction, comparison := "asc", ">"
+	if filter.Desc {
+		direction, comparison = "desc", "<"
+	}
+
+	builder := r.Builder.
+		Select("user_id", "external_id", "name", "lastname", "sex", "sex_text", "age", "is_deleted", "attributes").
+		From("users").
+		OrderBy(fmt.Sprintf("%s %s", sortBy, direction), fmt.Sprintf("user_id %s", direction)).
+		Limit(uint64(filter.Limit))
+	if filter.AfterValue != nil {
+		builder = builder.Where(fmt.Sprintf("(%s, user_id) %s (?, ?)", sortBy, comparison), filter.AfterValue, filter.AfterID)
+	}
+	if filter.Name != nil {
+		builder = builder.Where("name = ?", *filter.Name)
+	}
+	if filter.Lastname != nil {
+		builder = builder.Where("lastname = ?", *filter.Lastname)
+	}
+	if filter.Sex != nil {
+		builder = builder.Where("sex = ?", *filter.Sex)
+	}
+	if filter.MinAge != nil {
+		builder = builder.Where("age >= ?", *filter.MinAge)
+	}
+	if filter.MaxAge != nil {
+		builder = builder.Where("age <= ?", *filter.MaxAge)
+	}
+	if filter.IsDeleted != nil {
+		builder = builder.Where("is_deleted = ?", *filter.IsDeleted)
+	}
+	if len(filter.Attributes) > 0 {
+		attributes, err := json.Marshal(filter.Attributes)
+		if err != nil {
+			return nil, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+				OriginError:     err,
+				OriginErrorText: err.Error(),
+				Comment:         "Failed to marshal attributes filter",
+				Location:        "UserRepository.GetAllUsers - json.Marshal",
+			}}
+		}
+		builder = builder.Where("attributes @> ?::text::jsonb", string(attributes))
+	}
+
+	sql, args, err := builder.ToSql()
+	if err != nil {
+		return nil, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         "Failed to build query to fetch users, inspect origin error text",
 			Location:        "UserRepository.GetAllUsers - r.Builder",
 		}}
 	}
 
-	rows, err := r.Pool.Query(ctx, sql, args...)
+	rows, err := conn(ctx, r.Pool).Query(ctx, sql, args...)
 	if err != nil {
 		return nil, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
 			OriginError:     err,
 			OriginErrorText: err.Error(),
-			Comment:         "Failed to fetch all users from database, inspect origin error text",
+			Comment:         "Failed to fetch users from database, inspect origin error text",
 			Location:        "UserRepository.GetAllUsers - r.Pool.Query",
 		}}
 	}
+	defer rows.Close()
 
 	var users []entity.User
 	for rows.Next() {
 		var user entity.User
 		err := rows.Scan(
 			&user.ID,
+			&user.ExternalID,
 			&user.Name,
 			&user.Lastname,
 			&user.Sex,
 			&user.SexText,
 			&user.Age,
 			&user.IsDeleted,
+			&user.Attributes,
 		)
 		if err != nil {
 			return nil, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
@@ -96,15 +234,132 @@ func (r *UserRepository) GetAllUsers(ctx context.Context) ([]entity.User, error)
 	return users, nil
 }
 
+// GetUsersWithSegments возвращает не более `limit` пользователей с ID больше `afterID` в порядке возрастания ID
+// вместе с их активными на текущий момент сегментами, включая вычисляемые. Пользователи и их хранимые сегменты
+// выбираются одним запросом, вычисляемые сегменты всех пользователей страницы - вторым.
+func (r *UserRepository) GetUsersWithSegments(ctx context.Context, afterID int, limit int) ([]entity.UserWithSegments, error) {
+	sql, args, err := r.Builder.
+		Select("u.user_id", "u.external_id", "u.name", "u.lastname", "u.sex", "u.sex_text", "u.age", "u.is_deleted", "u.attributes",
+			"us.user_segment_id", "s.segment_id", "s.name",
+			"to_char(us.start_date, 'HH24:MI:SS DD.MM.YYYY')",
+			"coalesce(to_char(us.end_date, 'HH24:MI:SS DD.MM.YYYY'), '')").
+		FromSelect(squirrel.
+			Select("*").
+			From("users").
+			Where("user_id > ?", afterID).
+			OrderBy("user_id").
+			Limit(uint64(limit)), "u").
+		LeftJoin("users_segments us on us.user_id = u.user_id and us.start_date <= current_timestamp and "+
+			openMembership("us.")).
+		LeftJoin("segments s on s.segment_id = us.segment_id").
+		OrderBy("u.user_id", "us.user_segment_id").
+		ToSql()
+	if err != nil {
+		return nil, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         "Failed to build sql query for fetching users with segments",
+			Location:        "UserRepository.GetUsersWithSegments - r.Builder",
+		}}
+	}
+
+	rows, err := conn(ctx, r.Pool).Query(ctx, sql, args...)
+	if err != nil {
+		return nil, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         "Failed to query users with segments",
+			Location:        "UserRepository.GetUsersWithSegments - conn.Query",
+		}}
+	}
+	defer rows.Close()
+
+	var usersWithSegments []entity.UserWithSegments
+	for rows.Next() {
+		var user entity.User
+		var infoID, segmentID *int
+		var segmentName, startDate, endDate *string
+		err = rows.Scan(
+			&user.ID,
+			&user.ExternalID,
+			&user.Name,
+			&user.Lastname,
+			&user.Sex,
+			&user.SexText,
+			&user.Age,
+			&user.IsDeleted,
+			&user.Attributes,
+			&infoID,
+			&segmentID,
+			&segmentName,
+			&startDate,
+			&endDate,
+		)
+		if err != nil {
+			return nil, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+				OriginError:     err,
+				OriginErrorText: err.Error(),
+				Comment:         "Failed to scan user with segment to structure",
+				Location:        "UserRepository.GetUsersWithSegments - rows.Scan",
+			}}
+		}
+
+		// Строки одного пользователя идут подряд, так как выборка упорядочена по ID пользователя
+		if n := len(usersWithSegments); n == 0 || usersWithSegments[n-1].User.ID != user.ID {
+			usersWithSegments = append(usersWithSegments, entity.UserWithSegments{
+				User:     user,
+				Segments: []entity.UserSegmentInformation{},
+			})
+		}
+		// У пользователя без активных сегментов единственная строка выборки не содержит сегмента
+		if infoID == nil {
+			continue
+		}
+		last := &usersWithSegments[len(usersWithSegments)-1]
+		last.Segments = append(last.Segments, entity.UserSegmentInformation{
+			InfoID:    *infoID,
+			UserID:    user.ID,
+			SegmentID: *segmentID,
+			Name:      *segmentName,
+			StartDate: *startDate,
+			EndDate:   *endDate,
+		})
+	}
+	rows.Close()
+	if len(usersWithSegments) == 0 {
+		return usersWithSegments, nil
+	}
+
+	// Вычисляемые сегменты не хранят вхождений, поэтому добавим их отдельным запросом по пользователям страницы
+	userIDs := make([]int, 0, len(usersWithSegments))
+	for _, userWithSegments := range usersWithSegments {
+		userIDs = append(userIDs, userWithSegments.User.ID)
+	}
+	derivedSegments, err := r.getUsersDerivedSegments(ctx, userIDs, "UserRepository.GetUsersWithSegments")
+	if err != nil {
+		return nil, err
+	}
+	// Пользователи страницы и их вычисляемые сегменты упорядочены по ID пользователя
+	i := 0
+	for _, segmentInfo := range derivedSegments {
+		for usersWithSegments[i].User.ID != segmentInfo.UserID {
+			i++
+		}
+		usersWithSegments[i].Segments = append(usersWithSegments[i].Segments, segmentInfo)
+	}
+
+	return usersWithSegments, nil
+}
+
 // GetUserByID возвращает информацию о пользователе с указанным `id`.
 func (r *UserRepository) GetUserByID(ctx context.Context, id int) (entity.User, error) {
 	sql, args, _ := r.Builder.
-		Select("*").
+		Select("user_id", "external_id", "name", "lastname", "sex", "sex_text", "age", "is_deleted", "attributes").
 		From("users").
 		Where("user_id = ?", id).
 		ToSql()
 
-	rows, err := r.Pool.Query(ctx, sql, args...)
+	rows, err := conn(ctx, r.Pool).Query(ctx, sql, args...)
 	if err != nil {
 		return entity.User{}, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
 			OriginError:     err,
@@ -113,17 +368,20 @@ func (r *UserRepository) GetUserByID(ctx context.Context, id int) (entity.User,
 			Location:        "UserRepository.GetUserByID - r.Pool.Exec",
 		}}
 	}
+	defer rows.Close()
 
 	for rows.Next() {
 		var user entity.User
 		err = rows.Scan(
 			&user.ID,
+			&user.ExternalID,
 			&user.Name,
 			&user.Lastname,
 			&user.Sex,
 			&user.SexText,
 			&user.Age,
 			&user.IsDeleted,
+			&user.Attributes,
 		)
 		if err != nil {
 			return entity.User{}, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
@@ -144,10 +402,110 @@ func (r *UserRepository) GetUserByID(ctx context.Context, id int) (entity.User,
 	}}
 }
 
+// GetUserByExternalID возвращает информацию о пользователе с внешним идентификатором `externalID`.
+func (r *UserRepository) GetUserByExternalID(ctx context.Context, externalID string) (entity.User, error) {
+	sql, args, _ := r.Builder.
+		Select("user_id").
+		From("users").
+		Where("external_id = ?", externalID).
+		ToSql()
+
+	var id int
+	if err := conn(ctx, r.Pool).QueryRow(ctx, sql, args...).Scan(&id); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return entity.User{}, customError.ErrUserNotFound{ErrBase: customError.ErrBase{
+				Comment:  fmt.Sprintf("User with external id \"%s\" not found", externalID),
+				Location: "UserRepository.GetUserByExternalID",
+			}}
+		}
+		return entity.User{}, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         fmt.Sprintf("Failed to perform sql query to get user by external id \"%s\"", externalID),
+			Location:        "UserRepository.GetUserByExternalID - conn.QueryRow",
+		}}
+	}
+
+	return r.GetUserByID(ctx, id)
+}
+
+// GetTakenExternalIDs возвращает внешние идентификаторы из `externalIDs`, уже занятые пользователями,
+// в том числе удалёнными.
+func (r *UserRepository) GetTakenExternalIDs(ctx context.Context, externalIDs []string) ([]string, error) {
+	sql, args, _ := r.Builder.
+		Select("external_id").
+		From("users").
+		Where("external_id = any(?)", externalIDs).
+		ToSql()
+
+	rows, err := conn(ctx, r.Pool).Query(ctx, sql, args...)
+	if err != nil {
+		return nil, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         fmt.Sprintf("Failed to check %d external ids", len(externalIDs)),
+			Location:        "UserRepository.GetTakenExternalIDs - conn.Query",
+		}}
+	}
+	defer rows.Close()
+
+	var taken []string
+	for rows.Next() {
+		var externalID string
+		if err = rows.Scan(&externalID); err != nil {
+			return nil, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+				OriginError:     err,
+				OriginErrorText: err.Error(),
+				Comment:         "Failed to scan taken external id",
+				Location:        "UserRepository.GetTakenExternalIDs - rows.Scan",
+			}}
+		}
+		taken = append(taken, externalID)
+	}
+
+	return taken, nil
+}
+
+// UpsertUserByExternalID создаёт пользователя `user` с внешним идентификатором `*user.ExternalID` или, если такой
+// пользователь уже существует и не удалён, заменяет его атрибуты. Возвращает ID пользователя и true, если
+// пользователь был создан. Для удалённого пользователя возвращает ошибку ErrUserDeleted.
+func (r *UserRepository) UpsertUserByExternalID(ctx context.Context, user entity.User) (int, bool, error) {
+	sql, args, _ := r.Builder.
+		Insert("users").
+		Columns("external_id", "name", "lastname", "sex", "age", "attributes").
+		Values(user.ExternalID, user.Name, user.Lastname, user.Sex, user.Age, user.Attributes).
+		Suffix("ON CONFLICT (external_id) DO UPDATE SET " +
+			"name = excluded.name, lastname = excluded.lastname, sex = excluded.sex, " +
+			"age = excluded.age, attributes = excluded.attributes " +
+			"WHERE users.is_deleted = false " +
+			"RETURNING user_id, (xmax = 0)").
+		ToSql()
+
+	var id int
+	var created bool
+	if err := conn(ctx, r.Pool).QueryRow(ctx, sql, args...).Scan(&id, &created); err != nil {
+		// Строка не возвращается, только если конфликтующий пользователь удалён
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, false, customError.ErrUserDeleted{ErrBase: customError.ErrBase{
+				Comment:  fmt.Sprintf("User with external id \"%s\" is deleted", *user.ExternalID),
+				Location: "UserRepository.UpsertUserByExternalID",
+			}}
+		}
+		return 0, false, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         fmt.Sprintf("Failed to upsert user with external id \"%s\"", *user.ExternalID),
+			Location:        "UserRepository.UpsertUserByExternalID - conn.QueryRow",
+		}}
+	}
+
+	return id, created, nil
+}
+
 // GetUserSegmentsByUserID возвращает список сегментов, в которые входит пользователь с указанным `id`,
 // в формате структуры, содержащей: наименование сегмента, дату добавления пользователя в сегмент,
 // дату выхода пользователя из сегмента (если установлена). Возвращает только актуальные на текущий
-// момент сегменты.
+// момент сегменты: запланированные вхождения, которые ещё не начались, не возвращаются.
 func (r *UserRepository) GetUserSegmentsByUserID(ctx context.Context, id int) ([]entity.UserSegmentInformation, error) {
 	sql, args, _ := r.Builder.
 		Select("users_segments.user_segment_id", "users.user_id", "segments.segment_id", "segments.name", "to_char(users_segments.start_date, 'HH24:MI:SS DD.MM.YYYY') as start_date",
@@ -155,12 +513,13 @@ func (r *UserRepository) GetUserSegmentsByUserID(ctx context.Context, id int) ([
 		From("segments").
 		Join("users_segments on users_segments.segment_id = segments.segment_id").
 		Join("users on users.user_id = users_segments.user_id").
-		Where("users.user_id = ? and (users_segments.end_date >= current_timestamp or users_segments.end_date is null)", id).
+		Where("users.user_id = ? and users_segments.start_date <= current_timestamp", id).
+		Where(openMembership("users_segments.")).
 		ToSql()
 
 	var userSegments []entity.UserSegmentInformation
 
-	rows, err := r.Pool.Query(ctx, sql, args...)
+	rows, err := conn(ctx, r.Pool).Query(ctx, sql, args...)
 	if err != nil {
 		return nil, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
 			OriginError:     err,
@@ -169,6 +528,7 @@ func (r *UserRepository) GetUserSegmentsByUserID(ctx context.Context, id int) ([
 			Location:        "UserRepository.GetUserSegmentsByUserID - r.Pool.Query",
 		}}
 	}
+	defer rows.Close()
 
 	for rows.Next() {
 		var segmentInfo entity.UserSegmentInformation
@@ -189,17 +549,219 @@ func (r *UserRepository) GetUserSegmentsByUserID(ctx context.Context, id int) ([
 	return userSegments, nil
 }
 
+// GetUserScheduledSegments возвращает запланированные и ещё не начавшиеся вхождения пользователя
+// с указанным `id` в сегменты, за исключением отменённых.
+func (r *UserRepository) GetUserScheduledSegments(ctx context.Context, id int) ([]entity.UserSegmentInformation, error) {
+	sql, args, err := r.Builder.
+		Select("us.user_segment_id", "us.user_id", "s.segment_id", "s.name",
+			"to_char(us.start_date, 'HH24:MI:SS DD.MM.YYYY')",
+			"coalesce(to_char(us.end_date, 'HH24:MI:SS DD.MM.YYYY'), '')").
+		From("users_segments us").
+		Join("segments s on s.segment_id = us.segment_id").
+		Where("us.user_id = ? and us.start_date > current_timestamp and us.end_reason is null", id).
+		OrderBy("us.start_date", "us.user_segment_id").
+		ToSql()
+	if err != nil {
+		return nil, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         fmt.Sprintf("Failed to build sql query for fetching scheduled segments of user (id = %d)", id),
+			Location:        "UserRepository.GetUserScheduledSegments - r.Builder",
+		}}
+	}
+
+	rows, err := conn(ctx, r.Pool).Query(ctx, sql, args...)
+	if err != nil {
+		return nil, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         fmt.Sprintf("Failed to query scheduled segments of user (id = %d)", id),
+			Location:        "UserRepository.GetUserScheduledSegments - conn.Query",
+		}}
+	}
+	defer rows.Close()
+
+	var userSegments []entity.UserSegmentInformation
+	for rows.Next() {
+		var segmentInfo entity.UserSegmentInformation
+		err = rows.Scan(
+			&segmentInfo.InfoID,
+			&segmentInfo.UserID,
+			&segmentInfo.SegmentID,
+			&segmentInfo.Name,
+			&segmentInfo.StartDate,
+			&segmentInfo.EndDate,
+		)
+		if err != nil {
+			return nil, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+				OriginError:     err,
+				OriginErrorText: err.Error(),
+				Comment:         "Failed to scan user's scheduled segment",
+				Location:        "UserRepository.GetUserScheduledSegments - rows.Scan",
+			}}
+		}
+		userSegments = append(userSegments, segmentInfo)
+	}
+
+	return userSegments, nil
+}
+
+// GetUserDerivedSegments возвращает вычисляемые сегменты, в которые пользователь с указанным `id` входит
+// на текущий момент согласно операции над его текущими сегментами. Вычисляемые сегменты не хранят вхождений,
+// поэтому у возвращаемых записей не заполнены идентификатор записи и даты вхождения.
+func (r *UserRepository) GetUserDerivedSegments(ctx context.Context, id int) ([]entity.UserSegmentInformation, error) {
+	return r.getUsersDerivedSegments(ctx, []int{id}, "UserRepository.GetUserDerivedSegments")
+}
+
+// getUsersDerivedSegments возвращает вычисляемые сегменты, в которые пользователи из списка `userIDs` входят
+// на текущий момент, упорядоченные по ID пользователя и ID сегмента. `location` - метод, от имени которого
+// выполняется запрос, для текста ошибок.
+func (r *UserRepository) getUsersDerivedSegments(ctx context.Context, userIDs []int, location string) ([]entity.UserSegmentInformation, error) {
+	sql, args, _ := r.Builder.
+		Select("c.user_id", "s.segment_id", "s.name").
+		Prefix("with current_segments as (select us.user_id, array_agg(us.segment_id) as ids "+
+			"from users_segments us join segments cs on cs.segment_id = us.segment_id "+
+			"where us.user_id = any(?) and cs.is_deleted = false and us.start_date <= current_timestamp "+
+			"and "+openMembership("us.")+" group by us.user_id)", userIDs).
+		From("segments s, current_segments c").
+		Where("s.is_deleted = false and s.derived_operation is not null").
+		Where("(s.derived_operation = 'union' and s.derived_from && c.ids) "+
+			"or (s.derived_operation = 'intersection' and s.derived_from <@ c.ids) "+
+			"or (s.derived_operation = 'difference' and s.derived_from[1] = any(c.ids) "+
+			"and not (s.derived_from[2:] && c.ids))").
+		OrderBy("c.user_id", "s.segment_id").
+		ToSql()
+
+	rows, err := conn(ctx, r.Pool).Query(ctx, sql, args...)
+	if err != nil {
+		return nil, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         fmt.Sprintf("Failed to query derived segments of users (ids = %v)", userIDs),
+			Location:        location + " - conn.Query",
+		}}
+	}
+	defer rows.Close()
+
+	var userSegments []entity.UserSegmentInformation
+	for rows.Next() {
+		var segmentInfo entity.UserSegmentInformation
+		if err = rows.Scan(&segmentInfo.UserID, &segmentInfo.SegmentID, &segmentInfo.Name); err != nil {
+			return nil, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+				OriginError:     err,
+				OriginErrorText: err.Error(),
+				Comment:         "Failed to scan user's derived segment",
+				Location:        location + " - rows.Scan",
+			}}
+		}
+		userSegments = append(userSegments, segmentInfo)
+	}
+
+	return userSegments, nil
+}
+
+// GetUserSegmentsAt возвращает список сегментов, в которые пользователь с указанным `id` входил в момент
+// времени `at`, включая сегменты, удалённые позднее. Нулевое значение `at` обозначает текущий момент.
+func (r *UserRepository) GetUserSegmentsAt(ctx context.Context, id int, at time.Time) ([]entity.UserSegmentInformation, error) {
+	sql, args, err := r.Builder.
+		Select("us.user_segment_id", "us.user_id", "s.segment_id", "s.name",
+			"to_char(us.start_date, 'HH24:MI:SS DD.MM.YYYY')",
+			"coalesce(to_char(us.end_date, 'HH24:MI:SS DD.MM.YYYY'), '')").
+		From("users_segments us").
+		Join("segments s on s.segment_id = us.segment_id").
+		Where("us.user_id = ?", id).
+		Where(membershipAt("us", entity.MembershipActive, at)).
+		OrderBy("us.start_date", "us.user_segment_id").
+		ToSql()
+	if err != nil {
+		return nil, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         fmt.Sprintf("Failed to build sql query for fetching segments of user (id = %d) at the given moment", id),
+			Location:        "UserRepository.GetUserSegmentsAt - r.Builder",
+		}}
+	}
+
+	rows, err := conn(ctx, r.Pool).Query(ctx, sql, args...)
+	if err != nil {
+		return nil, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         fmt.Sprintf("Failed to query segments of user (id = %d) at the given moment", id),
+			Location:        "UserRepository.GetUserSegmentsAt - conn.Query",
+		}}
+	}
+	defer rows.Close()
+
+	var userSegments []entity.UserSegmentInformation
+	for rows.Next() {
+		var segmentInfo entity.UserSegmentInformation
+		err = rows.Scan(
+			&segmentInfo.InfoID,
+			&segmentInfo.UserID,
+			&segmentInfo.SegmentID,
+			&segmentInfo.Name,
+			&segmentInfo.StartDate,
+			&segmentInfo.EndDate,
+		)
+		if err != nil {
+			return nil, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+				OriginError:     err,
+				OriginErrorText: err.Error(),
+				Comment:         "Failed to scan user's segment to structure",
+				Location:        "UserRepository.GetUserSegmentsAt - rows.Scan",
+			}}
+		}
+		userSegments = append(userSegments, segmentInfo)
+	}
+
+	return userSegments, nil
+}
+
+// membershipAt формирует условие, которому удовлетворяют записи таблицы `users_segments` с псевдонимом
+// `alias`, имеющие в момент времени `at` статус `status`: действующие (start_date <= at < end_date),
+// завершённые (end_date <= at) или все начавшиеся к этому моменту. Нулевое значение `at` обозначает текущий момент.
+func membershipAt(alias string, status string, at time.Time) squirrel.Sqlizer {
+	moment := "current_timestamp"
+	var args []interface{}
+	if !at.IsZero() {
+		moment = "?"
+		args = []interface{}{at}
+	}
+
+	switch status {
+	case entity.MembershipExpired:
+		return squirrel.Expr(fmt.Sprintf("%s.end_date <= %s", alias, moment), args...)
+	case entity.MembershipAll:
+		return squirrel.Expr(fmt.Sprintf("%s.start_date <= %s", alias, moment), args...)
+	default:
+		return squirrel.Expr(fmt.Sprintf("%[1]s.start_date <= %[2]s and (%[1]s.end_date > %[2]s or %[1]s.end_date is null)",
+			alias, moment), append(args, args...)...)
+	}
+}
+
+// membershipEndNow возвращает дату выхода пользователя из сегмента при досрочном завершении вхождения:
+// текущий момент, а для ещё не начавшегося запланированного вхождения - дату его начала, что отменяет вхождение.
+func membershipEndNow() squirrel.Sqlizer {
+	return squirrel.Expr("greatest(start_date, ?)", time.Now())
+}
+
 // AddUserToSegments добавляет пользователя с идентификатором `id` в сегменты,
 // перечисленные в списке `segments`, не проверяя пользователя на существование,
-// и не проверяя сегменты на существование и метку `is_deleted`.
+// и не проверяя сегменты на существование и метку `is_deleted`. Если у сегмента
+// не указана дата начала `StartDate`, пользователь входит в него немедленно.
 func (r *UserRepository) AddUserToSegments(ctx context.Context, id int, segments []entity.UserSegmentInformation) error {
-	query := r.Builder.Insert("users_segments").Columns("user_id", "segment_id", "end_date")
+	query := r.Builder.Insert("users_segments").Columns("user_id", "segment_id", "start_date", "end_date")
 	for _, segment := range segments {
+		var startDate interface{} = squirrel.Expr("current_timestamp")
+		if segment.StartDate != "" {
+			startDate, _ = time.Parse("15:04:05 02.01.2006", segment.StartDate)
+		}
 		if segment.EndDate == "" {
-			query = query.Values(id, segment.SegmentID, sqlLibrary.NullString{Valid: false})
+			query = query.Values(id, segment.SegmentID, startDate, sqlLibrary.NullString{Valid: false})
 		} else {
 			databaseTimeFormat, _ := time.Parse("15:04:05 02.01.2006", segment.EndDate)
-			query = query.Values(id, segment.SegmentID, databaseTimeFormat)
+			query = query.Values(id, segment.SegmentID, startDate, databaseTimeFormat)
 		}
 	}
 	sql, args, err := query.ToSql()
@@ -212,7 +774,7 @@ func (r *UserRepository) AddUserToSegments(ctx context.Context, id int, segments
 		}}
 	}
 
-	_, err = r.Pool.Exec(ctx, sql, args...)
+	_, err = conn(ctx, r.Pool).Exec(ctx, sql, args...)
 	if err != nil {
 		return customError.ErrInternalServerError{ErrBase: customError.ErrBase{
 			OriginError:     err,
@@ -225,6 +787,64 @@ func (r *UserRepository) AddUserToSegments(ctx context.Context, id int, segments
 	return nil
 }
 
+// UpdateUserSegmentEndDate устанавливает дату выхода пользователя из сегмента `endDate` (nil - бессрочно)
+// в записи о вхождении с идентификатором `infoID` и сохраняет прежнюю и новую даты в истории изменений.
+func (r *UserRepository) UpdateUserSegmentEndDate(ctx context.Context, infoID int, endDate *time.Time) error {
+	sql, args, err := r.Builder.
+		Insert("users_segments_history").
+		Columns("user_segment_id", "action", "old_end_date", "new_end_date").
+		Select(r.Builder.
+			Select("user_segment_id").
+			Column("?::text", entity.MembershipHistoryEndDateChanged).
+			Column("end_date").
+			Column("?::timestamp", endDate).
+			From("users_segments").
+			Where("user_segment_id = ?", infoID)).
+		ToSql()
+	if err != nil {
+		return customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         fmt.Sprintf("Failed to build sql query for recording end date change of membership (id = %d)", infoID),
+			Location:        "UserRepository.UpdateUserSegmentEndDate - r.Builder",
+		}}
+	}
+
+	if _, err = conn(ctx, r.Pool).Exec(ctx, sql, args...); err != nil {
+		return customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         fmt.Sprintf("Failed to record end date change of membership (id = %d)", infoID),
+			Location:        "UserRepository.UpdateUserSegmentEndDate - conn.Exec",
+		}}
+	}
+
+	sql, args, err = r.Builder.
+		Update("users_segments").
+		Set("end_date", endDate).
+		Where("user_segment_id = ?", infoID).
+		ToSql()
+	if err != nil {
+		return customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         fmt.Sprintf("Failed to build sql query for updating end date of membership (id = %d)", infoID),
+			Location:        "UserRepository.UpdateUserSegmentEndDate - r.Builder",
+		}}
+	}
+
+	if _, err = conn(ctx, r.Pool).Exec(ctx, sql, args...); err != nil {
+		return customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         fmt.Sprintf("Failed to update end date of membership (id = %d)", infoID),
+			Location:        "UserRepository.UpdateUserSegmentEndDate - conn.Exec",
+		}}
+	}
+
+	return nil
+}
+
 // DeleteUserFromSegments удаляет пользователя из указанных сегментов, не осуществляя проверки
 // на существование пользователя, существование сегментов.
 func (r *UserRepository) DeleteUserFromSegments(ctx context.Context, id int, segments []entity.UserSegmentInformation) error {
@@ -236,7 +856,8 @@ func (r *UserRepository) DeleteUserFromSegments(ctx context.Context, id int, seg
 	}
 
 	query := r.Builder.Update("users_segments").
-		Set("end_date", time.Now()).
+		Set("end_date", membershipEndNow()).
+		Set("end_reason", entity.MembershipEndReasonRemoved).
 		Where(fmt.Sprintf("user_segment_id in (%s)", strings.Join(placeholders, ", ")), infoIDs...)
 	sql, args, err := query.ToSql()
 	if err != nil {
@@ -248,7 +869,7 @@ func (r *UserRepository) DeleteUserFromSegments(ctx context.Context, id int, seg
 		}}
 	}
 
-	_, err = r.Pool.Exec(ctx, sql, args...)
+	_, err = conn(ctx, r.Pool).Exec(ctx, sql, args...)
 	if err != nil {
 		return customError.ErrInternalServerError{ErrBase: customError.ErrBase{
 			OriginError:     err,
@@ -260,3 +881,180 @@ func (r *UserRepository) DeleteUserFromSegments(ctx context.Context, id int, seg
 
 	return nil
 }
+
+// UpdateUser обновляет атрибуты пользователя `user` с идентификатором `user.ID`.
+func (r *UserRepository) UpdateUser(ctx context.Context, user entity.User) error {
+	sql, args, err := r.Builder.
+		Update("users").
+		Set("name", user.Name).
+		Set("lastname", user.Lastname).
+		Set("sex", user.Sex).
+		Set("age", user.Age).
+		Set("attributes", user.Attributes).
+		Where("user_id = ?", user.ID).
+		ToSql()
+	if err != nil {
+		return customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         fmt.Sprintf("Failed to build sql query for updating user (id = %d)", user.ID),
+			Location:        "UserRepository.UpdateUser - r.Builder",
+		}}
+	}
+
+	res, err := conn(ctx, r.Pool).Exec(ctx, sql, args...)
+	if err != nil {
+		return customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         fmt.Sprintf("Failed to update user (id = %d)", user.ID),
+			Location:        "UserRepository.UpdateUser - conn.Exec",
+		}}
+	}
+	if res.RowsAffected() == 0 {
+		return customError.ErrUserNotFound{ErrBase: customError.ErrBase{
+			Comment:  fmt.Sprintf("User with id %d does not exist", user.ID),
+			Location: "UserRepository.UpdateUser",
+		}}
+	}
+
+	return nil
+}
+
+// DeleteUser помечает пользователя с указанным `id` как удалённого и устанавливает для всех действующих
+// записей о вхождении пользователя в сегменты время выхода из сегмента, равное моменту удаления.
+func (r *UserRepository) DeleteUser(ctx context.Context, id int) error {
+	if err := r.setUserDeleted(ctx, id, true); err != nil {
+		return err
+	}
+
+	sql, args, err := r.Builder.
+		Update("users_segments").
+		Set("end_date", membershipEndNow()).
+		Set("end_reason", entity.MembershipEndReasonRemoved).
+		Where("user_id = ?", id).
+		Where(openMembership("")).
+		ToSql()
+	if err != nil {
+		return customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         fmt.Sprintf("Failed to build sql query for removing deleted user (id = %d) from segments", id),
+			Location:        "UserRepository.DeleteUser - r.Builder",
+		}}
+	}
+
+	if _, err = conn(ctx, r.Pool).Exec(ctx, sql, args...); err != nil {
+		return customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         fmt.Sprintf("Failed to remove deleted user (id = %d) from segments", id),
+			Location:        "UserRepository.DeleteUser - conn.Exec",
+		}}
+	}
+
+	return nil
+}
+
+// RestoreUser снимает с пользователя с указанным `id` пометку об удалении.
+func (r *UserRepository) RestoreUser(ctx context.Context, id int) error {
+	return r.setUserDeleted(ctx, id, false)
+}
+
+func (r *UserRepository) setUserDeleted(ctx context.Context, id int, isDeleted bool) error {
+	sql, args, err := r.Builder.
+		Update("users").
+		Set("is_deleted", isDeleted).
+		Where("user_id = ?", id).
+		ToSql()
+	if err != nil {
+		return customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         fmt.Sprintf("Failed to build sql query for changing deletion mark of user (id = %d)", id),
+			Location:        "UserRepository.setUserDeleted - r.Builder",
+		}}
+	}
+
+	res, err := conn(ctx, r.Pool).Exec(ctx, sql, args...)
+	if err != nil {
+		return customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         fmt.Sprintf("Failed to change deletion mark of user (id = %d)", id),
+			Location:        "UserRepository.setUserDeleted - conn.Exec",
+		}}
+	}
+	if res.RowsAffected() == 0 {
+		return customError.ErrUserNotFound{ErrBase: customError.ErrBase{
+			Comment:  fmt.Sprintf("User with id %d does not exist", id),
+			Location: "UserRepository.setUserDeleted",
+		}}
+	}
+
+	return nil
+}
+
+// LockUser блокирует запись пользователя с указанным `id` до конца текущей транзакции, тем самым
+// упорядочивая конкурирующие операции над сегментами одного и того же пользователя. Вне транзакции
+// блокировка снимается сразу после выполнения запроса.
+func (r *UserRepository) LockUser(ctx context.Context, id int) error {
+	sql, args, _ := r.Builder.
+		Select("user_id").
+		From("users").
+		Where("user_id = ?", id).
+		Suffix("FOR UPDATE").
+		ToSql()
+
+	_, err := conn(ctx, r.Pool).Exec(ctx, sql, args...)
+	if err != nil {
+		return customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         fmt.Sprintf("Failed to lock user with id = %d", id),
+			Location:        "UserRepository.LockUser - conn.Exec",
+		}}
+	}
+
+	return nil
+}
+
+// LockUsers блокирует записи пользователей с идентификаторами `ids` до конца текущей транзакции
+// в порядке возрастания идентификаторов и возвращает найденных пользователей с заполненными
+// полями `ID` и `IsDeleted`. Отсутствующие идентификаторы пропускаются.
+func (r *UserRepository) LockUsers(ctx context.Context, ids []int) ([]entity.User, error) {
+	sql, args, _ := r.Builder.
+		Select("user_id", "is_deleted").
+		From("users").
+		Where("user_id = any(?)", ids).
+		OrderBy("user_id").
+		Suffix("FOR UPDATE").
+		ToSql()
+
+	rows, err := conn(ctx, r.Pool).Query(ctx, sql, args...)
+	if err != nil {
+		return nil, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         fmt.Sprintf("Failed to lock %d users", len(ids)),
+			Location:        "UserRepository.LockUsers - conn.Query",
+		}}
+	}
+	defer rows.Close()
+
+	users := make([]entity.User, 0, len(ids))
+	for rows.Next() {
+		var user entity.User
+		if err = rows.Scan(&user.ID, &user.IsDeleted); err != nil {
+			return nil, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+				OriginError:     err,
+				OriginErrorText: err.Error(),
+				Comment:         "Failed to scan locked user to structure",
+				Location:        "UserRepository.LockUsers - rows.Scan",
+			}}
+		}
+		users = append(users, user)
+	}
+
+	return users, nil
+}
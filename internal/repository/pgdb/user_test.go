@@ -0,0 +1,175 @@
+package pgdb
+
+import (
+	"avito-rest-api/internal/entity"
+	"avito-rest-api/package/postgres"
+	"context"
+	"fmt"
+	"github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// countingPool - заглушка пула соединений, считающая запросы к базе данных и возвращающая на каждый запрос
+// строки, подготовленные функцией `rows`. Методы, не переопределённые заглушкой, не должны вызываться.
+type countingPool struct {
+	postgres.PgxPool
+	rows    func(sql string, args []any) [][]any
+	queries []string
+}
+
+func (p *countingPool) Query(_ context.Context, sql string, args ...any) (pgx.Rows, error) {
+	p.queries = append(p.queries, sql)
+	return &stubRows{rows: p.rows(sql, args)}, nil
+}
+
+// stubRows - результат запроса заглушки countingPool. Scan записывает значения столбцов в переданные
+// указатели, значение nil обнуляет указатель.
+type stubRows struct {
+	pgx.Rows
+	rows    [][]any
+	current int
+}
+
+func (r *stubRows) Next() bool {
+	r.current++
+	return r.current <= len(r.rows)
+}
+
+func (r *stubRows) Scan(dest ...any) error {
+	row := r.rows[r.current-1]
+	if len(dest) != len(row) {
+		return fmt.Errorf("scan into %d destinations, row has %d columns", len(dest), len(row))
+	}
+	for i, d := range dest {
+		target := reflect.ValueOf(d).Elem()
+		if row[i] == nil {
+			target.Set(reflect.Zero(target.Type()))
+			continue
+		}
+		value := reflect.ValueOf(row[i])
+		if target.Kind() == reflect.Pointer && value.Type() != target.Type() {
+			ptr := reflect.New(target.Type().Elem())
+			ptr.Elem().Set(value)
+			value = ptr
+		}
+		target.Set(value)
+	}
+	return nil
+}
+
+func (r *stubRows) Err() error {
+	return nil
+}
+
+func (r *stubRows) Close() {}
+
+func (r *stubRows) CommandTag() pgconn.CommandTag {
+	return pgconn.CommandTag{}
+}
+
+// usersWithSegmentsPool возвращает заглушку пула, в которой у каждого из `users` пользователей есть
+// `segments` хранимых сегментов и один вычисляемый сегмент.
+func usersWithSegmentsPool(users int, segments int) *countingPool {
+	return &countingPool{rows: func(sql string, args []any) [][]any {
+		var rows [][]any
+		// Запрос вычисляемых сегментов пользователей страницы
+		if strings.Contains(sql, "current_segments") {
+			for _, id := range args[0].([]int) {
+				rows = append(rows, []any{id, 1000, "AVITO_VOICE_AND_MAP"})
+			}
+			return rows
+		}
+		for id := 1; id <= users; id++ {
+			user := []any{id, nil, "Михаил", "Иванов", 0, "мужской", 27, false, map[string]interface{}{}}
+			if segments == 0 {
+				rows = append(rows, append(user, nil, nil, nil, nil, nil))
+			}
+			for s := 1; s <= segments; s++ {
+				rows = append(rows, append(append([]any(nil), user...),
+					id*segments+s, s, fmt.Sprintf("AVITO_SEGMENT_%d", s), "15:27:32 01.09.2023", ""))
+			}
+		}
+		return rows
+	}}
+}
+
+func newTestUserRepository(pool postgres.PgxPool) *UserRepository {
+	return NewUserRepository(&postgres.PostgreDB{
+		Builder: squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar),
+		Pool:    pool,
+	})
+}
+
+func TestUserRepository_GetUsersWithSegments(t *testing.T) {
+	testCases := []struct {
+		name            string
+		users           int
+		segments        int
+		expectedQueries int
+	}{
+		{name: "Empty page", expectedQueries: 1},
+		{name: "One user", users: 1, segments: 3, expectedQueries: 2},
+		{name: "Users without stored segments", users: 10, expectedQueries: 2},
+		{name: "Page of 100 users", users: 100, segments: 3, expectedQueries: 2},
+		{name: "Page of 1000 users", users: 1000, segments: 5, expectedQueries: 2},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Инициализация зависимостей
+			pool := usersWithSegmentsPool(tc.users, tc.segments)
+			r := newTestUserRepository(pool)
+
+			// Выполнение
+			users, err := r.GetUsersWithSegments(context.Background(), 0, tc.users+1)
+
+			// Проверка результата: количество запросов не зависит от количества пользователей и их сегментов
+			assert.NoError(t, err)
+			assert.Len(t, pool.queries, tc.expectedQueries)
+			assert.Len(t, users, tc.users)
+			for i, user := range users {
+				assert.Equal(t, i+1, user.User.ID)
+				// Хранимые сегменты, а за ними вычисляемый
+				assert.Len(t, user.Segments, tc.segments+1)
+				assert.Equal(t, "AVITO_VOICE_AND_MAP", user.Segments[tc.segments].Name)
+			}
+		})
+	}
+}
+
+func BenchmarkUserRepository_GetUsersWithSegments(b *testing.B) {
+	for _, users := range []int{10, 100, 1000} {
+		b.Run(fmt.Sprintf("users=%d", users), func(b *testing.B) {
+			pool := usersWithSegmentsPool(users, 3)
+			r := newTestUserRepository(pool)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := r.GetUsersWithSegments(context.Background(), 0, users); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(len(pool.queries))/float64(b.N), "queries/op")
+		})
+	}
+}
+
+func TestMembershipPredicates(t *testing.T) {
+	// Выполнение
+	atNow, _, err := membershipAt("us", entity.MembershipActive, time.Time{}).ToSql()
+	assert.NoError(t, err)
+	expiredNow, _, err := membershipAt("us", entity.MembershipExpired, time.Time{}).ToSql()
+	assert.NoError(t, err)
+
+	// Проверка результата: текущее вхождение и вхождение на момент времени используют один полуинтервал
+	// [start_date, end_date), поэтому в момент end_date пользователь не входит в сегмент ни в одном из них
+	assert.Contains(t, openMembership("us."), "us.end_date > current_timestamp")
+	assert.Contains(t, atNow, "us.end_date > current_timestamp")
+	assert.Contains(t, expiredNow, "us.end_date <= current_timestamp")
+}
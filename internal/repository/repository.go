@@ -5,40 +5,108 @@ import (
 	"avito-rest-api/internal/repository/pgdb"
 	"avito-rest-api/package/postgres"
 	"context"
+	"time"
 )
 
 type User interface {
 	CreateUser(ctx context.Context, user entity.User) (int, error)
+	ImportUsers(ctx context.Context, users []entity.User) ([]int, error)
 	GetUserByID(ctx context.Context, id int) (entity.User, error)
-	GetAllUsers(ctx context.Context) ([]entity.User, error)
+	GetUserByExternalID(ctx context.Context, externalID string) (entity.User, error)
+	GetTakenExternalIDs(ctx context.Context, externalIDs []string) ([]string, error)
+	UpsertUserByExternalID(ctx context.Context, user entity.User) (int, bool, error)
+	GetAllUsers(ctx context.Context, filter entity.UsersFilter) ([]entity.User, error)
+	GetUsersWithSegments(ctx context.Context, afterID int, limit int) ([]entity.UserWithSegments, error)
 	GetUserSegmentsByUserID(ctx context.Context, id int) ([]entity.UserSegmentInformation, error)
+	GetUserScheduledSegments(ctx context.Context, id int) ([]entity.UserSegmentInformation, error)
+	GetUserDerivedSegments(ctx context.Context, id int) ([]entity.UserSegmentInformation, error)
+	UpdateUserSegmentEndDate(ctx context.Context, infoID int, endDate *time.Time) error
+	GetUserSegmentsAt(ctx context.Context, id int, at time.Time) ([]entity.UserSegmentInformation, error)
 	AddUserToSegments(ctx context.Context, id int, segments []entity.UserSegmentInformation) error
 	DeleteUserFromSegments(ctx context.Context, id int, segments []entity.UserSegmentInformation) error
+	UpdateUser(ctx context.Context, user entity.User) error
+	DeleteUser(ctx context.Context, id int) error
+	RestoreUser(ctx context.Context, id int) error
+	LockUser(ctx context.Context, id int) error
+	LockUsers(ctx context.Context, ids []int) ([]entity.User, error)
 }
 
 type Segment interface {
 	CreateSegment(ctx context.Context, segment entity.Segment) (string, error)
-	GetAllSegments(ctx context.Context, sTypes int) ([]entity.Segment, error)
+	GetAllSegments(ctx context.Context, sTypes int, filter entity.SegmentsFilter) ([]entity.Segment, error)
 	GetSegmentByName(ctx context.Context, name string) (entity.Segment, error)
+	GetSegmentByFormerName(ctx context.Context, name string, gracePeriod time.Duration) (entity.Segment, error)
+	GetSegmentNameHistory(ctx context.Context, id int) ([]entity.SegmentNameChange, error)
+	RenameSegment(ctx context.Context, id int, oldName string, newName string) error
 	DeleteSegment(ctx context.Context, name string) error
-	RecoverSegment(ctx context.Context, name string) (string, error)
-	AddUsersToSegmentByRandomPercent(ctx context.Context, name string, percent int) error
+	RecoverSegment(ctx context.Context, name string, salt string) (string, error)
+	UpdateSegmentRules(ctx context.Context, id int, rules []entity.SegmentRule) error
+	UpdateSegmentPercentage(ctx context.Context, id int, percentage *int) error
+	UpdateSegmentMetadata(ctx context.Context, id int, description string, owner string, tags []string) error
+	UpdateSegmentLifetime(ctx context.Context, id int, defaultTTL *time.Duration, activeUntil *time.Time) error
+	GetAutomaticSegments(ctx context.Context) ([]entity.Segment, error)
+	SyncSegmentUsers(ctx context.Context, segment entity.Segment) error
+	AddMatchingUsersToSegment(ctx context.Context, segment entity.Segment, userIDs []int) error
+	GetSegmentExperimentName(ctx context.Context, id int) (string, error)
+	GetSegmentUsers(ctx context.Context, id int, filter entity.SegmentUsersFilter) ([]entity.SegmentMember, error)
+	GetSegmentMemberIDs(ctx context.Context, id int, userIDs []int) ([]int, error)
+	GetAllSegmentMemberIDs(ctx context.Context, id int) ([]int, error)
+	GetExclusiveMemberIDs(ctx context.Context, id int, groupID int, userIDs []int) ([]int, error)
+	GetDerivedSegmentUserIDs(ctx context.Context, derivation entity.SegmentDerivation) ([]int, error)
+	GetDependentSegmentNames(ctx context.Context, id int) ([]string, error)
+	AddUsersToSegment(ctx context.Context, id int, userIDs []int, endDate *time.Time) (int64, error)
+	DeleteUsersFromSegment(ctx context.Context, id int, userIDs []int) (int64, error)
+	ExpireMemberships(ctx context.Context, limit int) ([]entity.UserSegmentInformation, error)
+	GetExpiredSegmentNames(ctx context.Context) ([]string, error)
+	LockSegment(ctx context.Context, id int) error
+	GetSegmentArchiveRows(ctx context.Context, id int) ([]entity.SegmentArchiveRow, error)
+	PurgeSegment(ctx context.Context, id int) error
+	GetPurgeableSegmentNames(ctx context.Context, retention time.Duration, limit int) ([]string, error)
+}
+
+type SegmentGroup interface {
+	CreateSegmentGroup(ctx context.Context, name string) (int, error)
+	GetSegmentGroupByName(ctx context.Context, name string) (entity.SegmentGroup, error)
+	SetGroupSegments(ctx context.Context, id int, segmentIDs []int) error
+	CountUsersInSeveralSegments(ctx context.Context, segmentIDs []int) (int, error)
+	GetUserSegmentsInGroup(ctx context.Context, id int, userID int) ([]entity.UserSegmentInformation, error)
+}
+
+type Experiment interface {
+	CreateExperiment(ctx context.Context, experiment entity.Experiment) (int, error)
+	GetExperimentByName(ctx context.Context, name string) (entity.Experiment, error)
+	GetAllExperiments(ctx context.Context) ([]entity.Experiment, error)
+	AssignUsersToExperiment(ctx context.Context, experiment entity.Experiment, userIDs []int) error
+	GetUserVariant(ctx context.Context, experiment entity.Experiment, userID int) (entity.UserExperimentVariant, error)
+	GetVariantSizes(ctx context.Context, id int) ([]entity.ExperimentVariantSize, error)
 }
 
 type Report interface {
-	MakeReport(ctx context.Context) (entity.Report, error)
+	MakeReport(ctx context.Context, from, to time.Time) (entity.Report, error)
+}
+
+// Transactor позволяет сервисам выполнять несколько операций над репозиториями атомарно.
+// Репозитории, вызываемые с контекстом, переданным в `fn`, используют открытую транзакцию.
+type Transactor interface {
+	WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error
 }
 
 type Repositories struct {
 	User
 	Segment
+	SegmentGroup
+	Experiment
 	Report
+	Transactor
 }
 
 func NewRepositories(pg *postgres.PostgreDB) *Repositories {
 	return &Repositories{
-		User:    pgdb.NewUserRepository(pg),
-		Segment: pgdb.NewSegmentRepository(pg),
-		Report:  pgdb.NewReportRepository(pg),
+		User:         pgdb.NewUserRepository(pg),
+		Segment:      pgdb.NewSegmentRepository(pg),
+		SegmentGroup: pgdb.NewSegmentGroupRepository(pg),
+		Experiment:   pgdb.NewExperimentRepository(pg),
+		Report:       pgdb.NewReportRepository(pg),
+		Transactor:   pgdb.NewTxManager(pg),
 	}
 }
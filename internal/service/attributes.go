@@ -0,0 +1,153 @@
+package service
+
+import (
+	"avito-rest-api/internal/entity"
+	customError "avito-rest-api/internal/error"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// maxUserAttributes - максимальное количество дополнительных атрибутов одного пользователя.
+const maxUserAttributes = 50
+
+// attributeKeyRegexp задаёт допустимые ключи дополнительных атрибутов пользователя.
+var attributeKeyRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]{0,99}$`)
+
+// validateUserAttributes проверяет ключи и значения дополнительных атрибутов пользователя.
+func validateUserAttributes(attributes map[string]interface{}, location string) error {
+	if len(attributes) > maxUserAttributes {
+		return customError.ErrUserValidationError{ErrBase: customError.ErrBase{
+			Comment:  fmt.Sprintf("\"attributes\" field cannot contain more than %d attributes", maxUserAttributes),
+			Location: location,
+		}}
+	}
+	for key, value := range attributes {
+		if !attributeKeyRegexp.MatchString(key) {
+			return customError.ErrUserValidationError{ErrBase: customError.ErrBase{
+				Comment: fmt.Sprintf("Invalid attribute key \"%s\", key must start with a latin letter or underscore "+
+					"and contain only latin letters, digits and underscores (up to 100 symbols)", key),
+				Location: location,
+			}}
+		}
+		if _, ok := attributeValue(value); !ok {
+			return customError.ErrUserValidationError{ErrBase: customError.ErrBase{
+				Comment:  fmt.Sprintf("Invalid value of attribute \"%s\", expected string, number or boolean", key),
+				Location: location,
+			}}
+		}
+	}
+	return nil
+}
+
+// userAttributes возвращает пустой набор атрибутов вместо nil, так как столбец `attributes` не допускает NULL.
+func userAttributes(attributes map[string]interface{}) map[string]interface{} {
+	if attributes == nil {
+		return map[string]interface{}{}
+	}
+	return attributes
+}
+
+// attributeValue приводит значение дополнительного атрибута к string, float64 или bool.
+// Значения, полученные из JSON, уже имеют один из этих типов.
+func attributeValue(value interface{}) (interface{}, bool) {
+	switch v := value.(type) {
+	case string, float64, bool:
+		return v, true
+	case int:
+		return float64(v), true
+	}
+	return nil, false
+}
+
+// validateAttributeRule проверяет правило сегмента над дополнительным атрибутом пользователя
+// и возвращает его в нормализованном виде.
+func validateAttributeRule(i int, rule entity.SegmentRule) (entity.SegmentRule, error) {
+	key := strings.TrimPrefix(rule.Attribute, entity.SegmentRuleAttributePrefix)
+	if !attributeKeyRegexp.MatchString(key) {
+		return rule, customError.ErrSegmentValidationError{ErrBase: customError.ErrBase{
+			Comment:  fmt.Sprintf("Validation of segment's rules failed, rule #%d has invalid attribute key \"%s\"", i+1, key),
+			Location: "SegmentService.validateSegmentRules",
+		}}
+	}
+
+	value, ok := attributeValue(rule.Value)
+	if !ok {
+		return rule, customError.ErrSegmentValidationError{ErrBase: customError.ErrBase{
+			Comment: fmt.Sprintf("Validation of segment's rules failed, rule #%d: value of attribute \"%s\" "+
+				"must be a string, number or boolean", i+1, rule.Attribute),
+			Location: "SegmentService.validateSegmentRules",
+		}}
+	}
+
+	isOrdering, ok := entity.SegmentRuleOperators[rule.Operator]
+	if !ok {
+		return rule, customError.ErrSegmentValidationError{ErrBase: customError.ErrBase{
+			Comment: fmt.Sprintf("Validation of segment's rules failed, rule #%d has unsupported operator \"%s\", "+
+				"valid values: [\"=\", \"!=\", \">\", \">=\", \"<\", \"<=\"]", i+1, rule.Operator),
+			Location: "SegmentService.validateSegmentRules",
+		}}
+	}
+	if isOrdering {
+		if _, isBool := value.(bool); isBool {
+			return rule, customError.ErrSegmentValidationError{ErrBase: customError.ErrBase{
+				Comment: fmt.Sprintf("Validation of segment's rules failed, rule #%d: boolean value of attribute \"%s\" "+
+					"can only be compared with operators \"=\" and \"!=\"", i+1, rule.Attribute),
+				Location: "SegmentService.validateSegmentRules",
+			}}
+		}
+	}
+
+	rule.Value = value
+	return rule, nil
+}
+
+// matchAttributeRule проверяет, удовлетворяет ли пользователь правилу над дополнительным атрибутом. Пользователь
+// без атрибута не удовлетворяет правилу ни с каким оператором, значения разных типов не равны друг другу,
+// а сравнение на больше/меньше выполняется только между значениями одного типа: числа сравниваются
+// численно, строки - побайтово. Проверка должна совпадать с условием, формируемым репозиторием.
+func matchAttributeRule(rule entity.SegmentRule, attributes map[string]interface{}) bool {
+	actual, ok := attributes[strings.TrimPrefix(rule.Attribute, entity.SegmentRuleAttributePrefix)]
+	if !ok {
+		return false
+	}
+	actual, ok = attributeValue(actual)
+	if !ok {
+		return false
+	}
+	expected, _ := attributeValue(rule.Value)
+
+	switch rule.Operator {
+	case "=":
+		return actual == expected
+	case "!=":
+		return actual != expected
+	}
+
+	switch e := expected.(type) {
+	case float64:
+		if a, ok := actual.(float64); ok {
+			return compareOrder(a > e, a < e, rule.Operator)
+		}
+	case string:
+		if a, ok := actual.(string); ok {
+			return compareOrder(a > e, a < e, rule.Operator)
+		}
+	}
+	return false
+}
+
+// compareOrder применяет оператор >, >=, < или <= к результату сравнения двух значений.
+func compareOrder(greater bool, less bool, operator string) bool {
+	switch operator {
+	case ">":
+		return greater
+	case ">=":
+		return !less
+	case "<":
+		return less
+	case "<=":
+		return !greater
+	}
+	return false
+}
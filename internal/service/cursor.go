@@ -0,0 +1,62 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strconv"
+)
+
+const (
+	defaultPageLimit = 100
+	maxPageLimit     = 1000
+)
+
+// PageInput - DTO с параметрами страницы списка.
+type PageInput struct {
+	Cursor string // Курсор страницы, пустой для первой страницы
+	Limit  int    // Размер страницы, 0 - размер по умолчанию
+}
+
+// encodeCursor формирует непрозрачный для клиента курсор, указывающий на запись с указанным `id`.
+func encodeCursor(id int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(id)))
+}
+
+// decodeCursor извлекает ID записи из курсора, сформированного encodeCursor.
+// Для пустого курсора возвращается 0, то есть выборка начинается с первой записи.
+func decodeCursor(cursor string) (int, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(string(raw))
+}
+
+// sortCursor - содержимое курсора списка, упорядоченного по произвольному полю и затем по ID.
+type sortCursor struct {
+	Sort  string      `json:"s"`  // Порядок списка, для которого сформирован курсор
+	Value interface{} `json:"v"`  // Значение поля сортировки у последней записи страницы
+	ID    int         `json:"id"` // ID последней записи страницы
+}
+
+// encodeSortCursor формирует непрозрачный для клиента курсор из содержимого `cursor`.
+func encodeSortCursor(cursor sortCursor) string {
+	raw, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// decodeSortCursor извлекает содержимое курсора, сформированного encodeSortCursor.
+func decodeSortCursor(cursor string) (sortCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return sortCursor{}, err
+	}
+	var decoded sortCursor
+	if err = json.Unmarshal(raw, &decoded); err != nil {
+		return sortCursor{}, err
+	}
+	return decoded, nil
+}
@@ -0,0 +1,240 @@
+package service
+
+import (
+	"avito-rest-api/internal/entity"
+	customError "avito-rest-api/internal/error"
+	"avito-rest-api/internal/repository"
+	"context"
+	"fmt"
+)
+
+type ExperimentService struct {
+	experimentRepository repository.Experiment
+	segmentRepository    repository.Segment
+	userRepository       repository.User
+	transactor           repository.Transactor
+}
+
+// NewExperimentService инициализирует сервис для A/B экспериментов
+func NewExperimentService(experimentRepository repository.Experiment, segmentRepository repository.Segment, userRepository repository.User, transactor repository.Transactor) *ExperimentService {
+	return &ExperimentService{
+		experimentRepository: experimentRepository,
+		segmentRepository:    segmentRepository,
+		userRepository:       userRepository,
+		transactor:           transactor,
+	}
+}
+
+// ExperimentCreateInput - DTO для маппинга данных из тела
+// POST-запроса на создание эксперимента.
+type ExperimentCreateInput struct {
+	// Имя эксперимента, используется как префикс имён сегментов вариантов
+	Name string `json:"name" example:"AVITO_CHECKOUT" validate:"required"`
+	// Варианты эксперимента, не менее двух
+	Variants []ExperimentVariantInput `json:"variants" validate:"required"`
+}
+
+type ExperimentVariantInput struct {
+	// Имя варианта, сегмент варианта получит имя `<имя эксперимента>_<имя варианта>`
+	Name string `json:"name" example:"A" validate:"required"`
+	// Вес варианта, целое положительное число
+	Weight int `json:"weight" example:"50" validate:"required"`
+}
+
+// CreateExperiment создаёт эксперимент вместе с сегментами его вариантов и распределяет
+// по вариантам всех существующих пользователей. Удалённый сегмент с именем сегмента варианта
+// восстанавливается, как при создании сегмента.
+func (s *ExperimentService) CreateExperiment(ctx context.Context, input ExperimentCreateInput) (entity.Experiment, error) {
+	// Валидация
+	if input.Name == "" {
+		return entity.Experiment{}, customError.ErrExperimentValidationError{ErrBase: customError.ErrBase{
+			Comment:  "Validation of experiment's data failed, field \"name\" cannot be empty",
+			Location: "ExperimentService.CreateExperiment",
+		}}
+	}
+	if len(input.Variants) < 2 {
+		return entity.Experiment{}, customError.ErrExperimentValidationError{ErrBase: customError.ErrBase{
+			Comment:  "Validation of experiment's data failed, experiment must have at least 2 variants",
+			Location: "ExperimentService.CreateExperiment",
+		}}
+	}
+	variantNames := make(map[string]bool)
+	for i, variant := range input.Variants {
+		if variant.Name == "" {
+			return entity.Experiment{}, customError.ErrExperimentValidationError{ErrBase: customError.ErrBase{
+				Comment:  fmt.Sprintf("Validation of experiment's data failed, variant #%d has empty name", i+1),
+				Location: "ExperimentService.CreateExperiment",
+			}}
+		}
+		if variantNames[variant.Name] {
+			return entity.Experiment{}, customError.ErrExperimentValidationError{ErrBase: customError.ErrBase{
+				Comment:  fmt.Sprintf("Validation of experiment's data failed, variant \"%s\" is provided more than once", variant.Name),
+				Location: "ExperimentService.CreateExperiment",
+			}}
+		}
+		variantNames[variant.Name] = true
+		if variant.Weight <= 0 {
+			return entity.Experiment{}, customError.ErrExperimentValidationError{ErrBase: customError.ErrBase{
+				Comment:  fmt.Sprintf("Validation of experiment's data failed, weight of variant \"%s\" must be a positive integer number", variant.Name),
+				Location: "ExperimentService.CreateExperiment",
+			}}
+		}
+	}
+
+	var experiment entity.Experiment
+	err := s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		// Проверим, что эксперимент с таким именем ещё не существует
+		_, err := s.experimentRepository.GetExperimentByName(ctx, input.Name)
+		if err == nil {
+			return customError.ErrExperimentAlreadyExists{ErrBase: customError.ErrBase{
+				Comment:  fmt.Sprintf("Experiment with the given name \"%s\" already exists", input.Name),
+				Location: "ExperimentService.CreateExperiment - GetExperimentByName",
+			}}
+		}
+		if _, ok := err.(customError.ErrExperimentNotFound); !ok {
+			return err
+		}
+
+		// Создадим сегменты вариантов
+		experiment = entity.Experiment{Name: input.Name}
+		for _, variantInput := range input.Variants {
+			segmentName := fmt.Sprintf("%s_%s", input.Name, variantInput.Name)
+			segment, err := s.segmentRepository.GetSegmentByName(ctx, segmentName)
+			if err == nil && !segment.IsDeleted {
+				return customError.ErrSegmentAlreadyExists{ErrBase: customError.ErrBase{
+					Comment:  fmt.Sprintf("Segment \"%s\" for variant \"%s\" already exists", segmentName, variantInput.Name),
+					Location: "ExperimentService.CreateExperiment - GetSegmentByName",
+				}}
+			}
+			if err == nil {
+				// Сегмент с таким именем удалён - восстановим его так же, как при создании сегмента
+				if err = s.recoverVariantSegment(ctx, segment, variantInput.Name); err != nil {
+					return err
+				}
+			} else if _, ok := err.(customError.ErrSegmentNotFound); !ok {
+				return err
+			} else if _, err = s.segmentRepository.CreateSegment(ctx, entity.Segment{Name: segmentName}); err != nil {
+				return err
+			}
+			segment, err = s.segmentRepository.GetSegmentByName(ctx, segmentName)
+			if err != nil {
+				return err
+			}
+
+			experiment.Variants = append(experiment.Variants, entity.ExperimentVariant{
+				Name:        variantInput.Name,
+				Weight:      variantInput.Weight,
+				SegmentID:   segment.ID,
+				SegmentName: segment.Name,
+			})
+		}
+
+		if _, err = s.experimentRepository.CreateExperiment(ctx, experiment); err != nil {
+			return err
+		}
+		// Получим эксперимент повторно, чтобы узнать идентификаторы и соль, назначенные базой данных
+		experiment, err = s.experimentRepository.GetExperimentByName(ctx, input.Name)
+		if err != nil {
+			return err
+		}
+
+		return s.experimentRepository.AssignUsersToExperiment(ctx, experiment, nil)
+	})
+	if err != nil {
+		return entity.Experiment{}, err
+	}
+
+	return experiment, nil
+}
+
+// recoverVariantSegment восстанавливает удалённый сегмент `segment` для варианта `variant` эксперимента.
+// Как и при восстановлении сегмента через его создание, прежние правила, процент раскатки, срок вхождения
+// по умолчанию, дата автоматического удаления и метаданные сбрасываются. Сегмент из группы взаимоисключающих
+// сегментов не восстанавливается: распределение по вариантам не учитывает группы.
+func (s *ExperimentService) recoverVariantSegment(ctx context.Context, segment entity.Segment, variant string) error {
+	if segment.GroupID != nil {
+		return customError.ErrSegmentAlreadyExists{ErrBase: customError.ErrBase{
+			Comment: fmt.Sprintf("Segment \"%s\" for variant \"%s\" is deleted but belongs to a group of mutually "+
+				"exclusive segments, so it cannot be recovered as a variant segment", segment.Name, variant),
+			Location: "ExperimentService.recoverVariantSegment",
+		}}
+	}
+
+	if _, err := s.segmentRepository.RecoverSegment(ctx, segment.Name, ""); err != nil {
+		return err
+	}
+	if err := s.segmentRepository.UpdateSegmentRules(ctx, segment.ID, nil); err != nil {
+		return err
+	}
+	if err := s.segmentRepository.UpdateSegmentPercentage(ctx, segment.ID, nil); err != nil {
+		return err
+	}
+	if err := s.segmentRepository.UpdateSegmentLifetime(ctx, segment.ID, nil, nil); err != nil {
+		return err
+	}
+	return s.segmentRepository.UpdateSegmentMetadata(ctx, segment.ID, "", "", nil)
+}
+
+// GetExperimentByName возвращает эксперимент с указанным именем вместе с его вариантами.
+func (s *ExperimentService) GetExperimentByName(ctx context.Context, name string) (entity.Experiment, error) {
+	return s.experimentRepository.GetExperimentByName(ctx, name)
+}
+
+// GetUserVariant возвращает вариант эксперимента с указанным именем, в который распределён пользователь.
+func (s *ExperimentService) GetUserVariant(ctx context.Context, name string, userID int) (entity.UserExperimentVariant, error) {
+	experiment, err := s.experimentRepository.GetExperimentByName(ctx, name)
+	if err != nil {
+		return entity.UserExperimentVariant{}, err
+	}
+
+	user, err := s.userRepository.GetUserByID(ctx, userID)
+	if err != nil {
+		return entity.UserExperimentVariant{}, err
+	}
+	if user.IsDeleted {
+		return entity.UserExperimentVariant{}, customError.ErrUserDeleted{ErrBase: customError.ErrBase{
+			Comment:  fmt.Sprintf("User with id %d is deleted", userID),
+			Location: "ExperimentService.GetUserVariant - s.userRepository.GetUserByID",
+		}}
+	}
+
+	return s.experimentRepository.GetUserVariant(ctx, experiment, userID)
+}
+
+// GetExperimentReport возвращает количество пользователей в каждом из вариантов эксперимента.
+func (s *ExperimentService) GetExperimentReport(ctx context.Context, name string) (entity.ExperimentReport, error) {
+	experiment, err := s.experimentRepository.GetExperimentByName(ctx, name)
+	if err != nil {
+		return entity.ExperimentReport{}, err
+	}
+
+	sizes, err := s.experimentRepository.GetVariantSizes(ctx, experiment.ID)
+	if err != nil {
+		return entity.ExperimentReport{}, err
+	}
+
+	report := entity.ExperimentReport{Experiment: experiment.Name, Variants: sizes}
+	for _, size := range sizes {
+		report.Total += size.Users
+	}
+
+	return report, nil
+}
+
+// experimentVariant возвращает вариант эксперимента, в который распределяется пользователь с указанным ID.
+// Распределение должно совпадать с ExperimentRepository.AssignUsersToExperiment.
+func experimentVariant(experiment entity.Experiment, userID int) entity.ExperimentVariant {
+	var totalWeight int
+	for _, variant := range experiment.Variants {
+		totalWeight += variant.Weight
+	}
+
+	bucket := int(userHash(experiment.Salt, userID) % uint32(totalWeight))
+	for _, variant := range experiment.Variants {
+		if bucket < variant.Weight {
+			return variant
+		}
+		bucket -= variant.Weight
+	}
+	return experiment.Variants[len(experiment.Variants)-1]
+}
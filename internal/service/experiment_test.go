@@ -0,0 +1,99 @@
+package service
+
+import (
+	"avito-rest-api/internal/entity"
+	customError "avito-rest-api/internal/error"
+	"avito-rest-api/internal/repository"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// creatingExperimentRepository - заглушка репозитория экспериментов, хранящая создаваемый эксперимент.
+type creatingExperimentRepository struct {
+	repository.Experiment
+	experiment *entity.Experiment
+}
+
+func (r *creatingExperimentRepository) GetExperimentByName(_ context.Context, _ string) (entity.Experiment, error) {
+	if r.experiment == nil {
+		return entity.Experiment{}, customError.ErrExperimentNotFound{}
+	}
+	return *r.experiment, nil
+}
+
+func (r *creatingExperimentRepository) CreateExperiment(_ context.Context, experiment entity.Experiment) (int, error) {
+	experiment.ID = 7
+	r.experiment = &experiment
+	return experiment.ID, nil
+}
+
+func (r *creatingExperimentRepository) AssignUsersToExperiment(_ context.Context, _ entity.Experiment, _ []int) error {
+	return nil
+}
+
+func TestExperimentService_CreateExperiment_VariantSegments(t *testing.T) {
+	groupID := 3
+	input := ExperimentCreateInput{
+		Name:     "AVITO_CHECKOUT",
+		Variants: []ExperimentVariantInput{{Name: "A", Weight: 50}, {Name: "B", Weight: 50}},
+	}
+
+	testCases := []struct {
+		name                 string
+		segments             []entity.Segment
+		expectedVariantIDs   []int
+		expectedRecovered    []string
+		expectedErr          error
+		expectedErrorComment string
+	}{
+		{
+			name:               "New variant segments",
+			expectedVariantIDs: []int{1, 2},
+		},
+		{
+			name:               "Deleted variant segment is recovered",
+			segments:           []entity.Segment{{ID: 43, Name: "AVITO_CHECKOUT_A", IsDeleted: true}},
+			expectedVariantIDs: []int{43, 2},
+			expectedRecovered:  []string{"AVITO_CHECKOUT_A"},
+		},
+		{
+			name:                 "Existing variant segment",
+			segments:             []entity.Segment{{ID: 44, Name: "AVITO_CHECKOUT_B"}},
+			expectedErr:          customError.ErrSegmentAlreadyExists{},
+			expectedErrorComment: "Segment \"AVITO_CHECKOUT_B\" for variant \"B\" already exists",
+		},
+		{
+			name:        "Deleted variant segment in group",
+			segments:    []entity.Segment{{ID: 43, Name: "AVITO_CHECKOUT_A", IsDeleted: true, GroupID: &groupID}},
+			expectedErr: customError.ErrSegmentAlreadyExists{},
+			expectedErrorComment: "Segment \"AVITO_CHECKOUT_A\" for variant \"A\" is deleted but belongs to a group of " +
+				"mutually exclusive segments, so it cannot be recovered as a variant segment",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Инициализация зависимостей
+			segmentRepo := newFakeSegmentRepository(tc.segments...)
+			s := NewExperimentService(&creatingExperimentRepository{}, segmentRepo, nil, &stubTransactor{})
+
+			// Выполнение
+			experiment, err := s.CreateExperiment(context.Background(), input)
+
+			// Проверка результата: удалённый сегмент варианта восстанавливается, как при создании сегмента
+			if tc.expectedErr != nil {
+				assert.IsType(t, tc.expectedErr, err)
+				assert.Equal(t, tc.expectedErrorComment, err.(customError.ErrSegmentAlreadyExists).Comment)
+				return
+			}
+			assert.NoError(t, err)
+			var variantIDs []int
+			for _, variant := range experiment.Variants {
+				variantIDs = append(variantIDs, variant.SegmentID)
+			}
+			assert.Equal(t, tc.expectedVariantIDs, variantIDs)
+			assert.Equal(t, tc.expectedRecovered, segmentRepo.recovered)
+		})
+	}
+}
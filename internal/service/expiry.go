@@ -0,0 +1,97 @@
+package service
+
+import (
+	"avito-rest-api/internal/entity"
+	"avito-rest-api/internal/repository"
+	"avito-rest-api/internal/webapi"
+	"context"
+	"time"
+)
+
+// defaultExpiryBatchSize - количество вхождений, помечаемых истёкшими в одной транзакции по умолчанию.
+const defaultExpiryBatchSize = 1000
+
+type ExpiryService struct {
+	segmentRepository repository.Segment
+	transactor        repository.Transactor
+	eventPublisher    webapi.EventPublisher
+	batchSize         int
+}
+
+func NewExpiryService(segmentRepository repository.Segment, transactor repository.Transactor, eventPublisher webapi.EventPublisher, batchSize int) *ExpiryService {
+	if batchSize <= 0 {
+		batchSize = defaultExpiryBatchSize
+	}
+	return &ExpiryService{
+		segmentRepository: segmentRepository,
+		transactor:        transactor,
+		eventPublisher:    eventPublisher,
+		batchSize:         batchSize,
+	}
+}
+
+// ExpireMemberships помечает как истёкшие все вхождения пользователей в сегменты, дата выхода из которых
+// уже наступила, и публикует событие `membership.expired` для каждого из них. Вхождения обрабатываются
+// пакетами, каждый пакет - в своей транзакции: если публикация событий не удалась, пометка пакета
+// откатывается, и он будет обработан повторно. Возвращает количество обработанных вхождений.
+func (es *ExpiryService) ExpireMemberships(ctx context.Context) (int, error) {
+	total := 0
+	for {
+		var expired []entity.UserSegmentInformation
+		err := es.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+			var err error
+			expired, err = es.segmentRepository.ExpireMemberships(ctx, es.batchSize)
+			if err != nil || len(expired) == 0 {
+				return err
+			}
+
+			occurredAt := time.Now().Format("15:04:05 02.01.2006")
+			events := make([]entity.MembershipEvent, 0, len(expired))
+			for _, membership := range expired {
+				events = append(events, entity.MembershipEvent{
+					Type:        entity.MembershipEventExpired,
+					InfoID:      membership.InfoID,
+					UserID:      membership.UserID,
+					SegmentID:   membership.SegmentID,
+					SegmentName: membership.Name,
+					StartDate:   membership.StartDate,
+					EndDate:     membership.EndDate,
+					OccurredAt:  occurredAt,
+				})
+			}
+			return es.eventPublisher.PublishMembershipEvents(ctx, events)
+		})
+		if err != nil {
+			return total, err
+		}
+
+		total += len(expired)
+		if len(expired) < es.batchSize {
+			return total, nil
+		}
+	}
+}
+
+// DeleteExpiredSegments удаляет все сегменты, дата автоматического удаления которых уже наступила,
+// так же, как их удаляет DeleteSegment: вхождения пользователей в такие сегменты завершаются.
+// Все сегменты удаляются в одной транзакции. Возвращает количество удалённых сегментов.
+func (es *ExpiryService) DeleteExpiredSegments(ctx context.Context) (int, error) {
+	var names []string
+	err := es.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		names, err = es.segmentRepository.GetExpiredSegmentNames(ctx)
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			if err = es.segmentRepository.DeleteSegment(ctx, name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(names), nil
+}
@@ -0,0 +1,109 @@
+package service
+
+import (
+	"avito-rest-api/internal/entity"
+	"avito-rest-api/internal/repository"
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// expiringSegmentRepository - заглушка репозитория сегментов, хранящая вхождения, ожидающие истечения.
+// Вхождения помечаются истёкшими только при успешном завершении транзакции.
+type expiringSegmentRepository struct {
+	repository.Segment
+	pending []entity.UserSegmentInformation
+	batch   []entity.UserSegmentInformation
+}
+
+func (r *expiringSegmentRepository) ExpireMemberships(_ context.Context, limit int) ([]entity.UserSegmentInformation, error) {
+	r.batch = r.pending
+	if len(r.batch) > limit {
+		r.batch = r.batch[:limit]
+	}
+	return r.batch, nil
+}
+
+// commitBatch фиксирует пометку истёкшими вхождений последнего пакета.
+func (r *expiringSegmentRepository) commitBatch() {
+	r.pending = r.pending[len(r.batch):]
+}
+
+// stubEventPublisher - заглушка, запоминающая опубликованные события.
+type stubEventPublisher struct {
+	events []entity.MembershipEvent
+	err    error
+}
+
+func (p *stubEventPublisher) PublishMembershipEvents(_ context.Context, events []entity.MembershipEvent) error {
+	if p.err != nil {
+		return p.err
+	}
+	p.events = append(p.events, events...)
+	return nil
+}
+
+func TestExpiryService_ExpireMemberships(t *testing.T) {
+	memberships := []entity.UserSegmentInformation{
+		{InfoID: 1, UserID: 16, SegmentID: 43, Name: "AVITO_MUSIC_SERVICE", StartDate: "15:27:32 01.09.2023", EndDate: "10:00:00 25.09.2023"},
+		{InfoID: 2, UserID: 17, SegmentID: 43, Name: "AVITO_MUSIC_SERVICE", StartDate: "15:27:32 01.09.2023", EndDate: "10:00:00 25.09.2023"},
+		{InfoID: 3, UserID: 16, SegmentID: 44, Name: "AVITO_DELIVERY", StartDate: "15:27:32 01.09.2023", EndDate: "11:00:00 25.09.2023"},
+	}
+
+	testCases := []struct {
+		name            string
+		batchSize       int
+		publishErr      error
+		expectedExpired int
+		expectedEvents  []int // InfoID опубликованных событий
+		expectedPending int
+		expectedErr     bool
+	}{
+		{
+			name:            "Ok: several batches",
+			batchSize:       2,
+			expectedExpired: 3,
+			expectedEvents:  []int{1, 2, 3},
+			expectedPending: 0,
+		},
+		{
+			name:            "Ok: single batch",
+			batchSize:       10,
+			expectedExpired: 3,
+			expectedEvents:  []int{1, 2, 3},
+			expectedPending: 0,
+		},
+		{
+			name:            "Publishing failed, memberships stay pending",
+			batchSize:       2,
+			publishErr:      errors.New("broker is unavailable"),
+			expectedExpired: 0,
+			expectedPending: 3,
+			expectedErr:     true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Инициализация зависимостей
+			repo := &expiringSegmentRepository{pending: append([]entity.UserSegmentInformation(nil), memberships...)}
+			publisher := &stubEventPublisher{err: tc.publishErr}
+			es := NewExpiryService(repo, &stubTransactor{onCommit: repo.commitBatch}, publisher, tc.batchSize)
+
+			// Выполнение
+			expired, err := es.ExpireMemberships(context.Background())
+
+			// Проверка результата
+			assert.Equal(t, tc.expectedErr, err != nil)
+			assert.Equal(t, tc.expectedExpired, expired)
+			assert.Len(t, repo.pending, tc.expectedPending)
+			var infoIDs []int
+			for _, event := range publisher.events {
+				assert.Equal(t, entity.MembershipEventExpired, event.Type)
+				infoIDs = append(infoIDs, event.InfoID)
+			}
+			assert.Equal(t, tc.expectedEvents, infoIDs)
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package service
+
+import (
+	"avito-rest-api/internal/entity"
+	"time"
+)
+
+// membershipWindow - период [start, end) вхождения пользователя в сегмент.
+// Нулевое значение `end` означает, что дата выхода из сегмента не определена.
+type membershipWindow struct {
+	start time.Time
+	end   time.Time
+}
+
+// newMembershipWindow возвращает период вхождения `segment` в сегмент. Даты должны быть предварительно
+// провалидированы. Отсутствующая дата начала означает момент `now`.
+func newMembershipWindow(segment entity.UserSegmentInformation, now time.Time) membershipWindow {
+	window := membershipWindow{start: now}
+	if segment.StartDate != "" {
+		window.start, _ = time.Parse("15:04:05 02.01.2006", segment.StartDate)
+	}
+	if segment.EndDate != "" {
+		window.end, _ = time.Parse("15:04:05 02.01.2006", segment.EndDate)
+	}
+	return window
+}
+
+// overlaps сообщает, пересекаются ли периоды `w` и `other`.
+func (w membershipWindow) overlaps(other membershipWindow) bool {
+	startsBeforeOtherEnds := other.end.IsZero() || w.start.Before(other.end)
+	otherStartsBeforeEnds := w.end.IsZero() || other.start.Before(w.end)
+	return startsBeforeOtherEnds && otherStartsBeforeEnds
+}
+
+// membershipNow возвращает текущий момент с точностью до секунды в том же представлении, в котором
+// хранятся и передаются даты вхождения в сегменты: настенное время без часового пояса.
+func membershipNow() time.Time {
+	now, _ := time.Parse("15:04:05 02.01.2006", time.Now().Format("15:04:05 02.01.2006"))
+	return now
+}
@@ -0,0 +1,59 @@
+package service
+
+import (
+	"avito-rest-api/internal/entity"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestMembershipWindow_overlaps(t *testing.T) {
+	now := membershipNow()
+	window := func(startDate, endDate string) membershipWindow {
+		return newMembershipWindow(entity.UserSegmentInformation{StartDate: startDate, EndDate: endDate}, now)
+	}
+
+	testCases := []struct {
+		name     string
+		window   membershipWindow
+		other    membershipWindow
+		expected bool
+	}{
+		{
+			name:     "Both open-ended",
+			window:   window("", ""),
+			other:    window("10:00:00 25.09.2099", ""),
+			expected: true,
+		},
+		{
+			name:     "Scheduled after the current one ends",
+			window:   window("10:00:00 25.09.2099", ""),
+			other:    window("", "10:00:00 25.09.2099"),
+			expected: false,
+		},
+		{
+			name:     "Scheduled before the current one ends",
+			window:   window("10:00:00 24.09.2099", ""),
+			other:    window("", "10:00:00 25.09.2099"),
+			expected: true,
+		},
+		{
+			name:     "Scheduled windows follow each other",
+			window:   window("10:00:00 18.09.2099", "10:00:00 25.09.2099"),
+			other:    window("10:00:00 25.09.2099", "10:00:00 02.10.2099"),
+			expected: false,
+		},
+		{
+			name:     "Scheduled window inside another one",
+			window:   window("10:00:00 18.09.2099", "10:00:00 25.09.2099"),
+			other:    window("10:00:00 01.09.2099", "10:00:00 02.10.2099"),
+			expected: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.window.overlaps(tc.other))
+			assert.Equal(t, tc.expected, tc.other.overlaps(tc.window))
+		})
+	}
+}
@@ -8,7 +8,9 @@ import (
 	entity "avito-rest-api/internal/entity"
 	service "avito-rest-api/internal/service"
 	context "context"
+	io "io"
 	reflect "reflect"
+	time "time"
 
 	gomock "go.uber.org/mock/gomock"
 )
@@ -37,17 +39,17 @@ func (m *MockUser) EXPECT() *MockUserMockRecorder {
 }
 
 // AddUserToSegments mocks base method.
-func (m *MockUser) AddUserToSegments(ctx context.Context, id int, segments []entity.UserSegmentInformation) error {
+func (m *MockUser) AddUserToSegments(ctx context.Context, id int, segments []entity.UserSegmentInformation, replaceExclusive bool) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "AddUserToSegments", ctx, id, segments)
+	ret := m.ctrl.Call(m, "AddUserToSegments", ctx, id, segments, replaceExclusive)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // AddUserToSegments indicates an expected call of AddUserToSegments.
-func (mr *MockUserMockRecorder) AddUserToSegments(ctx, id, segments interface{}) *gomock.Call {
+func (mr *MockUserMockRecorder) AddUserToSegments(ctx, id, segments, replaceExclusive interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddUserToSegments", reflect.TypeOf((*MockUser)(nil).AddUserToSegments), ctx, id, segments)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddUserToSegments", reflect.TypeOf((*MockUser)(nil).AddUserToSegments), ctx, id, segments, replaceExclusive)
 }
 
 // CreateUser mocks base method.
@@ -65,6 +67,20 @@ func (mr *MockUserMockRecorder) CreateUser(ctx, input interface{}) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateUser", reflect.TypeOf((*MockUser)(nil).CreateUser), ctx, input)
 }
 
+// DeleteUser mocks base method.
+func (m *MockUser) DeleteUser(ctx context.Context, id int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteUser", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteUser indicates an expected call of DeleteUser.
+func (mr *MockUserMockRecorder) DeleteUser(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUser", reflect.TypeOf((*MockUser)(nil).DeleteUser), ctx, id)
+}
+
 // DeleteUserFromSegments mocks base method.
 func (m *MockUser) DeleteUserFromSegments(ctx context.Context, id int, segments []string) error {
 	m.ctrl.T.Helper()
@@ -80,33 +96,48 @@ func (mr *MockUserMockRecorder) DeleteUserFromSegments(ctx, id, segments interfa
 }
 
 // GetAllUsers mocks base method.
-func (m *MockUser) GetAllUsers(ctx context.Context) ([]entity.User, error) {
+func (m *MockUser) GetAllUsers(ctx context.Context, input service.UsersInput) (entity.UsersPage, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetAllUsers", ctx)
-	ret0, _ := ret[0].([]entity.User)
+	ret := m.ctrl.Call(m, "GetAllUsers", ctx, input)
+	ret0, _ := ret[0].(entity.UsersPage)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetAllUsers indicates an expected call of GetAllUsers.
-func (mr *MockUserMockRecorder) GetAllUsers(ctx interface{}) *gomock.Call {
+func (mr *MockUserMockRecorder) GetAllUsers(ctx, input interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllUsers", reflect.TypeOf((*MockUser)(nil).GetAllUsers), ctx)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllUsers", reflect.TypeOf((*MockUser)(nil).GetAllUsers), ctx, input)
 }
 
 // GetAllUsersWithSegments mocks base method.
-func (m *MockUser) GetAllUsersWithSegments(ctx context.Context) ([]entity.UserWithSegments, error) {
+func (m *MockUser) GetAllUsersWithSegments(ctx context.Context, input service.PageInput) (entity.UsersWithSegmentsPage, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetAllUsersWithSegments", ctx)
-	ret0, _ := ret[0].([]entity.UserWithSegments)
+	ret := m.ctrl.Call(m, "GetAllUsersWithSegments", ctx, input)
+	ret0, _ := ret[0].(entity.UsersWithSegmentsPage)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetAllUsersWithSegments indicates an expected call of GetAllUsersWithSegments.
-func (mr *MockUserMockRecorder) GetAllUsersWithSegments(ctx interface{}) *gomock.Call {
+func (mr *MockUserMockRecorder) GetAllUsersWithSegments(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllUsersWithSegments", reflect.TypeOf((*MockUser)(nil).GetAllUsersWithSegments), ctx, input)
+}
+
+// GetUserByExternalID mocks base method.
+func (m *MockUser) GetUserByExternalID(ctx context.Context, externalID string) (entity.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserByExternalID", ctx, externalID)
+	ret0, _ := ret[0].(entity.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserByExternalID indicates an expected call of GetUserByExternalID.
+func (mr *MockUserMockRecorder) GetUserByExternalID(ctx, externalID interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllUsersWithSegments", reflect.TypeOf((*MockUser)(nil).GetAllUsersWithSegments), ctx)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserByExternalID", reflect.TypeOf((*MockUser)(nil).GetUserByExternalID), ctx, externalID)
 }
 
 // GetUserByID mocks base method.
@@ -124,6 +155,21 @@ func (mr *MockUserMockRecorder) GetUserByID(ctx, id interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserByID", reflect.TypeOf((*MockUser)(nil).GetUserByID), ctx, id)
 }
 
+// GetUserSegmentsAt mocks base method.
+func (m *MockUser) GetUserSegmentsAt(ctx context.Context, id int, at time.Time) ([]entity.UserSegmentInformation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserSegmentsAt", ctx, id, at)
+	ret0, _ := ret[0].([]entity.UserSegmentInformation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserSegmentsAt indicates an expected call of GetUserSegmentsAt.
+func (mr *MockUserMockRecorder) GetUserSegmentsAt(ctx, id, at interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserSegmentsAt", reflect.TypeOf((*MockUser)(nil).GetUserSegmentsAt), ctx, id, at)
+}
+
 // GetUserSegmentsByUserID mocks base method.
 func (m *MockUser) GetUserSegmentsByUserID(ctx context.Context, id int) ([]entity.UserSegmentInformation, error) {
 	m.ctrl.T.Helper()
@@ -154,6 +200,96 @@ func (mr *MockUserMockRecorder) GetUserWithSegmentsByUserID(ctx, id interface{})
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserWithSegmentsByUserID", reflect.TypeOf((*MockUser)(nil).GetUserWithSegmentsByUserID), ctx, id)
 }
 
+// ImportUsers mocks base method.
+func (m *MockUser) ImportUsers(ctx context.Context, format string, data io.Reader) (entity.UserImportReport, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ImportUsers", ctx, format, data)
+	ret0, _ := ret[0].(entity.UserImportReport)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ImportUsers indicates an expected call of ImportUsers.
+func (mr *MockUserMockRecorder) ImportUsers(ctx, format, data interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ImportUsers", reflect.TypeOf((*MockUser)(nil).ImportUsers), ctx, format, data)
+}
+
+// RestoreUser mocks base method.
+func (m *MockUser) RestoreUser(ctx context.Context, id int) (entity.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RestoreUser", ctx, id)
+	ret0, _ := ret[0].(entity.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RestoreUser indicates an expected call of RestoreUser.
+func (mr *MockUserMockRecorder) RestoreUser(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RestoreUser", reflect.TypeOf((*MockUser)(nil).RestoreUser), ctx, id)
+}
+
+// UpdateUser mocks base method.
+func (m *MockUser) UpdateUser(ctx context.Context, id int, input service.UserUpdateInput) (entity.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateUser", ctx, id, input)
+	ret0, _ := ret[0].(entity.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateUser indicates an expected call of UpdateUser.
+func (mr *MockUserMockRecorder) UpdateUser(ctx, id, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUser", reflect.TypeOf((*MockUser)(nil).UpdateUser), ctx, id, input)
+}
+
+// UpdateUserSegmentEndDate mocks base method.
+func (m *MockUser) UpdateUserSegmentEndDate(ctx context.Context, id int, name, endDate string) (entity.UserSegmentInformation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateUserSegmentEndDate", ctx, id, name, endDate)
+	ret0, _ := ret[0].(entity.UserSegmentInformation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateUserSegmentEndDate indicates an expected call of UpdateUserSegmentEndDate.
+func (mr *MockUserMockRecorder) UpdateUserSegmentEndDate(ctx, id, name, endDate interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUserSegmentEndDate", reflect.TypeOf((*MockUser)(nil).UpdateUserSegmentEndDate), ctx, id, name, endDate)
+}
+
+// UpdateUserSegments mocks base method.
+func (m *MockUser) UpdateUserSegments(ctx context.Context, id int, segmentsToAdd []entity.UserSegmentInformation, segmentsToDelete []string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateUserSegments", ctx, id, segmentsToAdd, segmentsToDelete)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateUserSegments indicates an expected call of UpdateUserSegments.
+func (mr *MockUserMockRecorder) UpdateUserSegments(ctx, id, segmentsToAdd, segmentsToDelete interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUserSegments", reflect.TypeOf((*MockUser)(nil).UpdateUserSegments), ctx, id, segmentsToAdd, segmentsToDelete)
+}
+
+// UpsertUserByExternalID mocks base method.
+func (m *MockUser) UpsertUserByExternalID(ctx context.Context, externalID string, input service.UserCreateInput) (entity.User, bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpsertUserByExternalID", ctx, externalID, input)
+	ret0, _ := ret[0].(entity.User)
+	ret1, _ := ret[1].(bool)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// UpsertUserByExternalID indicates an expected call of UpsertUserByExternalID.
+func (mr *MockUserMockRecorder) UpsertUserByExternalID(ctx, externalID, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpsertUserByExternalID", reflect.TypeOf((*MockUser)(nil).UpsertUserByExternalID), ctx, externalID, input)
+}
+
 // MockSegment is a mock of Segment interface.
 type MockSegment struct {
 	ctrl     *gomock.Controller
@@ -177,6 +313,37 @@ func (m *MockSegment) EXPECT() *MockSegmentMockRecorder {
 	return m.recorder
 }
 
+// AddUsersToSegment mocks base method.
+func (m *MockSegment) AddUsersToSegment(ctx context.Context, name string, input service.SegmentUsersAddInput) (entity.SegmentUsersAddReport, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddUsersToSegment", ctx, name, input)
+	ret0, _ := ret[0].(entity.SegmentUsersAddReport)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddUsersToSegment indicates an expected call of AddUsersToSegment.
+func (mr *MockSegmentMockRecorder) AddUsersToSegment(ctx, name, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddUsersToSegment", reflect.TypeOf((*MockSegment)(nil).AddUsersToSegment), ctx, name, input)
+}
+
+// CreateDerivedSegment mocks base method.
+func (m *MockSegment) CreateDerivedSegment(ctx context.Context, input service.SegmentDeriveInput) (string, int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateDerivedSegment", ctx, input)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(int)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// CreateDerivedSegment indicates an expected call of CreateDerivedSegment.
+func (mr *MockSegmentMockRecorder) CreateDerivedSegment(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateDerivedSegment", reflect.TypeOf((*MockSegment)(nil).CreateDerivedSegment), ctx, input)
+}
+
 // CreateSegment mocks base method.
 func (m *MockSegment) CreateSegment(ctx context.Context, input service.SegmentCreateInput) (string, error) {
 	m.ctrl.T.Helper()
@@ -206,19 +373,34 @@ func (mr *MockSegmentMockRecorder) DeleteSegment(ctx, name interface{}) *gomock.
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteSegment", reflect.TypeOf((*MockSegment)(nil).DeleteSegment), ctx, name)
 }
 
+// DeleteUsersFromSegment mocks base method.
+func (m *MockSegment) DeleteUsersFromSegment(ctx context.Context, name string, input service.SegmentUsersDeleteInput) (entity.SegmentUsersDeleteReport, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteUsersFromSegment", ctx, name, input)
+	ret0, _ := ret[0].(entity.SegmentUsersDeleteReport)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteUsersFromSegment indicates an expected call of DeleteUsersFromSegment.
+func (mr *MockSegmentMockRecorder) DeleteUsersFromSegment(ctx, name, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUsersFromSegment", reflect.TypeOf((*MockSegment)(nil).DeleteUsersFromSegment), ctx, name, input)
+}
+
 // GetAllSegments mocks base method.
-func (m *MockSegment) GetAllSegments(ctx context.Context, sType int) ([]entity.Segment, error) {
+func (m *MockSegment) GetAllSegments(ctx context.Context, sType int, filter entity.SegmentsFilter) ([]entity.Segment, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetAllSegments", ctx, sType)
+	ret := m.ctrl.Call(m, "GetAllSegments", ctx, sType, filter)
 	ret0, _ := ret[0].([]entity.Segment)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetAllSegments indicates an expected call of GetAllSegments.
-func (mr *MockSegmentMockRecorder) GetAllSegments(ctx, sType interface{}) *gomock.Call {
+func (mr *MockSegmentMockRecorder) GetAllSegments(ctx, sType, filter interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllSegments", reflect.TypeOf((*MockSegment)(nil).GetAllSegments), ctx, sType)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllSegments", reflect.TypeOf((*MockSegment)(nil).GetAllSegments), ctx, sType, filter)
 }
 
 // GetSegmentByName mocks base method.
@@ -236,6 +418,263 @@ func (mr *MockSegmentMockRecorder) GetSegmentByName(ctx, name interface{}) *gomo
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSegmentByName", reflect.TypeOf((*MockSegment)(nil).GetSegmentByName), ctx, name)
 }
 
+// GetSegmentUsers mocks base method.
+func (m *MockSegment) GetSegmentUsers(ctx context.Context, name string, input service.SegmentUsersInput) (entity.SegmentUsersPage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSegmentUsers", ctx, name, input)
+	ret0, _ := ret[0].(entity.SegmentUsersPage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSegmentUsers indicates an expected call of GetSegmentUsers.
+func (mr *MockSegmentMockRecorder) GetSegmentUsers(ctx, name, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSegmentUsers", reflect.TypeOf((*MockSegment)(nil).GetSegmentUsers), ctx, name, input)
+}
+
+// PreviewSegment mocks base method.
+func (m *MockSegment) PreviewSegment(ctx context.Context, input service.SegmentCreateInput) (entity.SegmentPreview, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PreviewSegment", ctx, input)
+	ret0, _ := ret[0].(entity.SegmentPreview)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PreviewSegment indicates an expected call of PreviewSegment.
+func (mr *MockSegmentMockRecorder) PreviewSegment(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PreviewSegment", reflect.TypeOf((*MockSegment)(nil).PreviewSegment), ctx, input)
+}
+
+// PurgeSegment mocks base method.
+func (m *MockSegment) PurgeSegment(ctx context.Context, name, format string) (entity.SegmentArchive, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PurgeSegment", ctx, name, format)
+	ret0, _ := ret[0].(entity.SegmentArchive)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PurgeSegment indicates an expected call of PurgeSegment.
+func (mr *MockSegmentMockRecorder) PurgeSegment(ctx, name, format interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PurgeSegment", reflect.TypeOf((*MockSegment)(nil).PurgeSegment), ctx, name, format)
+}
+
+// RenameSegment mocks base method.
+func (m *MockSegment) RenameSegment(ctx context.Context, name, newName string) (entity.Segment, []entity.SegmentNameChange, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RenameSegment", ctx, name, newName)
+	ret0, _ := ret[0].(entity.Segment)
+	ret1, _ := ret[1].([]entity.SegmentNameChange)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// RenameSegment indicates an expected call of RenameSegment.
+func (mr *MockSegmentMockRecorder) RenameSegment(ctx, name, newName interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RenameSegment", reflect.TypeOf((*MockSegment)(nil).RenameSegment), ctx, name, newName)
+}
+
+// UpdateSegment mocks base method.
+func (m *MockSegment) UpdateSegment(ctx context.Context, name string, input service.SegmentUpdateInput) (entity.Segment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateSegment", ctx, name, input)
+	ret0, _ := ret[0].(entity.Segment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateSegment indicates an expected call of UpdateSegment.
+func (mr *MockSegmentMockRecorder) UpdateSegment(ctx, name, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateSegment", reflect.TypeOf((*MockSegment)(nil).UpdateSegment), ctx, name, input)
+}
+
+// UpdateSegmentPercentage mocks base method.
+func (m *MockSegment) UpdateSegmentPercentage(ctx context.Context, name string, percentage *int) (entity.Segment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateSegmentPercentage", ctx, name, percentage)
+	ret0, _ := ret[0].(entity.Segment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateSegmentPercentage indicates an expected call of UpdateSegmentPercentage.
+func (mr *MockSegmentMockRecorder) UpdateSegmentPercentage(ctx, name, percentage interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateSegmentPercentage", reflect.TypeOf((*MockSegment)(nil).UpdateSegmentPercentage), ctx, name, percentage)
+}
+
+// UpdateSegmentRules mocks base method.
+func (m *MockSegment) UpdateSegmentRules(ctx context.Context, name string, rules []entity.SegmentRule) (entity.Segment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateSegmentRules", ctx, name, rules)
+	ret0, _ := ret[0].(entity.Segment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateSegmentRules indicates an expected call of UpdateSegmentRules.
+func (mr *MockSegmentMockRecorder) UpdateSegmentRules(ctx, name, rules interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateSegmentRules", reflect.TypeOf((*MockSegment)(nil).UpdateSegmentRules), ctx, name, rules)
+}
+
+// MockSegmentGroup is a mock of SegmentGroup interface.
+type MockSegmentGroup struct {
+	ctrl     *gomock.Controller
+	recorder *MockSegmentGroupMockRecorder
+}
+
+// MockSegmentGroupMockRecorder is the mock recorder for MockSegmentGroup.
+type MockSegmentGroupMockRecorder struct {
+	mock *MockSegmentGroup
+}
+
+// NewMockSegmentGroup creates a new mock instance.
+func NewMockSegmentGroup(ctrl *gomock.Controller) *MockSegmentGroup {
+	mock := &MockSegmentGroup{ctrl: ctrl}
+	mock.recorder = &MockSegmentGroupMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockSegmentGroup) EXPECT() *MockSegmentGroupMockRecorder {
+	return m.recorder
+}
+
+// CreateSegmentGroup mocks base method.
+func (m *MockSegmentGroup) CreateSegmentGroup(ctx context.Context, input service.SegmentGroupCreateInput) (entity.SegmentGroup, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateSegmentGroup", ctx, input)
+	ret0, _ := ret[0].(entity.SegmentGroup)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateSegmentGroup indicates an expected call of CreateSegmentGroup.
+func (mr *MockSegmentGroupMockRecorder) CreateSegmentGroup(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateSegmentGroup", reflect.TypeOf((*MockSegmentGroup)(nil).CreateSegmentGroup), ctx, input)
+}
+
+// GetSegmentGroupByName mocks base method.
+func (m *MockSegmentGroup) GetSegmentGroupByName(ctx context.Context, name string) (entity.SegmentGroup, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSegmentGroupByName", ctx, name)
+	ret0, _ := ret[0].(entity.SegmentGroup)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSegmentGroupByName indicates an expected call of GetSegmentGroupByName.
+func (mr *MockSegmentGroupMockRecorder) GetSegmentGroupByName(ctx, name interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSegmentGroupByName", reflect.TypeOf((*MockSegmentGroup)(nil).GetSegmentGroupByName), ctx, name)
+}
+
+// UpdateSegmentGroupSegments mocks base method.
+func (m *MockSegmentGroup) UpdateSegmentGroupSegments(ctx context.Context, name string, segments []string) (entity.SegmentGroup, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateSegmentGroupSegments", ctx, name, segments)
+	ret0, _ := ret[0].(entity.SegmentGroup)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateSegmentGroupSegments indicates an expected call of UpdateSegmentGroupSegments.
+func (mr *MockSegmentGroupMockRecorder) UpdateSegmentGroupSegments(ctx, name, segments interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateSegmentGroupSegments", reflect.TypeOf((*MockSegmentGroup)(nil).UpdateSegmentGroupSegments), ctx, name, segments)
+}
+
+// MockExperiment is a mock of Experiment interface.
+type MockExperiment struct {
+	ctrl     *gomock.Controller
+	recorder *MockExperimentMockRecorder
+}
+
+// MockExperimentMockRecorder is the mock recorder for MockExperiment.
+type MockExperimentMockRecorder struct {
+	mock *MockExperiment
+}
+
+// NewMockExperiment creates a new mock instance.
+func NewMockExperiment(ctrl *gomock.Controller) *MockExperiment {
+	mock := &MockExperiment{ctrl: ctrl}
+	mock.recorder = &MockExperimentMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockExperiment) EXPECT() *MockExperimentMockRecorder {
+	return m.recorder
+}
+
+// CreateExperiment mocks base method.
+func (m *MockExperiment) CreateExperiment(ctx context.Context, input service.ExperimentCreateInput) (entity.Experiment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateExperiment", ctx, input)
+	ret0, _ := ret[0].(entity.Experiment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateExperiment indicates an expected call of CreateExperiment.
+func (mr *MockExperimentMockRecorder) CreateExperiment(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateExperiment", reflect.TypeOf((*MockExperiment)(nil).CreateExperiment), ctx, input)
+}
+
+// GetExperimentByName mocks base method.
+func (m *MockExperiment) GetExperimentByName(ctx context.Context, name string) (entity.Experiment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetExperimentByName", ctx, name)
+	ret0, _ := ret[0].(entity.Experiment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetExperimentByName indicates an expected call of GetExperimentByName.
+func (mr *MockExperimentMockRecorder) GetExperimentByName(ctx, name interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetExperimentByName", reflect.TypeOf((*MockExperiment)(nil).GetExperimentByName), ctx, name)
+}
+
+// GetExperimentReport mocks base method.
+func (m *MockExperiment) GetExperimentReport(ctx context.Context, name string) (entity.ExperimentReport, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetExperimentReport", ctx, name)
+	ret0, _ := ret[0].(entity.ExperimentReport)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetExperimentReport indicates an expected call of GetExperimentReport.
+func (mr *MockExperimentMockRecorder) GetExperimentReport(ctx, name interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetExperimentReport", reflect.TypeOf((*MockExperiment)(nil).GetExperimentReport), ctx, name)
+}
+
+// GetUserVariant mocks base method.
+func (m *MockExperiment) GetUserVariant(ctx context.Context, name string, userID int) (entity.UserExperimentVariant, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserVariant", ctx, name, userID)
+	ret0, _ := ret[0].(entity.UserExperimentVariant)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserVariant indicates an expected call of GetUserVariant.
+func (mr *MockExperimentMockRecorder) GetUserVariant(ctx, name, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserVariant", reflect.TypeOf((*MockExperiment)(nil).GetUserVariant), ctx, name, userID)
+}
+
 // MockReport is a mock of Report interface.
 type MockReport struct {
 	ctrl     *gomock.Controller
@@ -260,16 +699,107 @@ func (m *MockReport) EXPECT() *MockReportMockRecorder {
 }
 
 // MakeReport mocks base method.
-func (m *MockReport) MakeReport(ctx context.Context) (entity.ReportCSV, error) {
+func (m *MockReport) MakeReport(ctx context.Context, input service.MakeReportInput) (entity.ReportCSV, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "MakeReport", ctx)
+	ret := m.ctrl.Call(m, "MakeReport", ctx, input)
 	ret0, _ := ret[0].(entity.ReportCSV)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // MakeReport indicates an expected call of MakeReport.
-func (mr *MockReportMockRecorder) MakeReport(ctx interface{}) *gomock.Call {
+func (mr *MockReportMockRecorder) MakeReport(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MakeReport", reflect.TypeOf((*MockReport)(nil).MakeReport), ctx, input)
+}
+
+// MockExpiry is a mock of Expiry interface.
+type MockExpiry struct {
+	ctrl     *gomock.Controller
+	recorder *MockExpiryMockRecorder
+}
+
+// MockExpiryMockRecorder is the mock recorder for MockExpiry.
+type MockExpiryMockRecorder struct {
+	mock *MockExpiry
+}
+
+// NewMockExpiry creates a new mock instance.
+func NewMockExpiry(ctrl *gomock.Controller) *MockExpiry {
+	mock := &MockExpiry{ctrl: ctrl}
+	mock.recorder = &MockExpiryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockExpiry) EXPECT() *MockExpiryMockRecorder {
+	return m.recorder
+}
+
+// DeleteExpiredSegments mocks base method.
+func (m *MockExpiry) DeleteExpiredSegments(ctx context.Context) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteExpiredSegments", ctx)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteExpiredSegments indicates an expected call of DeleteExpiredSegments.
+func (mr *MockExpiryMockRecorder) DeleteExpiredSegments(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteExpiredSegments", reflect.TypeOf((*MockExpiry)(nil).DeleteExpiredSegments), ctx)
+}
+
+// ExpireMemberships mocks base method.
+func (m *MockExpiry) ExpireMemberships(ctx context.Context) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExpireMemberships", ctx)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ExpireMemberships indicates an expected call of ExpireMemberships.
+func (mr *MockExpiryMockRecorder) ExpireMemberships(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExpireMemberships", reflect.TypeOf((*MockExpiry)(nil).ExpireMemberships), ctx)
+}
+
+// MockSegmentPurge is a mock of SegmentPurge interface.
+type MockSegmentPurge struct {
+	ctrl     *gomock.Controller
+	recorder *MockSegmentPurgeMockRecorder
+}
+
+// MockSegmentPurgeMockRecorder is the mock recorder for MockSegmentPurge.
+type MockSegmentPurgeMockRecorder struct {
+	mock *MockSegmentPurge
+}
+
+// NewMockSegmentPurge creates a new mock instance.
+func NewMockSegmentPurge(ctrl *gomock.Controller) *MockSegmentPurge {
+	mock := &MockSegmentPurge{ctrl: ctrl}
+	mock.recorder = &MockSegmentPurgeMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockSegmentPurge) EXPECT() *MockSegmentPurgeMockRecorder {
+	return m.recorder
+}
+
+// PurgeDeletedSegments mocks base method.
+func (m *MockSegmentPurge) PurgeDeletedSegments(ctx context.Context) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PurgeDeletedSegments", ctx)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PurgeDeletedSegments indicates an expected call of PurgeDeletedSegments.
+func (mr *MockSegmentPurgeMockRecorder) PurgeDeletedSegments(ctx interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MakeReport", reflect.TypeOf((*MockReport)(nil).MakeReport), ctx)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PurgeDeletedSegments", reflect.TypeOf((*MockSegmentPurge)(nil).PurgeDeletedSegments), ctx)
 }
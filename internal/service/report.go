@@ -23,8 +23,28 @@ func NewReportService(reportRepository repository.Report, gDrive webapi.GDrive)
 	}
 }
 
-func (rs *ReportService) MakeReport(ctx context.Context) (entity.ReportCSV, error) {
-	report, err := rs.reportRepository.MakeReport(ctx)
+// MakeReportInput - DTO с параметрами формирования отчёта. Отчёт включает
+// записи, период действия которых пересекается с полуинтервалом [From, To).
+// Нулевое значение границы означает, что граница не задана.
+type MakeReportInput struct {
+	From time.Time
+	To   time.Time
+}
+
+func (rs *ReportService) MakeReport(ctx context.Context, input MakeReportInput) (entity.ReportCSV, error) {
+	// Валидация
+	if !input.From.IsZero() && !input.To.IsZero() && !input.From.Before(input.To) {
+		return entity.ReportCSV{}, customError.ErrReportValidationError{ErrBase: customError.ErrBase{
+			Comment: fmt.Sprintf(
+				"Lower bound of the report period (%s) must be earlier than its upper bound (%s)",
+				input.From.Format("15:04:05 02.01.2006"),
+				input.To.Format("15:04:05 02.01.2006"),
+			),
+			Location: "ReportService.MakeReport - validation",
+		}}
+	}
+
+	report, err := rs.reportRepository.MakeReport(ctx, input.From, input.To)
 	if err != nil {
 		return entity.ReportCSV{}, err
 	}
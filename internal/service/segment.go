@@ -4,17 +4,32 @@ import (
 	"avito-rest-api/internal/entity"
 	customError "avito-rest-api/internal/error"
 	"avito-rest-api/internal/repository"
+	"avito-rest-api/internal/webapi"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
 	"fmt"
 	"golang.org/x/net/context"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type SegmentService struct {
 	segmentRepository repository.Segment
+	userRepository    repository.User
+	transactor        repository.Transactor
+	renameGracePeriod time.Duration
+	gDrive            webapi.GDrive
 }
 
-// NewSegmentService инициализирует сервис для сегментов
-func NewSegmentService(segmentRepository repository.Segment) *SegmentService {
-	return &SegmentService{segmentRepository: segmentRepository}
+// NewSegmentService инициализирует сервис для сегментов. Переименованные сегменты находятся по прежнему
+// имени в течение `renameGracePeriod` после переименования. Архивы окончательно удаляемых сегментов
+// загружаются в облачное хранилище `gDrive`.
+func NewSegmentService(segmentRepository repository.Segment, userRepository repository.User, transactor repository.Transactor, renameGracePeriod time.Duration, gDrive webapi.GDrive) *SegmentService {
+	return &SegmentService{segmentRepository: segmentRepository, userRepository: userRepository, transactor: transactor, renameGracePeriod: renameGracePeriod, gDrive: gDrive}
 }
 
 // SegmentCreateInput - DTO для маппинга данных из тела
@@ -22,8 +37,37 @@ func NewSegmentService(segmentRepository repository.Segment) *SegmentService {
 type SegmentCreateInput struct {
 	// Имя сегмента
 	Name string `json:"name" example:"AVITO_MUSIC_SERVICE" validate:"required"`
-	// Необязательное поле, процент пользователей, которое автоматически войдёт в сегмент при его создании
+	// Необязательное поле, процент пользователей, которые автоматически войдут в сегмент - как существующих,
+	// так и созданных позднее. Если заданы правила, процент отсчитывается от удовлетворяющих им пользователей
 	PercentageOfUsersAdded int `json:"percentage" example:"57" minimum:"0" maximum:"100"`
+	// Необязательное поле, правила автоматического вхождения пользователей в сегмент, объединяемые через AND
+	Rules []entity.SegmentRule `json:"rules"`
+	// Необязательное поле, срок вхождения пользователя в сегмент, если при добавлении не указана дата выхода,
+	// в виде "30d", "12h" или "1d12h30m"
+	DefaultTTL string `json:"default_ttl" example:"30d"`
+	// Необязательное поле, дата и время, после которых сегмент автоматически удаляется
+	ActiveUntil string `json:"active_until" example:"00:00:00 01.01.2024"`
+	// Необязательное поле, описание назначения сегмента
+	Description string `json:"description" example:"Скидка 45% на товары маркета"`
+	// Необязательное поле, команда, владеющая сегментом
+	Owner string `json:"owner" example:"market-team"`
+	// Необязательное поле, теги сегмента
+	Tags []string `json:"tags" example:"market,discount"`
+	// Необязательное поле, соль раскатки на процент из ответа пробного запуска: с ней состав сегмента совпадает
+	// с предварительным. По умолчанию новому сегменту генерируется новая соль, а восстановленный сохраняет прежнюю
+	Salt string `json:"salt" example:"9e107d9d372bb6826bd81d3542a419d6"`
+}
+
+// segmentSaltRegexp - формат соли раскатки сегмента: md5-хеш в шестнадцатеричной записи.
+var segmentSaltRegexp = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// newSegmentSalt генерирует соль раскатки нового сегмента в формате segmentSaltRegexp.
+func newSegmentSalt() (string, error) {
+	b := make([]byte, md5.Size)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
 }
 
 // doesSegmentExist используется для проверки существования сегмента опираясь указанное название.
@@ -61,6 +105,35 @@ func (s *SegmentService) CreateSegment(ctx context.Context, input SegmentCreateI
 			Location:    "SegmentService.CreateSegment",
 		}}
 	}
+	if input.PercentageOfUsersAdded < 0 || input.PercentageOfUsersAdded > 100 {
+		return "", customError.ErrSegmentValidationError{ErrBase: customError.ErrBase{
+			Comment:  "Validation of segment's data failed, field \"percentage\" must be an integer number from range [0, 100]",
+			Location: "SegmentService.CreateSegment",
+		}}
+	}
+	rules, err := validateSegmentRules(input.Rules)
+	if err != nil {
+		return "", err
+	}
+	defaultTTL, activeUntil, err := validateSegmentLifetime(input.DefaultTTL, input.ActiveUntil, "SegmentService.CreateSegment")
+	if err != nil {
+		return "", err
+	}
+	tags, err := validateSegmentMetadata(input.Description, input.Owner, input.Tags, "SegmentService.CreateSegment")
+	if err != nil {
+		return "", err
+	}
+	if input.Salt != "" && !segmentSaltRegexp.MatchString(input.Salt) {
+		return "", customError.ErrSegmentValidationError{ErrBase: customError.ErrBase{
+			Comment:  "Validation of segment's data failed, field \"salt\" must be 32 lowercase hexadecimal characters",
+			Location: "SegmentService.CreateSegment",
+		}}
+	}
+	// Нулевой процент означает, что раскатка на процент не задана
+	var percentage *int
+	if input.PercentageOfUsersAdded > 0 {
+		percentage = &input.PercentageOfUsersAdded
+	}
 	// Проверим, существует ли сегмент с таким же именем
 	exist, err := s.doesSegmentExist(ctx, input.Name)
 	if err != nil {
@@ -73,8 +146,26 @@ func (s *SegmentService) CreateSegment(ctx context.Context, input SegmentCreateI
 			return "", err
 		}
 		if isDeleted {
-			// Восстанавливаем сегмент
-			return s.segmentRepository.RecoverSegment(ctx, input.Name)
+			// Восстанавливаем сегмент, заменяя его прежние правила, процент раскатки, срок вхождения
+			// по умолчанию, дату автоматического удаления и метаданные новыми, а соль - указанной
+			var name string
+			err = s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+				name, err = s.segmentRepository.RecoverSegment(ctx, input.Name, input.Salt)
+				if err != nil {
+					return err
+				}
+				if err = s.applySegmentLifetime(ctx, input.Name, defaultTTL, activeUntil); err != nil {
+					return err
+				}
+				if err = s.applySegmentMetadata(ctx, input.Name, input.Description, input.Owner, tags); err != nil {
+					return err
+				}
+				return s.applySegmentSettings(ctx, input.Name, rules, percentage)
+			})
+			if err != nil {
+				return "", err
+			}
+			return name, nil
 		} else {
 			return "", customError.ErrSegmentAlreadyExists{ErrBase: customError.ErrBase{
 				Comment:  fmt.Sprintf("Segment with the given name \"%s\" already exists", input.Name),
@@ -82,17 +173,34 @@ func (s *SegmentService) CreateSegment(ctx context.Context, input SegmentCreateI
 			}}
 		}
 	}
-	// Если сегмент не существует, создадим с нуля
-	name, err := s.segmentRepository.CreateSegment(ctx, entity.Segment{Name: input.Name})
-	if err != nil {
-		return "", err
+	// Если сегмент не существует, создадим с нуля вместе с пользователями, попадающими в него
+	salt := input.Salt
+	if salt == "" {
+		if salt, err = newSegmentSalt(); err != nil {
+			return "", customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+				OriginError: err,
+				Comment:     "Failed to generate segment's salt",
+				Location:    "SegmentService.CreateSegment - newSegmentSalt",
+			}}
+		}
 	}
-	// Если задан случайный процент пользователей для попадания в сегмент, добавим их
-	if input.PercentageOfUsersAdded > 0 && input.PercentageOfUsersAdded <= 100 {
-		err = s.segmentRepository.AddUsersToSegmentByRandomPercent(ctx, input.Name, input.PercentageOfUsersAdded)
+	var name string
+	err = s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		name, err = s.segmentRepository.CreateSegment(ctx, entity.Segment{Name: input.Name, Salt: salt})
 		if err != nil {
-			return "", err
+			return err
 		}
+		if err = s.applySegmentLifetime(ctx, input.Name, defaultTTL, activeUntil); err != nil {
+			return err
+		}
+		if err = s.applySegmentMetadata(ctx, input.Name, input.Description, input.Owner, tags); err != nil {
+			return err
+		}
+		// Если заданы правила или процент раскатки, добавим подходящих пользователей
+		return s.applySegmentSettings(ctx, input.Name, rules, percentage)
+	})
+	if err != nil {
+		return "", err
 	}
 
 	return name, nil
@@ -100,29 +208,27 @@ func (s *SegmentService) CreateSegment(ctx context.Context, input SegmentCreateI
 
 // GetAllSegments используется для получения всех существующих в системе сегментов, учитывая
 // переданный параметр `sType` ("alive" - все сегменты, не помеченные как удалённые,
-// "deleted" - все сегменты, помеченные как удалённые, "both" - абсолютно все сегменты).
-func (s *SegmentService) GetAllSegments(ctx context.Context, sType int) ([]entity.Segment, error) {
-	return s.segmentRepository.GetAllSegments(ctx, sType)
+// "deleted" - все сегменты, помеченные как удалённые, "both" - абсолютно все сегменты) и условия
+// выборки по метаданным `filter`.
+func (s *SegmentService) GetAllSegments(ctx context.Context, sType int, filter entity.SegmentsFilter) ([]entity.Segment, error) {
+	return s.segmentRepository.GetAllSegments(ctx, sType, filter)
 }
 
 // GetSegmentByName используется для получения сегмента по имени и включает в себя проверки
-// на существование сегмента.
+// на существование сегмента. Переименованный сегмент находится и по прежнему имени в течение
+// периода, заданного при создании сервиса.
 func (s *SegmentService) GetSegmentByName(ctx context.Context, name string) (entity.Segment, error) {
-	exist, err := s.doesSegmentExist(ctx, name)
-	if err != nil {
-		return entity.Segment{}, err
-	}
-	if !exist {
-		return entity.Segment{}, customError.ErrSegmentNotFound{ErrBase: customError.ErrBase{
-			Comment:  fmt.Sprintf("Segment with provided name \"%s\" does not exist", name),
-			Location: "SegmentService.GetSegmentByName - doesSegmentExist",
-		}}
-	}
-	isDeleted, err := s.isSegmentDeleted(ctx, name)
+	segment, err := getSegmentByCurrentOrFormerName(ctx, s.segmentRepository, name, s.renameGracePeriod)
 	if err != nil {
+		if _, ok := err.(customError.ErrSegmentNotFound); ok {
+			return entity.Segment{}, customError.ErrSegmentNotFound{ErrBase: customError.ErrBase{
+				Comment:  fmt.Sprintf("Segment with provided name \"%s\" does not exist", name),
+				Location: "SegmentService.GetSegmentByName - doesSegmentExist",
+			}}
+		}
 		return entity.Segment{}, err
 	}
-	if isDeleted {
+	if segment.IsDeleted {
 		return entity.Segment{}, customError.ErrSegmentDeleted{ErrBase: customError.ErrBase{
 			Comment: fmt.Sprintf("Segment with provided name \"%s\" was deleted. "+
 				"If you want work with this segment, recover it by performing create "+
@@ -131,52 +237,440 @@ func (s *SegmentService) GetSegmentByName(ctx context.Context, name string) (ent
 		}}
 	}
 
-	segment, err := s.segmentRepository.GetSegmentByName(ctx, name)
+	return segment, nil
+}
+
+// SegmentUsersInput - DTO с параметрами запроса на получение пользователей сегмента.
+type SegmentUsersInput struct {
+	At     time.Time // Момент времени, нулевое значение - текущий момент
+	Status string    // Статус записей: active (по умолчанию), expired или all
+	Sex    *int      // Пол пользователей
+	MinAge *int      // Минимальный возраст пользователей
+	MaxAge *int      // Максимальный возраст пользователей
+	Cursor string    // Курсор страницы, пустой для первой страницы
+	Limit  int       // Размер страницы, 0 - размер по умолчанию
+}
+
+// GetSegmentUsers возвращает страницу записей о вхождении пользователей в сегмент с указанным именем.
+// Запрос истории допускается и для удалённых сегментов.
+func (s *SegmentService) GetSegmentUsers(ctx context.Context, name string, input SegmentUsersInput) (entity.SegmentUsersPage, error) {
+	// Валидация
+	filter := entity.SegmentUsersFilter{
+		At:     input.At,
+		Status: input.Status,
+		Sex:    input.Sex,
+		MinAge: input.MinAge,
+		MaxAge: input.MaxAge,
+		Limit:  input.Limit,
+	}
+	switch filter.Status {
+	case "":
+		filter.Status = entity.MembershipActive
+	case entity.MembershipActive, entity.MembershipExpired, entity.MembershipAll:
+	default:
+		return entity.SegmentUsersPage{}, customError.ErrSegmentValidationError{ErrBase: customError.ErrBase{
+			Comment: fmt.Sprintf("Invalid status \"%s\", expected one of: %s, %s, %s",
+				filter.Status, entity.MembershipActive, entity.MembershipExpired, entity.MembershipAll),
+			Location: "SegmentService.GetSegmentUsers - validation",
+		}}
+	}
+	if filter.Sex != nil && *filter.Sex != 0 && *filter.Sex != 1 {
+		return entity.SegmentUsersPage{}, customError.ErrSegmentValidationError{ErrBase: customError.ErrBase{
+			Comment:  "Invalid sex filter, expected 0 or 1",
+			Location: "SegmentService.GetSegmentUsers - validation",
+		}}
+	}
+	if filter.MinAge != nil && filter.MaxAge != nil && *filter.MinAge > *filter.MaxAge {
+		return entity.SegmentUsersPage{}, customError.ErrSegmentValidationError{ErrBase: customError.ErrBase{
+			Comment:  "Invalid age filter, minimum age cannot be greater than maximum age",
+			Location: "SegmentService.GetSegmentUsers - validation",
+		}}
+	}
+	if filter.Limit == 0 {
+		filter.Limit = defaultPageLimit
+	}
+	if filter.Limit < 0 || filter.Limit > maxPageLimit {
+		return entity.SegmentUsersPage{}, customError.ErrSegmentValidationError{ErrBase: customError.ErrBase{
+			Comment:  fmt.Sprintf("Invalid limit %d, expected integer number from range [1, %d]", filter.Limit, maxPageLimit),
+			Location: "SegmentService.GetSegmentUsers - validation",
+		}}
+	}
+	afterID, err := decodeCursor(input.Cursor)
 	if err != nil {
-		return entity.Segment{}, err
+		return entity.SegmentUsersPage{}, customError.ErrSegmentValidationError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         fmt.Sprintf("Invalid cursor \"%s\"", input.Cursor),
+			Location:        "SegmentService.GetSegmentUsers - decodeCursor",
+		}}
 	}
+	filter.AfterID = afterID
 
-	return segment, nil
+	segment, err := getSegmentByCurrentOrFormerName(ctx, s.segmentRepository, name, s.renameGracePeriod)
+	if err != nil {
+		return entity.SegmentUsersPage{}, err
+	}
+
+	// Запросим на одну запись больше, чтобы узнать, есть ли следующая страница
+	limit := filter.Limit
+	filter.Limit++
+	members, err := s.segmentRepository.GetSegmentUsers(ctx, segment.ID, filter)
+	if err != nil {
+		return entity.SegmentUsersPage{}, err
+	}
+
+	page := entity.SegmentUsersPage{Users: members}
+	if len(members) > limit {
+		page.Users = members[:limit]
+		page.NextCursor = encodeCursor(page.Users[limit-1].InfoID)
+	}
+	if page.Users == nil {
+		page.Users = []entity.SegmentMember{}
+	}
+
+	return page, nil
 }
 
 // DeleteSegment используется для удаления сегмента, проверяя, существует ли сегмент
-// и не помечен ли он как удалённый.
+// и не помечен ли он как удалённый. Переименованный сегмент можно удалить и по прежнему
+// имени в течение периода, заданного при создании сервиса.
 func (s *SegmentService) DeleteSegment(ctx context.Context, name string) error {
-	exist, err := s.doesSegmentExist(ctx, name)
+	return s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		return s.deleteSegment(ctx, name)
+	})
+}
+
+// deleteSegment содержит проверки и удаление сегмента, выполняемые DeleteSegment в одной транзакции.
+func (s *SegmentService) deleteSegment(ctx context.Context, name string) error {
+	segment, err := getSegmentByCurrentOrFormerName(ctx, s.segmentRepository, name, s.renameGracePeriod)
 	if err != nil {
+		if _, ok := err.(customError.ErrSegmentNotFound); ok {
+			return customError.ErrSegmentNotFound{ErrBase: customError.ErrBase{
+				OriginError: nil,
+				Comment:     fmt.Sprintf("Unable to delete segment \"%s\" because it does not exist", name),
+				Location:    "SegmentService.DeleteSegment - getSegmentByCurrentOrFormerName",
+			}}
+		}
 		return customError.ErrInternalServerError{ErrBase: customError.ErrBase{
 			OriginError: err,
 			Comment:     err.Error(),
-			Location:    "SegmentService.DeleteSegment - s.doesSegmentExist",
+			Location:    "SegmentService.DeleteSegment - getSegmentByCurrentOrFormerName",
 		}}
 	}
-	if !exist {
+	if segment.IsDeleted {
 		return customError.ErrSegmentNotFound{ErrBase: customError.ErrBase{
 			OriginError: nil,
-			Comment:     fmt.Sprintf("Unable to delete segment \"%s\" because it does not exist", name),
-			Location:    "SegmentService.DeleteSegment - s.doesSegmentExist",
+			Comment:     fmt.Sprintf("Unable to delete segment \"%s\" because it does not exist (segment was deleted earlier and was not created again)", name),
+			Location:    "SegmentService.DeleteSegment - isDeleted",
 		}}
 	}
 
-	isDeleted, err := s.isSegmentDeleted(ctx, name)
+	if err = checkSegmentNotInExperiment(ctx, s.segmentRepository, segment, "SegmentService.DeleteSegment"); err != nil {
+		return err
+	}
+	if err = checkSegmentHasNoDependents(ctx, s.segmentRepository, segment, "SegmentService.DeleteSegment"); err != nil {
+		return err
+	}
+
+	err = s.segmentRepository.DeleteSegment(ctx, segment.Name)
 	if err != nil {
-		return customError.ErrInternalServerError{ErrBase: customError.ErrBase{
-			OriginError: err,
-			Comment:     err.Error(),
-			Location:    "SegmentService.DeleteSegment - s.doesSegmentExist",
-		}}
+		return err
 	}
-	if isDeleted {
-		return customError.ErrSegmentNotFound{ErrBase: customError.ErrBase{
-			OriginError: nil,
-			Comment:     fmt.Sprintf("Unable to delete segment \"%s\" because it does not exist (segment was deleted earlier and was not created again)", name),
-			Location:    "SegmentService.DeleteSegment - s.isSegmentDeleted",
+	return nil
+}
+
+// checkSegmentHasNoDependents возвращает ошибку со списком вычисляемых сегментов, в операции которых
+// участвует сегмент: такой сегмент нельзя удалить, пока не удалены зависящие от него сегменты.
+func checkSegmentHasNoDependents(ctx context.Context, segmentRepository repository.Segment, segment entity.Segment, location string) error {
+	dependents, err := segmentRepository.GetDependentSegmentNames(ctx, segment.ID)
+	if err != nil {
+		return err
+	}
+	if len(dependents) > 0 {
+		return customError.ErrSegmentValidationError{ErrBase: customError.ErrBase{
+			Comment: fmt.Sprintf("Segment \"%s\" is a source of computed segments \"%s\", delete them first",
+				segment.Name, strings.Join(dependents, "\", \"")),
+			Location: location + " - checkSegmentHasNoDependents",
 		}}
 	}
+	return nil
+}
 
-	err = s.segmentRepository.DeleteSegment(ctx, name)
+// checkSegmentNotInExperiment возвращает ошибку, если сегмент является вариантом эксперимента:
+// составом таких сегментов управляет эксперимент.
+func checkSegmentNotInExperiment(ctx context.Context, segmentRepository repository.Segment, segment entity.Segment, location string) error {
+	experiment, err := segmentRepository.GetSegmentExperimentName(ctx, segment.ID)
 	if err != nil {
 		return err
 	}
+	if experiment != "" {
+		return customError.ErrSegmentValidationError{ErrBase: customError.ErrBase{
+			Comment:  fmt.Sprintf("Segment \"%s\" is a variant of experiment \"%s\" and its users are managed by the experiment", segment.Name, experiment),
+			Location: location + " - checkSegmentNotInExperiment",
+		}}
+	}
 	return nil
 }
+
+// UpdateSegmentRules заменяет правила автоматического вхождения пользователей в сегмент с указанным
+// именем и приводит состав сегмента в соответствие с новыми правилами: пользователи, не удовлетворяющие
+// правилам, выходят из сегмента, удовлетворяющие - добавляются в него. Пустой список `rules` удаляет
+// правила сегмента, не изменяя его состав (если для сегмента не задан процент раскатки).
+func (s *SegmentService) UpdateSegmentRules(ctx context.Context, name string, rules []entity.SegmentRule) (entity.Segment, error) {
+	rules, err := validateSegmentRules(rules)
+	if err != nil {
+		return entity.Segment{}, err
+	}
+
+	var segment entity.Segment
+	err = s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		// Проверим, что сегмент существует и не удалён
+		current, err := s.GetSegmentByName(ctx, name)
+		if err != nil {
+			return err
+		}
+		if err = checkSegmentNotDerived(current, "SegmentService.UpdateSegmentRules"); err != nil {
+			return err
+		}
+		if err = checkSegmentNotInExperiment(ctx, s.segmentRepository, current, "SegmentService.UpdateSegmentRules"); err != nil {
+			return err
+		}
+		if err := s.applySegmentSettings(ctx, current.Name, rules, current.Percentage); err != nil {
+			return err
+		}
+		segment, err = s.segmentRepository.GetSegmentByName(ctx, current.Name)
+		return err
+	})
+	if err != nil {
+		return entity.Segment{}, err
+	}
+
+	return segment, nil
+}
+
+// UpdateSegmentPercentage изменяет процент пользователей, автоматически попадающих в сегмент с указанным
+// именем. Так как попадание пользователя в сегмент определяется стабильным хешем, при увеличении процента
+// пользователи, уже попавшие в сегмент, остаются в нём, а при уменьшении - выходят только пользователи
+// вне нового процента. Значение nil отключает раскатку, не изменяя состав сегмента.
+func (s *SegmentService) UpdateSegmentPercentage(ctx context.Context, name string, percentage *int) (entity.Segment, error) {
+	if percentage != nil && (*percentage < 0 || *percentage > 100) {
+		return entity.Segment{}, customError.ErrSegmentValidationError{ErrBase: customError.ErrBase{
+			Comment:  "Validation of segment's data failed, field \"percentage\" must be an integer number from range [0, 100]",
+			Location: "SegmentService.UpdateSegmentPercentage",
+		}}
+	}
+
+	var segment entity.Segment
+	err := s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		// Проверим, что сегмент существует и не удалён
+		current, err := s.GetSegmentByName(ctx, name)
+		if err != nil {
+			return err
+		}
+		if err = checkSegmentNotDerived(current, "SegmentService.UpdateSegmentPercentage"); err != nil {
+			return err
+		}
+		if err = checkSegmentNotInExperiment(ctx, s.segmentRepository, current, "SegmentService.UpdateSegmentPercentage"); err != nil {
+			return err
+		}
+		if err := s.applySegmentSettings(ctx, current.Name, current.Rules, percentage); err != nil {
+			return err
+		}
+		segment, err = s.segmentRepository.GetSegmentByName(ctx, current.Name)
+		return err
+	})
+	if err != nil {
+		return entity.Segment{}, err
+	}
+
+	return segment, nil
+}
+
+// applySegmentSettings сохраняет правила `rules` и процент раскатки `percentage` сегмента с указанным
+// именем и, если хотя бы одно из них задано, приводит состав сегмента в соответствие с ними. Сегменту
+// из группы взаимоисключающих сегментов правила и процент раскатки задать нельзя.
+func (s *SegmentService) applySegmentSettings(ctx context.Context, name string, rules []entity.SegmentRule, percentage *int) error {
+	segment, err := s.segmentRepository.GetSegmentByName(ctx, name)
+	if err != nil {
+		return err
+	}
+	isAutomatic := len(rules) > 0 || percentage != nil
+	if !isAutomatic && len(segment.Rules) == 0 && segment.Percentage == nil {
+		return nil
+	}
+	// Пользователи добавляются в автоматические сегменты без проверки групп
+	if isAutomatic && segment.GroupID != nil {
+		return customError.ErrSegmentValidationError{ErrBase: customError.ErrBase{
+			Comment: fmt.Sprintf("Operation was canceled. Segment \"%s\" belongs to a group of mutually exclusive segments "+
+				"and cannot have rules or percentage", name),
+			Location: "SegmentService.applySegmentSettings - validation",
+		}}
+	}
+
+	if err = s.segmentRepository.UpdateSegmentRules(ctx, segment.ID, rules); err != nil {
+		return err
+	}
+	if err = s.segmentRepository.UpdateSegmentPercentage(ctx, segment.ID, percentage); err != nil {
+		return err
+	}
+	if !isAutomatic {
+		return nil
+	}
+
+	segment.Rules = rules
+	segment.Percentage = percentage
+	return s.segmentRepository.SyncSegmentUsers(ctx, segment)
+}
+
+// userHash возвращает стабильный хеш пользователя с указанным ID по соли `salt`.
+// Вычисление должно совпадать с userHashExpr в репозитории.
+func userHash(salt string, userID int) uint32 {
+	sum := md5.Sum([]byte(salt + ":" + strconv.Itoa(userID)))
+	return binary.BigEndian.Uint32(sum[:4])
+}
+
+// rolloutBucket возвращает номер корзины пользователя от 0 до 99 для сегмента с солью `salt`.
+// Пользователь попадает в сегмент с процентом раскатки N, если номер его корзины меньше N.
+func rolloutBucket(salt string, userID int) int {
+	return int(userHash(salt, userID) % 100)
+}
+
+// matchSegment проверяет, подходит ли пользователь сегменту с правилами и (или) процентом раскатки.
+func matchSegment(segment entity.Segment, user entity.User) bool {
+	if segment.Percentage != nil && rolloutBucket(segment.Salt, user.ID) >= *segment.Percentage {
+		return false
+	}
+	return matchSegmentRules(segment.Rules, user)
+}
+
+// validateSegmentRules проверяет корректность правил сегмента и возвращает их
+// в нормализованном виде (числовые значения приводятся к int).
+func validateSegmentRules(rules []entity.SegmentRule) ([]entity.SegmentRule, error) {
+	var normalized []entity.SegmentRule
+	for i, rule := range rules {
+		if strings.HasPrefix(rule.Attribute, entity.SegmentRuleAttributePrefix) {
+			attributeRule, err := validateAttributeRule(i, rule)
+			if err != nil {
+				return nil, err
+			}
+			normalized = append(normalized, attributeRule)
+			continue
+		}
+
+		isNumeric, ok := entity.SegmentRuleAttributes[rule.Attribute]
+		if !ok {
+			return nil, customError.ErrSegmentValidationError{ErrBase: customError.ErrBase{
+				Comment: fmt.Sprintf("Validation of segment's rules failed, rule #%d has unsupported attribute \"%s\", "+
+					"valid values: [\"name\", \"lastname\", \"sex\", \"age\", \"attributes.<key>\"]", i+1, rule.Attribute),
+				Location: "SegmentService.validateSegmentRules",
+			}}
+		}
+
+		isOrdering, ok := entity.SegmentRuleOperators[rule.Operator]
+		if !ok {
+			return nil, customError.ErrSegmentValidationError{ErrBase: customError.ErrBase{
+				Comment: fmt.Sprintf("Validation of segment's rules failed, rule #%d has unsupported operator \"%s\", "+
+					"valid values: [\"=\", \"!=\", \">\", \">=\", \"<\", \"<=\"]", i+1, rule.Operator),
+				Location: "SegmentService.validateSegmentRules",
+			}}
+		}
+		if isOrdering && !isNumeric {
+			return nil, customError.ErrSegmentValidationError{ErrBase: customError.ErrBase{
+				Comment: fmt.Sprintf("Validation of segment's rules failed, rule #%d: attribute \"%s\" "+
+					"can only be compared with operators \"=\" and \"!=\"", i+1, rule.Attribute),
+				Location: "SegmentService.validateSegmentRules",
+			}}
+		}
+
+		if isNumeric {
+			number, ok := ruleNumber(rule.Value)
+			if !ok {
+				return nil, customError.ErrSegmentValidationError{ErrBase: customError.ErrBase{
+					Comment:  fmt.Sprintf("Validation of segment's rules failed, rule #%d: value of attribute \"%s\" must be an integer number", i+1, rule.Attribute),
+					Location: "SegmentService.validateSegmentRules",
+				}}
+			}
+			rule.Value = number
+		} else if _, ok := rule.Value.(string); !ok {
+			return nil, customError.ErrSegmentValidationError{ErrBase: customError.ErrBase{
+				Comment:  fmt.Sprintf("Validation of segment's rules failed, rule #%d: value of attribute \"%s\" must be a string", i+1, rule.Attribute),
+				Location: "SegmentService.validateSegmentRules",
+			}}
+		}
+
+		normalized = append(normalized, rule)
+	}
+	return normalized, nil
+}
+
+// matchSegmentRules проверяет, удовлетворяет ли пользователь всем правилам сегмента.
+// Правила должны быть предварительно провалидированы с помощью validateSegmentRules.
+func matchSegmentRules(rules []entity.SegmentRule, user entity.User) bool {
+	for _, rule := range rules {
+		var matched bool
+		switch rule.Attribute {
+		case "name":
+			matched = compareStrings(user.Name, rule.Operator, rule.Value)
+		case "lastname":
+			matched = compareStrings(user.Lastname, rule.Operator, rule.Value)
+		case "sex":
+			matched = compareNumbers(user.Sex, rule.Operator, rule.Value)
+		case "age":
+			matched = compareNumbers(user.Age, rule.Operator, rule.Value)
+		default:
+			matched = strings.HasPrefix(rule.Attribute, entity.SegmentRuleAttributePrefix) && matchAttributeRule(rule, user.Attributes)
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+func compareStrings(actual string, operator string, value interface{}) bool {
+	expected, _ := value.(string)
+	switch operator {
+	case "=":
+		return actual == expected
+	case "!=":
+		return actual != expected
+	}
+	return false
+}
+
+func compareNumbers(actual int, operator string, value interface{}) bool {
+	expected, ok := ruleNumber(value)
+	if !ok {
+		return false
+	}
+	switch operator {
+	case "=":
+		return actual == expected
+	case "!=":
+		return actual != expected
+	case ">":
+		return actual > expected
+	case ">=":
+		return actual >= expected
+	case "<":
+		return actual < expected
+	case "<=":
+		return actual <= expected
+	}
+	return false
+}
+
+// ruleNumber приводит значение правила к целому числу. Значения, полученные из JSON,
+// имеют тип float64.
+func ruleNumber(value interface{}) (int, bool) {
+	switch v := value.(type) {
+	case int:
+		return v, true
+	case float64:
+		if v != float64(int(v)) {
+			return 0, false
+		}
+		return int(v), true
+	}
+	return 0, false
+}
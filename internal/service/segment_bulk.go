@@ -0,0 +1,298 @@
+package service
+
+import (
+	"avito-rest-api/internal/entity"
+	customError "avito-rest-api/internal/error"
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxBulkUsers - максимальное количество пользователей в одном запросе массового изменения состава сегмента.
+const maxBulkUsers = 100000
+
+// maxIDsInComment - максимальное количество идентификаторов пользователей, перечисляемых в тексте ошибки.
+const maxIDsInComment = 20
+
+// SegmentUsersAddInput - DTO для получения данных о массовом добавлении пользователей в сегмент.
+type SegmentUsersAddInput struct {
+	UserIDs []int  `json:"user_ids" example:"1,2,3"`               // Идентификаторы добавляемых пользователей
+	EndDate string `json:"end_date" example:"15:00:00 31.12.2023"` // Необязательное поле, дата и время выхода пользователей из сегмента
+	DryRun  bool   `json:"-"`                                      // Пробный запуск: изменения не сохраняются
+}
+
+// SegmentUsersDeleteInput - DTO для получения данных о массовом удалении пользователей из сегмента.
+type SegmentUsersDeleteInput struct {
+	UserIDs []int `json:"user_ids" example:"1,2,3"` // Идентификаторы удаляемых пользователей
+	DryRun  bool  `json:"-"`                        // Пробный запуск: изменения не сохраняются
+}
+
+// AddUsersToSegment добавляет пользователей `input.UserIDs` в сегмент с указанным именем. Все пользователи
+// должны существовать и не быть удалёнными, а пользователи, уже входящие в сегмент, пропускаются и
+// перечисляются в результате. Проверки и добавление выполняются в одной транзакции под блокировкой пользователей.
+// Если `input.DryRun` равен true, транзакция откатывается, а результат дополняется списком пользователей,
+// которые были бы добавлены.
+func (s *SegmentService) AddUsersToSegment(ctx context.Context, name string, input SegmentUsersAddInput) (entity.SegmentUsersAddReport, error) {
+	// Валидация
+	if err := validateBulkUserIDs(input.UserIDs, "SegmentService.AddUsersToSegment"); err != nil {
+		return entity.SegmentUsersAddReport{}, err
+	}
+	var endDate *time.Time
+	if input.EndDate != "" {
+		parsed, err := time.Parse("15:04:05 02.01.2006", input.EndDate)
+		if err != nil {
+			return entity.SegmentUsersAddReport{}, customError.ErrSegmentValidationError{ErrBase: customError.ErrBase{
+				OriginError:     err,
+				OriginErrorText: err.Error(),
+				Comment:         fmt.Sprintf("Operation was canceled. Invalid \"end_date\" = %s was provided", input.EndDate),
+				Location:        "SegmentService.AddUsersToSegment - time.Parse",
+			}}
+		}
+		endDate = &parsed
+	}
+
+	var report entity.SegmentUsersAddReport
+	err := withinTransaction(ctx, s.transactor, input.DryRun, func(ctx context.Context) error {
+		segment, err := s.getSegmentForBulkUpdate(ctx, name, "SegmentService.AddUsersToSegment")
+		if err != nil {
+			return err
+		}
+		// Если дата выхода не указана, вхождение ограничивается сроком по умолчанию, заданным у сегмента
+		if endDate == nil {
+			if end, ok := segmentDefaultEnd(segment, membershipNow()); ok {
+				endDate = &end
+			}
+		}
+		if err = s.lockBulkUsers(ctx, input.UserIDs, true, "SegmentService.AddUsersToSegment"); err != nil {
+			return err
+		}
+
+		// Пользователи, уже входящие в сегмент, пропускаются
+		members, err := s.segmentRepository.GetSegmentMemberIDs(ctx, segment.ID, input.UserIDs)
+		if err != nil {
+			return err
+		}
+		sort.Ints(members)
+		report.AlreadyMembers = members
+		userIDs := excludeIDs(input.UserIDs, members)
+
+		// Пользователь не может оказаться одновременно в нескольких сегментах одной группы
+		if segment.GroupID != nil && len(userIDs) > 0 {
+			conflicts, err := s.segmentRepository.GetExclusiveMemberIDs(ctx, segment.ID, *segment.GroupID, userIDs)
+			if err != nil {
+				return err
+			}
+			if len(conflicts) > 0 {
+				sort.Ints(conflicts)
+				return customError.ErrSegmentGroupConflict{ErrBase: customError.ErrBase{
+					Comment: fmt.Sprintf("Operation was canceled. Segment \"%s\" cannot be added to users [%s] "+
+						"since they already have mutually exclusive segments", segment.Name, formatIDs(conflicts)),
+					Location: "SegmentService.AddUsersToSegment - exclusivity",
+				}}
+			}
+		}
+
+		if len(userIDs) == 0 {
+			return nil
+		}
+		added, err := s.segmentRepository.AddUsersToSegment(ctx, segment.ID, userIDs, endDate)
+		if err != nil {
+			return err
+		}
+		report.Added = int(added)
+		if input.DryRun {
+			report.UserIDs = userIDs
+			sort.Ints(report.UserIDs)
+		}
+		return nil
+	})
+	if err != nil {
+		return entity.SegmentUsersAddReport{}, err
+	}
+
+	if report.AlreadyMembers == nil {
+		report.AlreadyMembers = []int{}
+	}
+	if input.DryRun {
+		report.DryRun = true
+		if report.UserIDs == nil {
+			report.UserIDs = []int{}
+		}
+	}
+	return report, nil
+}
+
+// DeleteUsersFromSegment удаляет пользователей `input.UserIDs` из сегмента с указанным именем. Все пользователи
+// должны существовать, а пользователи, не входящие в сегмент, пропускаются и перечисляются в результате.
+// Проверки и удаление выполняются в одной транзакции под блокировкой пользователей. Если `input.DryRun`
+// равен true, транзакция откатывается, а результат дополняется списком пользователей, которые вышли бы из сегмента.
+func (s *SegmentService) DeleteUsersFromSegment(ctx context.Context, name string, input SegmentUsersDeleteInput) (entity.SegmentUsersDeleteReport, error) {
+	userIDs := input.UserIDs
+	// Валидация
+	if err := validateBulkUserIDs(userIDs, "SegmentService.DeleteUsersFromSegment"); err != nil {
+		return entity.SegmentUsersDeleteReport{}, err
+	}
+
+	var report entity.SegmentUsersDeleteReport
+	err := withinTransaction(ctx, s.transactor, input.DryRun, func(ctx context.Context) error {
+		segment, err := s.getSegmentForBulkUpdate(ctx, name, "SegmentService.DeleteUsersFromSegment")
+		if err != nil {
+			return err
+		}
+		if err = s.lockBulkUsers(ctx, userIDs, false, "SegmentService.DeleteUsersFromSegment"); err != nil {
+			return err
+		}
+
+		// Пользователи, не входящие в сегмент, пропускаются
+		members, err := s.segmentRepository.GetSegmentMemberIDs(ctx, segment.ID, userIDs)
+		if err != nil {
+			return err
+		}
+		report.NotMembers = excludeIDs(userIDs, members)
+		sort.Ints(report.NotMembers)
+
+		if len(members) == 0 {
+			return nil
+		}
+		deleted, err := s.segmentRepository.DeleteUsersFromSegment(ctx, segment.ID, members)
+		if err != nil {
+			return err
+		}
+		report.Deleted = int(deleted)
+		if input.DryRun {
+			report.UserIDs = members
+			sort.Ints(report.UserIDs)
+		}
+		return nil
+	})
+	if err != nil {
+		return entity.SegmentUsersDeleteReport{}, err
+	}
+
+	if report.NotMembers == nil {
+		report.NotMembers = []int{}
+	}
+	if input.DryRun {
+		report.DryRun = true
+		if report.UserIDs == nil {
+			report.UserIDs = []int{}
+		}
+	}
+	return report, nil
+}
+
+// getSegmentForBulkUpdate возвращает сегмент с указанным именем, проверяя, что он не удалён
+// и его составом не управляет эксперимент.
+func (s *SegmentService) getSegmentForBulkUpdate(ctx context.Context, name string, location string) (entity.Segment, error) {
+	segment, err := getSegmentByCurrentOrFormerName(ctx, s.segmentRepository, name, s.renameGracePeriod)
+	if err != nil {
+		return entity.Segment{}, err
+	}
+	if segment.IsDeleted {
+		return entity.Segment{}, customError.ErrSegmentDeleted{ErrBase: customError.ErrBase{
+			Comment: fmt.Sprintf("Operation was canceled. Segment \"%s\" does not exist "+
+				"(segment was deleted earlier and was not created again)", name),
+			Location: location + " - isDeleted",
+		}}
+	}
+	if err = checkSegmentNotDerived(segment, location); err != nil {
+		return entity.Segment{}, err
+	}
+	if err = checkSegmentNotInExperiment(ctx, s.segmentRepository, segment, location); err != nil {
+		return entity.Segment{}, err
+	}
+	return segment, nil
+}
+
+// lockBulkUsers блокирует пользователей `userIDs` до конца транзакции и проверяет, что все они существуют,
+// а если `requireActive` равен true, то и не удалены.
+func (s *SegmentService) lockBulkUsers(ctx context.Context, userIDs []int, requireActive bool, location string) error {
+	users, err := s.userRepository.LockUsers(ctx, userIDs)
+	if err != nil {
+		return err
+	}
+
+	found := make([]int, 0, len(users))
+	var deleted []int
+	for _, user := range users {
+		found = append(found, user.ID)
+		if user.IsDeleted {
+			deleted = append(deleted, user.ID)
+		}
+	}
+	if missing := excludeIDs(userIDs, found); len(missing) > 0 {
+		sort.Ints(missing)
+		return customError.ErrUserNotFound{ErrBase: customError.ErrBase{
+			Comment:  fmt.Sprintf("Operation was canceled because users [%s] do not exist", formatIDs(missing)),
+			Location: location + " - s.userRepository.LockUsers",
+		}}
+	}
+	if requireActive && len(deleted) > 0 {
+		return customError.ErrUserDeleted{ErrBase: customError.ErrBase{
+			Comment:  fmt.Sprintf("Operation was canceled because users [%s] are deleted", formatIDs(deleted)),
+			Location: location + " - s.userRepository.LockUsers",
+		}}
+	}
+	return nil
+}
+
+// validateBulkUserIDs проверяет, что список идентификаторов пользователей не пуст, не превышает
+// maxBulkUsers и не содержит повторов.
+func validateBulkUserIDs(userIDs []int, location string) error {
+	if len(userIDs) == 0 {
+		return customError.ErrSegmentValidationError{ErrBase: customError.ErrBase{
+			Comment:  "Operation was canceled. List of users cannot be empty",
+			Location: location + " - validation",
+		}}
+	}
+	if len(userIDs) > maxBulkUsers {
+		return customError.ErrSegmentValidationError{ErrBase: customError.ErrBase{
+			Comment:  fmt.Sprintf("Operation was canceled. List of users cannot contain more than %d users", maxBulkUsers),
+			Location: location + " - validation",
+		}}
+	}
+
+	// Валидируем, что в запросе нет повторяющихся пользователей, за О(N) с использованием map
+	usersInRequest := make(map[int]bool, len(userIDs))
+	for _, id := range userIDs {
+		if usersInRequest[id] {
+			return customError.ErrSegmentValidationError{ErrBase: customError.ErrBase{
+				Comment:  fmt.Sprintf("Operation was canceled. The user %d occurs more than 1 time in the list", id),
+				Location: location + " - validation",
+			}}
+		}
+		usersInRequest[id] = true
+	}
+	return nil
+}
+
+// excludeIDs возвращает идентификаторы из `ids`, отсутствующие в `excluded`, сохраняя их порядок.
+func excludeIDs(ids []int, excluded []int) []int {
+	excludedMap := make(map[int]bool, len(excluded))
+	for _, id := range excluded {
+		excludedMap[id] = true
+	}
+	var result []int
+	for _, id := range ids {
+		if !excludedMap[id] {
+			result = append(result, id)
+		}
+	}
+	return result
+}
+
+// formatIDs перечисляет через запятую не более maxIDsInComment идентификаторов для текста ошибки.
+func formatIDs(ids []int) string {
+	parts := make([]string, 0, maxIDsInComment+1)
+	for i, id := range ids {
+		if i == maxIDsInComment {
+			parts = append(parts, fmt.Sprintf("... and %d more", len(ids)-maxIDsInComment))
+			break
+		}
+		parts = append(parts, strconv.Itoa(id))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,156 @@
+package service
+
+import (
+	"avito-rest-api/internal/entity"
+	customError "avito-rest-api/internal/error"
+	"context"
+	"fmt"
+)
+
+// SegmentDeriveInput - DTO для маппинга данных из тела POST-запроса на создание сегмента
+// операцией над существующими сегментами.
+type SegmentDeriveInput struct {
+	// Имя создаваемого сегмента
+	Name string `json:"name" example:"AVITO_MUSIC_AND_VOICE" validate:"required"`
+	// Операция над исходными сегментами: "union", "intersection" или "difference" (первый сегмент без остальных)
+	Operation string `json:"operation" example:"intersection" enums:"union,intersection,difference" validate:"required"`
+	// Имена исходных сегментов, не менее двух
+	Segments []string `json:"segments" example:"AVITO_MUSIC_SERVICE,AVITO_VOICE_MESSAGES" validate:"required"`
+	// Необязательное поле, если true, сегмент вычисляется при каждом запросе сегментов пользователя,
+	// иначе в него однократно добавляются пользователи, входящие в результат операции на момент создания
+	Live bool `json:"live" example:"false"`
+}
+
+// checkSegmentNotDerived проверяет, что сегмент не является вычисляемым: состав вычисляемого сегмента
+// определяется исходными сегментами и не может изменяться вручную.
+func checkSegmentNotDerived(segment entity.Segment, location string) error {
+	if segment.Derivation == nil {
+		return nil
+	}
+	return customError.ErrSegmentValidationError{ErrBase: customError.ErrBase{
+		Comment: fmt.Sprintf("Operation was canceled. Membership in segment \"%s\" is computed by %s "+
+			"of other segments and cannot be changed manually", segment.Name, segment.Derivation.Operation),
+		Location: location + " - validation",
+	}}
+}
+
+// CreateDerivedSegment создаёт сегмент, состоящий из пользователей, полученных объединением, пересечением
+// или разностью множеств пользователей, входящих на текущий момент в исходные сегменты. Если `input.Live`
+// равен false, пользователи однократно добавляются в новый обычный сегмент, иначе сегмент остаётся
+// вычисляемым и его состав определяется при каждом запросе сегментов пользователя. Возвращает имя
+// созданного сегмента и количество входящих в него пользователей.
+func (s *SegmentService) CreateDerivedSegment(ctx context.Context, input SegmentDeriveInput) (string, int, error) {
+	// Валидация
+	if input.Name == "" {
+		return "", 0, customError.ErrSegmentValidationError{ErrBase: customError.ErrBase{
+			Comment:  "Validation of segment's data failed, field \"name\" cannot be empty",
+			Location: "SegmentService.CreateDerivedSegment - validation",
+		}}
+	}
+	if len(input.Name) > 1000 {
+		return "", 0, customError.ErrSegmentValidationError{ErrBase: customError.ErrBase{
+			Comment:  "Validation of segment's data failed, field \"name\" cannot have length over 1000 symbols",
+			Location: "SegmentService.CreateDerivedSegment - validation",
+		}}
+	}
+	switch input.Operation {
+	case entity.SegmentOperationUnion, entity.SegmentOperationIntersection, entity.SegmentOperationDifference:
+	default:
+		return "", 0, customError.ErrSegmentValidationError{ErrBase: customError.ErrBase{
+			Comment: fmt.Sprintf("Validation of segment's data failed, field \"operation\" must be one of "+
+				"\"%s\", \"%s\", \"%s\"", entity.SegmentOperationUnion, entity.SegmentOperationIntersection, entity.SegmentOperationDifference),
+			Location: "SegmentService.CreateDerivedSegment - validation",
+		}}
+	}
+	if len(input.Segments) < 2 {
+		return "", 0, customError.ErrSegmentValidationError{ErrBase: customError.ErrBase{
+			Comment:  "Validation of segment's data failed, field \"segments\" must contain at least 2 segments",
+			Location: "SegmentService.CreateDerivedSegment - validation",
+		}}
+	}
+
+	var name string
+	var count int
+	err := s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		// Имя не должно быть занято другим сегментом, в том числе удалённым
+		exist, err := s.doesSegmentExist(ctx, input.Name)
+		if err != nil {
+			return err
+		}
+		if exist {
+			return customError.ErrSegmentAlreadyExists{ErrBase: customError.ErrBase{
+				Comment:  fmt.Sprintf("Segment with the given name \"%s\" already exists", input.Name),
+				Location: "SegmentService.CreateDerivedSegment - doesSegmentExist",
+			}}
+		}
+
+		// Проверим исходные сегменты, порядок важен для разности
+		derivation := entity.SegmentDerivation{Operation: input.Operation, SegmentIDs: make([]int, 0, len(input.Segments))}
+		segmentsInRequest := make(map[int]bool, len(input.Segments))
+		for _, sourceName := range input.Segments {
+			source, err := getSegmentByCurrentOrFormerName(ctx, s.segmentRepository, sourceName, s.renameGracePeriod)
+			if err != nil {
+				if _, ok := err.(customError.ErrSegmentNotFound); ok {
+					return customError.ErrSegmentNotFound{ErrBase: customError.ErrBase{
+						Comment:  fmt.Sprintf("Operation was canceled. Segment \"%s\" does not exist", sourceName),
+						Location: "SegmentService.CreateDerivedSegment - s.segmentRepository.GetSegmentByName",
+					}}
+				}
+				return err
+			}
+			if source.IsDeleted {
+				return customError.ErrSegmentDeleted{ErrBase: customError.ErrBase{
+					Comment: fmt.Sprintf("Operation was canceled. Segment \"%s\" does not exist "+
+						"(segment was deleted earlier and was not created again)", sourceName),
+					Location: "SegmentService.CreateDerivedSegment - isDeleted",
+				}}
+			}
+			if source.Derivation != nil {
+				return customError.ErrSegmentValidationError{ErrBase: customError.ErrBase{
+					Comment:  fmt.Sprintf("Validation of segment's data failed, computed segment \"%s\" cannot be used as a source segment", sourceName),
+					Location: "SegmentService.CreateDerivedSegment - validation",
+				}}
+			}
+			// Сегмент может быть указан дважды под текущим и прежним именем
+			if segmentsInRequest[source.ID] {
+				return customError.ErrSegmentValidationError{ErrBase: customError.ErrBase{
+					Comment:  fmt.Sprintf("Validation of segment's data failed, the segment \"%s\" occurs more than 1 time in the list", source.Name),
+					Location: "SegmentService.CreateDerivedSegment - validation",
+				}}
+			}
+			segmentsInRequest[source.ID] = true
+			derivation.SegmentIDs = append(derivation.SegmentIDs, source.ID)
+		}
+
+		userIDs, err := s.segmentRepository.GetDerivedSegmentUserIDs(ctx, derivation)
+		if err != nil {
+			return err
+		}
+
+		if input.Live {
+			name, err = s.segmentRepository.CreateSegment(ctx, entity.Segment{Name: input.Name, Derivation: &derivation})
+			count = len(userIDs)
+			return err
+		}
+
+		// Снимок: новый обычный сегмент с пользователями, входящими в результат операции
+		if name, err = s.segmentRepository.CreateSegment(ctx, entity.Segment{Name: input.Name}); err != nil {
+			return err
+		}
+		if len(userIDs) == 0 {
+			return nil
+		}
+		segment, err := s.segmentRepository.GetSegmentByName(ctx, name)
+		if err != nil {
+			return err
+		}
+		added, err := s.segmentRepository.AddUsersToSegment(ctx, segment.ID, userIDs, nil)
+		count = int(added)
+		return err
+	})
+	if err != nil {
+		return "", 0, err
+	}
+
+	return name, count, nil
+}
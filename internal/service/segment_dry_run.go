@@ -0,0 +1,61 @@
+package service
+
+import (
+	"avito-rest-api/internal/entity"
+	"avito-rest-api/internal/repository"
+	"context"
+	"errors"
+)
+
+// errDryRun возвращается из транзакции пробного запуска, чтобы откатить сделанные в ней изменения.
+var errDryRun = errors.New("dry run")
+
+// withinTransaction выполняет `fn` в транзакции. Если `dryRun` равен true, после успешного выполнения `fn`
+// транзакция откатывается: все проверки и вычисления выполняются так же, как при настоящем запуске,
+// но изменения не сохраняются и не видны другим запросам.
+func withinTransaction(ctx context.Context, transactor repository.Transactor, dryRun bool, fn func(ctx context.Context) error) error {
+	err := transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := fn(ctx); err != nil {
+			return err
+		}
+		if dryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if dryRun && errors.Is(err, errDryRun) {
+		return nil
+	}
+	return err
+}
+
+// PreviewSegment выполняет пробное создание сегмента по данным `input` и возвращает пользователей, которые
+// вошли бы в сегмент по его правилам и проценту раскатки, не сохраняя сегмент, и использованную соль раскатки.
+// Создание сегмента с этой солью даёт тот же состав, если пользователи и их атрибуты не изменились.
+func (s *SegmentService) PreviewSegment(ctx context.Context, input SegmentCreateInput) (entity.SegmentPreview, error) {
+	var preview entity.SegmentPreview
+	err := withinTransaction(ctx, s.transactor, true, func(ctx context.Context) error {
+		name, err := s.CreateSegment(ctx, input)
+		if err != nil {
+			return err
+		}
+		segment, err := s.segmentRepository.GetSegmentByName(ctx, name)
+		if err != nil {
+			return err
+		}
+		userIDs, err := s.segmentRepository.GetAllSegmentMemberIDs(ctx, segment.ID)
+		if err != nil {
+			return err
+		}
+		preview = entity.SegmentPreview{Name: name, UsersCount: len(userIDs), UserIDs: userIDs, Salt: segment.Salt}
+		return nil
+	})
+	if err != nil {
+		return entity.SegmentPreview{}, err
+	}
+
+	if preview.UserIDs == nil {
+		preview.UserIDs = []int{}
+	}
+	return preview, nil
+}
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestWithinTransaction(t *testing.T) {
+	testCases := []struct {
+		name              string
+		dryRun            bool
+		fnErr             error
+		expectedCommitted bool
+		expectedErr       error
+	}{
+		{
+			name:              "Ok",
+			expectedCommitted: true,
+		},
+		{
+			name:   "Dry run is rolled back without error",
+			dryRun: true,
+		},
+		{
+			name:        "Error is returned",
+			fnErr:       errors.New("validation failed"),
+			expectedErr: errors.New("validation failed"),
+		},
+		{
+			name:        "Error is returned on dry run",
+			dryRun:      true,
+			fnErr:       errors.New("validation failed"),
+			expectedErr: errors.New("validation failed"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Инициализация зависимостей
+			transactor := &stubTransactor{}
+			called := false
+
+			// Выполнение
+			err := withinTransaction(context.Background(), transactor, tc.dryRun, func(ctx context.Context) error {
+				called = true
+				return tc.fnErr
+			})
+
+			// Проверка результата
+			assert.True(t, called)
+			assert.Equal(t, tc.expectedErr, err)
+			assert.Equal(t, tc.expectedCommitted, transactor.committed)
+		})
+	}
+}
+
+func TestSegmentService_PreviewSegment_Salt(t *testing.T) {
+	// Инициализация зависимостей
+	input := SegmentCreateInput{Name: "AVITO_ROLLOUT", PercentageOfUsersAdded: 30}
+	previewRepo := newFakeSegmentRepository()
+	createRepo := newFakeSegmentRepository()
+
+	// Выполнение
+	preview, err := NewSegmentService(previewRepo, nil, &stubTransactor{}, 0, nil).PreviewSegment(context.Background(), input)
+	assert.NoError(t, err)
+	input.Salt = preview.Salt
+	_, err = NewSegmentService(createRepo, nil, &stubTransactor{}, 0, nil).CreateSegment(context.Background(), input)
+
+	// Проверка результата: сегмент, созданный с солью пробного запуска, совпадает с предварительным составом
+	assert.NoError(t, err)
+	assert.Regexp(t, segmentSaltRegexp, preview.Salt)
+	assert.Equal(t, preview.Salt, createRepo.segments["AVITO_ROLLOUT"].Salt)
+	assert.Equal(t, preview.UserIDs, createRepo.members[createRepo.segments["AVITO_ROLLOUT"].ID])
+	assert.Equal(t, len(preview.UserIDs), preview.UsersCount)
+}
+
+func TestSegmentService_CreateSegment_Salt(t *testing.T) {
+	testCases := []struct {
+		name        string
+		salt        string
+		expectedErr bool
+	}{
+		{name: "Ok: generated salt"},
+		{name: "Ok: provided salt", salt: "9e107d9d372bb6826bd81d3542a419d6"},
+		{name: "Salt of invalid length", salt: "9e107d9d", expectedErr: true},
+		{name: "Salt with uppercase characters", salt: "9E107D9D372BB6826BD81D3542A419D6", expectedErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Инициализация зависимостей
+			repo := newFakeSegmentRepository()
+			s := NewSegmentService(repo, nil, &stubTransactor{}, 0, nil)
+
+			// Выполнение
+			_, err := s.CreateSegment(context.Background(), SegmentCreateInput{Name: "AVITO_ROLLOUT", PercentageOfUsersAdded: 30, Salt: tc.salt})
+
+			// Проверка результата
+			assert.Equal(t, tc.expectedErr, err != nil)
+			if tc.expectedErr {
+				assert.Empty(t, repo.segments)
+				return
+			}
+			assert.Regexp(t, segmentSaltRegexp, repo.segments["AVITO_ROLLOUT"].Salt)
+			if tc.salt != "" {
+				assert.Equal(t, tc.salt, repo.segments["AVITO_ROLLOUT"].Salt)
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+package service
+
+import (
+	"avito-rest-api/internal/entity"
+	customError "avito-rest-api/internal/error"
+	"avito-rest-api/internal/repository"
+	"context"
+	"fmt"
+)
+
+type SegmentGroupService struct {
+	segmentGroupRepository repository.SegmentGroup
+	segmentRepository      repository.Segment
+	transactor             repository.Transactor
+}
+
+// NewSegmentGroupService инициализирует сервис для групп взаимоисключающих сегментов
+func NewSegmentGroupService(segmentGroupRepository repository.SegmentGroup, segmentRepository repository.Segment, transactor repository.Transactor) *SegmentGroupService {
+	return &SegmentGroupService{
+		segmentGroupRepository: segmentGroupRepository,
+		segmentRepository:      segmentRepository,
+		transactor:             transactor,
+	}
+}
+
+// SegmentGroupCreateInput - DTO для маппинга данных из тела
+// POST-запроса на создание группы сегментов.
+type SegmentGroupCreateInput struct {
+	// Имя группы
+	Name string `json:"name" example:"AVITO_DISCOUNT" validate:"required"`
+	// Наименования сегментов, входящих в группу
+	Segments []string `json:"segments" example:"AVITO_DISCOUNT_30,AVITO_DISCOUNT_50,AVITO_DISCOUNT_70"`
+}
+
+// CreateSegmentGroup создаёт группу взаимоисключающих сегментов из указанных сегментов.
+func (s *SegmentGroupService) CreateSegmentGroup(ctx context.Context, input SegmentGroupCreateInput) (entity.SegmentGroup, error) {
+	if input.Name == "" {
+		return entity.SegmentGroup{}, customError.ErrSegmentGroupValidationError{ErrBase: customError.ErrBase{
+			Comment:  "Validation of segment group's data failed, field \"name\" cannot be empty",
+			Location: "SegmentGroupService.CreateSegmentGroup",
+		}}
+	}
+
+	var group entity.SegmentGroup
+	err := s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		// Проверим, что группа с таким именем ещё не существует
+		_, err := s.segmentGroupRepository.GetSegmentGroupByName(ctx, input.Name)
+		if err == nil {
+			return customError.ErrSegmentGroupAlreadyExists{ErrBase: customError.ErrBase{
+				Comment:  fmt.Sprintf("Segment group with the given name \"%s\" already exists", input.Name),
+				Location: "SegmentGroupService.CreateSegmentGroup - GetSegmentGroupByName",
+			}}
+		}
+		if _, ok := err.(customError.ErrSegmentGroupNotFound); !ok {
+			return err
+		}
+
+		id, err := s.segmentGroupRepository.CreateSegmentGroup(ctx, input.Name)
+		if err != nil {
+			return err
+		}
+		if err = s.setGroupSegments(ctx, id, input.Segments); err != nil {
+			return err
+		}
+
+		group, err = s.segmentGroupRepository.GetSegmentGroupByName(ctx, input.Name)
+		return err
+	})
+	if err != nil {
+		return entity.SegmentGroup{}, err
+	}
+
+	return group, nil
+}
+
+// GetSegmentGroupByName возвращает группу сегментов с указанным именем.
+func (s *SegmentGroupService) GetSegmentGroupByName(ctx context.Context, name string) (entity.SegmentGroup, error) {
+	return s.segmentGroupRepository.GetSegmentGroupByName(ctx, name)
+}
+
+// UpdateSegmentGroupSegments заменяет список сегментов группы с указанным именем.
+func (s *SegmentGroupService) UpdateSegmentGroupSegments(ctx context.Context, name string, segments []string) (entity.SegmentGroup, error) {
+	var group entity.SegmentGroup
+	err := s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		current, err := s.segmentGroupRepository.GetSegmentGroupByName(ctx, name)
+		if err != nil {
+			return err
+		}
+		if err = s.setGroupSegments(ctx, current.ID, segments); err != nil {
+			return err
+		}
+
+		group, err = s.segmentGroupRepository.GetSegmentGroupByName(ctx, name)
+		return err
+	})
+	if err != nil {
+		return entity.SegmentGroup{}, err
+	}
+
+	return group, nil
+}
+
+// setGroupSegments проверяет, что сегменты `segments` существуют, не удалены, не входят в другие группы
+// и пользователи добавляются в них только вручную, а также что ни один пользователь не входит одновременно
+// в несколько из них, и делает их сегментами группы с указанным `id`.
+func (s *SegmentGroupService) setGroupSegments(ctx context.Context, id int, segments []string) error {
+	var segmentIDs []int
+	segmentsInRequest := make(map[string]bool)
+	for _, name := range segments {
+		if segmentsInRequest[name] {
+			return customError.ErrSegmentGroupValidationError{ErrBase: customError.ErrBase{
+				Comment:  fmt.Sprintf("Validation of segment group's data failed, the segment \"%s\" occurs more than 1 time in the list", name),
+				Location: "SegmentGroupService.setGroupSegments - validation",
+			}}
+		}
+		segmentsInRequest[name] = true
+
+		segment, err := s.segmentRepository.GetSegmentByName(ctx, name)
+		if err != nil {
+			if _, ok := err.(customError.ErrSegmentNotFound); ok {
+				return customError.ErrSegmentNotFound{ErrBase: customError.ErrBase{
+					Comment:  fmt.Sprintf("Operation was canceled. Segment \"%s\" does not exist", name),
+					Location: "SegmentGroupService.setGroupSegments - s.segmentRepository.GetSegmentByName",
+				}}
+			}
+			return err
+		}
+		if segment.IsDeleted {
+			return customError.ErrSegmentDeleted{ErrBase: customError.ErrBase{
+				Comment:  fmt.Sprintf("Operation was canceled. Segment \"%s\" was deleted", name),
+				Location: "SegmentGroupService.setGroupSegments - isDeleted",
+			}}
+		}
+		// Взаимоисключаемость не может быть обеспечена для сегментов, состав которых вычисляется
+		if err = checkSegmentNotDerived(segment, "SegmentGroupService.setGroupSegments"); err != nil {
+			return err
+		}
+		// Пользователи добавляются в сегменты с правилами, процентом раскатки и сегменты вариантов экспериментов
+		// без проверки групп, поэтому такие сегменты не могут входить в группу
+		if len(segment.Rules) > 0 || segment.Percentage != nil {
+			return customError.ErrSegmentGroupValidationError{ErrBase: customError.ErrBase{
+				Comment: fmt.Sprintf("Validation of segment group's data failed, membership in segment \"%s\" "+
+					"is managed by its rules and percentage", name),
+				Location: "SegmentGroupService.setGroupSegments - validation",
+			}}
+		}
+		experiment, err := s.segmentRepository.GetSegmentExperimentName(ctx, segment.ID)
+		if err != nil {
+			return err
+		}
+		if experiment != "" {
+			return customError.ErrSegmentGroupValidationError{ErrBase: customError.ErrBase{
+				Comment: fmt.Sprintf("Validation of segment group's data failed, segment \"%s\" is a variant "+
+					"of experiment \"%s\"", name, experiment),
+				Location: "SegmentGroupService.setGroupSegments - validation",
+			}}
+		}
+		if segment.GroupID != nil && *segment.GroupID != id {
+			return customError.ErrSegmentGroupValidationError{ErrBase: customError.ErrBase{
+				Comment:  fmt.Sprintf("Validation of segment group's data failed, segment \"%s\" already belongs to another group", name),
+				Location: "SegmentGroupService.setGroupSegments - validation",
+			}}
+		}
+		segmentIDs = append(segmentIDs, segment.ID)
+	}
+
+	// Взаимоисключаемость должна соблюдаться и для пользователей, уже входящих в сегменты
+	if len(segmentIDs) > 1 {
+		count, err := s.segmentGroupRepository.CountUsersInSeveralSegments(ctx, segmentIDs)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return customError.ErrSegmentGroupConflict{ErrBase: customError.ErrBase{
+				Comment:  fmt.Sprintf("Operation was canceled. %d user(s) currently belong to more than one of the segments of the group", count),
+				Location: "SegmentGroupService.setGroupSegments - CountUsersInSeveralSegments",
+			}}
+		}
+	}
+
+	return s.segmentGroupRepository.SetGroupSegments(ctx, id, segmentIDs)
+}
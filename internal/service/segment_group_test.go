@@ -0,0 +1,129 @@
+package service
+
+import (
+	"avito-rest-api/internal/entity"
+	"avito-rest-api/internal/repository"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// stubSegmentGroupRepository - заглушка репозитория групп, запоминающая сегменты группы.
+type stubSegmentGroupRepository struct {
+	repository.SegmentGroup
+	segmentIDs []int
+}
+
+func (r *stubSegmentGroupRepository) GetSegmentGroupByName(_ context.Context, name string) (entity.SegmentGroup, error) {
+	return entity.SegmentGroup{ID: 3, Name: name}, nil
+}
+
+func (r *stubSegmentGroupRepository) CountUsersInSeveralSegments(_ context.Context, _ []int) (int, error) {
+	return 0, nil
+}
+
+func (r *stubSegmentGroupRepository) SetGroupSegments(_ context.Context, _ int, segmentIDs []int) error {
+	r.segmentIDs = segmentIDs
+	return nil
+}
+
+// newGroupingSegmentRepository возвращает заглушку репозитория с сегментами разных видов.
+func newGroupingSegmentRepository() *fakeSegmentRepository {
+	percentage := 30
+	groupID := 3
+	repo := newFakeSegmentRepository(
+		entity.Segment{ID: 43, Name: "AVITO_DISCOUNT_30"},
+		entity.Segment{ID: 44, Name: "AVITO_DISCOUNT_50", GroupID: &groupID},
+		entity.Segment{ID: 45, Name: "AVITO_ADULTS", Rules: []entity.SegmentRule{{Attribute: "age", Operator: ">=", Value: 18}}},
+		entity.Segment{ID: 46, Name: "AVITO_ROLLOUT", Percentage: &percentage},
+		entity.Segment{ID: 47, Name: "AVITO_CHECKOUT_A"},
+		entity.Segment{ID: 48, Name: "AVITO_VOICE_AND_MAP", Derivation: &entity.SegmentDerivation{Operation: entity.SegmentOperationUnion, SegmentIDs: []int{43, 44}}},
+	)
+	repo.experiments = map[int]string{47: "AVITO_CHECKOUT"}
+	return repo
+}
+
+func TestSegmentGroupService_UpdateSegmentGroupSegments(t *testing.T) {
+	testCases := []struct {
+		name               string
+		segments           []string
+		expectedSegmentIDs []int
+		expectedErr        bool
+	}{
+		{
+			name:               "Ok",
+			segments:           []string{"AVITO_DISCOUNT_30", "AVITO_DISCOUNT_50"},
+			expectedSegmentIDs: []int{43, 44},
+		},
+		{
+			name:        "Segment with rules",
+			segments:    []string{"AVITO_DISCOUNT_30", "AVITO_ADULTS"},
+			expectedErr: true,
+		},
+		{
+			name:        "Segment with percentage",
+			segments:    []string{"AVITO_DISCOUNT_30", "AVITO_ROLLOUT"},
+			expectedErr: true,
+		},
+		{
+			name:        "Experiment variant segment",
+			segments:    []string{"AVITO_DISCOUNT_30", "AVITO_CHECKOUT_A"},
+			expectedErr: true,
+		},
+		{
+			name:        "Computed segment",
+			segments:    []string{"AVITO_DISCOUNT_30", "AVITO_VOICE_AND_MAP"},
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Инициализация зависимостей
+			groupRepo := &stubSegmentGroupRepository{}
+			s := NewSegmentGroupService(groupRepo, newGroupingSegmentRepository(), &stubTransactor{})
+
+			// Выполнение
+			_, err := s.UpdateSegmentGroupSegments(context.Background(), "AVITO_DISCOUNT", tc.segments)
+
+			// Проверка результата
+			assert.Equal(t, tc.expectedErr, err != nil)
+			assert.Equal(t, tc.expectedSegmentIDs, groupRepo.segmentIDs)
+		})
+	}
+}
+
+func TestSegmentService_UpdateSegmentRules_Grouped(t *testing.T) {
+	testCases := []struct {
+		name           string
+		segment        string
+		expectedSynced bool
+		expectedErr    bool
+	}{
+		{
+			name:           "Ok: segment outside of groups",
+			segment:        "AVITO_DISCOUNT_30",
+			expectedSynced: true,
+		},
+		{
+			name:        "Segment of a group",
+			segment:     "AVITO_DISCOUNT_50",
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Инициализация зависимостей
+			repo := newGroupingSegmentRepository()
+			s := NewSegmentService(repo, nil, &stubTransactor{}, 0, nil)
+
+			// Выполнение
+			_, err := s.UpdateSegmentRules(context.Background(), tc.segment, []entity.SegmentRule{{Attribute: "age", Operator: ">=", Value: float64(18)}})
+
+			// Проверка результата
+			assert.Equal(t, tc.expectedErr, err != nil)
+			assert.Equal(t, tc.expectedSynced, len(repo.synced) > 0)
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package service
+
+import (
+	"avito-rest-api/internal/entity"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// TestUserHash фиксирует совпадение хеша пользователя с выражением userHashExpr репозитория, по которому
+// пользователи распределяются при раскатке сегментов и в эксперименты. Ожидаемые значения - результат запроса
+//
+//	select ('x' || substr(md5(<salt>::text || ':' || <user_id>::text), 1, 8))::bit(32)::bigint
+//
+// Значения больше 2^31 проверяют, что хеш читается как беззнаковое число.
+func TestUserHash(t *testing.T) {
+	testCases := []struct {
+		salt            string
+		userID          int
+		expectedHash    uint32
+		expectedBucket  int    // Корзина раскатки: хеш % 100
+		expectedVariant string // Вариант эксперимента с весами A = 3, B = 4: A, если хеш % 7 < 3
+	}{
+		{salt: "", userID: 1, expectedHash: 894966076, expectedBucket: 76, expectedVariant: "B"},
+		{salt: "", userID: 16, expectedHash: 1287614343, expectedBucket: 43, expectedVariant: "A"},
+		{salt: "", userID: 1000000, expectedHash: 2258302755, expectedBucket: 55, expectedVariant: "A"},
+		{salt: "9e107d9d372bb6826bd81d3542a419d6", userID: 1, expectedHash: 1265027724, expectedBucket: 24, expectedVariant: "A"},
+		{salt: "9e107d9d372bb6826bd81d3542a419d6", userID: 16, expectedHash: 2570492764, expectedBucket: 64, expectedVariant: "A"},
+		{salt: "9e107d9d372bb6826bd81d3542a419d6", userID: 1000000, expectedHash: 1082578311, expectedBucket: 11, expectedVariant: "B"},
+		{salt: "d41d8cd98f00b204e9800998ecf8427e", userID: 1, expectedHash: 662453433, expectedBucket: 33, expectedVariant: "B"},
+		{salt: "d41d8cd98f00b204e9800998ecf8427e", userID: 16, expectedHash: 663107351, expectedBucket: 51, expectedVariant: "B"},
+		{salt: "d41d8cd98f00b204e9800998ecf8427e", userID: 1000000, expectedHash: 3209552384, expectedBucket: 84, expectedVariant: "B"},
+		{salt: "соль", userID: 1, expectedHash: 2703265549, expectedBucket: 49, expectedVariant: "B"},
+		{salt: "соль", userID: 16, expectedHash: 181933469, expectedBucket: 69, expectedVariant: "B"},
+		{salt: "соль", userID: 1000000, expectedHash: 4247839074, expectedBucket: 74, expectedVariant: "B"},
+	}
+
+	experiment := entity.Experiment{Variants: []entity.ExperimentVariant{{Name: "A", Weight: 3}, {Name: "B", Weight: 4}}}
+
+	for _, tc := range testCases {
+		experiment.Salt = tc.salt
+		hash := userHash(tc.salt, tc.userID)
+		assert.Equal(t, tc.expectedHash, hash, "salt %q, user %d", tc.salt, tc.userID)
+		assert.Equal(t, tc.expectedBucket, rolloutBucket(tc.salt, tc.userID), "salt %q, user %d", tc.salt, tc.userID)
+		assert.Equal(t, tc.expectedVariant, experimentVariant(experiment, tc.userID).Name, "salt %q, user %d", tc.salt, tc.userID)
+	}
+}
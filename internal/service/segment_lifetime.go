@@ -0,0 +1,144 @@
+package service
+
+import (
+	"avito-rest-api/internal/entity"
+	customError "avito-rest-api/internal/error"
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// maxSegmentTTL - максимальный срок вхождения пользователя в сегмент по умолчанию.
+const maxSegmentTTL = 100 * 365 * 24 * time.Hour
+
+// segmentTTLUnits - единицы, из которых складывается срок вхождения в сегмент, в порядке их следования.
+// Должны совпадать с единицами, в которых срок форматирует репозиторий.
+var segmentTTLUnits = []struct {
+	suffix string
+	unit   time.Duration
+}{
+	{"d", 24 * time.Hour},
+	{"h", time.Hour},
+	{"m", time.Minute},
+	{"s", time.Second},
+}
+
+// parseSegmentTTL разбирает срок вхождения в сегмент вида "30d", "12h" или "1d12h30m": последовательность
+// целых чисел с единицами d (дни), h (часы), m (минуты) и s (секунды), каждая из которых указывается
+// не более одного раза и в этом порядке.
+func parseSegmentTTL(ttl string) (time.Duration, error) {
+	if ttl == "" {
+		return 0, fmt.Errorf("empty duration")
+	}
+	var total time.Duration
+	rest := ttl
+	nextUnit := 0
+	for rest != "" {
+		digits := 0
+		for digits < len(rest) && rest[digits] >= '0' && rest[digits] <= '9' {
+			digits++
+		}
+		if digits == 0 || digits == len(rest) {
+			return 0, fmt.Errorf("expected a number followed by one of the units d, h, m, s in \"%s\"", ttl)
+		}
+		value, err := strconv.ParseInt(rest[:digits], 10, 64)
+		if err != nil {
+			return 0, err
+		}
+
+		suffix := rest[digits : digits+1]
+		found := false
+		for nextUnit < len(segmentTTLUnits) {
+			unit := segmentTTLUnits[nextUnit]
+			nextUnit++
+			if unit.suffix == suffix {
+				if value > int64((maxSegmentTTL-total)/unit.unit) {
+					return 0, fmt.Errorf("duration \"%s\" is too long", ttl)
+				}
+				total += time.Duration(value) * unit.unit
+				found = true
+				break
+			}
+		}
+		if !found {
+			return 0, fmt.Errorf("unexpected unit \"%s\" in \"%s\", units d, h, m, s must follow in this order "+
+				"and occur at most once", suffix, ttl)
+		}
+		rest = rest[digits+1:]
+	}
+	return total, nil
+}
+
+// validateSegmentLifetime проверяет срок вхождения пользователей в сегмент по умолчанию `defaultTTL`
+// и дату автоматического удаления сегмента `activeUntil`. Пустые значения означают, что настройка не задана.
+func validateSegmentLifetime(defaultTTL string, activeUntil string, location string) (*time.Duration, *time.Time, error) {
+	var ttl *time.Duration
+	if defaultTTL != "" {
+		parsed, err := parseSegmentTTL(defaultTTL)
+		if err != nil {
+			return nil, nil, customError.ErrSegmentValidationError{ErrBase: customError.ErrBase{
+				OriginError:     err,
+				OriginErrorText: err.Error(),
+				Comment:         fmt.Sprintf("Validation of segment's data failed, invalid \"default_ttl\" = %s was provided", defaultTTL),
+				Location:        location + " - parseSegmentTTL",
+			}}
+		}
+		if parsed < time.Second {
+			return nil, nil, customError.ErrSegmentValidationError{ErrBase: customError.ErrBase{
+				Comment:  "Validation of segment's data failed, field \"default_ttl\" must be at least 1 second",
+				Location: location + " - validation",
+			}}
+		}
+		ttl = &parsed
+	}
+
+	var until *time.Time
+	if activeUntil != "" {
+		parsed, err := time.Parse("15:04:05 02.01.2006", activeUntil)
+		if err != nil {
+			return nil, nil, customError.ErrSegmentValidationError{ErrBase: customError.ErrBase{
+				OriginError:     err,
+				OriginErrorText: err.Error(),
+				Comment:         fmt.Sprintf("Validation of segment's data failed, invalid \"active_until\" = %s was provided", activeUntil),
+				Location:        location + " - time.Parse",
+			}}
+		}
+		if !parsed.After(membershipNow()) {
+			return nil, nil, customError.ErrSegmentValidationError{ErrBase: customError.ErrBase{
+				Comment:  fmt.Sprintf("Validation of segment's data failed, \"active_until\" = %s is not in the future", activeUntil),
+				Location: location + " - validation",
+			}}
+		}
+		until = &parsed
+	}
+
+	return ttl, until, nil
+}
+
+// applySegmentLifetime сохраняет срок вхождения пользователей по умолчанию и дату автоматического удаления
+// сегмента с указанным именем, заменяя прежние значения.
+func (s *SegmentService) applySegmentLifetime(ctx context.Context, name string, defaultTTL *time.Duration, activeUntil *time.Time) error {
+	segment, err := s.segmentRepository.GetSegmentByName(ctx, name)
+	if err != nil {
+		return err
+	}
+	if defaultTTL == nil && activeUntil == nil && segment.DefaultTTL == "" && segment.ActiveUntil == "" {
+		return nil
+	}
+	return s.segmentRepository.UpdateSegmentLifetime(ctx, segment.ID, defaultTTL, activeUntil)
+}
+
+// segmentDefaultEnd возвращает дату выхода пользователя из сегмента `segment` при вхождении, начинающемся
+// в момент `start`, если дата выхода не указана явно: `start` плюс срок вхождения по умолчанию.
+// Если срок по умолчанию у сегмента не задан, возвращает false.
+func segmentDefaultEnd(segment entity.Segment, start time.Time) (time.Time, bool) {
+	if segment.DefaultTTL == "" {
+		return time.Time{}, false
+	}
+	ttl, err := parseSegmentTTL(segment.DefaultTTL)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return start.Add(ttl), true
+}
@@ -0,0 +1,78 @@
+package service
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestParseSegmentTTL(t *testing.T) {
+	testCases := []struct {
+		name        string
+		ttl         string
+		expected    time.Duration
+		expectedErr bool
+	}{
+		{
+			name:     "Days",
+			ttl:      "30d",
+			expected: 30 * 24 * time.Hour,
+		},
+		{
+			name:     "Several units",
+			ttl:      "1d12h30m15s",
+			expected: 36*time.Hour + 30*time.Minute + 15*time.Second,
+		},
+		{
+			name:     "Units may be skipped",
+			ttl:      "2h45s",
+			expected: 2*time.Hour + 45*time.Second,
+		},
+		{
+			name:        "Empty",
+			ttl:         "",
+			expectedErr: true,
+		},
+		{
+			name:        "Number without unit",
+			ttl:         "30",
+			expectedErr: true,
+		},
+		{
+			name:        "Unit without number",
+			ttl:         "d",
+			expectedErr: true,
+		},
+		{
+			name:        "Unknown unit",
+			ttl:         "2w",
+			expectedErr: true,
+		},
+		{
+			name:        "Units out of order",
+			ttl:         "12h1d",
+			expectedErr: true,
+		},
+		{
+			name:        "Repeated unit",
+			ttl:         "1d1d",
+			expectedErr: true,
+		},
+		{
+			name:        "Too long",
+			ttl:         "36500d1s",
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Выполнение
+			ttl, err := parseSegmentTTL(tc.ttl)
+
+			// Проверка результата
+			assert.Equal(t, tc.expectedErr, err != nil)
+			assert.Equal(t, tc.expected, ttl)
+		})
+	}
+}
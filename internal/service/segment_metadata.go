@@ -0,0 +1,162 @@
+package service
+
+import (
+	"avito-rest-api/internal/entity"
+	customError "avito-rest-api/internal/error"
+	"context"
+	"fmt"
+	"time"
+	"unicode/utf8"
+)
+
+// Ограничения метаданных сегмента
+const (
+	maxSegmentDescriptionLength = 1000 // Максимальная длина описания сегмента
+	maxSegmentOwnerLength       = 255  // Максимальная длина названия команды-владельца сегмента
+	maxSegmentTagLength         = 100  // Максимальная длина тега сегмента
+	maxSegmentTags              = 20   // Максимальное количество тегов сегмента
+)
+
+// SegmentUpdateInput - DTO для маппинга данных из тела PATCH-запроса на изменение сегмента.
+// Не указанные поля не изменяются.
+type SegmentUpdateInput struct {
+	// Описание назначения сегмента, пустая строка удаляет описание
+	Description *string `json:"description" example:"Скидка 45% на товары маркета"`
+	// Команда, владеющая сегментом, пустая строка удаляет владельца
+	Owner *string `json:"owner" example:"market-team"`
+	// Теги сегмента, заменяющие прежние, пустой список удаляет теги
+	Tags *[]string `json:"tags" example:"market,discount"`
+	// Срок вхождения пользователя в сегмент по умолчанию, пустая строка удаляет срок
+	DefaultTTL *string `json:"default_ttl" example:"30d"`
+	// Дата и время автоматического удаления сегмента, пустая строка отменяет автоматическое удаление
+	ActiveUntil *string `json:"active_until" example:"00:00:00 01.01.2024"`
+}
+
+// validateSegmentMetadata проверяет описание `description`, команду-владельца `owner` и теги `tags` сегмента
+// и возвращает теги без повторов в исходном порядке.
+func validateSegmentMetadata(description string, owner string, tags []string, location string) ([]string, error) {
+	if utf8.RuneCountInString(description) > maxSegmentDescriptionLength {
+		return nil, customError.ErrSegmentValidationError{ErrBase: customError.ErrBase{
+			Comment:  fmt.Sprintf("Validation of segment's data failed, field \"description\" cannot have length over %d symbols", maxSegmentDescriptionLength),
+			Location: location + " - validation",
+		}}
+	}
+	if utf8.RuneCountInString(owner) > maxSegmentOwnerLength {
+		return nil, customError.ErrSegmentValidationError{ErrBase: customError.ErrBase{
+			Comment:  fmt.Sprintf("Validation of segment's data failed, field \"owner\" cannot have length over %d symbols", maxSegmentOwnerLength),
+			Location: location + " - validation",
+		}}
+	}
+
+	uniqueTags := make([]string, 0, len(tags))
+	seen := make(map[string]struct{}, len(tags))
+	for _, tag := range tags {
+		if tag == "" || utf8.RuneCountInString(tag) > maxSegmentTagLength {
+			return nil, customError.ErrSegmentValidationError{ErrBase: customError.ErrBase{
+				Comment: fmt.Sprintf("Validation of segment's data failed, tag \"%s\" must be a non-empty string "+
+					"with length up to %d symbols", tag, maxSegmentTagLength),
+				Location: location + " - validation",
+			}}
+		}
+		if _, ok := seen[tag]; ok {
+			continue
+		}
+		seen[tag] = struct{}{}
+		uniqueTags = append(uniqueTags, tag)
+	}
+	if len(uniqueTags) > maxSegmentTags {
+		return nil, customError.ErrSegmentValidationError{ErrBase: customError.ErrBase{
+			Comment:  fmt.Sprintf("Validation of segment's data failed, segment cannot have more than %d tags", maxSegmentTags),
+			Location: location + " - validation",
+		}}
+	}
+
+	return uniqueTags, nil
+}
+
+// applySegmentMetadata сохраняет описание, команду-владельца и теги сегмента с указанным именем,
+// заменяя прежние значения.
+func (s *SegmentService) applySegmentMetadata(ctx context.Context, name string, description string, owner string, tags []string) error {
+	segment, err := s.segmentRepository.GetSegmentByName(ctx, name)
+	if err != nil {
+		return err
+	}
+	if description == "" && owner == "" && len(tags) == 0 &&
+		segment.Description == "" && segment.Owner == "" && len(segment.Tags) == 0 {
+		return nil
+	}
+	return s.segmentRepository.UpdateSegmentMetadata(ctx, segment.ID, description, owner, tags)
+}
+
+// UpdateSegment изменяет описание, команду-владельца, теги, срок вхождения пользователей по умолчанию
+// и дату автоматического удаления сегмента с указанным именем. Поля `input`, равные nil, не изменяются.
+// Возвращает изменённый сегмент.
+func (s *SegmentService) UpdateSegment(ctx context.Context, name string, input SegmentUpdateInput) (entity.Segment, error) {
+	var segment entity.Segment
+	err := s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		// Проверим, что сегмент существует и не удалён
+		current, err := s.GetSegmentByName(ctx, name)
+		if err != nil {
+			return err
+		}
+
+		// Метаданные
+		description, owner, tags := current.Description, current.Owner, current.Tags
+		if input.Description != nil {
+			description = *input.Description
+		}
+		if input.Owner != nil {
+			owner = *input.Owner
+		}
+		if input.Tags != nil {
+			tags = *input.Tags
+		}
+		if tags, err = validateSegmentMetadata(description, owner, tags, "SegmentService.UpdateSegment"); err != nil {
+			return err
+		}
+
+		// Срок вхождения по умолчанию и дата автоматического удаления
+		if input.DefaultTTL != nil || input.ActiveUntil != nil {
+			var newTTL, newActiveUntil string
+			if input.DefaultTTL != nil {
+				newTTL = *input.DefaultTTL
+			}
+			if input.ActiveUntil != nil {
+				newActiveUntil = *input.ActiveUntil
+			}
+			ttl, activeUntil, err := validateSegmentLifetime(newTTL, newActiveUntil, "SegmentService.UpdateSegment")
+			if err != nil {
+				return err
+			}
+			// Не указанные в запросе настройки сохраняют прежние значения
+			if input.DefaultTTL == nil && current.DefaultTTL != "" {
+				parsed, _ := parseSegmentTTL(current.DefaultTTL)
+				ttl = &parsed
+			}
+			if input.ActiveUntil == nil && current.ActiveUntil != "" {
+				parsed, _ := time.Parse("15:04:05 02.01.2006", current.ActiveUntil)
+				activeUntil = &parsed
+			}
+			// Автоматическое удаление сломало бы эксперимент, вариантом которого является сегмент
+			if input.ActiveUntil != nil && activeUntil != nil {
+				if err = checkSegmentNotInExperiment(ctx, s.segmentRepository, current, "SegmentService.UpdateSegment"); err != nil {
+					return err
+				}
+			}
+			if err = s.segmentRepository.UpdateSegmentLifetime(ctx, current.ID, ttl, activeUntil); err != nil {
+				return err
+			}
+		}
+
+		if err = s.segmentRepository.UpdateSegmentMetadata(ctx, current.ID, description, owner, tags); err != nil {
+			return err
+		}
+		segment, err = s.segmentRepository.GetSegmentByName(ctx, current.Name)
+		return err
+	})
+	if err != nil {
+		return entity.Segment{}, err
+	}
+
+	return segment, nil
+}
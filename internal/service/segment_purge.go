@@ -0,0 +1,218 @@
+package service
+
+import (
+	"avito-rest-api/internal/entity"
+	customError "avito-rest-api/internal/error"
+	"avito-rest-api/internal/repository"
+	"avito-rest-api/internal/webapi"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/gocarina/gocsv"
+	"time"
+)
+
+// defaultSegmentPurgeBatchSize - количество сегментов, окончательно удаляемых за один запуск по умолчанию.
+const defaultSegmentPurgeBatchSize = 100
+
+// encodeSegmentArchive кодирует записи о вхождении пользователей в сегмент в формат `format`
+// и возвращает содержимое архива и его MIME-тип.
+func encodeSegmentArchive(rows []entity.SegmentArchiveRow, format string) ([]byte, string, error) {
+	switch format {
+	case entity.SegmentArchiveFormatCSV:
+		content, err := gocsv.MarshalBytes(&rows)
+		return content, "text/csv", err
+	case entity.SegmentArchiveFormatNDJSON:
+		var buf bytes.Buffer
+		encoder := json.NewEncoder(&buf)
+		for _, row := range rows {
+			if err := encoder.Encode(row); err != nil {
+				return nil, "", err
+			}
+		}
+		return buf.Bytes(), "application/x-ndjson", nil
+	default:
+		return nil, "", fmt.Errorf("unknown archive format \"%s\"", format)
+	}
+}
+
+// validateSegmentArchiveFormat проверяет формат архива `format` и возвращает его, пустой формат означает CSV.
+func validateSegmentArchiveFormat(format string, location string) (string, error) {
+	switch format {
+	case "":
+		return entity.SegmentArchiveFormatCSV, nil
+	case entity.SegmentArchiveFormatCSV, entity.SegmentArchiveFormatNDJSON:
+		return format, nil
+	default:
+		return "", customError.ErrSegmentValidationError{ErrBase: customError.ErrBase{
+			Comment: fmt.Sprintf("Invalid archive format \"%s\" was provided, valid values: [\"%s\", \"%s\"]",
+				format, entity.SegmentArchiveFormatCSV, entity.SegmentArchiveFormatNDJSON),
+			Location: location + " - validation",
+		}}
+	}
+}
+
+// purgeSegment архивирует все записи о вхождении пользователей в удалённый сегмент с указанным именем
+// в формате `format` и окончательно удаляет сегмент. Архив загружается в облачное хранилище отчётов,
+// а если оно не настроено, возвращается в результате; если `requireStorage` равен true, без хранилища
+// сегмент не удаляется. Должна вызываться в транзакции: если загрузка архива или удаление не удались,
+// сегмент остаётся удалённым логически, а повторная архивация перезаписывает файл архива.
+func purgeSegment(ctx context.Context, segmentRepository repository.Segment, gDrive webapi.GDrive, name string, format string, requireStorage bool) (entity.SegmentArchive, error) {
+	segment, err := segmentRepository.GetSegmentByName(ctx, name)
+	if err != nil {
+		return entity.SegmentArchive{}, err
+	}
+	// Блокировка упорядочивает удаление с конкурирующим восстановлением сегмента,
+	// после неё сегмент перечитывается
+	if err = segmentRepository.LockSegment(ctx, segment.ID); err != nil {
+		return entity.SegmentArchive{}, err
+	}
+	if segment, err = segmentRepository.GetSegmentByName(ctx, name); err != nil {
+		return entity.SegmentArchive{}, err
+	}
+	if !segment.IsDeleted {
+		return entity.SegmentArchive{}, customError.ErrSegmentValidationError{ErrBase: customError.ErrBase{
+			Comment:  fmt.Sprintf("Operation was canceled. Segment \"%s\" must be deleted before it is purged", name),
+			Location: "purgeSegment - validation",
+		}}
+	}
+	experiment, err := segmentRepository.GetSegmentExperimentName(ctx, segment.ID)
+	if err != nil {
+		return entity.SegmentArchive{}, err
+	}
+	if experiment != "" {
+		return entity.SegmentArchive{}, customError.ErrSegmentValidationError{ErrBase: customError.ErrBase{
+			Comment:  fmt.Sprintf("Operation was canceled. Segment \"%s\" is a variant of experiment \"%s\" and cannot be purged", name, experiment),
+			Location: "purgeSegment - validation",
+		}}
+	}
+	if err = checkSegmentHasNoDependents(ctx, segmentRepository, segment, "purgeSegment"); err != nil {
+		return entity.SegmentArchive{}, err
+	}
+	if requireStorage && !gDrive.IsSet() {
+		return entity.SegmentArchive{}, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			Comment:  fmt.Sprintf("Segment \"%s\" was not purged because archive storage is not configured", name),
+			Location: "purgeSegment - gDrive.IsSet",
+		}}
+	}
+
+	rows, err := segmentRepository.GetSegmentArchiveRows(ctx, segment.ID)
+	if err != nil {
+		return entity.SegmentArchive{}, err
+	}
+	content, mimeType, err := encodeSegmentArchive(rows, format)
+	if err != nil {
+		return entity.SegmentArchive{}, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         fmt.Sprintf("Failed to encode archive of segment \"%s\"", name),
+			Location:        "purgeSegment - encodeSegmentArchive",
+		}}
+	}
+
+	archive := entity.SegmentArchive{Name: segment.Name, Format: format, ArchivedRows: len(rows)}
+	if gDrive.IsSet() {
+		// Идентификатор в имени файла не даёт архивам сегментов с одинаковым именем перезаписать друг друга
+		archive.Archive, err = gDrive.UploadArchiveFile(ctx, fmt.Sprintf("segment_archive_%d_%s.%s", segment.ID, segment.Name, format), mimeType, content)
+		if err != nil {
+			return entity.SegmentArchive{}, customError.ErrInternalServerError{ErrBase: customError.ErrBase{
+				OriginError:     err,
+				OriginErrorText: err.Error(),
+				Comment:         fmt.Sprintf("Failed to upload archive of segment \"%s\", segment was not purged", name),
+				Location:        "purgeSegment - gDrive.UploadArchiveFile",
+			}}
+		}
+	} else { // Возврат архива прямо в теле ответа
+		archive.Archive = string(content)
+	}
+
+	if err = segmentRepository.PurgeSegment(ctx, segment.ID); err != nil {
+		return entity.SegmentArchive{}, err
+	}
+	return archive, nil
+}
+
+// PurgeSegment архивирует историю вхождения пользователей в удалённый сегмент с указанным именем в формате
+// `format` ("csv" или "ndjson", по умолчанию "csv") и окончательно удаляет сегмент вместе с этой историей.
+// Архив загружается в облачное хранилище отчётов, а если оно не настроено, возвращается в результате.
+func (s *SegmentService) PurgeSegment(ctx context.Context, name string, format string) (entity.SegmentArchive, error) {
+	format, err := validateSegmentArchiveFormat(format, "SegmentService.PurgeSegment")
+	if err != nil {
+		return entity.SegmentArchive{}, err
+	}
+
+	var archive entity.SegmentArchive
+	err = s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		archive, err = purgeSegment(ctx, s.segmentRepository, s.gDrive, name, format, false)
+		return err
+	})
+	if err != nil {
+		return entity.SegmentArchive{}, err
+	}
+
+	return archive, nil
+}
+
+type SegmentPurgeService struct {
+	segmentRepository repository.Segment
+	transactor        repository.Transactor
+	gDrive            webapi.GDrive
+	retention         time.Duration
+	archiveFormat     string
+	batchSize         int
+}
+
+// NewSegmentPurgeService инициализирует сервис окончательного удаления сегментов: удалённые сегменты
+// окончательно удаляются через `retention` после удаления с архивацией в формате `archiveFormat`
+// в облачное хранилище `gDrive`. Если `retention` не больше нуля, удалённые сегменты хранятся бессрочно.
+func NewSegmentPurgeService(segmentRepository repository.Segment, transactor repository.Transactor, gDrive webapi.GDrive, retention time.Duration, archiveFormat string, batchSize int) *SegmentPurgeService {
+	if batchSize <= 0 {
+		batchSize = defaultSegmentPurgeBatchSize
+	}
+	return &SegmentPurgeService{
+		segmentRepository: segmentRepository,
+		transactor:        transactor,
+		gDrive:            gDrive,
+		retention:         retention,
+		archiveFormat:     archiveFormat,
+		batchSize:         batchSize,
+	}
+}
+
+// PurgeDeletedSegments окончательно удаляет сегменты, удалённые раньше, чем `retention` назад,
+// предварительно архивируя историю вхождения в них пользователей в облачное хранилище. За один вызов
+// обрабатывается не больше `batchSize` сегментов, каждый - в своей транзакции: сегмент, который не удалось
+// архивировать или удалить, остаётся удалённым логически, а остальные сегменты пакета обрабатываются.
+// Возвращает количество окончательно удалённых сегментов и первую возникшую ошибку.
+func (ps *SegmentPurgeService) PurgeDeletedSegments(ctx context.Context) (int, error) {
+	if ps.retention <= 0 {
+		return 0, nil
+	}
+	format, err := validateSegmentArchiveFormat(ps.archiveFormat, "SegmentPurgeService.PurgeDeletedSegments")
+	if err != nil {
+		return 0, err
+	}
+
+	names, err := ps.segmentRepository.GetPurgeableSegmentNames(ctx, ps.retention, ps.batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	var firstErr error
+	for _, name := range names {
+		err = ps.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+			_, err := purgeSegment(ctx, ps.segmentRepository, ps.gDrive, name, format, true)
+			return err
+		})
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		purged++
+	}
+	return purged, firstErr
+}
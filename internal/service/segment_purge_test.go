@@ -0,0 +1,218 @@
+package service
+
+import (
+	"avito-rest-api/internal/entity"
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// stubGDrive - заглушка облачного хранилища, запоминающая загруженные архивы.
+type stubGDrive struct {
+	set       bool
+	uploadErr error
+	files     map[string]string
+}
+
+func (g *stubGDrive) UploadCSVFile(ctx context.Context, name string, data []byte) (string, error) {
+	return g.UploadArchiveFile(ctx, name, "text/csv", data)
+}
+
+func (g *stubGDrive) UploadArchiveFile(_ context.Context, name string, _ string, data []byte) (string, error) {
+	if g.uploadErr != nil {
+		return "", g.uploadErr
+	}
+	g.files[name] = string(data)
+	return "https://drive.google.com/" + name, nil
+}
+
+func (g *stubGDrive) DeleteFile(_ context.Context, _ string) error {
+	return nil
+}
+
+func (g *stubGDrive) GetAllFilenames(_ context.Context) ([]string, error) {
+	return nil, nil
+}
+
+func (g *stubGDrive) IsSet() bool {
+	return g.set
+}
+
+// newPurgingSegmentRepository возвращает заглушку репозитория с удалёнными и неудалёнными сегментами.
+func newPurgingSegmentRepository() *fakeSegmentRepository {
+	repo := newFakeSegmentRepository(
+		entity.Segment{ID: 43, Name: "AVITO_MUSIC_SERVICE", IsDeleted: true},
+		entity.Segment{ID: 44, Name: "AVITO_DELIVERY", IsDeleted: true},
+		entity.Segment{ID: 45, Name: "AVITO_VOICE"},
+		entity.Segment{ID: 46, Name: "AVITO_MAP", IsDeleted: true},
+	)
+	repo.archiveRows = map[int][]entity.SegmentArchiveRow{
+		43: {
+			{InfoID: 1, UserID: 16, SegmentID: 43, SegmentName: "AVITO_MUSIC_SERVICE", StartDate: "15:27:32 01.09.2023", EndDate: "10:00:00 25.09.2023", EndReason: "removed"},
+			{InfoID: 2, UserID: 17, SegmentID: 43, SegmentName: "AVITO_MUSIC_SERVICE", StartDate: "15:27:32 01.09.2023"},
+		},
+	}
+	repo.dependents = map[int][]string{46: {"AVITO_VOICE_AND_MAP"}}
+	repo.purgeable = []string{"AVITO_MUSIC_SERVICE", "AVITO_DELIVERY"}
+	return repo
+}
+
+func TestSegmentService_PurgeSegment(t *testing.T) {
+	testCases := []struct {
+		name            string
+		segmentName     string
+		format          string
+		gDriveSet       bool
+		expectedArchive entity.SegmentArchive
+		expectedPurged  []int
+		expectedErr     bool
+	}{
+		{
+			name:        "Ok: CSV inline",
+			segmentName: "AVITO_MUSIC_SERVICE",
+			expectedArchive: entity.SegmentArchive{
+				Name:         "AVITO_MUSIC_SERVICE",
+				Format:       "csv",
+				ArchivedRows: 2,
+				Archive: "information_id,user_id,segment_id,segment_name,start_date,end_date,end_reason\n" +
+					"1,16,43,AVITO_MUSIC_SERVICE,15:27:32 01.09.2023,10:00:00 25.09.2023,removed\n" +
+					"2,17,43,AVITO_MUSIC_SERVICE,15:27:32 01.09.2023,,\n",
+			},
+			expectedPurged: []int{43},
+		},
+		{
+			name:        "Ok: NDJSON inline",
+			segmentName: "AVITO_MUSIC_SERVICE",
+			format:      "ndjson",
+			expectedArchive: entity.SegmentArchive{
+				Name:         "AVITO_MUSIC_SERVICE",
+				Format:       "ndjson",
+				ArchivedRows: 2,
+				Archive: `{"information_id":1,"user_id":16,"segment_id":43,"segment_name":"AVITO_MUSIC_SERVICE","start_date":"15:27:32 01.09.2023","end_date":"10:00:00 25.09.2023","end_reason":"removed"}` + "\n" +
+					`{"information_id":2,"user_id":17,"segment_id":43,"segment_name":"AVITO_MUSIC_SERVICE","start_date":"15:27:32 01.09.2023","end_date":"","end_reason":""}` + "\n",
+			},
+			expectedPurged: []int{43},
+		},
+		{
+			name:        "Ok: uploaded to storage",
+			segmentName: "AVITO_DELIVERY",
+			gDriveSet:   true,
+			expectedArchive: entity.SegmentArchive{
+				Name:    "AVITO_DELIVERY",
+				Format:  "csv",
+				Archive: "https://drive.google.com/segment_archive_44_AVITO_DELIVERY.csv",
+			},
+			expectedPurged: []int{44},
+		},
+		{
+			name:        "Segment is not deleted",
+			segmentName: "AVITO_VOICE",
+			expectedErr: true,
+		},
+		{
+			name:        "Segment is a source of computed segment",
+			segmentName: "AVITO_MAP",
+			expectedErr: true,
+		},
+		{
+			name:        "Segment does not exist",
+			segmentName: "AVITO_UNKNOWN",
+			expectedErr: true,
+		},
+		{
+			name:        "Invalid format",
+			segmentName: "AVITO_MUSIC_SERVICE",
+			format:      "xml",
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Инициализация зависимостей
+			repo := newPurgingSegmentRepository()
+			gDrive := &stubGDrive{set: tc.gDriveSet, files: map[string]string{}}
+			s := NewSegmentService(repo, nil, &stubTransactor{}, 0, gDrive)
+
+			// Выполнение
+			archive, err := s.PurgeSegment(context.Background(), tc.segmentName, tc.format)
+
+			// Проверка результата
+			assert.Equal(t, tc.expectedErr, err != nil)
+			assert.Equal(t, tc.expectedArchive, archive)
+			assert.Equal(t, tc.expectedPurged, repo.purged)
+		})
+	}
+}
+
+func TestSegmentPurgeService_PurgeDeletedSegments(t *testing.T) {
+	testCases := []struct {
+		name           string
+		retention      time.Duration
+		batchSize      int
+		gDriveSet      bool
+		uploadErr      error
+		expectedCount  int
+		expectedPurged []int
+		expectedFiles  int
+		expectedErr    bool
+	}{
+		{
+			name:           "Ok",
+			retention:      24 * time.Hour,
+			batchSize:      10,
+			gDriveSet:      true,
+			expectedCount:  2,
+			expectedPurged: []int{43, 44},
+			expectedFiles:  2,
+		},
+		{
+			name:           "Ok: batch is limited",
+			retention:      24 * time.Hour,
+			batchSize:      1,
+			gDriveSet:      true,
+			expectedCount:  1,
+			expectedPurged: []int{43},
+			expectedFiles:  1,
+		},
+		{
+			name:      "Retention is disabled",
+			batchSize: 10,
+			gDriveSet: true,
+		},
+		{
+			name:        "Storage is not configured, segments are kept",
+			retention:   24 * time.Hour,
+			batchSize:   10,
+			expectedErr: true,
+		},
+		{
+			name:        "Upload failed, segments are kept",
+			retention:   24 * time.Hour,
+			batchSize:   10,
+			gDriveSet:   true,
+			uploadErr:   errors.New("storage is unavailable"),
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Инициализация зависимостей
+			repo := newPurgingSegmentRepository()
+			gDrive := &stubGDrive{set: tc.gDriveSet, uploadErr: tc.uploadErr, files: map[string]string{}}
+			ps := NewSegmentPurgeService(repo, &stubTransactor{}, gDrive, tc.retention, "", tc.batchSize)
+
+			// Выполнение
+			count, err := ps.PurgeDeletedSegments(context.Background())
+
+			// Проверка результата
+			assert.Equal(t, tc.expectedErr, err != nil)
+			assert.Equal(t, tc.expectedCount, count)
+			assert.Equal(t, tc.expectedPurged, repo.purged)
+			assert.Len(t, gDrive.files, tc.expectedFiles)
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package service
+
+import (
+	"avito-rest-api/internal/entity"
+	customError "avito-rest-api/internal/error"
+	"avito-rest-api/internal/repository"
+	"context"
+	"fmt"
+	"time"
+)
+
+// getSegmentByCurrentOrFormerName находит сегмент по текущему имени `name`, а если сегмента с таким именем
+// нет, то по прежнему имени сегмента, переименованного не ранее, чем `renameGracePeriod` назад. Текущие имена
+// имеют приоритет: после создания нового сегмента с прежним именем переименованного сегмента находится новый.
+func getSegmentByCurrentOrFormerName(ctx context.Context, segmentRepository repository.Segment, name string, renameGracePeriod time.Duration) (entity.Segment, error) {
+	segment, err := segmentRepository.GetSegmentByName(ctx, name)
+	if _, ok := err.(customError.ErrSegmentNotFound); !ok || renameGracePeriod <= 0 {
+		return segment, err
+	}
+
+	renamed, formerErr := segmentRepository.GetSegmentByFormerName(ctx, name, renameGracePeriod)
+	if formerErr != nil {
+		if _, ok := formerErr.(customError.ErrSegmentNotFound); ok {
+			return entity.Segment{}, err
+		}
+		return entity.Segment{}, formerErr
+	}
+	return renamed, nil
+}
+
+// RenameSegment переименовывает сегмент с указанным именем в `newName`, сохраняя его идентификатор и историю
+// вхождения пользователей. Прежнее имя записывается в историю имён сегмента. Возвращает переименованный
+// сегмент и историю его имён.
+func (s *SegmentService) RenameSegment(ctx context.Context, name string, newName string) (entity.Segment, []entity.SegmentNameChange, error) {
+	// Валидация
+	if newName == "" {
+		return entity.Segment{}, nil, customError.ErrSegmentValidationError{ErrBase: customError.ErrBase{
+			Comment:  "Validation of segment's data failed, field \"name\" cannot be empty",
+			Location: "SegmentService.RenameSegment - validation",
+		}}
+	}
+	if len(newName) > 1000 {
+		return entity.Segment{}, nil, customError.ErrSegmentValidationError{ErrBase: customError.ErrBase{
+			Comment:  "Validation of segment's data failed, field \"name\" cannot have length over 1000 symbols",
+			Location: "SegmentService.RenameSegment - validation",
+		}}
+	}
+
+	var segment entity.Segment
+	var history []entity.SegmentNameChange
+	err := s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		// Проверим, что сегмент существует и не удалён
+		current, err := s.GetSegmentByName(ctx, name)
+		if err != nil {
+			return err
+		}
+		if current.Name == newName {
+			return customError.ErrSegmentValidationError{ErrBase: customError.ErrBase{
+				Comment:  fmt.Sprintf("Validation of segment's data failed, segment is already named \"%s\"", newName),
+				Location: "SegmentService.RenameSegment - validation",
+			}}
+		}
+		// Имя не должно быть занято другим сегментом, в том числе удалённым
+		exist, err := s.doesSegmentExist(ctx, newName)
+		if err != nil {
+			return err
+		}
+		if exist {
+			return customError.ErrSegmentAlreadyExists{ErrBase: customError.ErrBase{
+				Comment:  fmt.Sprintf("Segment with the given name \"%s\" already exists", newName),
+				Location: "SegmentService.RenameSegment - doesSegmentExist",
+			}}
+		}
+
+		if err = s.segmentRepository.RenameSegment(ctx, current.ID, current.Name, newName); err != nil {
+			return err
+		}
+		if segment, err = s.segmentRepository.GetSegmentByName(ctx, newName); err != nil {
+			return err
+		}
+		history, err = s.segmentRepository.GetSegmentNameHistory(ctx, segment.ID)
+		return err
+	})
+	if err != nil {
+		return entity.Segment{}, nil, err
+	}
+
+	return segment, history, nil
+}
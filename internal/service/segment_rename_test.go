@@ -0,0 +1,137 @@
+package service
+
+import (
+	"avito-rest-api/internal/entity"
+	customError "avito-rest-api/internal/error"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestGetSegmentByCurrentOrFormerName(t *testing.T) {
+	premium := entity.Segment{ID: 43, Name: "AVITO_MUSIC_PREMIUM"}
+	market := entity.Segment{ID: 44, Name: "AVITO_MARKET"}
+	repo := newFakeSegmentRepository(premium, market)
+	repo.formerNames = map[string]entity.Segment{
+		"AVITO_MUSIC_SERVICE": premium,
+		"AVITO_MARKET":        premium,
+	}
+
+	testCases := []struct {
+		name              string
+		segmentName       string
+		renameGracePeriod time.Duration
+		expected          entity.Segment
+		expectedNotFound  bool
+	}{
+		{
+			name:              "Current name",
+			segmentName:       "AVITO_MUSIC_PREMIUM",
+			renameGracePeriod: time.Hour,
+			expected:          premium,
+		},
+		{
+			name:              "Former name within grace period",
+			segmentName:       "AVITO_MUSIC_SERVICE",
+			renameGracePeriod: time.Hour,
+			expected:          premium,
+		},
+		{
+			name:              "Current name takes precedence over former one",
+			segmentName:       "AVITO_MARKET",
+			renameGracePeriod: time.Hour,
+			expected:          market,
+		},
+		{
+			name:              "Former names disabled",
+			segmentName:       "AVITO_MUSIC_SERVICE",
+			renameGracePeriod: 0,
+			expectedNotFound:  true,
+		},
+		{
+			name:              "Unknown name",
+			segmentName:       "AVITO_BAKERY",
+			renameGracePeriod: time.Hour,
+			expectedNotFound:  true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Выполнение
+			segment, err := getSegmentByCurrentOrFormerName(context.Background(), repo, tc.segmentName, tc.renameGracePeriod)
+
+			// Проверка результата
+			_, notFound := err.(customError.ErrSegmentNotFound)
+			assert.Equal(t, tc.expectedNotFound, notFound)
+			if !tc.expectedNotFound {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tc.expected, segment)
+		})
+	}
+}
+
+func TestSegmentService_DeleteSegment(t *testing.T) {
+	testCases := []struct {
+		name              string
+		segmentName       string
+		renameGracePeriod time.Duration
+		expectedDeleted   []string
+		expectedErr       bool
+	}{
+		{
+			name:              "Ok: current name",
+			segmentName:       "AVITO_MUSIC_PREMIUM",
+			renameGracePeriod: time.Hour,
+			expectedDeleted:   []string{"AVITO_MUSIC_PREMIUM"},
+		},
+		{
+			name:              "Ok: former name within grace period",
+			segmentName:       "AVITO_MUSIC_SERVICE",
+			renameGracePeriod: time.Hour,
+			expectedDeleted:   []string{"AVITO_MUSIC_PREMIUM"},
+		},
+		{
+			name:              "Former names disabled",
+			segmentName:       "AVITO_MUSIC_SERVICE",
+			renameGracePeriod: 0,
+			expectedErr:       true,
+		},
+		{
+			name:              "Segment is a source of computed segment",
+			segmentName:       "AVITO_VOICE",
+			renameGracePeriod: time.Hour,
+			expectedErr:       true,
+		},
+		{
+			name:              "Segment is already deleted",
+			segmentName:       "AVITO_DELIVERY",
+			renameGracePeriod: time.Hour,
+			expectedErr:       true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Инициализация зависимостей
+			premium := entity.Segment{ID: 43, Name: "AVITO_MUSIC_PREMIUM"}
+			repo := newFakeSegmentRepository(
+				premium,
+				entity.Segment{ID: 44, Name: "AVITO_DELIVERY", IsDeleted: true},
+				entity.Segment{ID: 45, Name: "AVITO_VOICE"},
+			)
+			repo.formerNames = map[string]entity.Segment{"AVITO_MUSIC_SERVICE": premium}
+			repo.dependents = map[int][]string{45: {"AVITO_VOICE_AND_MAP"}}
+			s := NewSegmentService(repo, nil, &stubTransactor{}, tc.renameGracePeriod, nil)
+
+			// Выполнение
+			err := s.DeleteSegment(context.Background(), tc.segmentName)
+
+			// Проверка результата
+			assert.Equal(t, tc.expectedErr, err != nil)
+			assert.Equal(t, tc.expectedDeleted, repo.deleted)
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"avito-rest-api/internal/entity"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestMatchSegmentRules(t *testing.T) {
+	user := entity.User{
+		ID:       16,
+		Name:     "Михаил",
+		Lastname: "Иванов",
+		Sex:      0,
+		Age:      27,
+		Attributes: map[string]interface{}{
+			"city":    "Москва",
+			"orders":  float64(5),
+			"premium": true,
+		},
+	}
+
+	testCases := []struct {
+		name     string
+		rules    []entity.SegmentRule
+		expected bool
+	}{
+		{name: "No rules", expected: true},
+		{name: "Number =", rules: []entity.SegmentRule{{Attribute: "age", Operator: "=", Value: 27}}, expected: true},
+		{name: "Number = mismatch", rules: []entity.SegmentRule{{Attribute: "age", Operator: "=", Value: 28}}, expected: false},
+		{name: "Number !=", rules: []entity.SegmentRule{{Attribute: "sex", Operator: "!=", Value: 1}}, expected: true},
+		{name: "Number != mismatch", rules: []entity.SegmentRule{{Attribute: "sex", Operator: "!=", Value: 0}}, expected: false},
+		{name: "Number >", rules: []entity.SegmentRule{{Attribute: "age", Operator: ">", Value: 26}}, expected: true},
+		{name: "Number > on boundary", rules: []entity.SegmentRule{{Attribute: "age", Operator: ">", Value: 27}}, expected: false},
+		{name: "Number >=", rules: []entity.SegmentRule{{Attribute: "age", Operator: ">=", Value: 27}}, expected: true},
+		{name: "Number >= mismatch", rules: []entity.SegmentRule{{Attribute: "age", Operator: ">=", Value: 28}}, expected: false},
+		{name: "Number <", rules: []entity.SegmentRule{{Attribute: "age", Operator: "<", Value: 28}}, expected: true},
+		{name: "Number < on boundary", rules: []entity.SegmentRule{{Attribute: "age", Operator: "<", Value: 27}}, expected: false},
+		{name: "Number <=", rules: []entity.SegmentRule{{Attribute: "age", Operator: "<=", Value: 27}}, expected: true},
+		{name: "Number <= mismatch", rules: []entity.SegmentRule{{Attribute: "age", Operator: "<=", Value: 26}}, expected: false},
+		{name: "Number from JSON", rules: []entity.SegmentRule{{Attribute: "age", Operator: "=", Value: float64(27)}}, expected: true},
+		{name: "String =", rules: []entity.SegmentRule{{Attribute: "name", Operator: "=", Value: "Михаил"}}, expected: true},
+		{name: "String = mismatch", rules: []entity.SegmentRule{{Attribute: "lastname", Operator: "=", Value: "Петров"}}, expected: false},
+		{name: "String !=", rules: []entity.SegmentRule{{Attribute: "lastname", Operator: "!=", Value: "Петров"}}, expected: true},
+		{name: "String != mismatch", rules: []entity.SegmentRule{{Attribute: "name", Operator: "!=", Value: "Михаил"}}, expected: false},
+		{name: "Attribute string =", rules: []entity.SegmentRule{{Attribute: "attributes.city", Operator: "=", Value: "Москва"}}, expected: true},
+		{name: "Attribute string <", rules: []entity.SegmentRule{{Attribute: "attributes.city", Operator: "<", Value: "Нижний Новгород"}}, expected: true},
+		{name: "Attribute number >=", rules: []entity.SegmentRule{{Attribute: "attributes.orders", Operator: ">=", Value: float64(5)}}, expected: true},
+		{name: "Attribute number >", rules: []entity.SegmentRule{{Attribute: "attributes.orders", Operator: ">", Value: float64(5)}}, expected: false},
+		{name: "Attribute number <=", rules: []entity.SegmentRule{{Attribute: "attributes.orders", Operator: "<=", Value: float64(4)}}, expected: false},
+		{name: "Attribute bool =", rules: []entity.SegmentRule{{Attribute: "attributes.premium", Operator: "=", Value: true}}, expected: true},
+		{name: "Attribute bool !=", rules: []entity.SegmentRule{{Attribute: "attributes.premium", Operator: "!=", Value: true}}, expected: false},
+		{name: "Attribute of another type is not equal", rules: []entity.SegmentRule{{Attribute: "attributes.orders", Operator: "=", Value: "5"}}, expected: false},
+		{name: "Attribute of another type is not ordered", rules: []entity.SegmentRule{{Attribute: "attributes.orders", Operator: "<", Value: "9"}}, expected: false},
+		{name: "Missing attribute never matches", rules: []entity.SegmentRule{{Attribute: "attributes.country", Operator: "!=", Value: "Россия"}}, expected: false},
+		{
+			name: "All rules must match",
+			rules: []entity.SegmentRule{
+				{Attribute: "age", Operator: ">=", Value: 18},
+				{Attribute: "attributes.city", Operator: "=", Value: "Казань"},
+			},
+			expected: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Выполнение
+			rules, err := validateSegmentRules(tc.rules)
+
+			// Проверка результата
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, matchSegmentRules(rules, user))
+		})
+	}
+}
+
+func TestValidateSegmentRules(t *testing.T) {
+	testCases := []struct {
+		name        string
+		rule        entity.SegmentRule
+		expectedErr bool
+	}{
+		{name: "Ok: ordering of number", rule: entity.SegmentRule{Attribute: "age", Operator: "<=", Value: float64(18)}},
+		{name: "Ok: ordering of attribute", rule: entity.SegmentRule{Attribute: "attributes.city", Operator: ">", Value: "А"}},
+		{name: "Unsupported attribute", rule: entity.SegmentRule{Attribute: "email", Operator: "=", Value: "a@b.c"}, expectedErr: true},
+		{name: "Unsupported operator", rule: entity.SegmentRule{Attribute: "age", Operator: "<>", Value: 18}, expectedErr: true},
+		{name: "Ordering of string attribute", rule: entity.SegmentRule{Attribute: "name", Operator: ">", Value: "А"}, expectedErr: true},
+		{name: "Ordering of boolean attribute", rule: entity.SegmentRule{Attribute: "attributes.premium", Operator: "<", Value: true}, expectedErr: true},
+		{name: "Fractional number", rule: entity.SegmentRule{Attribute: "age", Operator: "=", Value: 18.5}, expectedErr: true},
+		{name: "String instead of number", rule: entity.SegmentRule{Attribute: "age", Operator: "=", Value: "18"}, expectedErr: true},
+		{name: "Number instead of string", rule: entity.SegmentRule{Attribute: "name", Operator: "=", Value: float64(1)}, expectedErr: true},
+		{name: "Invalid attribute key", rule: entity.SegmentRule{Attribute: "attributes.1city", Operator: "=", Value: "Москва"}, expectedErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Выполнение
+			_, err := validateSegmentRules([]entity.SegmentRule{tc.rule})
+
+			// Проверка результата
+			assert.Equal(t, tc.expectedErr, err != nil)
+		})
+	}
+}
@@ -5,47 +5,110 @@ import (
 	"avito-rest-api/internal/repository"
 	"avito-rest-api/internal/webapi"
 	"context"
+	"io"
+	"time"
 )
 
 //go:generate mockgen -source=service.go -destination=mocks/mock.go
 
 type User interface {
 	CreateUser(ctx context.Context, input UserCreateInput) (int, error)
-	GetAllUsers(ctx context.Context) ([]entity.User, error)
-	GetAllUsersWithSegments(ctx context.Context) ([]entity.UserWithSegments, error)
+	ImportUsers(ctx context.Context, format string, data io.Reader) (entity.UserImportReport, error)
+	GetAllUsers(ctx context.Context, input UsersInput) (entity.UsersPage, error)
+	GetAllUsersWithSegments(ctx context.Context, input PageInput) (entity.UsersWithSegmentsPage, error)
 	GetUserByID(ctx context.Context, id int) (entity.User, error)
+	GetUserByExternalID(ctx context.Context, externalID string) (entity.User, error)
+	UpsertUserByExternalID(ctx context.Context, externalID string, input UserCreateInput) (entity.User, bool, error)
 	GetUserSegmentsByUserID(ctx context.Context, id int) ([]entity.UserSegmentInformation, error)
+	GetUserSegmentsAt(ctx context.Context, id int, at time.Time) ([]entity.UserSegmentInformation, error)
 	GetUserWithSegmentsByUserID(ctx context.Context, id int) (entity.UserWithSegments, error)
-	AddUserToSegments(ctx context.Context, id int, segments []entity.UserSegmentInformation) error
+	AddUserToSegments(ctx context.Context, id int, segments []entity.UserSegmentInformation, replaceExclusive bool) error
 	DeleteUserFromSegments(ctx context.Context, id int, segments []string) error
+	UpdateUserSegments(ctx context.Context, id int, segmentsToAdd []entity.UserSegmentInformation, segmentsToDelete []string) error
+	UpdateUserSegmentEndDate(ctx context.Context, id int, name string, endDate string) (entity.UserSegmentInformation, error)
+	UpdateUser(ctx context.Context, id int, input UserUpdateInput) (entity.User, error)
+	DeleteUser(ctx context.Context, id int) error
+	RestoreUser(ctx context.Context, id int) (entity.User, error)
 }
 
 type Segment interface {
 	CreateSegment(ctx context.Context, input SegmentCreateInput) (string, error)
-	GetAllSegments(ctx context.Context, sType int) ([]entity.Segment, error)
+	PreviewSegment(ctx context.Context, input SegmentCreateInput) (entity.SegmentPreview, error)
+	CreateDerivedSegment(ctx context.Context, input SegmentDeriveInput) (string, int, error)
+	GetAllSegments(ctx context.Context, sType int, filter entity.SegmentsFilter) ([]entity.Segment, error)
 	GetSegmentByName(ctx context.Context, name string) (entity.Segment, error)
+	GetSegmentUsers(ctx context.Context, name string, input SegmentUsersInput) (entity.SegmentUsersPage, error)
 	DeleteSegment(ctx context.Context, name string) error
+	UpdateSegment(ctx context.Context, name string, input SegmentUpdateInput) (entity.Segment, error)
+	RenameSegment(ctx context.Context, name string, newName string) (entity.Segment, []entity.SegmentNameChange, error)
+	UpdateSegmentRules(ctx context.Context, name string, rules []entity.SegmentRule) (entity.Segment, error)
+	UpdateSegmentPercentage(ctx context.Context, name string, percentage *int) (entity.Segment, error)
+	AddUsersToSegment(ctx context.Context, name string, input SegmentUsersAddInput) (entity.SegmentUsersAddReport, error)
+	DeleteUsersFromSegment(ctx context.Context, name string, input SegmentUsersDeleteInput) (entity.SegmentUsersDeleteReport, error)
+	PurgeSegment(ctx context.Context, name string, format string) (entity.SegmentArchive, error)
+}
+
+type SegmentGroup interface {
+	CreateSegmentGroup(ctx context.Context, input SegmentGroupCreateInput) (entity.SegmentGroup, error)
+	GetSegmentGroupByName(ctx context.Context, name string) (entity.SegmentGroup, error)
+	UpdateSegmentGroupSegments(ctx context.Context, name string, segments []string) (entity.SegmentGroup, error)
+}
+
+type Experiment interface {
+	CreateExperiment(ctx context.Context, input ExperimentCreateInput) (entity.Experiment, error)
+	GetExperimentByName(ctx context.Context, name string) (entity.Experiment, error)
+	GetUserVariant(ctx context.Context, name string, userID int) (entity.UserExperimentVariant, error)
+	GetExperimentReport(ctx context.Context, name string) (entity.ExperimentReport, error)
 }
 
 type Report interface {
-	MakeReport(ctx context.Context) (entity.ReportCSV, error)
+	MakeReport(ctx context.Context, input MakeReportInput) (entity.ReportCSV, error)
+}
+
+type Expiry interface {
+	ExpireMemberships(ctx context.Context) (int, error)
+	DeleteExpiredSegments(ctx context.Context) (int, error)
+}
+
+type SegmentPurge interface {
+	PurgeDeletedSegments(ctx context.Context) (int, error)
 }
 
 type Services struct {
-	User    User
-	Segment Segment
-	Report  Report
+	User         User
+	Segment      Segment
+	SegmentGroup SegmentGroup
+	Experiment   Experiment
+	Report       Report
+	Expiry       Expiry
+	SegmentPurge SegmentPurge
 }
 
 type ServicesDependencies struct {
 	Repositories *repository.Repositories
 	GDrive       webapi.GDrive
+	Events       webapi.EventPublisher
+	// Количество вхождений пользователей в сегменты, помечаемых истёкшими в одной транзакции
+	ExpiryBatchSize int
+	// Период после переименования сегмента, в течение которого сегмент находится по прежнему имени
+	SegmentRenameGracePeriod time.Duration
+	// Период после удаления сегмента, по истечении которого сегмент окончательно удаляется с архивацией,
+	// если не больше нуля, удалённые сегменты хранятся бессрочно
+	SegmentPurgeRetention time.Duration
+	// Формат архивов окончательно удаляемых сегментов: "csv" или "ndjson"
+	SegmentArchiveFormat string
+	// Количество сегментов, окончательно удаляемых за один запуск
+	SegmentPurgeBatchSize int
 }
 
 func NewService(dependencies ServicesDependencies) *Services {
 	return &Services{
-		User:    NewUserService(dependencies.Repositories.User, dependencies.Repositories.Segment),
-		Segment: NewSegmentService(dependencies.Repositories.Segment),
-		Report:  NewReportService(dependencies.Repositories.Report, dependencies.GDrive),
+		User:         NewUserService(dependencies.Repositories.User, dependencies.Repositories.Segment, dependencies.Repositories.SegmentGroup, dependencies.Repositories.Experiment, dependencies.Repositories.Transactor, dependencies.SegmentRenameGracePeriod),
+		Segment:      NewSegmentService(dependencies.Repositories.Segment, dependencies.Repositories.User, dependencies.Repositories.Transactor, dependencies.SegmentRenameGracePeriod, dependencies.GDrive),
+		SegmentGroup: NewSegmentGroupService(dependencies.Repositories.SegmentGroup, dependencies.Repositories.Segment, dependencies.Repositories.Transactor),
+		Experiment:   NewExperimentService(dependencies.Repositories.Experiment, dependencies.Repositories.Segment, dependencies.Repositories.User, dependencies.Repositories.Transactor),
+		Report:       NewReportService(dependencies.Repositories.Report, dependencies.GDrive),
+		Expiry:       NewExpiryService(dependencies.Repositories.Segment, dependencies.Repositories.Transactor, dependencies.Events, dependencies.ExpiryBatchSize),
+		SegmentPurge: NewSegmentPurgeService(dependencies.Repositories.Segment, dependencies.Repositories.Transactor, dependencies.GDrive, dependencies.SegmentPurgeRetention, dependencies.SegmentArchiveFormat, dependencies.SegmentPurgeBatchSize),
 	}
 }
@@ -0,0 +1,174 @@
+package service
+
+import (
+	"avito-rest-api/internal/entity"
+	customError "avito-rest-api/internal/error"
+	"avito-rest-api/internal/repository"
+	"context"
+	"sort"
+	"time"
+)
+
+// stubTransactor - заглушка, выполняющая функцию без транзакции. После успешного выполнения функции
+// транзакция считается зафиксированной: устанавливается `committed` и вызывается `onCommit`, если задан.
+type stubTransactor struct {
+	onCommit  func()
+	committed bool
+}
+
+func (t *stubTransactor) WithinTransaction(ctx context.Context, f func(ctx context.Context) error) error {
+	if err := f(ctx); err != nil {
+		return err
+	}
+	t.committed = true
+	if t.onCommit != nil {
+		t.onCommit()
+	}
+	return nil
+}
+
+// fakeSegmentRepository - заглушка репозитория сегментов, хранящая сегменты в памяти. Поля, не заданные тестом,
+// означают отсутствие данных: прежних имён, экспериментов, зависящих сегментов и т.д. Раскатка на процент
+// распределяет пользователей с ID от 1 до fakeSegmentUsers по соли сегмента так же, как репозиторий.
+// Методы, не переопределённые заглушкой, не должны вызываться.
+type fakeSegmentRepository struct {
+	repository.Segment
+	segments    map[string]entity.Segment
+	formerNames map[string]entity.Segment
+	experiments map[int]string
+	dependents  map[int][]string
+	archiveRows map[int][]entity.SegmentArchiveRow
+	purgeable   []string
+
+	members   map[int][]int // Участники сегментов, добавленные синхронизацией
+	added     map[int][]int // Пользователи, для которых обновлялось вхождение в сегменты
+	synced    []int         // Сегменты, состав которых синхронизировался
+	deleted   []string
+	recovered []string
+	purged    []int
+}
+
+// fakeSegmentUsers - количество пользователей, на которых раскатываются сегменты fakeSegmentRepository.
+const fakeSegmentUsers = 100
+
+// newFakeSegmentRepository возвращает заглушку репозитория, содержащую сегменты `segments`.
+func newFakeSegmentRepository(segments ...entity.Segment) *fakeSegmentRepository {
+	r := &fakeSegmentRepository{segments: map[string]entity.Segment{}, members: map[int][]int{}, added: map[int][]int{}}
+	for _, segment := range segments {
+		r.segments[segment.Name] = segment
+	}
+	return r
+}
+
+func (r *fakeSegmentRepository) GetSegmentByName(_ context.Context, name string) (entity.Segment, error) {
+	segment, ok := r.segments[name]
+	if !ok {
+		return entity.Segment{}, customError.ErrSegmentNotFound{}
+	}
+	return segment, nil
+}
+
+func (r *fakeSegmentRepository) GetSegmentByFormerName(_ context.Context, name string, _ time.Duration) (entity.Segment, error) {
+	segment, ok := r.formerNames[name]
+	if !ok {
+		return entity.Segment{}, customError.ErrSegmentNotFound{}
+	}
+	return segment, nil
+}
+
+func (r *fakeSegmentRepository) GetSegmentExperimentName(_ context.Context, id int) (string, error) {
+	return r.experiments[id], nil
+}
+
+func (r *fakeSegmentRepository) GetDependentSegmentNames(_ context.Context, id int) ([]string, error) {
+	return r.dependents[id], nil
+}
+
+func (r *fakeSegmentRepository) CreateSegment(_ context.Context, segment entity.Segment) (string, error) {
+	segment.ID = len(r.segments) + 1
+	r.segments[segment.Name] = segment
+	return segment.Name, nil
+}
+
+func (r *fakeSegmentRepository) DeleteSegment(_ context.Context, name string) error {
+	r.deleted = append(r.deleted, name)
+	return nil
+}
+
+func (r *fakeSegmentRepository) RecoverSegment(_ context.Context, name string, _ string) (string, error) {
+	segment := r.segments[name]
+	segment.IsDeleted = false
+	r.segments[name] = segment
+	r.recovered = append(r.recovered, name)
+	return name, nil
+}
+
+func (r *fakeSegmentRepository) UpdateSegmentLifetime(_ context.Context, _ int, _ *time.Duration, _ *time.Time) error {
+	return nil
+}
+
+func (r *fakeSegmentRepository) UpdateSegmentMetadata(_ context.Context, _ int, _ string, _ string, _ []string) error {
+	return nil
+}
+
+func (r *fakeSegmentRepository) UpdateSegmentRules(_ context.Context, _ int, _ []entity.SegmentRule) error {
+	return nil
+}
+
+func (r *fakeSegmentRepository) UpdateSegmentPercentage(_ context.Context, _ int, _ *int) error {
+	return nil
+}
+
+func (r *fakeSegmentRepository) GetAutomaticSegments(_ context.Context) ([]entity.Segment, error) {
+	var segments []entity.Segment
+	for _, segment := range r.segments {
+		if len(segment.Rules) > 0 || segment.Percentage != nil {
+			segments = append(segments, segment)
+		}
+	}
+	sort.Slice(segments, func(i, j int) bool { return segments[i].ID < segments[j].ID })
+	return segments, nil
+}
+
+func (r *fakeSegmentRepository) SyncSegmentUsers(_ context.Context, segment entity.Segment) error {
+	r.synced = append(r.synced, segment.ID)
+	if segment.Percentage == nil {
+		return nil
+	}
+	for userID := 1; userID <= fakeSegmentUsers; userID++ {
+		if rolloutBucket(segment.Salt, userID) < *segment.Percentage {
+			r.members[segment.ID] = append(r.members[segment.ID], userID)
+		}
+	}
+	return nil
+}
+
+func (r *fakeSegmentRepository) AddMatchingUsersToSegment(_ context.Context, segment entity.Segment, userIDs []int) error {
+	r.added[segment.ID] = userIDs
+	return nil
+}
+
+func (r *fakeSegmentRepository) GetAllSegmentMemberIDs(_ context.Context, id int) ([]int, error) {
+	return r.members[id], nil
+}
+
+func (r *fakeSegmentRepository) LockSegment(_ context.Context, _ int) error {
+	return nil
+}
+
+func (r *fakeSegmentRepository) GetSegmentArchiveRows(_ context.Context, id int) ([]entity.SegmentArchiveRow, error) {
+	return r.archiveRows[id], nil
+}
+
+func (r *fakeSegmentRepository) PurgeSegment(_ context.Context, id int) error {
+	r.purged = append(r.purged, id)
+	return nil
+}
+
+func (r *fakeSegmentRepository) GetPurgeableSegmentNames(_ context.Context, _ time.Duration, limit int) ([]string, error) {
+	names := r.purgeable
+	if len(names) > limit {
+		names = names[:limit]
+	}
+	return names, nil
+}
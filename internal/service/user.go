@@ -11,12 +11,23 @@ import (
 )
 
 type UserService struct {
-	userRepository    repository.User
-	segmentRepository repository.Segment
+	userRepository         repository.User
+	segmentRepository      repository.Segment
+	segmentGroupRepository repository.SegmentGroup
+	experimentRepository   repository.Experiment
+	transactor             repository.Transactor
+	renameGracePeriod      time.Duration
 }
 
-func NewUserService(userRepository repository.User, segmentRepository repository.Segment) *UserService {
-	return &UserService{userRepository: userRepository, segmentRepository: segmentRepository}
+func NewUserService(userRepository repository.User, segmentRepository repository.Segment, segmentGroupRepository repository.SegmentGroup, experimentRepository repository.Experiment, transactor repository.Transactor, renameGracePeriod time.Duration) *UserService {
+	return &UserService{
+		userRepository:         userRepository,
+		segmentRepository:      segmentRepository,
+		segmentGroupRepository: segmentGroupRepository,
+		experimentRepository:   experimentRepository,
+		transactor:             transactor,
+		renameGracePeriod:      renameGracePeriod,
+	}
 }
 
 // UserCreateInput - DTO для получения данных
@@ -26,80 +37,466 @@ type UserCreateInput struct {
 	Lastname string `json:"lastname" example:"Иванов" validate:"required"`
 	Sex      int    `json:"sex" example:"0" enums:"0,1" validate:"required"` // Пол, 0 - мужской, 1 - женский
 	Age      int    `json:"age" example:"27" validate:"required"`            // Целое положительное число
+	// Необязательное поле, дополнительные атрибуты пользователя: строки, числа или логические значения
+	Attributes map[string]interface{} `json:"attributes" swaggertype:"object"`
+	// Необязательное поле, уникальный идентификатор пользователя во внешней системе
+	ExternalID string `json:"external_id" example:"crm-1842"`
 }
 
 func (us *UserService) CreateUser(ctx context.Context, input UserCreateInput) (int, error) {
 	// Валидация
+	if err := validateUserCreateInput(input, "UserService.CreateUser"); err != nil {
+		return 0, err
+	}
+
+	// Маппинг данных из DTO в сущность User
+	user := entity.User{
+		ID:         0,
+		Name:       input.Name,
+		Lastname:   input.Lastname,
+		Sex:        input.Sex,
+		SexText:    "",
+		Age:        input.Age,
+		Attributes: userAttributes(input.Attributes),
+		ExternalID: externalID(input.ExternalID),
+	}
+
+	// Создадим пользователя и добавим его в сегменты, правилам и проценту раскатки которых он удовлетворяет,
+	// а также в сегменты вариантов всех экспериментов
+	var id int
+	err := us.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		id, err = us.userRepository.CreateUser(ctx, user)
+		if err != nil {
+			return err
+		}
+		user.ID = id
+
+		return us.syncAutomaticSegments(ctx, user)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// validateUserCreateInput проверяет атрибуты создаваемого пользователя.
+func validateUserCreateInput(input UserCreateInput, location string) error {
 	if input.Name == "" {
-		return 0, customError.ErrUserValidationError{ErrBase: customError.ErrBase{
-			OriginError:     nil,
-			OriginErrorText: "",
-			Comment:         "\"name\" field cannot be empty",
-			Location:        "UserService.CreateUser",
+		return customError.ErrUserValidationError{ErrBase: customError.ErrBase{
+			Comment:  "\"name\" field cannot be empty",
+			Location: location,
 		}}
 	}
 	if input.Lastname == "" {
-		return 0, customError.ErrUserValidationError{ErrBase: customError.ErrBase{
-			OriginError:     nil,
-			OriginErrorText: "",
-			Comment:         "\"lastname\" field cannot be empty",
-			Location:        "UserService.CreateUser",
+		return customError.ErrUserValidationError{ErrBase: customError.ErrBase{
+			Comment:  "\"lastname\" field cannot be empty",
+			Location: location,
 		}}
 	}
 	if input.Sex < 0 || input.Sex > 1 {
-		return 0, customError.ErrUserValidationError{ErrBase: customError.ErrBase{
-			OriginError:     nil,
-			OriginErrorText: "",
-			Comment:         "\"sex\" field can only take value from range [0, 1]",
-			Location:        "UserService.CreateUser",
+		return customError.ErrUserValidationError{ErrBase: customError.ErrBase{
+			Comment:  "\"sex\" field can only take value from range [0, 1]",
+			Location: location,
 		}}
 	}
 	if input.Age < 0 {
-		return 0, customError.ErrUserValidationError{ErrBase: customError.ErrBase{
-			OriginError:     nil,
-			OriginErrorText: "",
-			Comment:         "\"age\" field cannot be negative",
-			Location:        "UserService.CreateUser",
+		return customError.ErrUserValidationError{ErrBase: customError.ErrBase{
+			Comment:  "\"age\" field cannot be negative",
+			Location: location,
 		}}
 	}
+	if err := validateExternalID(input.ExternalID, location); err != nil {
+		return err
+	}
+	return validateUserAttributes(input.Attributes, location)
+}
 
-	// Маппинг данных из DTO в сущность User
-	user := entity.User{
-		ID:       0,
-		Name:     input.Name,
-		Lastname: input.Lastname,
-		Sex:      input.Sex,
-		SexText:  "",
-		Age:      input.Age,
-	}
-	return us.userRepository.CreateUser(ctx, user)
+// UserUpdateInput - DTO для получения данных для изменения пользователя из тела запроса.
+// Отсутствующие поля не изменяются.
+type UserUpdateInput struct {
+	Name     *string `json:"name" example:"Михаил"`
+	Lastname *string `json:"lastname" example:"Иванов"`
+	Sex      *int    `json:"sex" example:"0" enums:"0,1"` // Пол, 0 - мужской, 1 - женский
+	Age      *int    `json:"age" example:"27"`            // Целое положительное число
+	// Новые дополнительные атрибуты пользователя, заменяющие текущие целиком
+	Attributes map[string]interface{} `json:"attributes" swaggertype:"object"`
 }
 
-func (us *UserService) GetAllUsers(ctx context.Context) ([]entity.User, error) {
-	return us.userRepository.GetAllUsers(ctx)
+// UpdateUser изменяет атрибуты пользователя с указанным `id` и приводит его вхождение в сегменты
+// с правилами в соответствие с новыми атрибутами.
+func (us *UserService) UpdateUser(ctx context.Context, id int, input UserUpdateInput) (entity.User, error) {
+	// Валидация
+	if input.Name != nil && *input.Name == "" {
+		return entity.User{}, customError.ErrUserValidationError{ErrBase: customError.ErrBase{
+			Comment:  "\"name\" field cannot be empty",
+			Location: "UserService.UpdateUser",
+		}}
+	}
+	if input.Lastname != nil && *input.Lastname == "" {
+		return entity.User{}, customError.ErrUserValidationError{ErrBase: customError.ErrBase{
+			Comment:  "\"lastname\" field cannot be empty",
+			Location: "UserService.UpdateUser",
+		}}
+	}
+	if input.Sex != nil && (*input.Sex < 0 || *input.Sex > 1) {
+		return entity.User{}, customError.ErrUserValidationError{ErrBase: customError.ErrBase{
+			Comment:  "\"sex\" field can only take value from range [0, 1]",
+			Location: "UserService.UpdateUser",
+		}}
+	}
+	if input.Age != nil && *input.Age < 0 {
+		return entity.User{}, customError.ErrUserValidationError{ErrBase: customError.ErrBase{
+			Comment:  "\"age\" field cannot be negative",
+			Location: "UserService.UpdateUser",
+		}}
+	}
+	if err := validateUserAttributes(input.Attributes, "UserService.UpdateUser"); err != nil {
+		return entity.User{}, err
+	}
+
+	var user entity.User
+	err := us.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := us.userRepository.LockUser(ctx, id); err != nil {
+			return err
+		}
+		var err error
+		user, err = us.userRepository.GetUserByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if user.IsDeleted {
+			return customError.ErrUserDeleted{ErrBase: customError.ErrBase{
+				Comment:  fmt.Sprintf("User with id %d is deleted", id),
+				Location: "UserService.UpdateUser - us.userRepository.GetUserByID",
+			}}
+		}
+
+		if input.Name != nil {
+			user.Name = *input.Name
+		}
+		if input.Lastname != nil {
+			user.Lastname = *input.Lastname
+		}
+		if input.Sex != nil {
+			user.Sex = *input.Sex
+		}
+		if input.Age != nil {
+			user.Age = *input.Age
+		}
+		if input.Attributes != nil {
+			user.Attributes = input.Attributes
+		}
+		user.Attributes = userAttributes(user.Attributes)
+		if err = us.userRepository.UpdateUser(ctx, user); err != nil {
+			return err
+		}
+		if err = us.syncAutomaticSegments(ctx, user); err != nil {
+			return err
+		}
+
+		// Получим пользователя повторно, чтобы узнать значения, вычисляемые базой данных
+		user, err = us.userRepository.GetUserByID(ctx, id)
+		return err
+	})
+	if err != nil {
+		return entity.User{}, err
+	}
+
+	return user, nil
 }
 
-func (us *UserService) GetAllUsersWithSegments(ctx context.Context) ([]entity.UserWithSegments, error) {
-	users, err := us.GetAllUsers(ctx)
+// DeleteUser помечает пользователя с указанным `id` как удалённого. Пользователь выходит из всех сегментов,
+// в которые входит на момент удаления.
+func (us *UserService) DeleteUser(ctx context.Context, id int) error {
+	return us.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := us.userRepository.LockUser(ctx, id); err != nil {
+			return err
+		}
+		user, err := us.userRepository.GetUserByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if user.IsDeleted {
+			return customError.ErrUserDeleted{ErrBase: customError.ErrBase{
+				Comment:  fmt.Sprintf("User with id %d is already deleted", id),
+				Location: "UserService.DeleteUser - us.userRepository.GetUserByID",
+			}}
+		}
+
+		return us.userRepository.DeleteUser(ctx, id)
+	})
+}
+
+// RestoreUser снимает с пользователя с указанным `id` пометку об удалении и возвращает его в сегменты
+// с правилами и процентом раскатки, которым он удовлетворяет, а также в сегменты вариантов экспериментов.
+// Сегменты, в которые пользователь был добавлен вручную, не восстанавливаются.
+func (us *UserService) RestoreUser(ctx context.Context, id int) (entity.User, error) {
+	var user entity.User
+	err := us.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := us.userRepository.LockUser(ctx, id); err != nil {
+			return err
+		}
+		var err error
+		user, err = us.userRepository.GetUserByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if !user.IsDeleted {
+			return customError.ErrUserValidationError{ErrBase: customError.ErrBase{
+				Comment:  fmt.Sprintf("User with id %d is not deleted", id),
+				Location: "UserService.RestoreUser - us.userRepository.GetUserByID",
+			}}
+		}
+
+		if err = us.userRepository.RestoreUser(ctx, id); err != nil {
+			return err
+		}
+		user.IsDeleted = false
+
+		return us.syncAutomaticSegments(ctx, user)
+	})
 	if err != nil {
-		return nil, err
+		return entity.User{}, err
 	}
 
-	var usersWithSegments []entity.UserWithSegments
+	return user, nil
+}
+
+// syncAutomaticSegments приводит вхождение пользователя `user` в сегменты с правилами и процентом раскатки
+// в соответствие с его атрибутами, а также добавляет его в сегменты вариантов экспериментов,
+// в которые он ещё не входит. Запланированное вхождение считается существующим: пользователь не добавляется
+// в сегмент повторно, а если он перестал удовлетворять сегменту, запланированное вхождение отменяется.
+func (us *UserService) syncAutomaticSegments(ctx context.Context, user entity.User) error {
+	currentSegments, err := us.userRepository.GetUserSegmentsByUserID(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	scheduledSegments, err := us.userRepository.GetUserScheduledSegments(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	membership := make(map[int][]entity.UserSegmentInformation)
+	for _, segment := range append(currentSegments, scheduledSegments...) {
+		membership[segment.SegmentID] = append(membership[segment.SegmentID], segment)
+	}
 
-	for _, user := range users {
-		userSegments, err := us.userRepository.GetUserSegmentsByUserID(ctx, user.ID)
+	var toAdd, toDelete []entity.UserSegmentInformation
+	automaticSegments, err := us.segmentRepository.GetAutomaticSegments(ctx)
+	if err != nil {
+		return err
+	}
+	for _, segment := range automaticSegments {
+		existing, isMember := membership[segment.ID]
+		matched := matchSegment(segment, user)
+		if matched && !isMember {
+			toAdd = append(toAdd, entity.UserSegmentInformation{
+				SegmentID: segment.ID,
+				Name:      segment.Name,
+			})
+		} else if !matched && isMember {
+			toDelete = append(toDelete, existing...)
+		}
+	}
+
+	experiments, err := us.experimentRepository.GetAllExperiments(ctx)
+	if err != nil {
+		return err
+	}
+	for _, experiment := range experiments {
+		variant := experimentVariant(experiment, user.ID)
+		if _, isMember := membership[variant.SegmentID]; !isMember {
+			toAdd = append(toAdd, entity.UserSegmentInformation{
+				SegmentID: variant.SegmentID,
+				Name:      variant.SegmentName,
+			})
+		}
+	}
+
+	if len(toDelete) > 0 {
+		if err = us.userRepository.DeleteUserFromSegments(ctx, user.ID, toDelete); err != nil {
+			return err
+		}
+	}
+	if len(toAdd) > 0 {
+		return us.userRepository.AddUserToSegments(ctx, user.ID, toAdd)
+	}
+	return nil
+}
+
+// UsersInput - DTO с параметрами запроса на получение списка пользователей.
+type UsersInput struct {
+	Name       *string                // Имя пользователя
+	Lastname   *string                // Фамилия пользователя
+	Sex        *int                   // Пол пользователя
+	MinAge     *int                   // Минимальный возраст пользователя
+	MaxAge     *int                   // Максимальный возраст пользователя
+	IsDeleted  *bool                  // Пометка об удалении
+	Attributes map[string]interface{} // Значения дополнительных атрибутов пользователя
+	Sort       string                 // Поле сортировки: user_id (по умолчанию), name, lastname или age, префикс "-" задаёт убывание
+	PageInput
+}
+
+// GetAllUsers возвращает страницу списка пользователей, удовлетворяющих фильтрам, в заданном порядке.
+func (us *UserService) GetAllUsers(ctx context.Context, input UsersInput) (entity.UsersPage, error) {
+	// Валидация
+	filter := entity.UsersFilter{
+		Name:       input.Name,
+		Lastname:   input.Lastname,
+		Sex:        input.Sex,
+		MinAge:     input.MinAge,
+		MaxAge:     input.MaxAge,
+		IsDeleted:  input.IsDeleted,
+		Attributes: input.Attributes,
+		SortBy:     strings.TrimPrefix(input.Sort, "-"),
+		Desc:       strings.HasPrefix(input.Sort, "-"),
+		Limit:      input.Limit,
+	}
+	if filter.SortBy == "" {
+		filter.SortBy = "user_id"
+	}
+	if _, ok := userSortFields[filter.SortBy]; !ok {
+		return entity.UsersPage{}, customError.ErrUserValidationError{ErrBase: customError.ErrBase{
+			Comment:  fmt.Sprintf("Invalid sort \"%s\", expected one of: user_id, name, lastname, age, optionally prefixed with \"-\"", input.Sort),
+			Location: "UserService.GetAllUsers - validation",
+		}}
+	}
+	if filter.Sex != nil && *filter.Sex != 0 && *filter.Sex != 1 {
+		return entity.UsersPage{}, customError.ErrUserValidationError{ErrBase: customError.ErrBase{
+			Comment:  "Invalid sex filter, expected 0 or 1",
+			Location: "UserService.GetAllUsers - validation",
+		}}
+	}
+	if filter.MinAge != nil && filter.MaxAge != nil && *filter.MinAge > *filter.MaxAge {
+		return entity.UsersPage{}, customError.ErrUserValidationError{ErrBase: customError.ErrBase{
+			Comment:  "Invalid age filter, minimum age cannot be greater than maximum age",
+			Location: "UserService.GetAllUsers - validation",
+		}}
+	}
+	if err := validateUserAttributes(filter.Attributes, "UserService.GetAllUsers - validation"); err != nil {
+		return entity.UsersPage{}, err
+	}
+	if filter.Limit == 0 {
+		filter.Limit = defaultPageLimit
+	}
+	if filter.Limit < 0 || filter.Limit > maxPageLimit {
+		return entity.UsersPage{}, customError.ErrUserValidationError{ErrBase: customError.ErrBase{
+			Comment:  fmt.Sprintf("Invalid limit %d, expected integer number from range [1, %d]", filter.Limit, maxPageLimit),
+			Location: "UserService.GetAllUsers - validation",
+		}}
+	}
+	if input.Cursor != "" {
+		cursor, err := decodeSortCursor(input.Cursor)
+		if err == nil {
+			filter.AfterValue, err = userSortValueFromCursor(filter.SortBy, cursor.Value)
+		}
 		if err != nil {
-			return nil, err
+			return entity.UsersPage{}, customError.ErrUserValidationError{ErrBase: customError.ErrBase{
+				OriginError:     err,
+				OriginErrorText: err.Error(),
+				Comment:         fmt.Sprintf("Invalid cursor \"%s\"", input.Cursor),
+				Location:        "UserService.GetAllUsers - decodeSortCursor",
+			}}
+		}
+		if cursor.Sort != input.Sort {
+			return entity.UsersPage{}, customError.ErrUserValidationError{ErrBase: customError.ErrBase{
+				Comment:  fmt.Sprintf("Invalid cursor \"%s\", cursor was issued for another sort order", input.Cursor),
+				Location: "UserService.GetAllUsers - validation",
+			}}
 		}
+		filter.AfterID = cursor.ID
+	}
 
-		usersWithSegments = append(usersWithSegments, entity.UserWithSegments{
-			User:     user,
-			Segments: userSegments,
+	// Запросим на одного пользователя больше, чтобы узнать, есть ли следующая страница
+	limit := filter.Limit
+	filter.Limit++
+	users, err := us.userRepository.GetAllUsers(ctx, filter)
+	if err != nil {
+		return entity.UsersPage{}, err
+	}
+
+	page := entity.UsersPage{Users: users}
+	if len(users) > limit {
+		page.Users = users[:limit]
+		last := page.Users[limit-1]
+		page.NextCursor = encodeSortCursor(sortCursor{
+			Sort:  input.Sort,
+			Value: userSortFields[filter.SortBy](last),
+			ID:    last.ID,
 		})
 	}
+	if page.Users == nil {
+		page.Users = []entity.User{}
+	}
+
+	return page, nil
+}
+
+// userSortFields перечисляет поля, по которым допускается сортировка списка пользователей,
+// и возвращает значение поля у пользователя.
+var userSortFields = map[string]func(user entity.User) interface{}{
+	"user_id":  func(user entity.User) interface{} { return user.ID },
+	"name":     func(user entity.User) interface{} { return user.Name },
+	"lastname": func(user entity.User) interface{} { return user.Lastname },
+	"age":      func(user entity.User) interface{} { return user.Age },
+}
+
+// userSortValueFromCursor приводит значение поля сортировки `sortBy`, извлечённое из курсора, к типу поля.
+func userSortValueFromCursor(sortBy string, value interface{}) (interface{}, error) {
+	switch sortBy {
+	case "name", "lastname":
+		if str, ok := value.(string); ok {
+			return str, nil
+		}
+	default:
+		if number, ok := value.(float64); ok && number == float64(int(number)) {
+			return int(number), nil
+		}
+	}
+	return nil, fmt.Errorf("unexpected value %v of sort field \"%s\"", value, sortBy)
+}
+
+// GetAllUsersWithSegments возвращает страницу списка пользователей, упорядоченного по ID, вместе с их активными
+// сегментами. Страница выбирается одним запросом к базе данных независимо от количества пользователей на ней.
+func (us *UserService) GetAllUsersWithSegments(ctx context.Context, input PageInput) (entity.UsersWithSegmentsPage, error) {
+	// Валидация
+	limit := input.Limit
+	if limit == 0 {
+		limit = defaultPageLimit
+	}
+	if limit < 0 || limit > maxPageLimit {
+		return entity.UsersWithSegmentsPage{}, customError.ErrUserValidationError{ErrBase: customError.ErrBase{
+			Comment:  fmt.Sprintf("Invalid limit %d, expected integer number from range [1, %d]", limit, maxPageLimit),
+			Location: "UserService.GetAllUsersWithSegments - validation",
+		}}
+	}
+	afterID, err := decodeCursor(input.Cursor)
+	if err != nil {
+		return entity.UsersWithSegmentsPage{}, customError.ErrUserValidationError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         fmt.Sprintf("Invalid cursor \"%s\"", input.Cursor),
+			Location:        "UserService.GetAllUsersWithSegments - decodeCursor",
+		}}
+	}
+
+	// Запросим на одного пользователя больше, чтобы узнать, есть ли следующая страница
+	usersWithSegments, err := us.userRepository.GetUsersWithSegments(ctx, afterID, limit+1)
+	if err != nil {
+		return entity.UsersWithSegmentsPage{}, err
+	}
+
+	page := entity.UsersWithSegmentsPage{Users: usersWithSegments}
+	if len(usersWithSegments) > limit {
+		page.Users = usersWithSegments[:limit]
+		page.NextCursor = encodeCursor(page.Users[limit-1].User.ID)
+	}
+	if page.Users == nil {
+		page.Users = []entity.UserWithSegments{}
+	}
 
-	return usersWithSegments, nil
+	return page, nil
 }
 
 func (us *UserService) GetUserByID(ctx context.Context, id int) (entity.User, error) {
@@ -116,8 +513,28 @@ func (us *UserService) GetUserByID(ctx context.Context, id int) (entity.User, er
 	return user, nil
 }
 
+// GetUserSegmentsByUserID возвращает текущие сегменты пользователя, включая вычисляемые сегменты,
+// в которые пользователь входит согласно своим текущим сегментам.
 func (us *UserService) GetUserSegmentsByUserID(ctx context.Context, id int) ([]entity.UserSegmentInformation, error) {
-	return us.userRepository.GetUserSegmentsByUserID(ctx, id)
+	segments, err := us.userRepository.GetUserSegmentsByUserID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	derivedSegments, err := us.userRepository.GetUserDerivedSegments(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return append(segments, derivedSegments...), nil
+}
+
+// GetUserSegmentsAt возвращает сегменты, в которые пользователь входил в момент времени `at`.
+// Нулевое значение `at` обозначает текущий момент. Запрос истории допускается и для удалённых пользователей.
+func (us *UserService) GetUserSegmentsAt(ctx context.Context, id int, at time.Time) ([]entity.UserSegmentInformation, error) {
+	if _, err := us.userRepository.GetUserByID(ctx, id); err != nil {
+		return nil, err
+	}
+
+	return us.userRepository.GetUserSegmentsAt(ctx, id, at)
 }
 
 func (us *UserService) GetUserWithSegmentsByUserID(ctx context.Context, id int) (entity.UserWithSegments, error) {
@@ -133,7 +550,7 @@ func (us *UserService) GetUserWithSegmentsByUserID(ctx context.Context, id int)
 			Location: "UserService.GetUserWithSegmentsByUserID - us.userRepository.GetUserByID",
 		}}
 	}
-	segments, err := us.userRepository.GetUserSegmentsByUserID(ctx, id)
+	segments, err := us.GetUserSegmentsByUserID(ctx, id)
 	if err != nil {
 		return entity.UserWithSegments{}, err
 	}
@@ -144,12 +561,43 @@ func (us *UserService) GetUserWithSegmentsByUserID(ctx context.Context, id int)
 	return userWithSegments, nil
 }
 
-func (us *UserService) AddUserToSegments(ctx context.Context, id int, segments []entity.UserSegmentInformation) error {
+// AddUserToSegments добавляет пользователя с идентификатором `id` в сегменты `segments`. Если пользователь
+// уже входит в сегмент группы взаимоисключающих сегментов, добавление в другой сегмент этой группы отклоняется
+// ошибкой ErrSegmentGroupConflict, либо, если `replaceExclusive` равен true, пользователь выходит из прежнего
+// сегмента группы.
+func (us *UserService) AddUserToSegments(ctx context.Context, id int, segments []entity.UserSegmentInformation, replaceExclusive bool) error {
+	// Проверки и добавление выполняются в одной транзакции под блокировкой пользователя,
+	// чтобы конкурирующий запрос не изменил сегменты пользователя между ними
+	return us.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := us.userRepository.LockUser(ctx, id); err != nil {
+			return err
+		}
+
+		segments, segmentsToReplace, err := us.prepareSegmentsToAdd(ctx, id, segments, replaceExclusive)
+		if err != nil {
+			return err
+		}
+		if len(segmentsToReplace) > 0 {
+			if err = us.userRepository.DeleteUserFromSegments(ctx, id, segmentsToReplace); err != nil {
+				return err
+			}
+		}
+
+		return us.userRepository.AddUserToSegments(ctx, id, segments)
+	})
+}
+
+// prepareSegmentsToAdd проверяет возможность добавления пользователя с идентификатором `id`
+// в сегменты `segments` и возвращает сегменты с заполненными идентификаторами, а также, если
+// `replaceExclusive` равен true, записи о вхождении пользователя во взаимоисключающие с ними сегменты,
+// из которых пользователь должен выйти.
+func (us *UserService) prepareSegmentsToAdd(ctx context.Context, id int, segments []entity.UserSegmentInformation, replaceExclusive bool) ([]entity.UserSegmentInformation, []entity.UserSegmentInformation, error) {
 	// Валидация времени, переданного в сегментах
+	now := membershipNow()
 	for _, s := range segments {
 		if s.EndDate != "" {
 			if _, err := time.Parse("15:04:05 02.01.2006", s.EndDate); err != nil {
-				return customError.ErrUserValidationError{ErrBase: customError.ErrBase{
+				return nil, nil, customError.ErrUserValidationError{ErrBase: customError.ErrBase{
 					OriginError:     err,
 					OriginErrorText: err.Error(),
 					Comment:         fmt.Sprintf("Operation was canceled. Invalid \"end_date\" = %s was provided", s.EndDate),
@@ -157,6 +605,30 @@ func (us *UserService) AddUserToSegments(ctx context.Context, id int, segments [
 				}}
 			}
 		}
+		if s.StartDate != "" {
+			startDate, err := time.Parse("15:04:05 02.01.2006", s.StartDate)
+			if err != nil {
+				return nil, nil, customError.ErrUserValidationError{ErrBase: customError.ErrBase{
+					OriginError:     err,
+					OriginErrorText: err.Error(),
+					Comment:         fmt.Sprintf("Operation was canceled. Invalid \"start_date\" = %s was provided", s.StartDate),
+					Location:        "UserService.AddUserToSegments - time.Parse",
+				}}
+			}
+			if startDate.Before(now) {
+				return nil, nil, customError.ErrUserValidationError{ErrBase: customError.ErrBase{
+					Comment:  fmt.Sprintf("Operation was canceled. \"start_date\" = %s of the segment \"%s\" is in the past", s.StartDate, s.Name),
+					Location: "UserService.AddUserToSegments - validation",
+				}}
+			}
+			if window := newMembershipWindow(s, now); !window.end.IsZero() && !window.start.Before(window.end) {
+				return nil, nil, customError.ErrUserValidationError{ErrBase: customError.ErrBase{
+					Comment: fmt.Sprintf("Operation was canceled. \"end_date\" = %s of the segment \"%s\" "+
+						"must be later than its \"start_date\" = %s", s.EndDate, s.Name, s.StartDate),
+					Location: "UserService.AddUserToSegments - validation",
+				}}
+			}
+		}
 	}
 
 	// Валидируем, что в запросе нет повторяющихся сегментов, за О(N) с использованием map
@@ -170,7 +642,7 @@ func (us *UserService) AddUserToSegments(ctx context.Context, id int, segments [
 		}
 		for _, s := range segments {
 			if segmentsInRequest[s.Name] > 1 {
-				return customError.ErrUserValidationError{ErrBase: customError.ErrBase{
+				return nil, nil, customError.ErrUserValidationError{ErrBase: customError.ErrBase{
 					Comment:  fmt.Sprintf("Operation was canceled. The segment \"%s\" occurs more than 1 time in the list", s.Name),
 					Location: "UserService.AddUserToSegments - validation",
 				}}
@@ -182,7 +654,7 @@ func (us *UserService) AddUserToSegments(ctx context.Context, id int, segments [
 	_, err := us.GetUserByID(ctx, id)
 	if err != nil {
 		if _, ok := err.(customError.ErrUserNotFound); ok {
-			return customError.ErrUserNotFound{ErrBase: customError.ErrBase{
+			return nil, nil, customError.ErrUserNotFound{ErrBase: customError.ErrBase{
 				OriginError:     nil,
 				OriginErrorText: "",
 				Comment:         fmt.Sprintf("Operation was canceled because user with id = %d does not exist", id),
@@ -190,68 +662,94 @@ func (us *UserService) AddUserToSegments(ctx context.Context, id int, segments [
 			}}
 		}
 		if _, ok := err.(customError.ErrUserDeleted); ok {
-			return customError.ErrUserDeleted{ErrBase: customError.ErrBase{
+			return nil, nil, customError.ErrUserDeleted{ErrBase: customError.ErrBase{
 				OriginError:     nil,
 				OriginErrorText: "",
 				Comment:         fmt.Sprintf("Operation was canceled because user with id = %d is deleted", id),
 				Location:        "UserService.AddUserToSegments - us.GetUserByID",
 			}}
 		}
-		return err
+		return nil, nil, err
 	}
 
-	// Проверка существования сегментов
+	// Проверка существования сегментов, попутно сгруппируем сегменты по группам взаимоисключающих сегментов
+	segmentGroups := make(map[int][]entity.UserSegmentInformation)
 	for i, segment := range segments {
 		// Проверка на то, что сегмент существует и не удалён
-		tSegment, err := us.segmentRepository.GetSegmentByName(ctx, segment.Name)
+		tSegment, err := getSegmentByCurrentOrFormerName(ctx, us.segmentRepository, segment.Name, us.renameGracePeriod)
 		if err != nil {
 			if _, ok := err.(customError.ErrSegmentNotFound); ok {
-				return customError.ErrSegmentNotFound{ErrBase: customError.ErrBase{
+				return nil, nil, customError.ErrSegmentNotFound{ErrBase: customError.ErrBase{
 					Comment:  fmt.Sprintf("Operation was canceled. Failed to add user (id=%d) to the segment \"%s\" because segment does not exist", id, segment.Name),
 					Location: "UserService.AddUserToSegments - us.segmentRepository.GetSegmentByName",
 				}}
 			}
-			return err
+			return nil, nil, err
 		}
 		if tSegment.IsDeleted {
-			return customError.ErrSegmentDeleted{ErrBase: customError.ErrBase{
+			return nil, nil, customError.ErrSegmentDeleted{ErrBase: customError.ErrBase{
 				Comment: fmt.Sprintf("Operation was canceled. Failed to add user (id=%d) to the "+
 					"segment \"%s\" because segment does not exist "+
 					"(segment was deleted earlier and was not created again)", id, tSegment.Name),
 				Location: "UserService.AddUserToSegments - isDeleted",
 			}}
 		}
+		if err = checkSegmentNotDerived(tSegment, "UserService.AddUserToSegments"); err != nil {
+			return nil, nil, err
+		}
+		// Иначе пользователь мог бы оказаться сразу в нескольких вариантах эксперимента
+		if err = checkSegmentNotInExperiment(ctx, us.segmentRepository, tSegment, "UserService.AddUserToSegments"); err != nil {
+			return nil, nil, err
+		}
 		segments[i].SegmentID = tSegment.ID
+		// Сегмент мог быть найден по прежнему имени
+		segments[i].Name = tSegment.Name
+		// Если дата выхода не указана, вхождение ограничивается сроком по умолчанию, заданным у сегмента
+		if segments[i].EndDate == "" {
+			if end, ok := segmentDefaultEnd(tSegment, newMembershipWindow(segments[i], now).start); ok {
+				segments[i].EndDate = end.Format("15:04:05 02.01.2006")
+			}
+		}
+		if tSegment.GroupID != nil {
+			segmentGroups[*tSegment.GroupID] = append(segmentGroups[*tSegment.GroupID], segments[i])
+		}
 	}
 
-	// Получим сегменты, в которые пользователь входит на текущий момент,
-	// и проверим отсутствие пересечений со списком `segments` за O(N)
-	// с помощью мапы
-	userSegmentsMap := make(map[string]bool)
+	// Получим текущие и запланированные вхождения пользователя в сегменты и проверим, что периоды
+	// вхождения в сегменты из списка `segments` не пересекаются с ними
+	userSegmentsMap := make(map[string][]membershipWindow)
 	var intersection []string
 
 	userSegments, err := us.userRepository.GetUserSegmentsByUserID(ctx, id)
 	if err != nil {
-		return err
+		return nil, nil, err
+	}
+	scheduledSegments, err := us.userRepository.GetUserScheduledSegments(ctx, id)
+	if err != nil {
+		return nil, nil, err
 	}
-	for _, segment := range userSegments {
-		userSegmentsMap[segment.Name] = true
+	for _, segment := range append(userSegments, scheduledSegments...) {
+		userSegmentsMap[segment.Name] = append(userSegmentsMap[segment.Name], newMembershipWindow(segment, now))
 	}
 	for _, segment := range segments {
-		if userSegmentsMap[segment.Name] {
-			intersection = append(intersection, segment.Name)
+		window := newMembershipWindow(segment, now)
+		for _, userWindow := range userSegmentsMap[segment.Name] {
+			if window.overlaps(userWindow) {
+				intersection = append(intersection, segment.Name)
+				break
+			}
 		}
 	}
 
 	// Если есть пересечение, создадим ошибку
 	if len(intersection) > 0 {
-		return customError.ErrUserValidationError{ErrBase: customError.ErrBase{
+		return nil, nil, customError.ErrUserValidationError{ErrBase: customError.ErrBase{
 			OriginError:     nil,
 			OriginErrorText: "",
 			Comment: fmt.Sprintf(
 				"Operation was canceled. "+
 					"Failed to add segments: [%s] to user (id = %d) "+
-					"since user already has this segments",
+					"since user already has or is scheduled to have this segments in the given period",
 				strings.Join(intersection, ", "),
 				id,
 			),
@@ -259,23 +757,79 @@ func (us *UserService) AddUserToSegments(ctx context.Context, id int, segments [
 		}}
 	}
 
-	// В противном случае добавляем пользователя в указанные сегменты
-	return us.userRepository.AddUserToSegments(ctx, id, segments)
+	// Проверим, что пользователь не окажется одновременно в нескольких сегментах одной группы
+	var segmentsToReplace []entity.UserSegmentInformation
+	for groupID, groupSegmentsToAdd := range segmentGroups {
+		if len(groupSegmentsToAdd) > 1 {
+			var names []string
+			for _, segment := range groupSegmentsToAdd {
+				names = append(names, segment.Name)
+			}
+			return nil, nil, customError.ErrSegmentGroupConflict{ErrBase: customError.ErrBase{
+				Comment: fmt.Sprintf("Operation was canceled. Segments [%s] are mutually exclusive "+
+					"and cannot be added to user (id = %d) together", strings.Join(names, ", "), id),
+				Location: "UserService.AddUserToSegments - exclusivity",
+			}}
+		}
+
+		userGroupSegments, err := us.segmentGroupRepository.GetUserSegmentsInGroup(ctx, groupID, id)
+		if err != nil {
+			return nil, nil, err
+		}
+		// Конфликтуют только вхождения, периоды которых пересекаются с периодом добавляемого вхождения
+		window := newMembershipWindow(groupSegmentsToAdd[0], now)
+		var groupSegments []entity.UserSegmentInformation
+		for _, segment := range userGroupSegments {
+			if window.overlaps(newMembershipWindow(segment, now)) {
+				groupSegments = append(groupSegments, segment)
+			}
+		}
+		if len(groupSegments) == 0 {
+			continue
+		}
+		if !replaceExclusive {
+			return nil, nil, customError.ErrSegmentGroupConflict{ErrBase: customError.ErrBase{
+				Comment: fmt.Sprintf("Operation was canceled. Segment \"%s\" cannot be added to user (id = %d) "+
+					"since user already has mutually exclusive segment \"%s\"", groupSegmentsToAdd[0].Name, id, groupSegments[0].Name),
+				Location: "UserService.AddUserToSegments - exclusivity",
+			}}
+		}
+		segmentsToReplace = append(segmentsToReplace, groupSegments...)
+	}
+
+	return segments, segmentsToReplace, nil
 }
 
 func (us *UserService) DeleteUserFromSegments(ctx context.Context, id int, segments []string) error {
+	return us.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := us.userRepository.LockUser(ctx, id); err != nil {
+			return err
+		}
+
+		segmentsToDelete, err := us.prepareSegmentsToDelete(ctx, id, segments)
+		if err != nil {
+			return err
+		}
+
+		return us.userRepository.DeleteUserFromSegments(ctx, id, segmentsToDelete)
+	})
+}
+
+// prepareSegmentsToDelete проверяет возможность удаления пользователя с идентификатором `id`
+// из сегментов `segments` и возвращает соответствующие записи о вхождении пользователя в сегменты.
+func (us *UserService) prepareSegmentsToDelete(ctx context.Context, id int, segments []string) ([]entity.UserSegmentInformation, error) {
 	// Проверим существование юзера
 	_, err := us.GetUserByID(ctx, id)
 	if err != nil {
 		if _, ok := err.(customError.ErrUserNotFound); ok {
-			return customError.ErrUserNotFound{ErrBase: customError.ErrBase{
+			return nil, customError.ErrUserNotFound{ErrBase: customError.ErrBase{
 				OriginError:     nil,
 				OriginErrorText: "",
 				Comment:         fmt.Sprintf("Operation was canceled. User with id = %d does not exist", id),
 				Location:        "UserService.DeleteUserFromSegments - us.GetUserByID",
 			}}
 		}
-		return err
+		return nil, err
 	}
 
 	// Валидируем, что в запросе нет повторяющихся сегментов, за О(N) с использованием map
@@ -289,7 +843,7 @@ func (us *UserService) DeleteUserFromSegments(ctx context.Context, id int, segme
 		}
 		for _, s := range segments {
 			if segmentsInRequest[s] > 1 {
-				return customError.ErrUserValidationError{ErrBase: customError.ErrBase{
+				return nil, customError.ErrUserValidationError{ErrBase: customError.ErrBase{
 					Comment:  fmt.Sprintf("Operation was canceled. The segment \"%s\" occurs more than 1 time in the list", s),
 					Location: "UserService.DeleteUserFromSegments - validation",
 				}}
@@ -298,21 +852,21 @@ func (us *UserService) DeleteUserFromSegments(ctx context.Context, id int, segme
 	}
 
 	// Проверим существование переданных сегментов
-	for _, name := range segments {
-		segment, err := us.segmentRepository.GetSegmentByName(ctx, name)
+	for i, name := range segments {
+		segment, err := getSegmentByCurrentOrFormerName(ctx, us.segmentRepository, name, us.renameGracePeriod)
 		if err != nil {
 			if _, ok := err.(customError.ErrSegmentNotFound); ok {
-				return customError.ErrSegmentNotFound{ErrBase: customError.ErrBase{
+				return nil, customError.ErrSegmentNotFound{ErrBase: customError.ErrBase{
 					OriginError:     nil,
 					OriginErrorText: "",
 					Comment:         fmt.Sprintf("Operation was canceled. Request contains segment %s which does not exist", name),
 					Location:        "UserService.DeleteUserFromSegments - us.segmentRepository.GetSegmentByName",
 				}}
 			}
-			return err
+			return nil, err
 		}
 		if segment.IsDeleted {
-			return customError.ErrSegmentDeleted{ErrBase: customError.ErrBase{
+			return nil, customError.ErrSegmentDeleted{ErrBase: customError.ErrBase{
 				OriginError:     nil,
 				OriginErrorText: "",
 				Comment: fmt.Sprintf("Operation was canceled. Request contains segment \"%s\" which "+
@@ -320,13 +874,28 @@ func (us *UserService) DeleteUserFromSegments(ctx context.Context, id int, segme
 				Location: "UserService.DeleteUserFromSegments - us.segmentRepository.GetSegmentByName",
 			}}
 		}
+		if err = checkSegmentNotDerived(segment, "UserService.DeleteUserFromSegments"); err != nil {
+			return nil, err
+		}
+		// Иначе пользователь мог бы остаться вне всех вариантов эксперимента
+		if err = checkSegmentNotInExperiment(ctx, us.segmentRepository, segment, "UserService.DeleteUserFromSegments"); err != nil {
+			return nil, err
+		}
+		// Сегмент мог быть найден по прежнему имени
+		segments[i] = segment.Name
 	}
 
-	// Проверим, что пользователь входит в переданные сегменты
-	userSegments, err := us.GetUserSegmentsByUserID(ctx, id)
+	// Проверим, что пользователь входит или запланирован к вхождению в переданные сегменты.
+	// Запланированные вхождения при удалении отменяются
+	userSegments, err := us.userRepository.GetUserSegmentsByUserID(ctx, id)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	scheduledSegments, err := us.userRepository.GetUserScheduledSegments(ctx, id)
+	if err != nil {
+		return nil, err
 	}
+	userSegments = append(userSegments, scheduledSegments...)
 	userSegmentsMap := make(map[string]bool)
 	var exclusion []string
 	for _, segmentInfo := range userSegments {
@@ -340,7 +909,7 @@ func (us *UserService) DeleteUserFromSegments(ctx context.Context, id int, segme
 
 	// Если есть исключения (т.е. сегменты, в которые пользователь не входит), создадим ошибку
 	if len(exclusion) > 0 {
-		return customError.ErrUserValidationError{ErrBase: customError.ErrBase{
+		return nil, customError.ErrUserValidationError{ErrBase: customError.ErrBase{
 			OriginError:     nil,
 			OriginErrorText: "",
 			Comment: fmt.Sprintf(
@@ -364,5 +933,61 @@ func (us *UserService) DeleteUserFromSegments(ctx context.Context, id int, segme
 		}
 	}
 
-	return us.userRepository.DeleteUserFromSegments(ctx, id, segmentsToDelete)
+	return segmentsToDelete, nil
+}
+
+// UpdateUserSegments в рамках одной транзакции добавляет пользователя с идентификатором `id`
+// в сегменты `segmentsToAdd` и удаляет его из сегментов `segmentsToDelete`. Если хотя бы одна
+// из операций не может быть выполнена, не выполняется ни одна из них.
+func (us *UserService) UpdateUserSegments(ctx context.Context, id int, segmentsToAdd []entity.UserSegmentInformation, segmentsToDelete []string) error {
+	// Валидация
+	if len(segmentsToAdd) == 0 && len(segmentsToDelete) == 0 {
+		return customError.ErrUserValidationError{ErrBase: customError.ErrBase{
+			Comment:  "Operation was canceled. Neither segments to add nor segments to delete were provided",
+			Location: "UserService.UpdateUserSegments - validation",
+		}}
+	}
+
+	// Проверим, что один и тот же сегмент не указан одновременно в обоих списках
+	{
+		segmentsToDeleteMap := make(map[string]bool)
+		for _, name := range segmentsToDelete {
+			segmentsToDeleteMap[name] = true
+		}
+		for _, s := range segmentsToAdd {
+			if segmentsToDeleteMap[s.Name] {
+				return customError.ErrUserValidationError{ErrBase: customError.ErrBase{
+					Comment:  fmt.Sprintf("Operation was canceled. The segment \"%s\" occurs both in segments to add and in segments to delete", s.Name),
+					Location: "UserService.UpdateUserSegments - validation",
+				}}
+			}
+		}
+	}
+
+	return us.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := us.userRepository.LockUser(ctx, id); err != nil {
+			return err
+		}
+
+		if len(segmentsToDelete) > 0 {
+			userSegmentsToDelete, err := us.prepareSegmentsToDelete(ctx, id, segmentsToDelete)
+			if err != nil {
+				return err
+			}
+			if err = us.userRepository.DeleteUserFromSegments(ctx, id, userSegmentsToDelete); err != nil {
+				return err
+			}
+		}
+		if len(segmentsToAdd) > 0 {
+			segmentsToAdd, _, err := us.prepareSegmentsToAdd(ctx, id, segmentsToAdd, false)
+			if err != nil {
+				return err
+			}
+			if err = us.userRepository.AddUserToSegments(ctx, id, segmentsToAdd); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
 }
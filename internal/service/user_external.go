@@ -0,0 +1,118 @@
+package service
+
+import (
+	"avito-rest-api/internal/entity"
+	customError "avito-rest-api/internal/error"
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxExternalIDLength - максимальная длина внешнего идентификатора пользователя.
+const maxExternalIDLength = 255
+
+// validateExternalID проверяет внешний идентификатор пользователя. Пустое значение означает
+// отсутствие внешнего идентификатора.
+func validateExternalID(externalID string, location string) error {
+	if externalID == "" {
+		return nil
+	}
+	if strings.TrimSpace(externalID) == "" {
+		return customError.ErrUserValidationError{ErrBase: customError.ErrBase{
+			Comment:  "\"external_id\" field cannot be blank",
+			Location: location,
+		}}
+	}
+	if utf8.RuneCountInString(externalID) > maxExternalIDLength {
+		return customError.ErrUserValidationError{ErrBase: customError.ErrBase{
+			Comment:  fmt.Sprintf("\"external_id\" field cannot be longer than %d characters", maxExternalIDLength),
+			Location: location,
+		}}
+	}
+	return nil
+}
+
+// externalID возвращает указатель на внешний идентификатор или nil, если он не задан.
+func externalID(externalID string) *string {
+	if externalID == "" {
+		return nil
+	}
+	return &externalID
+}
+
+// GetUserByExternalID возвращает пользователя с указанным внешним идентификатором.
+func (us *UserService) GetUserByExternalID(ctx context.Context, externalID string) (entity.User, error) {
+	if err := validateExternalID(externalID, "UserService.GetUserByExternalID"); err != nil {
+		return entity.User{}, err
+	}
+
+	user, err := us.userRepository.GetUserByExternalID(ctx, externalID)
+	if err != nil {
+		return entity.User{}, err
+	}
+	if user.IsDeleted {
+		return entity.User{}, customError.ErrUserDeleted{ErrBase: customError.ErrBase{
+			Comment:  fmt.Sprintf("User with external id \"%s\" is deleted", externalID),
+			Location: "UserService.GetUserByExternalID - us.userRepository.GetUserByExternalID",
+		}}
+	}
+	return user, nil
+}
+
+// UpsertUserByExternalID создаёт пользователя с указанным внешним идентификатором или, если такой
+// пользователь уже существует, заменяет его атрибуты переданными. Повторная отправка одних и тех же
+// данных не создаёт дубликатов. Вхождение пользователя в сегменты с правилами приводится
+// в соответствие с его атрибутами. Возвращает пользователя и признак того, что он был создан.
+func (us *UserService) UpsertUserByExternalID(ctx context.Context, externalID string, input UserCreateInput) (entity.User, bool, error) {
+	// Валидация
+	if externalID == "" {
+		return entity.User{}, false, customError.ErrUserValidationError{ErrBase: customError.ErrBase{
+			Comment:  "external id cannot be empty",
+			Location: "UserService.UpsertUserByExternalID",
+		}}
+	}
+	if input.ExternalID != "" && input.ExternalID != externalID {
+		return entity.User{}, false, customError.ErrUserValidationError{ErrBase: customError.ErrBase{
+			Comment:  fmt.Sprintf("\"external_id\" field \"%s\" does not match external id \"%s\" in path", input.ExternalID, externalID),
+			Location: "UserService.UpsertUserByExternalID",
+		}}
+	}
+	input.ExternalID = externalID
+	if err := validateUserCreateInput(input, "UserService.UpsertUserByExternalID"); err != nil {
+		return entity.User{}, false, err
+	}
+
+	// Маппинг данных из DTO в сущность User
+	user := entity.User{
+		Name:       input.Name,
+		Lastname:   input.Lastname,
+		Sex:        input.Sex,
+		Age:        input.Age,
+		Attributes: userAttributes(input.Attributes),
+		ExternalID: &externalID,
+	}
+
+	var created bool
+	err := us.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		id, isCreated, err := us.userRepository.UpsertUserByExternalID(ctx, user)
+		if err != nil {
+			return err
+		}
+		user.ID = id
+		created = isCreated
+
+		if err = us.syncAutomaticSegments(ctx, user); err != nil {
+			return err
+		}
+
+		// Получим пользователя повторно, чтобы узнать значения, вычисляемые базой данных
+		user, err = us.userRepository.GetUserByID(ctx, id)
+		return err
+	})
+	if err != nil {
+		return entity.User{}, false, err
+	}
+
+	return user, created, nil
+}
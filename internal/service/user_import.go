@@ -0,0 +1,308 @@
+package service
+
+import (
+	"avito-rest-api/internal/entity"
+	customError "avito-rest-api/internal/error"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/gocarina/gocsv"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Форматы входных данных массового импорта пользователей
+const (
+	UserImportFormatCSV    = "csv"
+	UserImportFormatNDJSON = "ndjson"
+)
+
+// userImportCSVRow - строка CSV-файла с импортируемым пользователем. Числовые поля и атрибуты читаются
+// как строки, чтобы ошибка в одной строке не прерывала разбор всего файла. Столбцы external_id и attributes
+// необязательны.
+type userImportCSVRow struct {
+	ExternalID string `csv:"external_id"`
+	Name       string `csv:"name"`
+	Lastname   string `csv:"lastname"`
+	Sex        string `csv:"sex"`
+	Age        string `csv:"age"`
+	// Дополнительные атрибуты пользователя в виде JSON-объекта
+	Attributes string `csv:"attributes"`
+}
+
+// userImportJSONRow - строка NDJSON-файла с импортируемым пользователем.
+type userImportJSONRow struct {
+	ExternalID string `json:"external_id"`
+	Name       string `json:"name"`
+	Lastname   string `json:"lastname"`
+	Sex        *int   `json:"sex"`
+	Age        *int   `json:"age"`
+	// Дополнительные атрибуты пользователя
+	Attributes map[string]interface{} `json:"attributes"`
+}
+
+// userImportRow - прошедшая валидацию строка входных данных с её номером.
+type userImportRow struct {
+	row   int
+	input UserCreateInput
+}
+
+// ImportUsers создаёт пользователей из данных `data` в формате `format` (UserImportFormatCSV с заголовком
+// name,lastname,sex,age и необязательными столбцами external_id и attributes или UserImportFormatNDJSON).
+// Каждая строка валидируется по тем же правилам, что и при создании одного пользователя: корректные строки
+// импортируются, а ошибки остальных возвращаются в отчёте. Строка отклоняется и в том случае, если её внешний
+// идентификатор уже занят другим пользователем или указан в одной из предыдущих строк.
+// Импортированные пользователи добавляются в сегменты с правилами и процентом раскатки, которым удовлетворяют,
+// и распределяются по вариантам экспериментов. Импорт выполняется в одной транзакции.
+func (us *UserService) ImportUsers(ctx context.Context, format string, data io.Reader) (entity.UserImportReport, error) {
+	var rows []userImportRow
+	var rowErrors []entity.UserImportError
+	var err error
+	switch format {
+	case UserImportFormatCSV:
+		rows, rowErrors, err = parseUsersCSV(data)
+	case UserImportFormatNDJSON:
+		rows, rowErrors, err = parseUsersNDJSON(data)
+	default:
+		return entity.UserImportReport{}, customError.ErrUserValidationError{ErrBase: customError.ErrBase{
+			Comment:  fmt.Sprintf("Unsupported import format \"%s\", expected %s or %s", format, UserImportFormatCSV, UserImportFormatNDJSON),
+			Location: "UserService.ImportUsers - validation",
+		}}
+	}
+	if err != nil {
+		return entity.UserImportReport{}, err
+	}
+
+	var report entity.UserImportReport
+	err = us.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		rows, rowErrors, err := us.rejectDuplicateExternalIDs(ctx, rows, rowErrors)
+		if err != nil {
+			return err
+		}
+		sort.Slice(rowErrors, func(i, j int) bool {
+			return rowErrors[i].Row < rowErrors[j].Row
+		})
+		report = entity.UserImportReport{Failed: len(rowErrors), Errors: rowErrors}
+		if report.Errors == nil {
+			report.Errors = []entity.UserImportError{}
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+
+		users := make([]entity.User, 0, len(rows))
+		for _, row := range rows {
+			users = append(users, entity.User{
+				ExternalID: externalID(row.input.ExternalID),
+				Name:       row.input.Name,
+				Lastname:   row.input.Lastname,
+				Sex:        row.input.Sex,
+				Age:        row.input.Age,
+				Attributes: userAttributes(row.input.Attributes),
+			})
+		}
+		userIDs, err := us.userRepository.ImportUsers(ctx, users)
+		if err != nil {
+			return err
+		}
+		report.Imported = len(userIDs)
+
+		// Вхождение в автоматические сегменты и эксперименты обновляется запросами над множеством
+		// импортированных пользователей, а не для каждого из них в отдельности; вхождения остальных
+		// пользователей не затрагиваются
+		automaticSegments, err := us.segmentRepository.GetAutomaticSegments(ctx)
+		if err != nil {
+			return err
+		}
+		for _, segment := range automaticSegments {
+			if err = us.segmentRepository.AddMatchingUsersToSegment(ctx, segment, userIDs); err != nil {
+				return err
+			}
+		}
+
+		experiments, err := us.experimentRepository.GetAllExperiments(ctx)
+		if err != nil {
+			return err
+		}
+		for _, experiment := range experiments {
+			if err = us.experimentRepository.AssignUsersToExperiment(ctx, experiment, userIDs); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return entity.UserImportReport{}, err
+	}
+
+	return report, nil
+}
+
+// rejectDuplicateExternalIDs отклоняет строки `rows`, внешний идентификатор которых уже занят другим
+// пользователем или указан в одной из предыдущих строк, и добавляет их ошибки к `rowErrors`.
+func (us *UserService) rejectDuplicateExternalIDs(ctx context.Context, rows []userImportRow, rowErrors []entity.UserImportError) ([]userImportRow, []entity.UserImportError, error) {
+	var externalIDs []string
+	for _, row := range rows {
+		if row.input.ExternalID != "" {
+			externalIDs = append(externalIDs, row.input.ExternalID)
+		}
+	}
+	if len(externalIDs) == 0 {
+		return rows, rowErrors, nil
+	}
+
+	taken, err := us.userRepository.GetTakenExternalIDs(ctx, externalIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+	isTaken := make(map[string]bool, len(taken))
+	for _, externalID := range taken {
+		isTaken[externalID] = true
+	}
+
+	var accepted []userImportRow
+	firstRows := make(map[string]int)
+	for _, row := range rows {
+		externalID := row.input.ExternalID
+		if externalID != "" {
+			if isTaken[externalID] {
+				rowErrors = append(rowErrors, entity.UserImportError{
+					Row:   row.row,
+					Error: fmt.Sprintf("User with external id \"%s\" already exists", externalID),
+				})
+				continue
+			}
+			if firstRow, ok := firstRows[externalID]; ok {
+				rowErrors = append(rowErrors, entity.UserImportError{
+					Row:   row.row,
+					Error: fmt.Sprintf("\"external_id\" \"%s\" is already used in row %d", externalID, firstRow),
+				})
+				continue
+			}
+			firstRows[externalID] = row.row
+		}
+		accepted = append(accepted, row)
+	}
+
+	return accepted, rowErrors, nil
+}
+
+// parseUsersCSV разбирает CSV-файл с импортируемыми пользователями и валидирует каждую его строку.
+func parseUsersCSV(data io.Reader) ([]userImportRow, []entity.UserImportError, error) {
+	var rows []*userImportCSVRow
+	if err := gocsv.Unmarshal(data, &rows); err != nil {
+		return nil, nil, customError.ErrUserValidationError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment: "Failed to parse CSV, expected header name,lastname,sex,age (optionally with external_id " +
+				"and attributes) and one user per row",
+			Location: "UserService.ImportUsers - gocsv.Unmarshal",
+		}}
+	}
+
+	var inputs []userImportRow
+	var rowErrors []entity.UserImportError
+	for i, row := range rows {
+		input := UserCreateInput{ExternalID: row.ExternalID, Name: row.Name, Lastname: row.Lastname}
+		var comment string
+		if strings.TrimSpace(row.Sex) == "" {
+			comment = "\"sex\" field is required"
+		} else if input.Sex, comment = parseImportNumber("sex", row.Sex); comment == "" {
+			if strings.TrimSpace(row.Age) == "" {
+				comment = "\"age\" field is required"
+			} else {
+				input.Age, comment = parseImportNumber("age", row.Age)
+			}
+		}
+		if comment == "" && strings.TrimSpace(row.Attributes) != "" {
+			if err := json.Unmarshal([]byte(row.Attributes), &input.Attributes); err != nil {
+				comment = "\"attributes\" field must be JSON object"
+			}
+		}
+		if comment == "" {
+			comment = validateImportRow(input)
+		}
+
+		if comment != "" {
+			rowErrors = append(rowErrors, entity.UserImportError{Row: i + 1, Error: comment})
+			continue
+		}
+		inputs = append(inputs, userImportRow{row: i + 1, input: input})
+	}
+
+	return inputs, rowErrors, nil
+}
+
+// parseUsersNDJSON разбирает NDJSON-файл с импортируемыми пользователями и валидирует каждую его строку.
+// Пустые строки пропускаются.
+func parseUsersNDJSON(data io.Reader) ([]userImportRow, []entity.UserImportError, error) {
+	scanner := bufio.NewScanner(data)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var inputs []userImportRow
+	var rowErrors []entity.UserImportError
+	for line := 1; scanner.Scan(); line++ {
+		raw := bytes.TrimSpace(scanner.Bytes())
+		if len(raw) == 0 {
+			continue
+		}
+
+		var row userImportJSONRow
+		var comment string
+		if err := json.Unmarshal(raw, &row); err != nil {
+			comment = fmt.Sprintf("invalid JSON: %s", err.Error())
+		} else if row.Sex == nil {
+			comment = "\"sex\" field is required"
+		} else if row.Age == nil {
+			comment = "\"age\" field is required"
+		}
+
+		input := UserCreateInput{ExternalID: row.ExternalID, Name: row.Name, Lastname: row.Lastname, Attributes: row.Attributes}
+		if comment == "" {
+			input.Sex, input.Age = *row.Sex, *row.Age
+			comment = validateImportRow(input)
+		}
+
+		if comment != "" {
+			rowErrors = append(rowErrors, entity.UserImportError{Row: line, Error: comment})
+			continue
+		}
+		inputs = append(inputs, userImportRow{row: line, input: input})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, customError.ErrUserValidationError{ErrBase: customError.ErrBase{
+			OriginError:     err,
+			OriginErrorText: err.Error(),
+			Comment:         "Failed to read NDJSON, each line must be a JSON object not longer than 1 MB",
+			Location:        "UserService.ImportUsers - scanner.Scan",
+		}}
+	}
+
+	return inputs, rowErrors, nil
+}
+
+// parseImportNumber разбирает целочисленное поле `field` импортируемой строки и возвращает
+// пояснение ошибки, если значение не является целым числом.
+func parseImportNumber(field string, value string) (int, string) {
+	number, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		return 0, fmt.Sprintf("\"%s\" field must be integer number", field)
+	}
+	return number, ""
+}
+
+// validateImportRow валидирует импортируемого пользователя по правилам создания пользователя
+// и возвращает пояснение ошибки валидации или пустую строку.
+func validateImportRow(input UserCreateInput) string {
+	if err := validateUserCreateInput(input, "UserService.ImportUsers"); err != nil {
+		if validationErr, ok := err.(customError.ErrUserValidationError); ok {
+			return validationErr.Comment
+		}
+		return err.Error()
+	}
+	return ""
+}
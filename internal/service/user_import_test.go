@@ -0,0 +1,156 @@
+package service
+
+import (
+	"avito-rest-api/internal/entity"
+	"avito-rest-api/internal/repository"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+// importingUserRepository - заглушка репозитория пользователей, выдающая импортированным пользователям
+// идентификаторы после уже существующих и запоминающая импортированных пользователей.
+type importingUserRepository struct {
+	repository.User
+	lastID   int
+	taken    []string
+	imported []entity.User
+}
+
+func (r *importingUserRepository) GetTakenExternalIDs(_ context.Context, externalIDs []string) ([]string, error) {
+	var taken []string
+	for _, externalID := range externalIDs {
+		for _, t := range r.taken {
+			if externalID == t {
+				taken = append(taken, externalID)
+			}
+		}
+	}
+	return taken, nil
+}
+
+func (r *importingUserRepository) ImportUsers(_ context.Context, users []entity.User) ([]int, error) {
+	r.imported = append(r.imported, users...)
+	ids := make([]int, 0, len(users))
+	for range users {
+		r.lastID++
+		ids = append(ids, r.lastID)
+	}
+	return ids, nil
+}
+
+// importExperimentRepository - заглушка репозитория экспериментов, запоминающая распределяемых пользователей.
+type importExperimentRepository struct {
+	repository.Experiment
+	assigned map[int][]int
+}
+
+func (r *importExperimentRepository) GetAllExperiments(_ context.Context) ([]entity.Experiment, error) {
+	return []entity.Experiment{{ID: 7, Name: "AVITO_CHECKOUT"}}, nil
+}
+
+func (r *importExperimentRepository) AssignUsersToExperiment(_ context.Context, experiment entity.Experiment, userIDs []int) error {
+	r.assigned[experiment.ID] = userIDs
+	return nil
+}
+
+func TestUserService_ImportUsers(t *testing.T) {
+	// Инициализация зависимостей
+	userRepo := &importingUserRepository{lastID: 100}
+	segmentRepo := newFakeSegmentRepository(
+		entity.Segment{ID: 43, Name: "AVITO_ADULTS", Rules: []entity.SegmentRule{{Attribute: "age", Operator: ">=", Value: 18}}},
+		entity.Segment{ID: 44, Name: "AVITO_DISCOUNT_30"},
+	)
+	experimentRepo := &importExperimentRepository{assigned: map[int][]int{}}
+	us := NewUserService(userRepo, segmentRepo, nil, experimentRepo, &stubTransactor{}, 0)
+	data := "name,lastname,sex,age\n" +
+		"Михаил,Иванов,0,27\n" +
+		"Анна,Петрова,1,abc\n" +
+		"Ольга,Андреева,1,29\n"
+
+	// Выполнение
+	report, err := us.ImportUsers(context.Background(), UserImportFormatCSV, strings.NewReader(data))
+
+	// Проверка результата: вхождение в автоматические сегменты и эксперименты обновлено
+	// только для импортированных пользователей
+	assert.NoError(t, err)
+	assert.Equal(t, 2, report.Imported)
+	assert.Equal(t, 1, report.Failed)
+	assert.Equal(t, map[int][]int{43: {101, 102}}, segmentRepo.added)
+	assert.Empty(t, segmentRepo.synced)
+	assert.Equal(t, map[int][]int{7: {101, 102}}, experimentRepo.assigned)
+}
+
+func TestUserService_ImportUsers_ExternalID(t *testing.T) {
+	testCases := []struct {
+		name                string
+		format              string
+		data                string
+		expectedExternalIDs []*string
+		expectedAttributes  []map[string]interface{}
+		expectedErrors      []entity.UserImportError
+	}{
+		{
+			name:   "CSV with external ids and attributes",
+			format: UserImportFormatCSV,
+			data: "external_id,name,lastname,sex,age,attributes\n" +
+				"crm-1,Михаил,Иванов,0,27,\"{\"\"city\"\":\"\"Москва\"\"}\"\n" +
+				",Анна,Петрова,1,25,\n" +
+				"crm-2,Ольга,Андреева,1,29,[1]\n",
+			expectedExternalIDs: []*string{externalID("crm-1"), nil},
+			expectedAttributes:  []map[string]interface{}{{"city": "Москва"}, {}},
+			expectedErrors:      []entity.UserImportError{{Row: 3, Error: "\"attributes\" field must be JSON object"}},
+		},
+		{
+			name:   "CSV without optional columns",
+			format: UserImportFormatCSV,
+			data: "name,lastname,sex,age\n" +
+				"Михаил,Иванов,0,27\n",
+			expectedExternalIDs: []*string{nil},
+			expectedAttributes:  []map[string]interface{}{{}},
+			expectedErrors:      []entity.UserImportError{},
+		},
+		{
+			name:   "NDJSON with taken and repeated external ids",
+			format: UserImportFormatNDJSON,
+			data: `{"external_id":"crm-1","name":"Михаил","lastname":"Иванов","sex":0,"age":27}` + "\n" +
+				`{"external_id":"crm-42","name":"Анна","lastname":"Петрова","sex":1,"age":25}` + "\n" +
+				`{"external_id":"crm-1","name":"Ольга","lastname":"Андреева","sex":1,"age":29}` + "\n" +
+				`{"external_id":" ","name":"Иван","lastname":"Сидоров","sex":0,"age":31}` + "\n",
+			expectedExternalIDs: []*string{externalID("crm-1")},
+			expectedAttributes:  []map[string]interface{}{{}},
+			expectedErrors: []entity.UserImportError{
+				{Row: 2, Error: "User with external id \"crm-42\" already exists"},
+				{Row: 3, Error: "\"external_id\" \"crm-1\" is already used in row 1"},
+				{Row: 4, Error: "\"external_id\" field cannot be blank"},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Инициализация зависимостей
+			userRepo := &importingUserRepository{lastID: 100, taken: []string{"crm-42"}}
+			experimentRepo := &noExperimentRepository{}
+			us := NewUserService(userRepo, newFakeSegmentRepository(), nil, experimentRepo, &stubTransactor{}, 0)
+
+			// Выполнение
+			report, err := us.ImportUsers(context.Background(), tc.format, strings.NewReader(tc.data))
+
+			// Проверка результата: строки с занятым или повторяющимся внешним идентификатором отклонены
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedErrors, report.Errors)
+			assert.Equal(t, len(tc.expectedErrors), report.Failed)
+			assert.Equal(t, len(tc.expectedExternalIDs), report.Imported)
+			var externalIDs []*string
+			var attributes []map[string]interface{}
+			for _, user := range userRepo.imported {
+				externalIDs = append(externalIDs, user.ExternalID)
+				attributes = append(attributes, user.Attributes)
+			}
+			assert.Equal(t, tc.expectedExternalIDs, externalIDs)
+			assert.Equal(t, tc.expectedAttributes, attributes)
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package service
+
+import (
+	"avito-rest-api/internal/entity"
+	customError "avito-rest-api/internal/error"
+	"context"
+	"fmt"
+	"time"
+)
+
+// UpdateUserSegmentEndDate переносит дату выхода пользователя с идентификатором `id` из сегмента `name`
+// в текущем вхождении на `endDate` или, если `endDate` пуста, делает вхождение бессрочным. Изменение
+// записывается в историю изменений вхождения. Возвращает изменённое вхождение.
+func (us *UserService) UpdateUserSegmentEndDate(ctx context.Context, id int, name string, endDate string) (entity.UserSegmentInformation, error) {
+	// Валидация
+	now := membershipNow()
+	var newEndDate *time.Time
+	if endDate != "" {
+		parsed, err := time.Parse("15:04:05 02.01.2006", endDate)
+		if err != nil {
+			return entity.UserSegmentInformation{}, customError.ErrUserValidationError{ErrBase: customError.ErrBase{
+				OriginError:     err,
+				OriginErrorText: err.Error(),
+				Comment:         fmt.Sprintf("Operation was canceled. Invalid \"end_date\" = %s was provided", endDate),
+				Location:        "UserService.UpdateUserSegmentEndDate - time.Parse",
+			}}
+		}
+		if !parsed.After(now) {
+			return entity.UserSegmentInformation{}, customError.ErrUserValidationError{ErrBase: customError.ErrBase{
+				Comment: fmt.Sprintf("Operation was canceled. \"end_date\" = %s is not in the future, "+
+					"remove the user from the segment instead", endDate),
+				Location: "UserService.UpdateUserSegmentEndDate - validation",
+			}}
+		}
+		newEndDate = &parsed
+	}
+
+	var membership entity.UserSegmentInformation
+	err := us.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := us.userRepository.LockUser(ctx, id); err != nil {
+			return err
+		}
+		if _, err := us.GetUserByID(ctx, id); err != nil {
+			return err
+		}
+
+		segment, err := getSegmentByCurrentOrFormerName(ctx, us.segmentRepository, name, us.renameGracePeriod)
+		if err != nil {
+			return err
+		}
+		if segment.IsDeleted {
+			return customError.ErrSegmentDeleted{ErrBase: customError.ErrBase{
+				Comment: fmt.Sprintf("Operation was canceled. Segment \"%s\" does not exist "+
+					"(segment was deleted earlier and was not created again)", name),
+				Location: "UserService.UpdateUserSegmentEndDate - isDeleted",
+			}}
+		}
+		// Вхождением в сегменты с правилами, процентом раскатки и сегменты вариантов экспериментов
+		// управляет сервис, поэтому срок такого вхождения изменять нельзя
+		if err = checkSegmentNotDerived(segment, "UserService.UpdateUserSegmentEndDate"); err != nil {
+			return err
+		}
+		if len(segment.Rules) > 0 || segment.Percentage != nil {
+			return customError.ErrUserValidationError{ErrBase: customError.ErrBase{
+				Comment: fmt.Sprintf("Operation was canceled. Membership in segment \"%s\" is managed "+
+					"by its rules and percentage and cannot be changed manually", name),
+				Location: "UserService.UpdateUserSegmentEndDate - validation",
+			}}
+		}
+		experimentName, err := us.segmentRepository.GetSegmentExperimentName(ctx, segment.ID)
+		if err != nil {
+			return err
+		}
+		if experimentName != "" {
+			return customError.ErrUserValidationError{ErrBase: customError.ErrBase{
+				Comment: fmt.Sprintf("Operation was canceled. Membership in segment \"%s\" is managed "+
+					"by experiment \"%s\" and cannot be changed manually", name, experimentName),
+				Location: "UserService.UpdateUserSegmentEndDate - validation",
+			}}
+		}
+
+		// Найдём текущее вхождение пользователя в сегмент
+		userSegments, err := us.userRepository.GetUserSegmentsByUserID(ctx, id)
+		if err != nil {
+			return err
+		}
+		found := false
+		for _, userSegment := range userSegments {
+			if userSegment.SegmentID == segment.ID {
+				membership, found = userSegment, true
+				break
+			}
+		}
+		if !found {
+			return customError.ErrUserValidationError{ErrBase: customError.ErrBase{
+				Comment:  fmt.Sprintf("Operation was canceled. User (id = %d) does not belong to segment \"%s\"", id, name),
+				Location: "UserService.UpdateUserSegmentEndDate - us.userRepository.GetUserSegmentsByUserID",
+			}}
+		}
+
+		// Новый период вхождения не должен пересекаться с запланированными вхождениями в тот же сегмент
+		membership.EndDate = endDate
+		window := newMembershipWindow(membership, now)
+		scheduledSegments, err := us.userRepository.GetUserScheduledSegments(ctx, id)
+		if err != nil {
+			return err
+		}
+		for _, scheduled := range scheduledSegments {
+			if scheduled.SegmentID == segment.ID && window.overlaps(newMembershipWindow(scheduled, now)) {
+				return customError.ErrUserValidationError{ErrBase: customError.ErrBase{
+					Comment: fmt.Sprintf("Operation was canceled. New \"end_date\" of user (id = %d) in segment \"%s\" "+
+						"overlaps scheduled membership starting at %s", id, name, scheduled.StartDate),
+					Location: "UserService.UpdateUserSegmentEndDate - overlap",
+				}}
+			}
+		}
+
+		return us.userRepository.UpdateUserSegmentEndDate(ctx, membership.InfoID, newEndDate)
+	})
+	if err != nil {
+		return entity.UserSegmentInformation{}, err
+	}
+
+	return membership, nil
+}
@@ -0,0 +1,167 @@
+package service
+
+import (
+	"avito-rest-api/internal/entity"
+	"avito-rest-api/internal/repository"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// countingUserRepository - заглушка репозитория пользователей, считающая обращения за страницами пользователей.
+// Методы, не переопределённые заглушкой, не должны вызываться.
+type countingUserRepository struct {
+	repository.User
+	users   []entity.UserWithSegments
+	queries int
+}
+
+func (r *countingUserRepository) GetUsersWithSegments(_ context.Context, afterID int, limit int) ([]entity.UserWithSegments, error) {
+	r.queries++
+	var page []entity.UserWithSegments
+	for _, user := range r.users {
+		if user.User.ID > afterID && len(page) < limit {
+			page = append(page, user)
+		}
+	}
+	return page, nil
+}
+
+func TestUserService_GetAllUsersWithSegments(t *testing.T) {
+	// Инициализация зависимостей
+	repo := &countingUserRepository{}
+	for id := 1; id <= 5; id++ {
+		repo.users = append(repo.users, entity.UserWithSegments{
+			User: entity.User{ID: id, Name: "Михаил", Lastname: "Иванов", Age: 27},
+			Segments: []entity.UserSegmentInformation{
+				{InfoID: id, UserID: id, SegmentID: 1, Name: "AVITO_MUSIC_SERVICE"},
+			},
+		})
+	}
+	us := &UserService{userRepository: repo}
+
+	// Выполнение: обход всех страниц по 2 пользователя
+	var pages [][]int
+	input := PageInput{Limit: 2}
+	for {
+		page, err := us.GetAllUsersWithSegments(context.Background(), input)
+		assert.NoError(t, err)
+		var ids []int
+		for _, user := range page.Users {
+			assert.Len(t, user.Segments, 1)
+			ids = append(ids, user.User.ID)
+		}
+		pages = append(pages, ids)
+		if page.NextCursor == "" {
+			break
+		}
+		input.Cursor = page.NextCursor
+	}
+
+	// Проверка результата: каждая страница получена одним обращением к репозиторию; количество
+	// запросов к БД внутри обращения проверяет TestUserRepository_GetUsersWithSegments
+	assert.Equal(t, [][]int{{1, 2}, {3, 4}, {5}}, pages)
+	assert.Equal(t, 3, repo.queries)
+}
+
+// restoringUserRepository - заглушка репозитория пользователей с текущими и запланированными вхождениями
+// пользователя, запоминающая добавляемые и удаляемые вхождения.
+type restoringUserRepository struct {
+	repository.User
+	user      entity.User
+	current   []entity.UserSegmentInformation
+	scheduled []entity.UserSegmentInformation
+	added     []string
+	deleted   []int
+}
+
+func (r *restoringUserRepository) LockUser(_ context.Context, _ int) error {
+	return nil
+}
+
+func (r *restoringUserRepository) GetUserByID(_ context.Context, _ int) (entity.User, error) {
+	return r.user, nil
+}
+
+func (r *restoringUserRepository) RestoreUser(_ context.Context, _ int) error {
+	return nil
+}
+
+func (r *restoringUserRepository) GetUserSegmentsByUserID(_ context.Context, _ int) ([]entity.UserSegmentInformation, error) {
+	return r.current, nil
+}
+
+func (r *restoringUserRepository) GetUserScheduledSegments(_ context.Context, _ int) ([]entity.UserSegmentInformation, error) {
+	return r.scheduled, nil
+}
+
+func (r *restoringUserRepository) AddUserToSegments(_ context.Context, _ int, segments []entity.UserSegmentInformation) error {
+	for _, segment := range segments {
+		r.added = append(r.added, segment.Name)
+	}
+	return nil
+}
+
+func (r *restoringUserRepository) DeleteUserFromSegments(_ context.Context, _ int, segments []entity.UserSegmentInformation) error {
+	for _, segment := range segments {
+		r.deleted = append(r.deleted, segment.InfoID)
+	}
+	return nil
+}
+
+// noExperimentRepository - заглушка репозитория экспериментов без экспериментов.
+type noExperimentRepository struct {
+	repository.Experiment
+}
+
+func (r *noExperimentRepository) GetAllExperiments(_ context.Context) ([]entity.Experiment, error) {
+	return nil, nil
+}
+
+func TestUserService_RestoreUser(t *testing.T) {
+	adults := entity.Segment{ID: 43, Name: "AVITO_ADULTS", Rules: []entity.SegmentRule{{Attribute: "age", Operator: ">=", Value: 18}}}
+	active := entity.UserSegmentInformation{InfoID: 1, UserID: 16, SegmentID: 43, Name: "AVITO_ADULTS", StartDate: "15:27:32 01.09.2023"}
+	scheduled := entity.UserSegmentInformation{InfoID: 2, UserID: 16, SegmentID: 43, Name: "AVITO_ADULTS", StartDate: "10:00:00 01.01.2099"}
+
+	testCases := []struct {
+		name            string
+		age             int
+		current         []entity.UserSegmentInformation
+		scheduled       []entity.UserSegmentInformation
+		expectedAdded   []string
+		expectedDeleted []int
+	}{
+		{name: "Matching user without membership", age: 27, expectedAdded: []string{"AVITO_ADULTS"}},
+		{name: "Matching user with active membership", age: 27, current: []entity.UserSegmentInformation{active}},
+		{name: "Matching user with scheduled membership", age: 27, scheduled: []entity.UserSegmentInformation{scheduled}},
+		{name: "Not matching user with active membership", age: 16, current: []entity.UserSegmentInformation{active}, expectedDeleted: []int{1}},
+		{name: "Not matching user with scheduled membership", age: 16, scheduled: []entity.UserSegmentInformation{scheduled}, expectedDeleted: []int{2}},
+		{
+			name:            "Not matching user with active and scheduled memberships",
+			age:             16,
+			current:         []entity.UserSegmentInformation{active},
+			scheduled:       []entity.UserSegmentInformation{scheduled},
+			expectedDeleted: []int{1, 2},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Инициализация зависимостей
+			userRepo := &restoringUserRepository{
+				user:      entity.User{ID: 16, Name: "Михаил", Lastname: "Иванов", Age: tc.age, IsDeleted: true},
+				current:   tc.current,
+				scheduled: tc.scheduled,
+			}
+			us := NewUserService(userRepo, newFakeSegmentRepository(adults), nil, &noExperimentRepository{}, &stubTransactor{}, 0)
+
+			// Выполнение
+			_, err := us.RestoreUser(context.Background(), 16)
+
+			// Проверка результата: запланированное вхождение не дублируется открытым вхождением
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedAdded, userRepo.added)
+			assert.Equal(t, tc.expectedDeleted, userRepo.deleted)
+		})
+	}
+}
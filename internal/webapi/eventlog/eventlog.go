@@ -0,0 +1,33 @@
+package eventlog
+
+import (
+	"avito-rest-api/internal/entity"
+	"context"
+	log "github.com/sirupsen/logrus"
+)
+
+// EventLogWebAPI публикует события в журнал приложения, по одной записи на событие.
+// Используется, пока сервис не подключён к брокеру сообщений.
+type EventLogWebAPI struct {
+	logger *log.Logger
+}
+
+func New(logger *log.Logger) *EventLogWebAPI {
+	return &EventLogWebAPI{logger: logger}
+}
+
+func (w *EventLogWebAPI) PublishMembershipEvents(_ context.Context, events []entity.MembershipEvent) error {
+	for _, event := range events {
+		w.logger.WithFields(log.Fields{
+			"event":          event.Type,
+			"information_id": event.InfoID,
+			"user_id":        event.UserID,
+			"segment_id":     event.SegmentID,
+			"segment_name":   event.SegmentName,
+			"start_date":     event.StartDate,
+			"end_date":       event.EndDate,
+			"occurred_at":    event.OccurredAt,
+		}).Info("membership event")
+	}
+	return nil
+}
@@ -39,15 +39,35 @@ func (w *GDriveWebAPI) IsSet() bool {
 }
 
 func (w *GDriveWebAPI) UploadCSVFile(ctx context.Context, name string, data []byte) (string, error) {
+	url, err := w.uploadFile(ctx, name, "text/csv", data, true)
+	if err != nil {
+		return "", fmt.Errorf("GDriveWebAPI.UploadCSVFile: %w", err)
+	}
+
+	return url, nil
+}
+
+// UploadArchiveFile uploads an archive file without public access, so it can only be read by the drive's owner
+func (w *GDriveWebAPI) UploadArchiveFile(ctx context.Context, name string, mimeType string, data []byte) (string, error) {
+	url, err := w.uploadFile(ctx, name, mimeType, data, false)
+	if err != nil {
+		return "", fmt.Errorf("GDriveWebAPI.UploadArchiveFile: %w", err)
+	}
+
+	return url, nil
+}
+
+// uploadFile creates a file or replaces the content of the file with the same name and returns its URL
+func (w *GDriveWebAPI) uploadFile(ctx context.Context, name string, mimeType string, data []byte, public bool) (string, error) {
 	fileId, err := w.getFileIdByName(ctx, name)
 	if err != nil {
 		if !errors.Is(err, ErrFileNotFound) {
-			return "", fmt.Errorf("GDriveWebAPI.UploadCSVFile: w.getFileIdByName: %w", err)
+			return "", fmt.Errorf("w.getFileIdByName: %w", err)
 		}
 
-		id, err := w.createFile(ctx, name, data)
+		id, err := w.createFile(ctx, name, mimeType, data, public)
 		if err != nil {
-			return "", fmt.Errorf("GDriveWebAPI.UploadCSVFile: w.createFile: %w", err)
+			return "", fmt.Errorf("w.createFile: %w", err)
 		}
 
 		return w.getFileURL(id), nil
@@ -55,17 +75,17 @@ func (w *GDriveWebAPI) UploadCSVFile(ctx context.Context, name string, data []by
 
 	err = w.updateFile(ctx, fileId, data)
 	if err != nil {
-		return "", fmt.Errorf("GDriveWebAPI.UploadCSVFile: w.updateFile: %w", err)
+		return "", fmt.Errorf("w.updateFile: %w", err)
 	}
 
 	return w.getFileURL(fileId), nil
 }
 
-// createFile creates a csv file in Google Drive with public read access and returns its ID and URL
-func (w *GDriveWebAPI) createFile(ctx context.Context, name string, content []byte) (string, error) {
+// createFile creates a file in Google Drive, with public read access if `public` is true, and returns its ID
+func (w *GDriveWebAPI) createFile(ctx context.Context, name string, mimeType string, content []byte, public bool) (string, error) {
 	file := &drive.File{
 		Name:     name,
-		MimeType: "text/csv",
+		MimeType: mimeType,
 	}
 
 	permissions := &drive.Permission{
@@ -82,6 +102,9 @@ func (w *GDriveWebAPI) createFile(ctx context.Context, name string, content []by
 	if err != nil {
 		return "", err
 	}
+	if !public {
+		return fileId, nil
+	}
 
 	_, err = w.driveService.Permissions.Create(fileId, permissions).Context(ctx).Do()
 	if err != nil {
@@ -1,10 +1,18 @@
 package webapi
 
-import "context"
+import (
+	"avito-rest-api/internal/entity"
+	"context"
+)
 
 type GDrive interface {
 	UploadCSVFile(ctx context.Context, name string, data []byte) (string, error)
+	UploadArchiveFile(ctx context.Context, name string, mimeType string, data []byte) (string, error)
 	DeleteFile(ctx context.Context, name string) error
 	GetAllFilenames(ctx context.Context) ([]string, error)
 	IsSet() bool
 }
+
+type EventPublisher interface {
+	PublishMembershipEvents(ctx context.Context, events []entity.MembershipEvent) error
+}
@@ -0,0 +1,83 @@
+package worker
+
+import (
+	"avito-rest-api/internal/service"
+	"context"
+	log "github.com/sirupsen/logrus"
+	"time"
+)
+
+const defaultExpiryInterval = time.Minute
+
+// ExpiryWorker периодически помечает как истёкшие вхождения пользователей в сегменты,
+// дата выхода из которых наступила, и публикует события об этом, а также удаляет сегменты,
+// дата автоматического удаления которых наступила.
+type ExpiryWorker struct {
+	expiryService service.Expiry
+	interval      time.Duration
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewExpiryWorker создаёт и запускает обработчик, вызывающий `expiryService` сразу после запуска
+// и далее с периодом `interval`.
+func NewExpiryWorker(expiryService service.Expiry, interval time.Duration) *ExpiryWorker {
+	if interval <= 0 {
+		interval = defaultExpiryInterval
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &ExpiryWorker{
+		expiryService: expiryService,
+		interval:      interval,
+		cancel:        cancel,
+		done:          make(chan struct{}),
+	}
+
+	go w.run(ctx)
+
+	return w
+}
+
+func (w *ExpiryWorker) run(ctx context.Context) {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		w.expire(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (w *ExpiryWorker) expire(ctx context.Context) {
+	expired, err := w.expiryService.ExpireMemberships(ctx)
+	if expired > 0 {
+		log.Infof("ExpiryWorker - %d memberships expired", expired)
+	}
+	if err != nil && ctx.Err() == nil {
+		log.Errorf("ExpiryWorker - w.expiryService.ExpireMemberships: %s", err)
+	}
+
+	deleted, err := w.expiryService.DeleteExpiredSegments(ctx)
+	if deleted > 0 {
+		log.Infof("ExpiryWorker - %d segments deleted after their active window", deleted)
+	}
+	if err != nil && ctx.Err() == nil {
+		log.Errorf("ExpiryWorker - w.expiryService.DeleteExpiredSegments: %s", err)
+	}
+}
+
+// Shutdown останавливает обработчик и дожидается завершения текущей итерации.
+// Транзакция, выполняемая в момент остановки, откатывается.
+func (w *ExpiryWorker) Shutdown() {
+	w.cancel()
+	<-w.done
+}
@@ -0,0 +1,74 @@
+package worker
+
+import (
+	"avito-rest-api/internal/service"
+	"context"
+	log "github.com/sirupsen/logrus"
+	"time"
+)
+
+const defaultPurgeInterval = time.Hour
+
+// PurgeWorker периодически окончательно удаляет с архивацией сегменты, срок хранения которых
+// после удаления истёк.
+type PurgeWorker struct {
+	purgeService service.SegmentPurge
+	interval     time.Duration
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewPurgeWorker создаёт и запускает обработчик, вызывающий `purgeService` сразу после запуска
+// и далее с периодом `interval`.
+func NewPurgeWorker(purgeService service.SegmentPurge, interval time.Duration) *PurgeWorker {
+	if interval <= 0 {
+		interval = defaultPurgeInterval
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &PurgeWorker{
+		purgeService: purgeService,
+		interval:     interval,
+		cancel:       cancel,
+		done:         make(chan struct{}),
+	}
+
+	go w.run(ctx)
+
+	return w
+}
+
+func (w *PurgeWorker) run(ctx context.Context) {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		w.purge(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (w *PurgeWorker) purge(ctx context.Context) {
+	purged, err := w.purgeService.PurgeDeletedSegments(ctx)
+	if purged > 0 {
+		log.Infof("PurgeWorker - %d deleted segments purged after retention period", purged)
+	}
+	if err != nil && ctx.Err() == nil {
+		log.Errorf("PurgeWorker - w.purgeService.PurgeDeletedSegments: %s", err)
+	}
+}
+
+// Shutdown останавливает обработчик и дожидается завершения текущей итерации.
+// Транзакция, выполняемая в момент остановки, откатывается.
+func (w *PurgeWorker) Shutdown() {
+	w.cancel()
+	<-w.done
+}
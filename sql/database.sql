@@ -5,26 +5,110 @@ create table users (
 	sex int,
 	sex_text text generated always as (case when sex = 0 then 'мужской' else 'женский' end) stored,
 	age int not null,
-	is_deleted bool not null default false
+	is_deleted bool not null default false,
+	attributes jsonb not null default '{}'::jsonb,
+	external_id text,
+	unique (external_id)
+);
+
+create index users_name_idx on users (name, user_id);
+create index users_lastname_idx on users (lastname, user_id);
+create index users_age_idx on users (age, user_id);
+create index users_attributes_idx on users using gin (attributes jsonb_path_ops);
+
+create table segment_groups (
+	group_id serial primary key,
+	name text not null,
+	unique (name)
 );
 
 create table segments (
 	segment_id serial primary key,
 	name text not null,
 	is_deleted bool not null default false,
-	unique (name)
+	rules jsonb,
+	percentage int check (percentage between 0 and 100),
+	salt text not null default md5(random()::text),
+	group_id int,
+	default_ttl bigint check (default_ttl > 0),
+	active_until timestamp,
+	description text not null default '',
+	owner text not null default '',
+	tags text[] not null default '{}',
+	created_at timestamp not null default current_timestamp,
+	updated_at timestamp not null default current_timestamp,
+	deleted_at timestamp,
+	derived_operation text check (derived_operation in ('union', 'intersection', 'difference')),
+	derived_from int[],
+	check ((derived_operation is null) = (derived_from is null)),
+	unique (name),
+	foreign key (group_id) references segment_groups (group_id) on delete no action
 );
 
+create table segment_names_history (
+	history_id serial primary key,
+	segment_id int not null,
+	old_name text not null,
+	new_name text not null,
+	renamed_at timestamp not null default current_timestamp,
+	foreign key (segment_id) references segments (segment_id) on delete no action
+);
+
+create index segment_names_history_old_name_idx on segment_names_history (old_name, renamed_at);
+create index segment_names_history_segment_id_idx on segment_names_history (segment_id, history_id);
+
+create index segments_derived_idx on segments (segment_id) where is_deleted = false and derived_operation is not null;
+
+create index segments_owner_idx on segments (owner);
+create index segments_tags_idx on segments using gin (tags);
+create index segments_active_until_idx on segments (active_until) where is_deleted = false and active_until is not null;
+create index segments_deleted_at_idx on segments (deleted_at) where is_deleted = true;
+
 create table users_segments (
 	user_segment_id serial primary key,
 	user_id int not null,
 	segment_id int not null,
 	start_date timestamp not null default current_timestamp,
 	end_date timestamp,
+	end_reason text check (end_reason in ('expired', 'removed')),
 	foreign key (user_id) references users (user_id) on delete no action,
 	foreign key (segment_id) references segments(segment_id) on delete no action
 );
 
+create index users_segments_segment_id_idx on users_segments (segment_id, user_segment_id);
+create index users_segments_expiry_idx on users_segments (end_date) where end_reason is null and end_date is not null;
+
+create table users_segments_history (
+	history_id serial primary key,
+	user_segment_id int not null,
+	action text not null,
+	old_end_date timestamp,
+	new_end_date timestamp,
+	changed_at timestamp not null default current_timestamp,
+	foreign key (user_segment_id) references users_segments (user_segment_id) on delete no action
+);
+
+create index users_segments_history_user_segment_id_idx on users_segments_history (user_segment_id, history_id);
+
+create table experiments (
+	experiment_id serial primary key,
+	name text not null,
+	salt text not null default md5(random()::text),
+	unique (name)
+);
+
+create table experiment_variants (
+	variant_id serial primary key,
+	experiment_id int not null,
+	segment_id int not null,
+	name text not null,
+	weight int not null check (weight > 0),
+	unique (experiment_id, name),
+	unique (segment_id),
+	foreign key (experiment_id) references experiments (experiment_id) on delete no action,
+	foreign key (segment_id) references segments (segment_id) on delete no action
+);
+
 insert into users (name, lastname, sex, age)
 values
     ('Иван', 'Иванов', '0', 32),